@@ -0,0 +1,417 @@
+package main
+
+import (
+	"coding2fun.in/url-shortner/internal/domain"
+	"coding2fun.in/url-shortner/internal/service"
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type accountView struct {
+	ID             uint      `json:"id"`
+	Email          string    `json:"email"`
+	IsActive       bool      `json:"isActive"`
+	OrganizationId uint      `json:"organizationId"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+type keyView struct {
+	ID     uint          `json:"id"`
+	Name   string        `json:"name"`
+	Scopes domain.Scopes `json:"scopes"`
+	// Key is only set when the key is issued or rotated
+	Key            string     `json:"key,omitempty"`
+	AllowedDomains []string   `json:"allowedDomains"`
+	AllowedCIDRs   []string   `json:"allowedCidrs"`
+	IsActive       bool       `json:"isActive"`
+	ExpiresAt      *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt     *time.Time `json:"lastUsedAt,omitempty"`
+	RotatedToId    uint       `json:"rotatedToId,omitempty"`
+}
+
+type linkView struct {
+	ID             uint       `json:"id"`
+	ShortCode      string     `json:"shortCode"`
+	DomainId       uint       `json:"domainId"`
+	OrganizationId uint       `json:"organizationId"`
+	AccountId      uint       `json:"accountId"`
+	OriginalURL    string     `json:"originalUrl"`
+	IsActive       bool       `json:"isActive"`
+	DisabledReason string     `json:"disabledReason,omitempty"`
+	Clicks         int64      `json:"clicks"`
+	ExpiresAt      *time.Time `json:"expiresAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+type statsView struct {
+	Accounts        int64 `json:"accounts"`
+	ActiveAccounts  int64 `json:"activeAccounts"`
+	APIKeys         int64 `json:"apiKeys"`
+	ActiveAPIKeys   int64 `json:"activeApiKeys"`
+	Organizations   int64 `json:"organizations"`
+	Links           int64 `json:"links"`
+	ActiveLinks     int64 `json:"activeLinks"`
+	Domains         int64 `json:"domains"`
+	VerifiedDomains int64 `json:"verifiedDomains"`
+	Clicks          int64 `json:"clicks"`
+}
+
+func runMigrate(_ context.Context, a *app, args []string) error {
+	if err := parse(flag.NewFlagSet("migrate", flag.ContinueOnError), args); err != nil {
+		return err
+	}
+	if err := a.db.Migrate(); err != nil {
+		return err
+	}
+	return a.out.print(map[string]string{"status": "migrated"}, []string{"STATUS"}, [][]string{{"migrated"}})
+}
+
+func runAccountCreate(ctx context.Context, a *app, args []string) error {
+	flags := flag.NewFlagSet("accounts create", flag.ContinueOnError)
+	email := flags.String("email", "", "email address of the account")
+	if err := parse(flags, args, "email"); err != nil {
+		return err
+	}
+
+	account, err := a.accounts.Create(ctx, *email)
+	if err != nil {
+		return err
+	}
+	return a.printAccount(account)
+}
+
+func runAccountActivate(ctx context.Context, a *app, args []string) error {
+	flags := flag.NewFlagSet("accounts activate", flag.ContinueOnError)
+	id := flags.Uint("id", 0, "account id")
+	if err := parse(flags, args, "id"); err != nil {
+		return err
+	}
+
+	if err := a.accounts.Activate(ctx, *id); err != nil {
+		return err
+	}
+	return a.out.print(map[string]any{"id": *id, "isActive": true}, []string{"ID", "ACTIVE"}, [][]string{{strconv.FormatUint(uint64(*id), 10), "true"}})
+}
+
+func runKeyIssue(ctx context.Context, a *app, args []string) error {
+	flags := flag.NewFlagSet("keys issue", flag.ContinueOnError)
+	account := flags.Uint("account", 0, "account id")
+	name := flags.String("name", "", "name of the key")
+	scopes := flags.String("scopes", "", "comma separated scopes: links:read, links:write, analytics:read, admin")
+	domains := flags.String("domains", "", "comma separated hosts the key is limited to")
+	cidrs := flags.String("cidrs", "", "comma separated client addresses or ranges the key is limited to")
+	expires := flags.String("expires", "", "lifetime like 720h or an RFC 3339 time")
+	if err := parse(flags, args, "account", "scopes"); err != nil {
+		return err
+	}
+
+	in := service.APIKeyInput{
+		Name:           *name,
+		Scopes:         splitList(*scopes),
+		AllowedDomains: splitList(*domains),
+		AllowedCIDRs:   splitList(*cidrs),
+	}
+	if *expires != "" {
+		at, err := parseExpiry(*expires)
+		if err != nil {
+			return err
+		}
+		in.ExpiresAt = &at
+	}
+
+	key, plain, err := a.accounts.CreateAPIKey(ctx, *account, in)
+	if err != nil {
+		return err
+	}
+	return a.printKeys([]domain.APIKey{*key}, plain)
+}
+
+func runKeyList(ctx context.Context, a *app, args []string) error {
+	flags := flag.NewFlagSet("keys list", flag.ContinueOnError)
+	account := flags.Uint("account", 0, "account id")
+	if err := parse(flags, args, "account"); err != nil {
+		return err
+	}
+
+	keys, err := a.accounts.APIKeys(ctx, *account)
+	if err != nil {
+		return err
+	}
+	return a.printKeys(keys, "")
+}
+
+func runKeyRotate(ctx context.Context, a *app, args []string) error {
+	flags := flag.NewFlagSet("keys rotate", flag.ContinueOnError)
+	account := flags.Uint("account", 0, "account id")
+	id := flags.Uint("id", 0, "key id")
+	overlap := flags.Duration("overlap", 0, "how long the old key keeps working, the configured window by default")
+	if err := parse(flags, args, "account", "id"); err != nil {
+		return err
+	}
+
+	var window *time.Duration
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == "overlap" {
+			window = overlap
+		}
+	})
+
+	key, plain, err := a.accounts.RotateAPIKey(ctx, *account, *id, window)
+	if err != nil {
+		return err
+	}
+	return a.printKeys([]domain.APIKey{*key}, plain)
+}
+
+func runKeyRevoke(ctx context.Context, a *app, args []string) error {
+	flags := flag.NewFlagSet("keys revoke", flag.ContinueOnError)
+	account := flags.Uint("account", 0, "account id")
+	id := flags.Uint("id", 0, "key id")
+	all := flags.Bool("all", false, "revoke every key of the account")
+	if err := parse(flags, args, "account"); err != nil {
+		return err
+	}
+	if (*id == 0) == !*all {
+		return fmt.Errorf("pass either -id or -all")
+	}
+
+	revoked := int64(1)
+	if *all {
+		var err error
+		if revoked, err = a.accounts.RevokeAPIKeys(ctx, *account); err != nil {
+			return err
+		}
+	} else if err := a.accounts.DeactivateAPIKey(ctx, *account, *id); err != nil {
+		return err
+	}
+	return a.out.print(map[string]int64{"revoked": revoked}, []string{"REVOKED"}, [][]string{{strconv.FormatInt(revoked, 10)}})
+}
+
+func runLinkGet(ctx context.Context, a *app, args []string) error {
+	flags := flag.NewFlagSet("links get", flag.ContinueOnError)
+	code := flags.String("code", "", "short code or custom slug")
+	host := flags.String("domain", "", "custom domain serving the link, the shared domain by default")
+	if err := parse(flags, args, "code"); err != nil {
+		return err
+	}
+
+	link, err := a.admin.Link(ctx, *host, *code)
+	if err != nil {
+		return err
+	}
+	return a.printLink(link)
+}
+
+func runLinkDeactivate(ctx context.Context, a *app, args []string) error {
+	flags := flag.NewFlagSet("links deactivate", flag.ContinueOnError)
+	code := flags.String("code", "", "short code or custom slug")
+	host := flags.String("domain", "", "custom domain serving the link, the shared domain by default")
+	reason := flags.String("reason", "", "reason recorded on the link")
+	if err := parse(flags, args, "code"); err != nil {
+		return err
+	}
+
+	link, err := a.admin.DeactivateLink(ctx, *host, *code, *reason)
+	if err != nil {
+		return err
+	}
+	return a.printLink(link)
+}
+
+func runLinkReassign(ctx context.Context, a *app, args []string) error {
+	flags := flag.NewFlagSet("links reassign", flag.ContinueOnError)
+	code := flags.String("code", "", "short code or custom slug")
+	host := flags.String("domain", "", "custom domain serving the link, the shared domain by default")
+	org := flags.Uint("org", 0, "organization receiving the link")
+	account := flags.Uint("account", 0, "member of the organization recorded as the creator")
+	if err := parse(flags, args, "code", "org", "account"); err != nil {
+		return err
+	}
+
+	link, err := a.admin.ReassignLink(ctx, *host, *code, *org, *account)
+	if err != nil {
+		return err
+	}
+	return a.printLink(link)
+}
+
+func runStats(ctx context.Context, a *app, args []string) error {
+	if err := parse(flag.NewFlagSet("stats", flag.ContinueOnError), args); err != nil {
+		return err
+	}
+
+	stats, err := a.admin.Stats(ctx)
+	if err != nil {
+		return err
+	}
+	view := statsView(*stats)
+	rows := [][]string{
+		{"accounts", fmt.Sprintf("%d (%d active)", view.Accounts, view.ActiveAccounts)},
+		{"api keys", fmt.Sprintf("%d (%d active)", view.APIKeys, view.ActiveAPIKeys)},
+		{"organizations", strconv.FormatInt(view.Organizations, 10)},
+		{"links", fmt.Sprintf("%d (%d active)", view.Links, view.ActiveLinks)},
+		{"domains", fmt.Sprintf("%d (%d verified)", view.Domains, view.VerifiedDomains)},
+		{"clicks", strconv.FormatInt(view.Clicks, 10)},
+	}
+	return a.out.print(view, []string{"METRIC", "VALUE"}, rows)
+}
+
+func runAuditVerify(ctx context.Context, a *app, args []string) error {
+	if err := parse(flag.NewFlagSet("audit verify", flag.ContinueOnError), args); err != nil {
+		return err
+	}
+
+	checked, err := a.audit.Verify(ctx)
+	if err != nil {
+		return fmt.Errorf("audit chain broken after %d events: %w", checked, err)
+	}
+	return a.out.print(map[string]any{"checked": checked, "intact": true}, []string{"CHECKED", "INTACT"}, [][]string{{strconv.Itoa(checked), "true"}})
+}
+
+// runEventsRetry hands failed events to the subscribers that have not handled them yet
+func runEventsRetry(ctx context.Context, a *app, args []string) error {
+	flags := flag.NewFlagSet("events retry", flag.ContinueOnError)
+	id := flags.Uint("id", 0, "outbox event id")
+	all := flags.Bool("all", false, "retry every failed event")
+	if err := parse(flags, args); err != nil {
+		return err
+	}
+	if (*id == 0) == !*all {
+		return fmt.Errorf("pass either -id or -all")
+	}
+
+	var ids []uint
+	if !*all {
+		ids = append(ids, *id)
+	}
+	retried, err := a.outbox.Retry(ctx, time.Now(), ids...)
+	if err != nil {
+		return err
+	}
+	return a.out.print(map[string]int64{"retried": retried}, []string{"RETRIED"}, [][]string{{strconv.FormatInt(retried, 10)}})
+}
+
+func (a *app) printAccount(account *domain.Account) error {
+	view := accountView{
+		ID:             account.ID,
+		Email:          account.Email,
+		IsActive:       account.IsActive,
+		OrganizationId: account.OrganizationId,
+		CreatedAt:      account.CreatedAt,
+	}
+	row := []string{
+		strconv.FormatUint(uint64(view.ID), 10),
+		view.Email,
+		strconv.FormatBool(view.IsActive),
+		strconv.FormatUint(uint64(view.OrganizationId), 10),
+	}
+	return a.out.print(view, []string{"ID", "EMAIL", "ACTIVE", "ORGANIZATION"}, [][]string{row})
+}
+
+// printKeys lists keys, plain is the value of a single issued or rotated key
+func (a *app) printKeys(keys []domain.APIKey, plain string) error {
+	views := make([]keyView, 0, len(keys))
+	rows := make([][]string, 0, len(keys))
+	for _, key := range keys {
+		view := keyView{
+			ID:             key.ID,
+			Name:           key.Name,
+			Scopes:         key.Scopes,
+			Key:            plain,
+			AllowedDomains: key.AllowedDomains,
+			AllowedCIDRs:   key.AllowedCIDRs,
+			IsActive:       key.IsActive,
+			ExpiresAt:      optionalTime(key.ExpiresAt),
+			LastUsedAt:     optionalTime(key.LastUsed),
+			RotatedToId:    key.RotatedToId,
+		}
+		if view.AllowedDomains == nil {
+			view.AllowedDomains = []string{}
+		}
+		if view.AllowedCIDRs == nil {
+			view.AllowedCIDRs = []string{}
+		}
+		views = append(views, view)
+
+		scopes := make([]string, 0, len(key.Scopes))
+		for _, scope := range key.Scopes {
+			scopes = append(scopes, string(scope))
+		}
+		row := []string{
+			strconv.FormatUint(uint64(view.ID), 10),
+			view.Name,
+			formatList(scopes),
+			formatList(view.AllowedDomains),
+			formatList(view.AllowedCIDRs),
+			strconv.FormatBool(view.IsActive),
+			formatTime(view.ExpiresAt),
+			formatTime(view.LastUsedAt),
+		}
+		if plain != "" {
+			row = append(row, plain)
+		}
+		rows = append(rows, row)
+	}
+
+	header := []string{"ID", "NAME", "SCOPES", "DOMAINS", "CIDRS", "ACTIVE", "EXPIRES", "LAST USED"}
+	if plain != "" {
+		header = append(header, "KEY")
+		return a.out.print(views[0], header, rows)
+	}
+	return a.out.print(views, header, rows)
+}
+
+func (a *app) printLink(link *domain.ShortUrl) error {
+	view := linkView{
+		ID:             link.ID,
+		ShortCode:      link.ShortCode,
+		DomainId:       link.DomainId,
+		OrganizationId: link.OrganizationId,
+		AccountId:      link.AccountId,
+		OriginalURL:    link.OriginalURL,
+		IsActive:       link.IsActive,
+		DisabledReason: link.DisabledReason,
+		Clicks:         link.Clicks,
+		ExpiresAt:      optionalTime(link.ExpiresAt),
+		CreatedAt:      link.CreatedAt,
+	}
+	row := []string{
+		strconv.FormatUint(uint64(view.ID), 10),
+		view.ShortCode,
+		strconv.FormatUint(uint64(view.DomainId), 10),
+		strconv.FormatUint(uint64(view.OrganizationId), 10),
+		strconv.FormatUint(uint64(view.AccountId), 10),
+		view.OriginalURL,
+		strconv.FormatBool(view.IsActive),
+		strconv.FormatInt(view.Clicks, 10),
+		formatTime(view.ExpiresAt),
+	}
+	header := []string{"ID", "CODE", "DOMAIN", "ORGANIZATION", "ACCOUNT", "URL", "ACTIVE", "CLICKS", "EXPIRES"}
+	return a.out.print(view, header, [][]string{row})
+}
+
+// parseExpiry accepts a lifetime from now or an absolute RFC 3339 time
+func parseExpiry(value string) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(d), nil
+	}
+	at, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("-expires must be a duration like 720h or an RFC 3339 time")
+	}
+	return at, nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,204 @@
+// Command admin operates the url-shortner from a shell: accounts, API keys,
+// links, data export and import, migrations, failed events and service stats. It reads the
+// same config file as the API and works against any configured database driver.
+package main
+
+import (
+	"coding2fun.in/url-shortner/database"
+	"coding2fun.in/url-shortner/internal/audit"
+	"coding2fun.in/url-shortner/internal/config"
+	"coding2fun.in/url-shortner/internal/domain"
+	"coding2fun.in/url-shortner/internal/log"
+	"coding2fun.in/url-shortner/internal/repository"
+	"coding2fun.in/url-shortner/internal/service"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// errUsage marks a command line that could not be parsed, its usage was printed already
+var errUsage = errors.New("usage")
+
+// app carries the collaborators shared by the commands
+type app struct {
+	cfg      *config.Config
+	db       database.Service
+	auth     *service.AuthService
+	accounts *service.AccountService
+	admin    *service.AdminService
+	audit    *audit.Logger
+	outbox   domain.OutboxRepository
+	out      printer
+}
+
+type command struct {
+	usage string
+	run   func(ctx context.Context, a *app, args []string) error
+}
+
+var commands = map[string]command{
+	"migrate":           {"migrate", runMigrate},
+	"accounts create":   {"accounts create -email address", runAccountCreate},
+	"accounts activate": {"accounts activate -id account", runAccountActivate},
+	"keys issue":        {"keys issue -account id -scopes links:read,... [-name n] [-domains hosts] [-cidrs ranges] [-expires 720h|RFC3339]", runKeyIssue},
+	"keys list":         {"keys list -account id", runKeyList},
+	"keys rotate":       {"keys rotate -account id -id key [-overlap 24h]", runKeyRotate},
+	"keys revoke":       {"keys revoke -account id (-id key | -all)", runKeyRevoke},
+	"links get":         {"links get -code code [-domain host]", runLinkGet},
+	"links deactivate":  {"links deactivate -code code [-domain host] [-reason text]", runLinkDeactivate},
+	"links reassign":    {"links reassign -code code [-domain host] -org id -account id", runLinkReassign},
+	"export":            {"export -account id [-org id] [-format jsonl|csv] [-out file] [-password-hashes]", runExport},
+	"import":            {"import -account id [-org id] -file path [-format jsonl|csv|bitly] [-conflict skip|rename|fail] [-domain host]", runImport},
+	"stats":             {"stats", runStats},
+	"audit verify":      {"audit verify", runAuditVerify},
+	"events retry":      {"events retry (-id event | -all)", runEventsRetry},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout))
+}
+
+// run executes the command line against the configured database and returns
+// the exit code, results are written to stdout and problems to stderr
+func run(args []string, stdout io.Writer) int {
+	flags := flag.NewFlagSet("admin", flag.ContinueOnError)
+	configPath := flags.String("config", "resources/local.ini", "config file")
+	format := flags.String("o", "table", "output format, table or json")
+	verbose := flags.Bool("v", false, "log service messages and queries")
+	flags.Usage = func() { usage(flags) }
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *format != "table" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "unknown output format %q\n", *format)
+		return 2
+	}
+
+	name, rest, ok := lookup(flags.Args())
+	if !ok {
+		usage(flags)
+		return 2
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	level := "error"
+	if *verbose {
+		level = "info"
+	}
+	log.InitLogger(level, cfg.Server.Mode)
+	defer log.Sync()
+	cfg.Database.LogQueries = *verbose
+
+	db, err := database.NewService(&cfg.Database)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.Close()
+
+	a := newApp(cfg, db, printer{w: stdout, json: *format == "json"})
+	return a.exec(name, rest)
+}
+
+func newApp(cfg *config.Config, db database.Service, out printer) *app {
+	auditLog := audit.NewLogger(repository.NewAuditRepository(db))
+	accounts := repository.NewAccountRepository(db)
+	return &app{
+		cfg:      cfg,
+		db:       db,
+		auth:     service.NewAuthService(accounts, repository.NewOrganizationRepository(db)),
+		accounts: service.NewAccountService(accounts, auditLog, cfg.APIKeys),
+		admin: service.NewAdminService(
+			repository.NewShortURLRepository(db),
+			repository.NewDomainRepository(db),
+			repository.NewOrganizationRepository(db),
+			repository.NewStatsRepository(db),
+			auditLog,
+			cfg.Links,
+		),
+		audit:  auditLog,
+		outbox: repository.NewOutboxRepository(db),
+		out:    out,
+	}
+}
+
+// exec runs the named command as the admin actor and returns the exit code
+func (a *app) exec(name string, args []string) int {
+	ctx := audit.WithActor(context.Background(), audit.Actor{Kind: domain.ActorAdmin})
+	if err := commands[name].run(ctx, a, args); err != nil {
+		if errors.Is(err, errUsage) {
+			return 2
+		}
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return 1
+	}
+	return 0
+}
+
+// lookup finds the command named by the first one or two arguments
+func lookup(args []string) (string, []string, bool) {
+	if len(args) >= 2 {
+		if _, ok := commands[args[0]+" "+args[1]]; ok {
+			return args[0] + " " + args[1], args[2:], true
+		}
+	}
+	if len(args) >= 1 {
+		if _, ok := commands[args[0]]; ok {
+			return args[0], args[1:], true
+		}
+	}
+	return "", nil, false
+}
+
+func usage(flags *flag.FlagSet) {
+	fmt.Fprintln(os.Stderr, "usage: admin [-config file] [-o table|json] [-v] command [flags]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintln(os.Stderr, "  "+commands[name].usage)
+	}
+	fmt.Fprintln(os.Stderr, "\nflags:")
+	flags.PrintDefaults()
+}
+
+// parse parses the flags of a command, missing required flags fail with its usage
+func parse(flags *flag.FlagSet, args []string, required ...string) error {
+	flags.SetOutput(os.Stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: admin %s [flags]\n", flags.Name())
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	set := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	var missing []string
+	for _, name := range required {
+		if !set[name] {
+			missing = append(missing, "-"+name)
+		}
+	}
+	if len(missing) > 0 || flags.NArg() > 0 {
+		if len(missing) > 0 {
+			fmt.Fprintf(os.Stderr, "missing %s\n", strings.Join(missing, ", "))
+		}
+		flags.Usage()
+		return errUsage
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"coding2fun.in/url-shortner/database"
+	"coding2fun.in/url-shortner/internal/config"
+	"coding2fun.in/url-shortner/internal/domain"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfig writes a config file keeping the database in a sqlite file of the test
+func writeConfig(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "admin.ini")
+	ini := "[server]\nmode = test\n[database]\ndriver = sqlite\npath = " + filepath.Join(dir, "admin.db") + "\n"
+	if err := os.WriteFile(path, []byte(ini), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunCommandLines(t *testing.T) {
+	ini := writeConfig(t)
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"unknown output format", []string{"-config", ini, "-o", "yaml", "stats"}, 2},
+		{"unknown flag", []string{"-config", ini, "-q", "stats"}, 2},
+		{"unknown command", []string{"-config", ini, "accounts", "delete"}, 2},
+		{"no command", []string{"-config", ini}, 2},
+		{"missing config", []string{"-config", filepath.Join(t.TempDir(), "none.ini"), "stats"}, 1},
+		{"missing required flag", []string{"-config", ini, "keys", "issue", "-account", "1"}, 2},
+		{"stray argument", []string{"-config", ini, "accounts", "create", "-email", "a@example.com", "b@example.com"}, 2},
+		{"conflicting flags", []string{"-config", ini, "keys", "revoke", "-account", "1", "-id", "2", "-all"}, 1},
+		{"migrate", []string{"-config", ini, "migrate"}, 0},
+		{"migrate again", []string{"-config", ini, "-o", "json", "migrate"}, 0},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if got := run(tt.args, &out); got != tt.want {
+			t.Errorf("%s exited with %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRunOutputFormats(t *testing.T) {
+	ini := writeConfig(t)
+	var out bytes.Buffer
+	if code := run([]string{"-config", ini, "migrate"}, &out); code != 0 {
+		t.Fatalf("migrate exited with %d", code)
+	}
+
+	out.Reset()
+	if code := run([]string{"-config", ini, "stats"}, &out); code != 0 {
+		t.Fatalf("stats exited with %d", code)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 7 || !strings.HasPrefix(lines[0], "METRIC") || !strings.HasPrefix(lines[1], "accounts       0 (0 active)") {
+		t.Errorf("stats table:\n%s", out.String())
+	}
+
+	out.Reset()
+	if code := run([]string{"-config", ini, "-o", "json", "stats"}, &out); code != 0 {
+		t.Fatalf("stats exited with %d", code)
+	}
+	var stats statsView
+	if err := json.Unmarshal(out.Bytes(), &stats); err != nil {
+		t.Fatalf("stats json %q: %v", out.String(), err)
+	}
+	if stats != (statsView{}) {
+		t.Errorf("stats of an empty database = %+v", stats)
+	}
+}
+
+// newMemoryApp runs the commands against an in-memory database, exec fails
+// the test when a command does
+func newMemoryApp(t *testing.T) (a *app, db database.Service, out *bytes.Buffer, exec func(name string, args ...string)) {
+	t.Helper()
+	cfg, err := config.Load(writeConfig(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An in-memory database lives as long as its connection, it only holds
+	// together because sqlite is limited to a single one whatever the pool says
+	cfg.Database = config.DatabaseConfig{Driver: database.DriverSQLite, Path: ":memory:", MaxOpenConns: 8, MaxIdleConns: 8}
+	db, err = database.NewService(&cfg.Database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	out = &bytes.Buffer{}
+	a = newApp(cfg, db, printer{w: out})
+	exec = func(name string, args ...string) {
+		t.Helper()
+		out.Reset()
+		if code := a.exec(name, args); code != 0 {
+			t.Fatalf("%s exited with %d", name, code)
+		}
+	}
+	exec("migrate")
+	return a, db, out, exec
+}
+
+func TestMigrateAndVerifyAudit(t *testing.T) {
+	a, db, out, exec := newMemoryApp(t)
+
+	exec("accounts create", "-email", "ops@example.com")
+	if !strings.Contains(out.String(), "ops@example.com") {
+		t.Errorf("accounts create printed %q", out.String())
+	}
+	exec("accounts activate", "-id", "1")
+
+	a.out.json = true
+	exec("keys issue", "-account", "1", "-scopes", "links:read", "-cidrs", "10.0.0.1")
+	var key keyView
+	if err := json.Unmarshal(out.Bytes(), &key); err != nil {
+		t.Fatalf("keys issue printed %q: %v", out.String(), err)
+	}
+	if key.Key == "" || strings.Join(key.AllowedCIDRs, ",") != "10.0.0.1/32" {
+		t.Errorf("issued key %+v", key)
+	}
+
+	var verified struct {
+		Checked int  `json:"checked"`
+		Intact  bool `json:"intact"`
+	}
+	exec("audit verify")
+	if err := json.Unmarshal(out.Bytes(), &verified); err != nil || verified.Checked == 0 || !verified.Intact {
+		t.Fatalf("audit verify printed %q: %v", out.String(), err)
+	}
+
+	// The triggers keep the audit log append only
+	conn := db.GetConnection()
+	if err := conn.Exec("UPDATE audit_events SET action = 'tampered'").Error; err == nil || !strings.Contains(err.Error(), "append only") {
+		t.Errorf("updating the audit log returned %v", err)
+	}
+	if err := conn.Exec("DELETE FROM audit_events").Error; err == nil || !strings.Contains(err.Error(), "append only") {
+		t.Errorf("deleting from the audit log returned %v", err)
+	}
+
+	exec("migrate")
+	exec("audit verify")
+	checked := verified.Checked
+	if err := json.Unmarshal(out.Bytes(), &verified); err != nil || verified.Checked != checked || !verified.Intact {
+		t.Errorf("audit verify after migrating again printed %q: %v", out.String(), err)
+	}
+}
+
+func TestActivateAndReassignRecordEvents(t *testing.T) {
+	_, db, _, exec := newMemoryApp(t)
+	// Every account comes with an organization of its own
+	exec("accounts create", "-email", "ops@example.com")
+	exec("accounts create", "-email", "dev@example.com")
+	exec("accounts activate", "-id", "2")
+
+	conn := db.GetConnection()
+	link := domain.ShortUrl{AccountId: 1, OrganizationId: 1, OriginalURL: "https://example.com/", ShortCode: "moving", IsActive: true}
+	if err := conn.Create(&link).Error; err != nil {
+		t.Fatal(err)
+	}
+	exec("links reassign", "-code", "moving", "-org", "2", "-account", "2")
+
+	var recorded []domain.OutboxEvent
+	if err := conn.Order("id").Find(&recorded).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(recorded) != 2 {
+		t.Fatalf("recorded %d events, want the activation and the reassignment", len(recorded))
+	}
+	activated, err := domain.DecodeEvent(recorded[0].Type, []byte(recorded[0].Payload))
+	if err != nil || activated != (domain.AccountActivated{AccountId: 2}) {
+		t.Errorf("recorded %v: %v, want account 2 activated", activated, err)
+	}
+	event, err := domain.DecodeEvent(recorded[1].Type, []byte(recorded[1].Payload))
+	reassigned, ok := event.(domain.LinkReassigned)
+	if err != nil || !ok || reassigned.Link.ShortCode != "moving" || reassigned.Link.OrganizationId != 2 || reassigned.FromOrganizationId != 1 || reassigned.AccountId != 2 {
+		t.Errorf("recorded %+v: %v, want the link moved from organization 1 to 2", event, err)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"coding2fun.in/url-shortner/internal/archive"
+	"coding2fun.in/url-shortner/internal/repository"
+	"coding2fun.in/url-shortner/internal/safeurl"
+	"coding2fun.in/url-shortner/internal/service"
+	"coding2fun.in/url-shortner/internal/threat"
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+func runExport(ctx context.Context, a *app, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	account := flags.Uint("account", 0, "account id")
+	org := flags.Uint("org", 0, "organization whose links are exported, the personal one by default")
+	format := flags.String("format", string(archive.FormatJSONL), "archive format, jsonl or csv")
+	out := flags.String("out", "", "archive file, standard output by default")
+	hashes := flags.Bool("password-hashes", false, "include the bcrypt hashes of protected links, without them they cannot be imported")
+	if err := parse(flags, args, "account"); err != nil {
+		return err
+	}
+	if *format != string(archive.FormatJSONL) && *format != string(archive.FormatCSV) {
+		return fmt.Errorf("-format must be jsonl or csv")
+	}
+
+	portability, err := a.portability()
+	if err != nil {
+		return err
+	}
+	p, err := a.auth.Operator(ctx, *account, *org)
+	if err != nil {
+		return err
+	}
+	opts := service.ExportOptions{Format: archive.Format(*format), PasswordHashes: *hashes}
+
+	if *out == "" {
+		_, err := portability.Export(ctx, p, a.out.w, opts)
+		return err
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	summary, err := portability.Export(ctx, p, f, opts)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	view := map[string]any{"file": *out, "links": summary.Links, "apiKeys": summary.APIKeys, "versions": summary.Versions}
+	row := []string{*out, strconv.Itoa(summary.Links), strconv.Itoa(summary.APIKeys), strconv.Itoa(summary.Versions)}
+	return a.out.print(view, []string{"FILE", "LINKS", "KEYS", "VERSIONS"}, [][]string{row})
+}
+
+func runImport(ctx context.Context, a *app, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	account := flags.Uint("account", 0, "account id recorded as the creator of the links")
+	org := flags.Uint("org", 0, "organization receiving the links, the personal one by default")
+	file := flags.String("file", "", "archive or bit.ly CSV export")
+	format := flags.String("format", "", "jsonl, csv or bitly, detected by default")
+	conflict := flags.String("conflict", string(service.ConflictSkip), "what to do with a taken code: skip, rename or fail")
+	host := flags.String("domain", "", "verified custom domain receiving every link")
+	if err := parse(flags, args, "account", "file"); err != nil {
+		return err
+	}
+
+	archiveFormat, err := archive.ParseFormat(*format)
+	if err != nil {
+		return err
+	}
+	strategy, err := service.ParseConflictStrategy(*conflict)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(*file)
+	if err != nil {
+		return err
+	}
+
+	portability, err := a.portability()
+	if err != nil {
+		return err
+	}
+	p, err := a.auth.Operator(ctx, *account, *org)
+	if err != nil {
+		return err
+	}
+	report, err := portability.Import(ctx, p, data, service.ImportOptions{
+		Format:   archiveFormat,
+		Conflict: strategy,
+		Domain:   *host,
+	})
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0, len(report.Results))
+	for _, r := range report.Results {
+		message := "-"
+		if r.Error != nil {
+			message = r.Error.Message
+			for _, field := range r.Error.Details {
+				message += "; " + field.Field + " " + field.Message
+			}
+		}
+		rows = append(rows, []string{strconv.Itoa(r.Row), r.SourceCode, r.ShortCode, r.Status, message})
+	}
+	if err := a.out.print(report, []string{"ROW", "SOURCE", "CODE", "STATUS", "ERROR"}, rows); err != nil {
+		return err
+	}
+	if report.Aborted {
+		return fmt.Errorf("import aborted, codes are taken and the conflict strategy is fail")
+	}
+	return nil
+}
+
+// portability builds the import and export service, which validates
+// destinations like the API and so needs the threat lists
+func (a *app) portability() (*service.PortabilityService, error) {
+	validator, err := safeurl.NewValidator(a.cfg.Links, net.DefaultResolver)
+	if err != nil {
+		return nil, err
+	}
+	threats, err := threat.NewChecker(a.cfg.Threat)
+	if err != nil {
+		return nil, err
+	}
+
+	domains := service.NewDomainService(repository.NewDomainRepository(a.db), validator, net.DefaultResolver, a.audit, a.cfg.Links, a.cfg.Cache)
+	folders := service.NewFolderService(repository.NewFolderRepository(a.db), a.audit)
+	links := service.NewLinkService(repository.NewShortURLRepository(a.db), domains, folders, validator, threats, a.audit, a.cfg.Links)
+	return service.NewPortabilityService(repository.NewAccountRepository(a.db), repository.NewArchiveRepository(a.db), links, domains, a.audit, a.cfg.Links), nil
+}
@@ -2,10 +2,25 @@ package main
 
 import (
 	"coding2fun.in/url-shortner/database"
+	"coding2fun.in/url-shortner/internal/analytics"
+	"coding2fun.in/url-shortner/internal/audit"
+	"coding2fun.in/url-shortner/internal/cache"
 	"coding2fun.in/url-shortner/internal/config"
+	"coding2fun.in/url-shortner/internal/events"
+	"coding2fun.in/url-shortner/internal/geoip"
+	"coding2fun.in/url-shortner/internal/jobs"
 	"coding2fun.in/url-shortner/internal/log"
+	"coding2fun.in/url-shortner/internal/openapi"
+	"coding2fun.in/url-shortner/internal/qr"
+	"coding2fun.in/url-shortner/internal/repository"
+	"coding2fun.in/url-shortner/internal/safeurl"
 	"coding2fun.in/url-shortner/internal/server"
+	"coding2fun.in/url-shortner/internal/service"
+	"coding2fun.in/url-shortner/internal/threat"
+	"coding2fun.in/url-shortner/internal/webhook"
+	"context"
 	"go.uber.org/zap"
+	"net"
 )
 
 func main() {
@@ -23,13 +38,111 @@ func main() {
 	}
 	defer dbService.Close()
 
-	db := dbService.GetConnection()
 	err = dbService.Migrate()
 	if err != nil {
 		log.Fatal("Failed to run database migrations", zap.Error(err))
 	}
 
-	srv := server.NewServer(cfg, db)
+	accounts := repository.NewAccountRepository(dbService)
+	urls := repository.NewShortURLRepository(dbService)
+	bulkJobs := repository.NewBulkJobRepository(dbService)
+
+	validator, err := safeurl.NewValidator(cfg.Links, net.DefaultResolver)
+	if err != nil {
+		log.Fatal("Failed to create url validator", zap.Error(err))
+	}
+
+	threats, err := threat.NewChecker(cfg.Threat)
+	if err != nil {
+		log.Fatal("Failed to load threat lists", zap.Error(err))
+	}
+
+	geo, err := geoip.NewLocator(cfg.GeoIP)
+	if err != nil {
+		log.Fatal("Failed to load the geoip database", zap.Error(err))
+	}
+	defer geo.Close()
+
+	orgs := repository.NewOrganizationRepository(dbService)
+	auditLog := audit.NewLogger(repository.NewAuditRepository(dbService))
+	domains := service.NewDomainService(repository.NewDomainRepository(dbService), validator, net.DefaultResolver, auditLog, cfg.Links, cfg.Cache)
+	folders := service.NewFolderService(repository.NewFolderRepository(dbService), auditLog)
+	webhooks := service.NewWebhookService(repository.NewWebhookRepository(dbService), urls, domains, validator, webhook.NewSender(cfg.Webhooks.Timeout, cfg.Links.BlockPrivateNetworks), auditLog, cfg.Webhooks)
+	links := service.NewLinkService(urls, domains, folders, validator, threats, auditLog, cfg.Links)
+	bulk := service.NewBulkService(links, bulkJobs, cfg.Links)
+	if err := bulk.Recover(context.Background()); err != nil {
+		log.Fatal("Failed to recover bulk jobs", zap.Error(err))
+	}
+
+	clicks := analytics.NewClickCounter(urls, cfg.Analytics.FlushInterval, cfg.Analytics.MaxPending)
+	clicks.Start()
+
+	linkCache := cache.NewLinkCache(cfg.Cache.LinkTTL, cfg.Cache.LinkMaxEntries)
+
+	bus := events.NewBus()
+	webhooks.Subscribe(bus)
+	linkCache.Subscribe(bus)
+	if cfg.Events.NATSURL != "" || cfg.Events.NATSEmbedded {
+		publisher, err := events.NewPublisher(cfg.Events)
+		if err != nil {
+			log.Fatal("Failed to set up the nats publisher", zap.Error(err))
+		}
+		defer publisher.Close()
+		publisher.Subscribe(bus)
+	}
+	relay := events.NewRelay(repository.NewOutboxRepository(dbService), bus, cfg.Events)
+
+	renderer, err := qr.NewRenderer(cfg.QR)
+	if err != nil {
+		log.Fatal("Failed to set up qr codes", zap.Error(err))
+	}
+
+	scheduler := jobs.NewScheduler()
+	scheduler.Register(jobs.Job{Name: "reload-threat-lists", Interval: cfg.Threat.ReloadInterval, Run: threats.ReloadIfChanged})
+	scheduler.Register(jobs.Job{Name: "reload-geoip-database", Interval: cfg.GeoIP.ReloadInterval, Run: geo.ReloadIfChanged})
+	scheduler.Register(jobs.Job{Name: "relay-events", Interval: cfg.Events.RelayInterval, Run: relay.Run})
+	scheduler.Register(jobs.Job{Name: "deliver-webhooks", Interval: cfg.Webhooks.DeliveryInterval, Run: webhooks.Deliver})
+	if cfg.Jobs.Enabled {
+		retention := jobs.NewRetention(repository.NewRetentionRepository(dbService), linkCache, cfg.Jobs)
+		for _, job := range retention.Jobs() {
+			scheduler.Register(job)
+		}
+		scheduler.Register(jobs.Job{Name: "purge-webhook-deliveries", Interval: cfg.Jobs.PurgeInterval, Run: webhooks.PurgeDeliveries})
+		scheduler.Register(jobs.Job{Name: "purge-events", Interval: cfg.Jobs.PurgeInterval, Run: relay.Purge})
+	}
+	scheduler.Start()
+
+	var contract *openapi.Validator
+	if cfg.Server.ValidateAPI {
+		contract, err = openapi.NewValidator()
+		if err != nil {
+			log.Fatal("Failed to load the openapi spec", zap.Error(err))
+		}
+	}
+
+	srv := server.NewServer(cfg, server.Dependencies{
+		DB:          dbService,
+		URLs:        urls,
+		Clicks:      clicks,
+		Cache:       linkCache,
+		Jobs:        scheduler,
+		Threats:     threats,
+		GeoIP:       geo,
+		QR:          renderer,
+		Audit:       auditLog,
+		Orgs:        service.NewOrganizationService(orgs, auditLog),
+		Auth:        service.NewAuthService(accounts, orgs),
+		Accounts:    service.NewAccountService(accounts, auditLog, cfg.APIKeys),
+		Links:       links,
+		Domains:     domains,
+		Folders:     folders,
+		Webhooks:    webhooks,
+		Bulk:        bulk,
+		Portability: service.NewPortabilityService(accounts, repository.NewArchiveRepository(dbService), links, domains, auditLog, cfg.Links),
+		Contract:    contract,
+		Idempotency: cache.NewIdempotencyStore(cfg.Idempotency.TTL, cfg.Idempotency.MaxEntries),
+		Unlocks:     cache.NewAttemptLimiter(cfg.Unlock.Window, cfg.Unlock.MaxEntries),
+	})
 
 	// Start server with graceful shutdown handling
 	if err := srv.StartWithGracefulShutdown(); err != nil {
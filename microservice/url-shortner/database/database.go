@@ -2,60 +2,480 @@ package database
 
 import (
 	"coding2fun.in/url-shortner/internal/config"
+	"coding2fun.in/url-shortner/internal/domain"
 	"coding2fun.in/url-shortner/internal/log"
+	"database/sql"
+	"errors"
 	"fmt"
+	"github.com/glebarez/sqlite"
 	"go.uber.org/zap"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
-	"os"
+	"sync/atomic"
 	"time"
 )
 
+// Supported database drivers
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
 type Service interface {
+	// GetConnection returns the primary, all writes go through it
 	GetConnection() *gorm.DB
+	// Reader returns a healthy replica for reads of key, or the primary when
+	// there is none or the key was written within the read-your-writes window
+	Reader(key string) *gorm.DB
+	// MarkWritten pins subsequent reads of key to the primary for the read-your-writes window
+	MarkWritten(key string)
+	ReplicaHealth() []ReplicaHealth
 	Migrate() error
 	Close() error
 }
 
 type service struct {
-	db *gorm.DB
+	cfg      *config.DatabaseConfig
+	db       *gorm.DB
+	replicas []*replica
+	next     atomic.Uint64
+	writes   *writeTracker
+	stop     chan struct{}
+	done     chan struct{}
 }
 
 func NewService(cfg *config.DatabaseConfig) (Service, error) {
 	log.Info("Connecting to database", zap.String("host", cfg.Host), zap.String("dbName", cfg.Name))
-	db, err := gorm.Open(postgres.Open(cfg.ConnectionURL()), &gorm.Config{Logger: logger.Default.LogMode(logger.Info)})
+
+	policy := retryPolicy{
+		deadline:     cfg.ConnectDeadline,
+		initialDelay: cfg.RetryInitialDelay,
+		maxDelay:     cfg.RetryMaxDelay,
+	}
+
+	dialector, err := open(cfg)
 	if err != nil {
-		log.Error("Failed to connect to database", zap.Error(err))
-		os.Exit(1)
+		return nil, err
+	}
+
+	var db *gorm.DB
+	err = policy.do("database connect", func() error {
+		var err error
+		db, err = gorm.Open(dialector, &gorm.Config{
+			Logger: queryLogger(cfg),
+			// SQLite constraint errors are only matched by TranslateError once gorm translated them
+			TranslateError: cfg.Driver == DriverSQLite,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
 	sqlDB, err := db.DB()
 	if err != nil {
-		log.Error("Failed to get database instance", zap.Error(err))
-		os.Exit(1)
+		return nil, fmt.Errorf("failed to get database instance: %w", err)
+	}
+
+	configurePool(sqlDB, cfg)
+	if cfg.Driver == DriverSQLite {
+		// SQLite has a single writer, one connection avoids lock errors
+		sqlDB.SetMaxOpenConns(1)
+	}
+
+	s := &service{
+		cfg:    cfg,
+		db:     db,
+		writes: newWriteTracker(cfg.ReadYourWritesWindow),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	if err := s.openReplicas(); err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+
+	go s.monitor()
+
+	return s, nil
+}
+
+// open returns the dialector of the configured driver
+func open(cfg *config.DatabaseConfig) (gorm.Dialector, error) {
+	switch cfg.Driver {
+	case DriverPostgres:
+		return postgres.Open(cfg.ConnectionURL()), nil
+	case DriverSQLite:
+		if len(cfg.Replicas) > 0 {
+			return nil, errors.New("read replicas need the postgres driver")
+		}
+		return sqlite.Open(cfg.SQLiteURL()), nil
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", cfg.Driver)
+	}
+}
+
+func queryLogger(cfg *config.DatabaseConfig) logger.Interface {
+	if !cfg.LogQueries {
+		return logger.Default.LogMode(logger.Silent)
+	}
+	return logger.Default.LogMode(logger.Info)
+}
+
+func configurePool(sqlDB *sql.DB, cfg *config.DatabaseConfig) {
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+}
+
+// openReplicas opens a pool per replica without pinging, an unreachable replica
+// must not block startup and is picked up by the health monitor once it is back
+func (s *service) openReplicas() error {
+	for _, dsn := range s.cfg.Replicas {
+		name := replicaName(dsn)
+		db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+			Logger:               queryLogger(s.cfg),
+			DisableAutomaticPing: true,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to open replica %s: %w", name, err)
+		}
+
+		sqlDB, err := db.DB()
+		if err != nil {
+			return fmt.Errorf("failed to get replica instance %s: %w", name, err)
+		}
+		configurePool(sqlDB, s.cfg)
+
+		log.Info("Registered read replica", zap.String("replica", name))
+		s.replicas = append(s.replicas, &replica{name: name, db: db})
 	}
+	return nil
+}
+
+// monitor health checks the replicas and prunes the read-your-writes tracker
+func (s *service) monitor() {
+	defer close(s.done)
 
-	sqlDB.SetConnMaxLifetime(1 * time.Hour)
-	sqlDB.SetMaxOpenConns(64)
+	interval := s.cfg.ReplicaHealthInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
-	return &service{
-		db: db,
-	}, nil
+	s.checkReplicas(interval)
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.checkReplicas(interval)
+			s.writes.prune()
+		}
+	}
+}
+
+func (s *service) checkReplicas(timeout time.Duration) {
+	for _, r := range s.replicas {
+		r.check(timeout)
+	}
 }
 
 func (s *service) GetConnection() *gorm.DB {
 	return s.db
 }
 
+func (s *service) Reader(key string) *gorm.DB {
+	if len(s.replicas) == 0 || s.writes.recent(key) {
+		return s.db
+	}
+
+	// Round robin over the replicas, skipping the unhealthy ones
+	start := s.next.Add(1)
+	for i := range s.replicas {
+		r := s.replicas[(start+uint64(i))%uint64(len(s.replicas))]
+		if r.healthy.Load() {
+			return r.db
+		}
+	}
+	return s.db
+}
+
+func (s *service) MarkWritten(key string) {
+	s.writes.mark(key)
+}
+
+func (s *service) ReplicaHealth() []ReplicaHealth {
+	health := make([]ReplicaHealth, 0, len(s.replicas))
+	for _, r := range s.replicas {
+		health = append(health, r.health())
+	}
+	return health
+}
+
 func (s *service) Migrate() error {
+	onPostgres := s.db.Dialector.Name() == DriverPostgres
+	if onPostgres && s.cfg.Schema != "" {
+		if err := s.db.Exec(fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %q", s.cfg.Schema)).Error; err != nil {
+			return fmt.Errorf("failed to create schema: %w", err)
+		}
+	}
+
+	err := s.db.AutoMigrate(
+		&domain.Account{},
+		&domain.APIKey{},
+		&domain.ShortUrl{},
+		&domain.URLAnalytics{},
+		&domain.Tag{},
+		&domain.BulkJob{},
+		&domain.LinkVersion{},
+		&domain.AuditEvent{},
+		&domain.Domain{},
+		&domain.Organization{},
+		&domain.Membership{},
+		&domain.Invitation{},
+		&domain.Folder{},
+		&domain.RedirectRule{},
+		&domain.LinkVariant{},
+		&domain.WebhookEndpoint{},
+		&domain.WebhookDelivery{},
+		&domain.WebhookAttempt{},
+		&domain.OutboxEvent{},
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := s.backfillOrganizations(); err != nil {
+		return fmt.Errorf("failed to create personal organizations: %w", err)
+	}
+	// Bulk jobs used to belong to the account that submitted them
+	err = s.db.Exec(`UPDATE bulk_jobs SET organization_id =
+		(SELECT organization_id FROM accounts WHERE accounts.id = bulk_jobs.account_id)
+		WHERE organization_id = 0`).Error
+	if err != nil {
+		return fmt.Errorf("failed to move bulk jobs to organizations: %w", err)
+	}
+
+	// Keys issued before scopes existed could do everything
+	err = s.db.Model(&domain.APIKey{}).Where("scopes IS NULL").Update("scopes", `["admin"]`).Error
+	if err != nil {
+		return fmt.Errorf("failed to scope api keys: %w", err)
+	}
+
+	// Codes and slugs used to be unique across the service, they are now unique per domain
+	migrator := s.db.Migrator()
+	for _, index := range []string{"idx_short_urls_short_code", "idx_short_urls_custom_slug"} {
+		if migrator.HasIndex(&domain.ShortUrl{}, index) {
+			if err := migrator.DropIndex(&domain.ShortUrl{}, index); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Hosts used to be unique across organizations, which let an unverified
+	// claim block the owner. Only a verified host is unique now.
+	if migrator.HasIndex(&domain.Domain{}, "idx_domains_host") {
+		if err := migrator.DropIndex(&domain.Domain{}, "idx_domains_host"); err != nil {
+			return err
+		}
+	}
+	err = s.db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_domains_verified_host ON domains (host) WHERE verified_at IS NOT NULL`).Error
+	if err != nil {
+		return fmt.Errorf("failed to index verified domains: %w", err)
+	}
+
+	if err := s.backfillLinkColumns(); err != nil {
+		return fmt.Errorf("failed to backfill links: %w", err)
+	}
+	if err := s.indexLinkListings(); err != nil {
+		return fmt.Errorf("failed to index links: %w", err)
+	}
+	if onPostgres {
+		if err := s.indexLinkSearch(); err != nil {
+			return fmt.Errorf("failed to index link search: %w", err)
+		}
+	}
+
+	// The audit log is append only, the triggers reject any change to a stored event
+	if err := s.protectAuditLog(onPostgres); err != nil {
+		return fmt.Errorf("failed to protect audit log: %w", err)
+	}
+	return nil
+}
+
+func (s *service) protectAuditLog(onPostgres bool) error {
+	statements := []string{
+		`CREATE TRIGGER IF NOT EXISTS audit_events_no_update BEFORE UPDATE ON audit_events
+		BEGIN SELECT RAISE(ABORT, 'audit_events is append only'); END`,
+		`CREATE TRIGGER IF NOT EXISTS audit_events_no_delete BEFORE DELETE ON audit_events
+		BEGIN SELECT RAISE(ABORT, 'audit_events is append only'); END`,
+	}
+	if onPostgres {
+		statements = []string{
+			`CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+			BEGIN
+				RAISE EXCEPTION 'audit_events is append only';
+			END
+			$$ LANGUAGE plpgsql`,
+			`DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events`,
+			`CREATE TRIGGER audit_events_append_only BEFORE UPDATE OR DELETE ON audit_events
+			FOR EACH ROW EXECUTE FUNCTION audit_events_append_only()`,
+		}
+	}
+	for _, stmt := range statements {
+		if err := s.db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexLinkListings creates the composite indexes behind link listings, one per
+// sort order with id as the tie breaker and one per common filter. They only
+// cover live rows as soft deleted links are never listed.
+func (s *service) indexLinkListings() error {
+	statements := []string{
+		`CREATE INDEX IF NOT EXISTS idx_short_urls_org_created ON short_urls (organization_id, created_at, id) WHERE deleted_at IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_short_urls_org_clicks ON short_urls (organization_id, clicks, id) WHERE deleted_at IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_short_urls_org_last_clicked ON short_urls (organization_id, last_clicked_at, id) WHERE deleted_at IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_short_urls_org_host ON short_urls (organization_id, destination_host) WHERE deleted_at IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_short_urls_org_active_expiry ON short_urls (organization_id, is_active, expires_at) WHERE deleted_at IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_short_urls_org_folder ON short_urls (organization_id, folder_id) WHERE deleted_at IS NULL`,
+	}
+	for _, stmt := range statements {
+		if err := s.db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexLinkSearch creates the Postgres indexes behind link search, a GIN index
+// of the search text's tsvector and, when pg_trgm can be installed, a trigram
+// index serving substring matches. Without it substring matches scan the links.
+func (s *service) indexLinkSearch() error {
+	err := s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_short_urls_search_fts ON short_urls
+		USING gin (to_tsvector('simple', search_text)) WHERE deleted_at IS NULL`).Error
+	if err != nil {
+		return err
+	}
+	if err := s.db.Exec(`CREATE EXTENSION IF NOT EXISTS pg_trgm`).Error; err != nil {
+		log.Warn("Link search runs without trigram index, pg_trgm is unavailable", zap.Error(err))
+		return nil
+	}
+	return s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_short_urls_search_trgm ON short_urls
+		USING gin (search_text gin_trgm_ops) WHERE deleted_at IS NULL`).Error
+}
+
+// backfillLinkColumns fills the destination host and search text of links
+// created before the columns existed, in batches so a large table is not
+// locked at once
+func (s *service) backfillLinkColumns() error {
+	const batchSize = 1000
+	total := 0
+	var afterId uint
+	for {
+		var urls []domain.ShortUrl
+		err := s.db.Unscoped().Preload("Tags").Select("id", "short_code", "original_url", "title").
+			Where("(destination_host = '' OR search_text = '') AND id > ?", afterId).
+			Order("id").Limit(batchSize).Find(&urls).Error
+		if err != nil {
+			return err
+		}
+		if len(urls) == 0 {
+			break
+		}
+
+		err = s.db.Transaction(func(tx *gorm.DB) error {
+			for _, url := range urls {
+				err := tx.Model(&domain.ShortUrl{}).Unscoped().Where("id = ?", url.ID).
+					UpdateColumns(map[string]interface{}{
+						"destination_host": domain.HostOf(url.OriginalURL),
+						"search_text":      url.SearchDocument(),
+					}).Error
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		total += len(urls)
+		afterId = urls[len(urls)-1].ID
+	}
+	if total > 0 {
+		log.Info("Backfilled link columns", zap.Int("links", total))
+	}
+	return nil
+}
+
+// backfillOrganizations gives accounts created before organizations existed
+// their personal organization and moves their links and domains into it
+func (s *service) backfillOrganizations() error {
+	var accounts []domain.Account
+	if err := s.db.Where("organization_id = 0").Find(&accounts).Error; err != nil {
+		return err
+	}
+
+	for _, account := range accounts {
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			org := domain.Organization{Name: account.Email}
+			if err := tx.Create(&org).Error; err != nil {
+				return err
+			}
+			owner := domain.Membership{OrganizationId: org.ID, AccountId: account.ID, Role: domain.RoleOwner}
+			if err := tx.Create(&owner).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&account).Update("organization_id", org.ID).Error; err != nil {
+				return err
+			}
+			for _, table := range []string{"short_urls", "domains"} {
+				err := tx.Table(table).
+					Where("account_id = ? AND organization_id = 0", account.ID).
+					Update("organization_id", org.ID).Error
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if len(accounts) > 0 {
+		log.Info("Created personal organizations", zap.Int("accounts", len(accounts)))
+	}
 	return nil
 }
 
 func (s *service) Close() error {
+	select {
+	case <-s.stop:
+		return nil
+	default:
+		close(s.stop)
+	}
+
+	var errs []error
+	for _, r := range s.replicas {
+		if sqlDB, err := r.db.DB(); err == nil {
+			errs = append(errs, sqlDB.Close())
+		}
+	}
+
 	sqlDB, err := s.db.DB()
 	if err != nil {
 		return fmt.Errorf("failed to get database instance: %w", err)
 	}
-	return sqlDB.Close()
+	errs = append(errs, sqlDB.Close())
+	return errors.Join(errs...)
 }
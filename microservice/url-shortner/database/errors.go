@@ -0,0 +1,52 @@
+package database
+
+import (
+	"coding2fun.in/url-shortner/internal/domain"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// Postgres error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+	pgNotNullViolation    = "23502"
+	pgCheckViolation      = "23514"
+)
+
+// TranslateError converts gorm and postgres driver errors into domain errors,
+// SQLite errors arrive already translated by gorm.
+// The original error stays in the chain so it can still be logged.
+func TranslateError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("%w: %w", domain.ErrNotFound, err)
+	}
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return fmt.Errorf("%w: %w", domain.ErrConflict, err)
+	}
+	if errors.Is(err, gorm.ErrForeignKeyViolated) {
+		return fmt.Errorf("%w: %w", domain.NewValidationError("reference", "references a missing resource"), err)
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case pgUniqueViolation:
+			return fmt.Errorf("%w: %w", domain.ErrConflict, err)
+		case pgForeignKeyViolation:
+			return fmt.Errorf("%w: %w", domain.NewValidationError(pgErr.ConstraintName, "references a missing resource"), err)
+		case pgNotNullViolation:
+			return fmt.Errorf("%w: %w", domain.NewValidationError(pgErr.ColumnName, "is required"), err)
+		case pgCheckViolation:
+			return fmt.Errorf("%w: %w", domain.NewValidationError(pgErr.ConstraintName, "is invalid"), err)
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"coding2fun.in/url-shortner/internal/domain"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func TestTranslateError(t *testing.T) {
+	tests := []struct {
+		name  string
+		err   error
+		want  error
+		field string
+	}{
+		{"not found", gorm.ErrRecordNotFound, domain.ErrNotFound, ""},
+		{"sqlite duplicate", gorm.ErrDuplicatedKey, domain.ErrConflict, ""},
+		{"sqlite foreign key", gorm.ErrForeignKeyViolated, domain.ErrValidation, "reference"},
+		{"unique violation", &pgconn.PgError{Code: "23505", ConstraintName: "idx_domain_code"}, domain.ErrConflict, ""},
+		{"foreign key violation", &pgconn.PgError{Code: "23503", ConstraintName: "fk_short_urls_folder"}, domain.ErrValidation, "fk_short_urls_folder"},
+		{"not null violation", &pgconn.PgError{Code: "23502", ColumnName: "original_url"}, domain.ErrValidation, "original_url"},
+		{"check violation", &pgconn.PgError{Code: "23514", ConstraintName: "chk_max_clicks"}, domain.ErrValidation, "chk_max_clicks"},
+		{"wrapped unique violation", fmt.Errorf("insert: %w", &pgconn.PgError{Code: "23505"}), domain.ErrConflict, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TranslateError(tt.err)
+			if !errors.Is(got, tt.want) {
+				t.Fatalf("TranslateError() = %v, want %v", got, tt.want)
+			}
+			if !errors.Is(got, tt.err) {
+				t.Errorf("TranslateError() = %v dropped the original error", got)
+			}
+			var verr *domain.ValidationError
+			if errors.As(got, &verr) && verr.Fields[0].Field != tt.field {
+				t.Errorf("TranslateError() reports field %q, want %q", verr.Fields[0].Field, tt.field)
+			}
+		})
+	}
+}
+
+func TestTranslateErrorPassesOtherErrorsThrough(t *testing.T) {
+	if err := TranslateError(nil); err != nil {
+		t.Errorf("TranslateError(nil) = %v", err)
+	}
+
+	// Errors without a domain meaning, serialization failures among them, stay internal
+	tests := []error{
+		&pgconn.PgError{Code: "40001", Message: "could not serialize access"},
+		&pgconn.PgError{Code: "57014", Message: "canceling statement due to statement timeout"},
+		errors.New("connection refused"),
+	}
+	for _, err := range tests {
+		got := TranslateError(err)
+		if got != err {
+			t.Errorf("TranslateError(%v) = %v, want it unchanged", err, got)
+		}
+		if code := domain.ErrorCode(got); code != domain.CodeInternal {
+			t.Errorf("TranslateError(%v) has code %s, want %s", err, code, domain.CodeInternal)
+		}
+	}
+}
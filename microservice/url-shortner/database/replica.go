@@ -0,0 +1,117 @@
+package database
+
+import (
+	"coding2fun.in/url-shortner/internal/log"
+	"context"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type ReplicaHealth struct {
+	Name      string    `json:"name"`
+	Healthy   bool      `json:"healthy"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+type replica struct {
+	name    string
+	db      *gorm.DB
+	healthy atomic.Bool
+
+	mu        sync.Mutex
+	lastErr   error
+	checkedAt time.Time
+}
+
+func (r *replica) check(timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var err error
+	if sqlDB, dbErr := r.db.DB(); dbErr != nil {
+		err = dbErr
+	} else {
+		err = sqlDB.PingContext(ctx)
+	}
+
+	r.mu.Lock()
+	r.lastErr = err
+	r.checkedAt = time.Now()
+	r.mu.Unlock()
+
+	healthy := err == nil
+	if r.healthy.Swap(healthy) != healthy {
+		if healthy {
+			log.Info("Replica is healthy", zap.String("replica", r.name))
+		} else {
+			log.Warn("Replica is unhealthy", zap.String("replica", r.name), zap.Error(err))
+		}
+	}
+}
+
+func (r *replica) health() ReplicaHealth {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	h := ReplicaHealth{Name: r.name, Healthy: r.healthy.Load(), CheckedAt: r.checkedAt}
+	if r.lastErr != nil {
+		h.Error = r.lastErr.Error()
+	}
+	return h
+}
+
+// replicaName extracts host and port from a DSN so credentials never reach logs
+func replicaName(dsn string) string {
+	cfg, err := pgconn.ParseConfig(dsn)
+	if err != nil {
+		return "invalid-dsn"
+	}
+	return cfg.Host + ":" + strconv.Itoa(int(cfg.Port))
+}
+
+// writeTracker remembers recently written keys so their reads can be pinned to
+// the primary until replication has caught up
+type writeTracker struct {
+	window time.Duration
+	mu     sync.Mutex
+	writes map[string]time.Time
+}
+
+func newWriteTracker(window time.Duration) *writeTracker {
+	return &writeTracker{window: window, writes: make(map[string]time.Time)}
+}
+
+func (t *writeTracker) mark(key string) {
+	if t.window <= 0 {
+		return
+	}
+	t.mu.Lock()
+	t.writes[key] = time.Now()
+	t.mu.Unlock()
+}
+
+func (t *writeTracker) recent(key string) bool {
+	if t.window <= 0 {
+		return false
+	}
+	t.mu.Lock()
+	writtenAt, ok := t.writes[key]
+	t.mu.Unlock()
+	return ok && time.Since(writtenAt) < t.window
+}
+
+func (t *writeTracker) prune() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key, writtenAt := range t.writes {
+		if time.Since(writtenAt) >= t.window {
+			delete(t.writes, key)
+		}
+	}
+}
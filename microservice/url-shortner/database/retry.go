@@ -0,0 +1,69 @@
+package database
+
+import (
+	"coding2fun.in/url-shortner/internal/log"
+	"fmt"
+	"go.uber.org/zap"
+	"math/rand/v2"
+	"time"
+)
+
+// minRetryDelay keeps a zero or negative delay from retrying in a tight loop
+const minRetryDelay = 10 * time.Millisecond
+
+// retryPolicy describes an exponential backoff with equal jitter bounded by a
+// deadline. now and sleep default to the wall clock.
+type retryPolicy struct {
+	deadline     time.Duration
+	initialDelay time.Duration
+	maxDelay     time.Duration
+
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+// do runs fn until it succeeds or the next attempt would start after the deadline
+func (p retryPolicy) do(name string, fn func() error) error {
+	now, sleep := p.now, p.sleep
+	if now == nil {
+		now = time.Now
+	}
+	if sleep == nil {
+		sleep = time.Sleep
+	}
+
+	deadline := now().Add(p.deadline)
+	delay := max(p.initialDelay, minRetryDelay)
+	maxDelay := max(p.maxDelay, delay)
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		wait := jitter(delay)
+		if now().Add(wait).After(deadline) {
+			return fmt.Errorf("%s failed after %d attempts: %w", name, attempt, err)
+		}
+
+		log.Warn("Retrying "+name,
+			zap.Int("attempt", attempt),
+			zap.Duration("backoff", wait),
+			zap.Error(err),
+		)
+		sleep(wait)
+
+		delay = min(delay*2, maxDelay)
+	}
+}
+
+// jitter picks a random duration in [d/2, d) so that restarting replicas do
+// not retry in lockstep while each still backs off by at least half the delay
+func jitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+	half := d / 2
+	return half + rand.N(d-half)
+}
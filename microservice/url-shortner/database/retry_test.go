@@ -0,0 +1,128 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClock advances only when the policy sleeps
+type fakeClock struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) policy(deadline, initial, maxDelay time.Duration) retryPolicy {
+	return retryPolicy{
+		deadline:     deadline,
+		initialDelay: initial,
+		maxDelay:     maxDelay,
+		now:          func() time.Time { return c.now },
+		sleep: func(d time.Duration) {
+			c.sleeps = append(c.sleeps, d)
+			c.now = c.now.Add(d)
+		},
+	}
+}
+
+func TestRetryBacksOffExponentially(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	failures := 5
+	err := clock.policy(time.Hour, 100*time.Millisecond, 300*time.Millisecond).do("test", func() error {
+		if failures > 0 {
+			failures--
+			return errors.New("refused")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("do() error = %v", err)
+	}
+
+	delays := []time.Duration{100, 200, 300, 300, 300}
+	if len(clock.sleeps) != len(delays) {
+		t.Fatalf("do() slept %v, want %d sleeps", clock.sleeps, len(delays))
+	}
+	for i, delay := range delays {
+		delay *= time.Millisecond
+		if got := clock.sleeps[i]; got < delay/2 || got >= delay {
+			t.Errorf("sleep %d = %s, want within [%s, %s)", i+1, got, delay/2, delay)
+		}
+	}
+}
+
+func TestRetryStopsAtTheDeadline(t *testing.T) {
+	start := time.Now()
+	clock := &fakeClock{now: start}
+	refused := errors.New("refused")
+	attempts := 0
+	err := clock.policy(time.Second, 200*time.Millisecond, 400*time.Millisecond).do("test", func() error {
+		attempts++
+		return refused
+	})
+	if !errors.Is(err, refused) {
+		t.Fatalf("do() error = %v, want the last attempt's error", err)
+	}
+	if want := fmt.Sprintf("test failed after %d attempts", attempts); !strings.Contains(err.Error(), want) {
+		t.Errorf("do() error = %v, want %q", err, want)
+	}
+	if len(clock.sleeps) != attempts-1 {
+		t.Errorf("do() made %d attempts with %d sleeps", attempts, len(clock.sleeps))
+	}
+	if elapsed := clock.now.Sub(start); elapsed > time.Second {
+		t.Errorf("do() kept retrying for %s, past the deadline of 1s", elapsed)
+	}
+	// The shortest backoff of 0.1s, 0.2s, 0.2s, 0.2s... leaves room for three sleeps at least
+	if attempts < 4 {
+		t.Errorf("do() gave up after %d attempts", attempts)
+	}
+}
+
+func TestRetryWithoutADeadlineTriesOnce(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	attempts := 0
+	err := clock.policy(0, 100*time.Millisecond, time.Second).do("test", func() error {
+		attempts++
+		return errors.New("refused")
+	})
+	if err == nil || attempts != 1 || len(clock.sleeps) != 0 {
+		t.Errorf("do() = %v after %d attempts and %d sleeps, want one failed attempt", err, attempts, len(clock.sleeps))
+	}
+}
+
+func TestRetryKeepsANonPositiveDelayOutOfATightLoop(t *testing.T) {
+	for _, initial := range []time.Duration{0, -time.Second} {
+		clock := &fakeClock{now: time.Now()}
+		err := clock.policy(time.Second, initial, 0).do("test", func() error {
+			return errors.New("refused")
+		})
+		if err == nil {
+			t.Fatalf("do() with delay %s error = nil", initial)
+		}
+		for _, sleep := range clock.sleeps {
+			if sleep < minRetryDelay/2 {
+				t.Fatalf("do() with delay %s slept %s", initial, sleep)
+			}
+		}
+		if len(clock.sleeps) > int(2*time.Second/minRetryDelay) {
+			t.Errorf("do() with delay %s slept %d times within a second", initial, len(clock.sleeps))
+		}
+	}
+}
+
+func TestJitter(t *testing.T) {
+	for _, d := range []time.Duration{2, 3, time.Millisecond, time.Second} {
+		for range 1000 {
+			if got := jitter(d); got < d/2 || got >= d {
+				t.Fatalf("jitter(%s) = %s, want within [%s, %s)", d, got, d/2, d)
+			}
+		}
+	}
+	for _, d := range []time.Duration{0, 1} {
+		if got := jitter(d); got != d {
+			t.Errorf("jitter(%s) = %s, want it unchanged", d, got)
+		}
+	}
+}
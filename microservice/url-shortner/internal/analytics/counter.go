@@ -0,0 +1,128 @@
+package analytics
+
+import (
+	"coding2fun.in/url-shortner/internal/domain"
+	"coding2fun.in/url-shortner/internal/log"
+	"context"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+// ClickCounter buffers redirect clicks in memory and flushes them to the
+// primary in batches, keeping writes off the redirect hot path
+type ClickCounter struct {
+	urls       domain.ShortURLRepository
+	interval   time.Duration
+	maxPending int
+
+	mu      sync.Mutex
+	pending map[uint]domain.ClickDelta
+
+	flush chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+func NewClickCounter(urls domain.ShortURLRepository, interval time.Duration, maxPending int) *ClickCounter {
+	return &ClickCounter{
+		urls:       urls,
+		interval:   interval,
+		maxPending: maxPending,
+		pending:    make(map[uint]domain.ClickDelta),
+		flush:      make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// Record counts a click for the short url and the A/B variant it was sent to,
+// 0 for none. A full buffer triggers an early flush.
+func (c *ClickCounter) Record(id, variantId uint) {
+	c.mu.Lock()
+	delta := c.pending[id]
+	delta.Count++
+	delta.LastClickedAt = time.Now()
+	if variantId != 0 {
+		if delta.Variants == nil {
+			delta.Variants = make(map[uint]int64)
+		}
+		delta.Variants[variantId]++
+	}
+	c.pending[id] = delta
+	full := len(c.pending) >= c.maxPending
+	c.mu.Unlock()
+
+	if full {
+		select {
+		case c.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Start runs the flush loop in the background
+func (c *ClickCounter) Start() {
+	go func() {
+		defer close(c.done)
+
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.stop:
+				c.Flush(context.Background())
+				return
+			case <-ticker.C:
+				c.Flush(context.Background())
+			case <-c.flush:
+				c.Flush(context.Background())
+			}
+		}
+	}()
+}
+
+// Stop flushes the remaining clicks and waits for the flush loop to exit
+func (c *ClickCounter) Stop() {
+	close(c.stop)
+	<-c.done
+}
+
+// Flush writes the buffered clicks, a failed batch is merged back for the next attempt
+func (c *ClickCounter) Flush(ctx context.Context) {
+	c.mu.Lock()
+	batch := c.pending
+	c.pending = make(map[uint]domain.ClickDelta, len(batch))
+	c.mu.Unlock()
+
+	if len(batch) == 0 {
+		return
+	}
+
+	if err := c.urls.AddClicks(ctx, batch); err != nil {
+		log.Error("Failed to flush clicks", zap.Int("links", len(batch)), zap.Error(err))
+		c.requeue(batch)
+		return
+	}
+	log.Debug("Flushed clicks", zap.Int("links", len(batch)))
+}
+
+func (c *ClickCounter) requeue(batch map[uint]domain.ClickDelta) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, delta := range batch {
+		current := c.pending[id]
+		current.Count += delta.Count
+		if delta.LastClickedAt.After(current.LastClickedAt) {
+			current.LastClickedAt = delta.LastClickedAt
+		}
+		for variantId, count := range delta.Variants {
+			if current.Variants == nil {
+				current.Variants = make(map[uint]int64)
+			}
+			current.Variants[variantId] += count
+		}
+		c.pending[id] = current
+	}
+}
@@ -0,0 +1,266 @@
+// Package archive reads and writes the export archives of an account: the
+// account, its key metadata, the organization's links with their history and
+// analytics. Archives are JSON lines or a zip of CSV files, both versioned.
+package archive
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Version is the archive layout written by this package, archives of a newer
+// version are rejected
+const Version = 1
+
+type Format string
+
+const (
+	FormatJSONL Format = "jsonl"
+	FormatCSV   Format = "csv"
+	// FormatBitly is a link export of bit.ly, it can only be read
+	FormatBitly Format = "bitly"
+)
+
+// Archive is the content of an export. Records reference links by the ID they
+// had in the exporting service, importers assign new ones.
+type Archive struct {
+	Header    Header
+	Account   *Account
+	APIKeys   []APIKey
+	Links     []Link
+	Versions  []LinkVersion
+	Analytics []Analytics
+}
+
+type Header struct {
+	Version        int       `json:"version"`
+	ExportedAt     time.Time `json:"exportedAt"`
+	AccountId      uint      `json:"accountId"`
+	OrganizationId uint      `json:"organizationId"`
+}
+
+type Account struct {
+	ID        uint      `json:"id"`
+	Email     string    `json:"email"`
+	IsActive  bool      `json:"isActive"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// APIKey is the metadata of a key, the key itself is never exported
+type APIKey struct {
+	ID             uint       `json:"id"`
+	Name           string     `json:"name"`
+	Scopes         []string   `json:"scopes"`
+	AllowedDomains []string   `json:"allowedDomains"`
+	AllowedCIDRs   []string   `json:"allowedCidrs"`
+	IsActive       bool       `json:"isActive"`
+	ExpiresAt      *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt     *time.Time `json:"lastUsedAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+type Link struct {
+	ID        uint   `json:"id"`
+	ShortCode string `json:"shortCode"`
+	// CustomSlug is set when the code was chosen rather than generated
+	CustomSlug bool `json:"customSlug"`
+	// Domain is the host of the custom domain serving the link, empty for the shared domain
+	Domain         string     `json:"domain,omitempty"`
+	OriginalURL    string     `json:"originalUrl"`
+	Title          string     `json:"title,omitempty"`
+	IsActive       bool       `json:"isActive"`
+	DisabledReason string     `json:"disabledReason,omitempty"`
+	ExpiresAt      *time.Time `json:"expiresAt,omitempty"`
+	Tags           []string   `json:"tags"`
+	// Protected is set when a password guards the link, PasswordHash is its
+	// bcrypt hash and only exported on request so protection survives a migration
+	Protected      bool      `json:"protected,omitempty"`
+	PasswordHash   string    `json:"passwordHash,omitempty"`
+	MaxClicks      int64     `json:"maxClicks"`
+	ConsumedClicks int64     `json:"consumedClicks"`
+	RedirectType   int       `json:"redirectType"`
+	Version        int       `json:"version"`
+	Clicks         int64     `json:"clicks"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UTM            *UTM      `json:"utm,omitempty"`
+	// QueryPassthrough is shortLink or destination when the query string of
+	// the short url is forwarded
+	QueryPassthrough string    `json:"queryPassthrough,omitempty"`
+	Rules            []Rule    `json:"rules,omitempty"`
+	Variants         []Variant `json:"variants,omitempty"`
+}
+
+// Rule is a redirect rule of a link, rules are kept in evaluation order
+type Rule struct {
+	URL        string     `json:"url"`
+	Countries  []string   `json:"countries,omitempty"`
+	OS         []string   `json:"os,omitempty"`
+	Devices    []string   `json:"devices,omitempty"`
+	Languages  []string   `json:"languages,omitempty"`
+	Referrers  []string   `json:"referrers,omitempty"`
+	From       *time.Time `json:"from,omitempty"`
+	Until      *time.Time `json:"until,omitempty"`
+	DailyFrom  string     `json:"dailyFrom,omitempty"`
+	DailyUntil string     `json:"dailyUntil,omitempty"`
+	TimeZone   string     `json:"timeZone,omitempty"`
+}
+
+// Variant is a weighted destination of the A/B split of a link
+type Variant struct {
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+	Clicks int64  `json:"clicks"`
+}
+
+// UTM holds the campaign parameters merged into the destination of a link
+type UTM struct {
+	Source   string `json:"source,omitempty"`
+	Medium   string `json:"medium,omitempty"`
+	Campaign string `json:"campaign,omitempty"`
+	Term     string `json:"term,omitempty"`
+	Content  string `json:"content,omitempty"`
+}
+
+// LinkVersion is a prior state of a link
+type LinkVersion struct {
+	LinkId        uint       `json:"linkId"`
+	Version       int        `json:"version"`
+	OriginalURL   string     `json:"originalUrl"`
+	ExpiresAt     *time.Time `json:"expiresAt,omitempty"`
+	IsActive      bool       `json:"isActive"`
+	RedirectType  int        `json:"redirectType"`
+	ChangedFields []string   `json:"changedFields"`
+	ChangedAt     time.Time  `json:"changedAt"`
+}
+
+// Analytics are the aggregated clicks of a link
+type Analytics struct {
+	LinkId        uint       `json:"linkId"`
+	TotalClicks   int64      `json:"totalClicks"`
+	LastClickedAt *time.Time `json:"lastClickedAt,omitempty"`
+}
+
+// ParseFormat validates a format name, empty detects the format while reading
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(name); format {
+	case "", FormatJSONL, FormatCSV, FormatBitly:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown archive format %q", name)
+	}
+}
+
+// Detect tells the format of an archive from its first bytes: a zip is a CSV
+// archive, a JSON object a JSON lines archive and anything else a bit.ly export
+func Detect(data []byte) Format {
+	trimmed := bytes.TrimLeft(data, " \t\r\n\ufeff")
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return FormatCSV
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		return FormatJSONL
+	default:
+		return FormatBitly
+	}
+}
+
+// ContentType is the media type of an archive written in the format
+func ContentType(format Format) string {
+	if format == FormatCSV {
+		return "application/zip"
+	}
+	return "application/x-ndjson"
+}
+
+// Extension is the file extension of an archive written in the format
+func Extension(format Format) string {
+	if format == FormatCSV {
+		return ".zip"
+	}
+	return ".jsonl"
+}
+
+func checkVersion(h Header) error {
+	if h.Version < 1 || h.Version > Version {
+		return fmt.Errorf("archive version %d is not supported, expected 1 to %d", h.Version, Version)
+	}
+	return nil
+}
+
+// Writer encodes an archive record by record, so an export never holds all of
+// it. Records of one kind are written together in the order account, keys,
+// links, versions and analytics. Close completes the archive.
+type Writer interface {
+	WriteAccount(Account) error
+	WriteAPIKey(APIKey) error
+	WriteLink(Link) error
+	WriteVersion(LinkVersion) error
+	WriteAnalytics(Analytics) error
+	Close() error
+}
+
+// NewWriter starts an archive in the format with its header, bit.ly exports
+// cannot be written
+func NewWriter(w io.Writer, format Format, h Header) (Writer, error) {
+	switch format {
+	case FormatJSONL:
+		return newJSONLWriter(w, h)
+	case FormatCSV:
+		return newCSVWriter(w, h)
+	default:
+		return nil, fmt.Errorf("archives cannot be written as %q", format)
+	}
+}
+
+// Write encodes an archive held in memory in the format
+func Write(w io.Writer, format Format, a *Archive) error {
+	aw, err := NewWriter(w, format, a.Header)
+	if err != nil {
+		return err
+	}
+	if a.Account != nil {
+		if err := aw.WriteAccount(*a.Account); err != nil {
+			return err
+		}
+	}
+	for _, key := range a.APIKeys {
+		if err := aw.WriteAPIKey(key); err != nil {
+			return err
+		}
+	}
+	for _, link := range a.Links {
+		if err := aw.WriteLink(link); err != nil {
+			return err
+		}
+	}
+	for _, v := range a.Versions {
+		if err := aw.WriteVersion(v); err != nil {
+			return err
+		}
+	}
+	for _, stats := range a.Analytics {
+		if err := aw.WriteAnalytics(stats); err != nil {
+			return err
+		}
+	}
+	return aw.Close()
+}
+
+// Read decodes an archive, an empty format is detected from the data
+func Read(data []byte, format Format) (*Archive, error) {
+	if format == "" {
+		format = Detect(data)
+	}
+	switch format {
+	case FormatJSONL:
+		return readJSONL(data)
+	case FormatCSV:
+		return readCSV(data)
+	case FormatBitly:
+		return readBitly(data)
+	default:
+		return nil, fmt.Errorf("unknown archive format %q", format)
+	}
+}
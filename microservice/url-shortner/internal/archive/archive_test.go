@@ -0,0 +1,143 @@
+package archive
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sampleArchive() *Archive {
+	at := time.Date(2026, 3, 4, 5, 6, 7, 890, time.UTC)
+	later := at.Add(48 * time.Hour)
+	return &Archive{
+		Header:  Header{Version: Version, ExportedAt: at, AccountId: 7, OrganizationId: 9},
+		Account: &Account{ID: 7, Email: "owner@example.com", IsActive: true, CreatedAt: at},
+		APIKeys: []APIKey{
+			{ID: 3, Name: "ci", Scopes: []string{"links:read"}, AllowedDomains: []string{"go.example.com"}, AllowedCIDRs: []string{"10.0.0.0/8"}, IsActive: true, ExpiresAt: &later, LastUsedAt: &at, CreatedAt: at},
+		},
+		Links: []Link{
+			{
+				ID: 11, ShortCode: "promo", CustomSlug: true, Domain: "go.example.com", OriginalURL: "https://example.com/a?b=1,2",
+				Title: "Promo, \"quoted\"", IsActive: true, ExpiresAt: &later, Tags: []string{"spring", "sale"}, Protected: true,
+				PasswordHash: "$2a$10$abcdefghijklmnopqrstuv", MaxClicks: 100, ConsumedClicks: 4, RedirectType: 301, Version: 3,
+				Clicks: 42, CreatedAt: at, UTM: &UTM{Source: "news", Campaign: "spring"}, QueryPassthrough: "destination",
+				Rules:    []Rule{{URL: "https://example.de/", Countries: []string{"DE"}, From: &at, DailyFrom: "09:00", DailyUntil: "17:00", TimeZone: "Europe/Berlin"}},
+				Variants: []Variant{{URL: "https://example.com/a", Weight: 70, Clicks: 30}, {URL: "https://example.com/b", Weight: 30, Clicks: 12}},
+			},
+			{ID: 12, ShortCode: "Xy12abc", OriginalURL: "https://example.org/\nline", DisabledReason: "abuse", RedirectType: 302, Version: 1, CreatedAt: at},
+		},
+		Versions: []LinkVersion{
+			{LinkId: 11, Version: 1, OriginalURL: "https://example.com/old", IsActive: true, RedirectType: 302, ChangedFields: []string{"url"}, ChangedAt: at},
+			{LinkId: 11, Version: 2, OriginalURL: "https://example.com/older", ExpiresAt: &later, RedirectType: 301, ChangedFields: []string{"url", "redirectType"}, ChangedAt: later},
+		},
+		Analytics: []Analytics{{LinkId: 11, TotalClicks: 42, LastClickedAt: &later}, {LinkId: 12}},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, format := range []Format{FormatJSONL, FormatCSV} {
+		t.Run(string(format), func(t *testing.T) {
+			want := sampleArchive()
+			var buf bytes.Buffer
+			if err := Write(&buf, format, want); err != nil {
+				t.Fatal(err)
+			}
+			if got := Detect(buf.Bytes()); got != format {
+				t.Errorf("detected %q", got)
+			}
+
+			got, err := Read(buf.Bytes(), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("read back\n%+v\nwant\n%+v", got, want)
+			}
+		})
+	}
+}
+
+func TestRoundTripWithoutRecords(t *testing.T) {
+	for _, format := range []Format{FormatJSONL, FormatCSV} {
+		want := &Archive{Header: Header{Version: Version, AccountId: 1}}
+		var buf bytes.Buffer
+		if err := Write(&buf, format, want); err != nil {
+			t.Fatal(err)
+		}
+		got, err := Read(buf.Bytes(), format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s read back %+v", format, got)
+		}
+	}
+}
+
+func TestCSVWriterKeepsKindsTogether(t *testing.T) {
+	w, err := NewWriter(&bytes.Buffer{}, FormatCSV, Header{Version: Version})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteLink(Link{ID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteVersion(LinkVersion{LinkId: 1, Version: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteLink(Link{ID: 2}); err == nil {
+		t.Error("a link after the versions was accepted")
+	}
+	if _, err := NewWriter(&bytes.Buffer{}, FormatBitly, Header{}); err == nil {
+		t.Error("a bit.ly writer was created")
+	}
+}
+
+func TestReadRejectsNewerVersions(t *testing.T) {
+	for _, format := range []Format{FormatJSONL, FormatCSV} {
+		var buf bytes.Buffer
+		if err := Write(&buf, format, &Archive{Header: Header{Version: Version + 1}}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Read(buf.Bytes(), format); err == nil || !strings.Contains(err.Error(), "not supported") {
+			t.Errorf("%s read a newer archive: %v", format, err)
+		}
+	}
+}
+
+func TestReadBitly(t *testing.T) {
+	export := "\ufeffTitle, Long URL ,Link,Custom Bitlinks,Created,Total Clicks,Tags\n" +
+		"Spring,https://example.com/spring,https://bit.ly/3xYz,bit.ly/spring-sale,2024-05-06 07:08:09,\"1,204\",promo; 2024\n" +
+		"Blank,,https://bit.ly/none,,,,\n" +
+		",https://example.com/plain,bit.ly/4AbC/,,05/06/2024,,\n"
+
+	a, err := Read([]byte(export), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Header.Version != Version {
+		t.Errorf("header %+v", a.Header)
+	}
+	want := []Link{
+		{
+			ID: 2, ShortCode: "spring-sale", CustomSlug: true, OriginalURL: "https://example.com/spring", Title: "Spring",
+			IsActive: true, Tags: []string{"promo", "2024"}, Version: 1, Clicks: 1204,
+			CreatedAt: time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC),
+		},
+		{ID: 4, ShortCode: "4AbC", OriginalURL: "https://example.com/plain", IsActive: true, Version: 1, CreatedAt: time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)},
+	}
+	if !reflect.DeepEqual(a.Links, want) {
+		t.Errorf("links\n%+v\nwant\n%+v", a.Links, want)
+	}
+	if !reflect.DeepEqual(a.Analytics, []Analytics{{LinkId: 2, TotalClicks: 1204}}) {
+		t.Errorf("analytics %+v", a.Analytics)
+	}
+
+	for _, bad := range []string{"", "link,title\nbit.ly/x,X\n", "long_url,clicks\nhttps://example.com/,many\n"} {
+		if _, err := Read([]byte(bad), FormatBitly); err == nil {
+			t.Errorf("read the export %q", bad)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package archive
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// bitlyColumns are the header names bit.ly used across its export versions
+var bitlyColumns = map[string][]string{
+	"destination": {"long_url", "long url", "destination", "original_url", "url"},
+	"link":        {"link", "bitlink", "short_url", "short url", "short link"},
+	"custom":      {"custom_bitlinks", "custom bitlinks", "custom_bitlink", "custom back-half"},
+	"created":     {"created_at", "created", "date created", "created date"},
+	"tags":        {"tags", "tag"},
+	"title":       {"title"},
+	"clicks":      {"clicks", "total clicks", "total_clicks", "engagements"},
+}
+
+var bitlyTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05-0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"01/02/2006 15:04",
+	"01/02/2006",
+}
+
+// readBitly reads a bit.ly link export. The links are numbered by their row,
+// back-halves become custom slugs and the code of a generated bitlink is kept.
+func readBitly(data []byte) (*Archive, error) {
+	cr := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\ufeff"))))
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("the export is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]int, len(bitlyColumns))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		for column, aliases := range bitlyColumns {
+			for _, alias := range aliases {
+				if _, seen := index[column]; !seen && name == alias {
+					index[column] = i
+				}
+			}
+		}
+	}
+	if _, ok := index["destination"]; !ok {
+		return nil, fmt.Errorf("the export has no long url column")
+	}
+
+	a := &Archive{Header: Header{Version: Version}}
+	for row := 2; ; row++ {
+		values, err := cr.Read()
+		if err == io.EOF {
+			return a, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		c := cells{index: index, values: values}
+		if strings.TrimSpace(c.str("destination")) == "" {
+			continue
+		}
+
+		link := Link{
+			ID:          uint(row),
+			OriginalURL: strings.TrimSpace(c.str("destination")),
+			Title:       strings.TrimSpace(c.str("title")),
+			IsActive:    true,
+			Tags:        splitTags(c.str("tags")),
+			Version:     1,
+		}
+		if custom := firstField(c.str("custom")); custom != "" {
+			link.ShortCode, link.CustomSlug = backHalf(custom), true
+		} else {
+			link.ShortCode = backHalf(c.str("link"))
+		}
+		if created := strings.TrimSpace(c.str("created")); created != "" {
+			t, err := parseBitlyTime(created)
+			if err != nil {
+				return nil, fmt.Errorf("row %d: %w", row, err)
+			}
+			link.CreatedAt = t
+		}
+		if clicks := strings.TrimSpace(c.str("clicks")); clicks != "" {
+			n, err := strconv.ParseInt(strings.ReplaceAll(clicks, ",", ""), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("row %d: column clicks: %w", row, err)
+			}
+			link.Clicks = n
+			a.Analytics = append(a.Analytics, Analytics{LinkId: link.ID, TotalClicks: n})
+		}
+		a.Links = append(a.Links, link)
+	}
+}
+
+// backHalf returns the code of a bitlink such as bit.ly/abc or https://bit.ly/abc
+func backHalf(link string) string {
+	link = strings.TrimRight(strings.TrimSpace(link), "/")
+	if i := strings.LastIndex(link, "/"); i >= 0 {
+		link = link[i+1:]
+	}
+	return link
+}
+
+// firstField returns the first entry of a list cell
+func firstField(value string) string {
+	fields := strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' || r == '|' || r == ' ' })
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+func splitTags(value string) []string {
+	var tags []string
+	for _, tag := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' || r == '|' }) {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func parseBitlyTime(value string) (time.Time, error) {
+	for _, layout := range bitlyTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("column created: unknown time %q", value)
+}
@@ -0,0 +1,493 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Files of a CSV archive, list cells hold JSON arrays and the rules of a link
+// a JSON array of objects
+const (
+	manifestFile  = "manifest.json"
+	accountFile   = "account.csv"
+	apiKeysFile   = "api_keys.csv"
+	linksFile     = "links.csv"
+	versionsFile  = "link_versions.csv"
+	analyticsFile = "analytics.csv"
+)
+
+// table is the CSV layout of one record type
+type table[T any] struct {
+	columns []string
+	row     func(T) []string
+	parse   func(cells) (T, error)
+}
+
+var accountTable = table[Account]{
+	columns: []string{"id", "email", "is_active", "created_at"},
+	row: func(a Account) []string {
+		return []string{formatUint(a.ID), a.Email, strconv.FormatBool(a.IsActive), formatTime(a.CreatedAt)}
+	},
+	parse: func(c cells) (Account, error) {
+		return Account{ID: c.uint("id"), Email: c.str("email"), IsActive: c.bool("is_active"), CreatedAt: c.time("created_at")}, c.err
+	},
+}
+
+var apiKeyTable = table[APIKey]{
+	columns: []string{"id", "name", "scopes", "allowed_domains", "allowed_cidrs", "is_active", "expires_at", "last_used_at", "created_at"},
+	row: func(k APIKey) []string {
+		return []string{
+			formatUint(k.ID), k.Name, formatList(k.Scopes), formatList(k.AllowedDomains), formatList(k.AllowedCIDRs),
+			strconv.FormatBool(k.IsActive), formatOptionalTime(k.ExpiresAt), formatOptionalTime(k.LastUsedAt), formatTime(k.CreatedAt),
+		}
+	},
+	parse: func(c cells) (APIKey, error) {
+		return APIKey{
+			ID:             c.uint("id"),
+			Name:           c.str("name"),
+			Scopes:         c.list("scopes"),
+			AllowedDomains: c.list("allowed_domains"),
+			AllowedCIDRs:   c.list("allowed_cidrs"),
+			IsActive:       c.bool("is_active"),
+			ExpiresAt:      c.optionalTime("expires_at"),
+			LastUsedAt:     c.optionalTime("last_used_at"),
+			CreatedAt:      c.time("created_at"),
+		}, c.err
+	},
+}
+
+var linkTable = table[Link]{
+	columns: []string{
+		"id", "short_code", "custom_slug", "domain", "original_url", "is_active", "disabled_reason", "expires_at", "tags",
+		"password_hash", "max_clicks", "consumed_clicks", "redirect_type", "version", "clicks", "created_at", "title",
+		"utm_source", "utm_medium", "utm_campaign", "utm_term", "utm_content", "query_passthrough", "rules",
+		"variants", "protected",
+	},
+	row: func(l Link) []string {
+		var utm UTM
+		if l.UTM != nil {
+			utm = *l.UTM
+		}
+		return []string{
+			formatUint(l.ID), l.ShortCode, strconv.FormatBool(l.CustomSlug), l.Domain, l.OriginalURL, strconv.FormatBool(l.IsActive),
+			l.DisabledReason, formatOptionalTime(l.ExpiresAt), formatList(l.Tags), l.PasswordHash,
+			strconv.FormatInt(l.MaxClicks, 10), strconv.FormatInt(l.ConsumedClicks, 10), strconv.Itoa(l.RedirectType),
+			strconv.Itoa(l.Version), strconv.FormatInt(l.Clicks, 10), formatTime(l.CreatedAt), l.Title,
+			utm.Source, utm.Medium, utm.Campaign, utm.Term, utm.Content, l.QueryPassthrough, formatJSON(l.Rules),
+			formatJSON(l.Variants), strconv.FormatBool(l.Protected),
+		}
+	},
+	parse: func(c cells) (Link, error) {
+		return Link{
+			ID:             c.uint("id"),
+			ShortCode:      c.str("short_code"),
+			CustomSlug:     c.bool("custom_slug"),
+			Domain:         c.str("domain"),
+			OriginalURL:    c.str("original_url"),
+			Title:          c.str("title"),
+			IsActive:       c.bool("is_active"),
+			DisabledReason: c.str("disabled_reason"),
+			ExpiresAt:      c.optionalTime("expires_at"),
+			Tags:           c.list("tags"),
+			Protected:      c.bool("protected"),
+			PasswordHash:   c.str("password_hash"),
+			MaxClicks:      c.int("max_clicks"),
+			ConsumedClicks: c.int("consumed_clicks"),
+			RedirectType:   int(c.int("redirect_type")),
+			Version:        int(c.int("version")),
+			Clicks:         c.int("clicks"),
+			CreatedAt:      c.time("created_at"),
+			UTM: optionalUTM(UTM{
+				Source:   c.str("utm_source"),
+				Medium:   c.str("utm_medium"),
+				Campaign: c.str("utm_campaign"),
+				Term:     c.str("utm_term"),
+				Content:  c.str("utm_content"),
+			}),
+			QueryPassthrough: c.str("query_passthrough"),
+			Rules:            jsonCell[Rule](&c, "rules"),
+			Variants:         jsonCell[Variant](&c, "variants"),
+		}, c.err
+	},
+}
+
+var versionTable = table[LinkVersion]{
+	columns: []string{"link_id", "version", "original_url", "expires_at", "is_active", "redirect_type", "changed_fields", "changed_at"},
+	row: func(v LinkVersion) []string {
+		return []string{
+			formatUint(v.LinkId), strconv.Itoa(v.Version), v.OriginalURL, formatOptionalTime(v.ExpiresAt),
+			strconv.FormatBool(v.IsActive), strconv.Itoa(v.RedirectType), formatList(v.ChangedFields), formatTime(v.ChangedAt),
+		}
+	},
+	parse: func(c cells) (LinkVersion, error) {
+		return LinkVersion{
+			LinkId:        c.uint("link_id"),
+			Version:       int(c.int("version")),
+			OriginalURL:   c.str("original_url"),
+			ExpiresAt:     c.optionalTime("expires_at"),
+			IsActive:      c.bool("is_active"),
+			RedirectType:  int(c.int("redirect_type")),
+			ChangedFields: c.list("changed_fields"),
+			ChangedAt:     c.time("changed_at"),
+		}, c.err
+	},
+}
+
+var analyticsTable = table[Analytics]{
+	columns: []string{"link_id", "total_clicks", "last_clicked_at"},
+	row: func(a Analytics) []string {
+		return []string{formatUint(a.LinkId), strconv.FormatInt(a.TotalClicks, 10), formatOptionalTime(a.LastClickedAt)}
+	},
+	parse: func(c cells) (Analytics, error) {
+		return Analytics{LinkId: c.uint("link_id"), TotalClicks: c.int("total_clicks"), LastClickedAt: c.optionalTime("last_clicked_at")}, c.err
+	},
+}
+
+// csvFiles are the tables of a CSV archive in the order they are written
+var csvFiles = []struct {
+	name    string
+	columns []string
+}{
+	{accountFile, accountTable.columns},
+	{apiKeysFile, apiKeyTable.columns},
+	{linksFile, linkTable.columns},
+	{versionsFile, versionTable.columns},
+	{analyticsFile, analyticsTable.columns},
+}
+
+// Indexes of csvFiles
+const (
+	fileAccount = iota
+	fileAPIKeys
+	fileLinks
+	fileVersions
+	fileAnalytics
+)
+
+// csvWriter writes the tables of a zip one after the other, as a zip entry
+// cannot be reopened once the next one started
+type csvWriter struct {
+	zw *zip.Writer
+	cw *csv.Writer
+	// next is the index of the next table to start
+	next int
+}
+
+func newCSVWriter(w io.Writer, h Header) (*csvWriter, error) {
+	zw := zip.NewWriter(w)
+	manifest, err := zw.Create(manifestFile)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.NewEncoder(manifest).Encode(h); err != nil {
+		return nil, err
+	}
+	return &csvWriter{zw: zw}, nil
+}
+
+// open moves on to the table, the tables before it are finished with the
+// records written so far
+func (w *csvWriter) open(file int) error {
+	if file < w.next-1 {
+		return fmt.Errorf("%s is finished, records of one kind must be written together", csvFiles[file].name)
+	}
+	for w.next <= file {
+		if err := w.flush(); err != nil {
+			return err
+		}
+		f, err := w.zw.Create(csvFiles[w.next].name)
+		if err != nil {
+			return err
+		}
+		w.cw = csv.NewWriter(f)
+		if err := w.cw.Write(csvFiles[w.next].columns); err != nil {
+			return err
+		}
+		w.next++
+	}
+	return nil
+}
+
+func (w *csvWriter) flush() error {
+	if w.cw == nil {
+		return nil
+	}
+	w.cw.Flush()
+	return w.cw.Error()
+}
+
+func (w *csvWriter) write(file int, row []string) error {
+	if err := w.open(file); err != nil {
+		return err
+	}
+	return w.cw.Write(row)
+}
+
+func (w *csvWriter) WriteAccount(a Account) error {
+	return w.write(fileAccount, accountTable.row(a))
+}
+
+func (w *csvWriter) WriteAPIKey(k APIKey) error {
+	return w.write(fileAPIKeys, apiKeyTable.row(k))
+}
+
+func (w *csvWriter) WriteLink(l Link) error {
+	return w.write(fileLinks, linkTable.row(l))
+}
+
+func (w *csvWriter) WriteVersion(v LinkVersion) error {
+	return w.write(fileVersions, versionTable.row(v))
+}
+
+func (w *csvWriter) WriteAnalytics(a Analytics) error {
+	return w.write(fileAnalytics, analyticsTable.row(a))
+}
+
+// Close writes the tables that got no records and finishes the zip
+func (w *csvWriter) Close() error {
+	if err := w.open(len(csvFiles) - 1); err != nil {
+		return err
+	}
+	if err := w.flush(); err != nil {
+		return err
+	}
+	return w.zw.Close()
+}
+
+func readCSV(data []byte) (*Archive, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("not a zip archive: %w", err)
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	a := &Archive{}
+	manifest, ok := files[manifestFile]
+	if !ok {
+		return nil, fmt.Errorf("the archive has no %s", manifestFile)
+	}
+	if err := readFile(manifest, func(r io.Reader) error { return json.NewDecoder(r).Decode(&a.Header) }); err != nil {
+		return nil, fmt.Errorf("%s: %w", manifestFile, err)
+	}
+	if err := checkVersion(a.Header); err != nil {
+		return nil, err
+	}
+
+	accounts, err := readTable(files, accountFile, accountTable)
+	if err != nil {
+		return nil, err
+	}
+	if len(accounts) > 0 {
+		a.Account = &accounts[0]
+	}
+	if a.APIKeys, err = readTable(files, apiKeysFile, apiKeyTable); err != nil {
+		return nil, err
+	}
+	if a.Links, err = readTable(files, linksFile, linkTable); err != nil {
+		return nil, err
+	}
+	if a.Versions, err = readTable(files, versionsFile, versionTable); err != nil {
+		return nil, err
+	}
+	if a.Analytics, err = readTable(files, analyticsFile, analyticsTable); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// readTable parses a file of the archive, a missing file has no records
+func readTable[T any](files map[string]*zip.File, name string, t table[T]) ([]T, error) {
+	f, ok := files[name]
+	if !ok {
+		return nil, nil
+	}
+
+	var records []T
+	err := readFile(f, func(r io.Reader) error {
+		cr := csv.NewReader(r)
+		header, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		index := make(map[string]int, len(header))
+		for i, column := range header {
+			index[column] = i
+		}
+
+		for row := 2; ; row++ {
+			values, err := cr.Read()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			record, err := t.parse(cells{index: index, values: values})
+			if err != nil {
+				return fmt.Errorf("row %d: %w", row, err)
+			}
+			records = append(records, record)
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return records, nil
+}
+
+func readFile(f *zip.File, read func(io.Reader) error) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return read(rc)
+}
+
+// cells reads the values of a CSV row by column name, the first malformed
+// value is kept in err
+type cells struct {
+	index  map[string]int
+	values []string
+	err    error
+}
+
+func (c *cells) str(column string) string {
+	i, ok := c.index[column]
+	if !ok || i >= len(c.values) {
+		return ""
+	}
+	return c.values[i]
+}
+
+func (c *cells) fail(column string, err error) {
+	if c.err == nil {
+		c.err = fmt.Errorf("column %s: %w", column, err)
+	}
+}
+
+func (c *cells) int(column string) int64 {
+	value := c.str(column)
+	if value == "" {
+		return 0
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		c.fail(column, err)
+	}
+	return n
+}
+
+func (c *cells) uint(column string) uint {
+	return uint(c.int(column))
+}
+
+func (c *cells) bool(column string) bool {
+	value := c.str(column)
+	if value == "" {
+		return false
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		c.fail(column, err)
+	}
+	return b
+}
+
+func (c *cells) time(column string) time.Time {
+	if t := c.optionalTime(column); t != nil {
+		return *t
+	}
+	return time.Time{}
+}
+
+func (c *cells) optionalTime(column string) *time.Time {
+	value := c.str(column)
+	if value == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		c.fail(column, err)
+		return nil
+	}
+	return &t
+}
+
+func (c *cells) list(column string) []string {
+	value := c.str(column)
+	if value == "" {
+		return nil
+	}
+	var items []string
+	if err := json.Unmarshal([]byte(value), &items); err != nil {
+		c.fail(column, err)
+	}
+	return items
+}
+
+// jsonCell decodes a cell holding a JSON array of objects like the rules of a link
+func jsonCell[T any](c *cells, column string) []T {
+	value := c.str(column)
+	if value == "" {
+		return nil
+	}
+	var items []T
+	if err := json.Unmarshal([]byte(value), &items); err != nil {
+		c.fail(column, err)
+	}
+	return items
+}
+
+func formatUint(n uint) string {
+	return strconv.FormatUint(uint64(n), 10)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return formatTime(*t)
+}
+
+func optionalUTM(utm UTM) *UTM {
+	if utm == (UTM{}) {
+		return nil
+	}
+	return &utm
+}
+
+func formatJSON[T any](items []T) string {
+	if len(items) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(items)
+	return string(data)
+}
+
+func formatList(items []string) string {
+	if len(items) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(items)
+	return string(data)
+}
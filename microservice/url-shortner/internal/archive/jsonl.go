@@ -0,0 +1,124 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Record types of a JSON lines archive, the header comes first
+const (
+	recordHeader    = "header"
+	recordAccount   = "account"
+	recordAPIKey    = "api_key"
+	recordLink      = "link"
+	recordVersion   = "link_version"
+	recordAnalytics = "analytics"
+)
+
+// maxLineBytes bounds a single record of a JSON lines archive
+const maxLineBytes = 1 << 20
+
+type line struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+type jsonlWriter struct {
+	bw  *bufio.Writer
+	enc *json.Encoder
+}
+
+func newJSONLWriter(w io.Writer, h Header) (*jsonlWriter, error) {
+	bw := bufio.NewWriter(w)
+	jw := &jsonlWriter{bw: bw, enc: json.NewEncoder(bw)}
+	if err := jw.write(recordHeader, h); err != nil {
+		return nil, err
+	}
+	return jw, nil
+}
+
+func (w *jsonlWriter) write(kind string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return w.enc.Encode(line{Type: kind, Data: data})
+}
+
+func (w *jsonlWriter) WriteAccount(a Account) error     { return w.write(recordAccount, a) }
+func (w *jsonlWriter) WriteAPIKey(k APIKey) error       { return w.write(recordAPIKey, k) }
+func (w *jsonlWriter) WriteLink(l Link) error           { return w.write(recordLink, l) }
+func (w *jsonlWriter) WriteVersion(v LinkVersion) error { return w.write(recordVersion, v) }
+func (w *jsonlWriter) WriteAnalytics(a Analytics) error { return w.write(recordAnalytics, a) }
+func (w *jsonlWriter) Close() error                     { return w.bw.Flush() }
+
+func readJSONL(data []byte) (*Archive, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), maxLineBytes)
+
+	a := &Archive{}
+	n := 0
+	for scanner.Scan() {
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		n++
+
+		var l line
+		if err := json.Unmarshal(raw, &l); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		if n == 1 {
+			if l.Type != recordHeader {
+				return nil, fmt.Errorf("line 1: expected the archive header, got %q", l.Type)
+			}
+			if err := json.Unmarshal(l.Data, &a.Header); err != nil {
+				return nil, fmt.Errorf("line 1: %w", err)
+			}
+			if err := checkVersion(a.Header); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var err error
+		switch l.Type {
+		case recordAccount:
+			a.Account = &Account{}
+			err = json.Unmarshal(l.Data, a.Account)
+		case recordAPIKey:
+			err = appendRecord(l.Data, &a.APIKeys)
+		case recordLink:
+			err = appendRecord(l.Data, &a.Links)
+		case recordVersion:
+			err = appendRecord(l.Data, &a.Versions)
+		case recordAnalytics:
+			err = appendRecord(l.Data, &a.Analytics)
+		default:
+			err = fmt.Errorf("unknown record type %q", l.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("the archive is empty")
+	}
+	return a, nil
+}
+
+func appendRecord[T any](data json.RawMessage, records *[]T) error {
+	var record T
+	if err := json.Unmarshal(data, &record); err != nil {
+		return err
+	}
+	*records = append(*records, record)
+	return nil
+}
@@ -0,0 +1,155 @@
+package audit
+
+import (
+	"coding2fun.in/url-shortner/internal/domain"
+	"coding2fun.in/url-shortner/internal/log"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go.uber.org/zap"
+	"reflect"
+	"time"
+)
+
+const verifyBatchSize = 1000
+
+// Actor is who performs the actions recorded for a request
+type Actor struct {
+	Kind      string
+	APIKeyId  uint
+	IP        string
+	RequestID string
+}
+
+type actorKey struct{}
+
+// WithActor attaches the actor to the context of a request or job
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor of the context, actions without one are the system's
+func ActorFrom(ctx context.Context) Actor {
+	if actor, ok := ctx.Value(actorKey{}).(Actor); ok {
+		return actor
+	}
+	return Actor{Kind: domain.ActorSystem}
+}
+
+// Entry is an action to record. Before and After are marshaled to JSON objects.
+type Entry struct {
+	AccountId      uint
+	OrganizationId uint
+	Action         string
+	Resource       string
+	Before         any
+	After          any
+}
+
+// Logger appends the sensitive actions to the hash chained audit log
+type Logger struct {
+	events domain.AuditRepository
+}
+
+func NewLogger(events domain.AuditRepository) *Logger {
+	return &Logger{events: events}
+}
+
+// Record appends the entries on behalf of the context's actor. The action has
+// already happened at this point, a failed append is logged and not returned.
+func (l *Logger) Record(ctx context.Context, entries ...Entry) {
+	actor := ActorFrom(ctx)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	events := make([]*domain.AuditEvent, 0, len(entries))
+	for _, entry := range entries {
+		events = append(events, &domain.AuditEvent{
+			AccountId:      entry.AccountId,
+			OrganizationId: entry.OrganizationId,
+			Actor:          actor.Kind,
+			APIKeyId:       actor.APIKeyId,
+			IP:             actor.IP,
+			RequestID:      actor.RequestID,
+			Action:         entry.Action,
+			Resource:       entry.Resource,
+			Before:         marshal(entry.Before),
+			After:          marshal(entry.After),
+			CreatedAt:      now,
+		})
+	}
+
+	// A cancelled request must not lose the record of what it already did
+	if err := l.events.Append(context.WithoutCancel(ctx), events); err != nil {
+		for _, event := range events {
+			log.Error("Failed to record audit event",
+				zap.String("action", event.Action),
+				zap.String("resource", event.Resource),
+				zap.Uint("accountId", event.AccountId),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+func (l *Logger) List(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEvent, error) {
+	return l.events.List(ctx, filter)
+}
+
+// Verify walks the whole chain and returns the number of events checked, the
+// error names the first event whose link or content does not match
+func (l *Logger) Verify(ctx context.Context) (int, error) {
+	checked := 0
+	var prev string
+	var afterId uint
+	for {
+		events, err := l.events.Scan(ctx, afterId, verifyBatchSize)
+		if err != nil {
+			return checked, err
+		}
+		for _, event := range events {
+			if event.PrevHash != prev {
+				return checked, fmt.Errorf("audit event %d does not link to its predecessor", event.ID)
+			}
+			if event.ComputeHash() != event.Hash {
+				return checked, fmt.Errorf("audit event %d does not match its hash", event.ID)
+			}
+			prev = event.Hash
+			afterId = event.ID
+			checked++
+		}
+		if len(events) < verifyBatchSize {
+			return checked, nil
+		}
+	}
+}
+
+// Diff reduces two snapshots of the same struct to the fields that differ
+func Diff(before, after any) (map[string]any, map[string]any) {
+	old, updated := fields(before), fields(after)
+	for name, value := range old {
+		if reflect.DeepEqual(value, updated[name]) {
+			delete(old, name)
+			delete(updated, name)
+		}
+	}
+	return old, updated
+}
+
+func fields(v any) map[string]any {
+	m := make(map[string]any)
+	if raw, err := json.Marshal(v); err == nil {
+		_ = json.Unmarshal(raw, &m)
+	}
+	return m
+}
+
+func marshal(v any) string {
+	if v == nil {
+		return ""
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(raw)
+}
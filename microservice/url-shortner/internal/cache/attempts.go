@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// Limit caps the attempts counted against a key within a window
+type Limit struct {
+	Key string
+	Max int
+}
+
+type attemptWindow struct {
+	attempts int
+	resetAt  time.Time
+}
+
+// AttemptLimiter counts attempts per key in fixed windows, a key that used up
+// its limit waits for its window to end. Counts are kept per instance.
+type AttemptLimiter struct {
+	window     time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]attemptWindow
+}
+
+func NewAttemptLimiter(window time.Duration, maxEntries int) *AttemptLimiter {
+	return &AttemptLimiter{
+		window:     window,
+		maxEntries: maxEntries,
+		entries:    make(map[string]attemptWindow),
+	}
+}
+
+// Take counts an attempt against every limit unless one of them is used up.
+// It returns how long until the used up limits allow another attempt, 0 when
+// the attempt was counted.
+func (l *AttemptLimiter) Take(limits ...Limit) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	var wait time.Duration
+	for _, limit := range limits {
+		if entry, ok := l.entries[limit.Key]; ok && now.Before(entry.resetAt) && entry.attempts >= limit.Max {
+			wait = max(wait, entry.resetAt.Sub(now))
+		}
+	}
+	if wait > 0 {
+		return wait
+	}
+
+	for _, limit := range limits {
+		entry, ok := l.entries[limit.Key]
+		if !ok || !now.Before(entry.resetAt) {
+			if !ok && len(l.entries) >= l.maxEntries {
+				l.evict(now)
+			}
+			entry = attemptWindow{resetAt: now.Add(l.window)}
+		}
+		entry.attempts++
+		l.entries[limit.Key] = entry
+	}
+	return 0
+}
+
+// Return gives back an attempt taken with the same limits, so only failed
+// attempts use them up
+func (l *AttemptLimiter) Return(limits ...Limit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, limit := range limits {
+		if entry, ok := l.entries[limit.Key]; ok && entry.attempts > 0 {
+			entry.attempts--
+			l.entries[limit.Key] = entry
+		}
+	}
+}
+
+// evict removes ended windows, or an arbitrary one when none has ended
+func (l *AttemptLimiter) evict(now time.Time) {
+	for k, entry := range l.entries {
+		if !now.Before(entry.resetAt) {
+			delete(l.entries, k)
+		}
+	}
+	if len(l.entries) < l.maxEntries {
+		return
+	}
+	for k := range l.entries {
+		delete(l.entries, k)
+		return
+	}
+}
@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAttemptLimiterBlocksUsedUpLimits(t *testing.T) {
+	limiter := NewAttemptLimiter(time.Minute, 100)
+	link := Limit{Key: "link:1", Max: 3}
+	client := Limit{Key: "ip:192.0.2.1", Max: 2}
+
+	for i := range 2 {
+		if wait := limiter.Take(link, client); wait != 0 {
+			t.Fatalf("attempt %d waits %s, want it counted", i+1, wait)
+		}
+	}
+	wait := limiter.Take(link, client)
+	if wait <= 0 || wait > time.Minute {
+		t.Fatalf("third attempt of the client waits %s, want up to a minute", wait)
+	}
+
+	// The blocked attempt was not counted against the link
+	if wait := limiter.Take(link, Limit{Key: "ip:192.0.2.2", Max: 2}); wait != 0 {
+		t.Fatalf("another client waits %s, want its attempt counted", wait)
+	}
+	if wait := limiter.Take(link, Limit{Key: "ip:192.0.2.3", Max: 2}); wait == 0 {
+		t.Fatal("the link allowed a fourth attempt, want it blocked")
+	}
+}
+
+func TestAttemptLimiterReturnedAttemptsAreFree(t *testing.T) {
+	limiter := NewAttemptLimiter(time.Minute, 100)
+	link := Limit{Key: "link:1", Max: 1}
+
+	for i := range 5 {
+		if wait := limiter.Take(link); wait != 0 {
+			t.Fatalf("attempt %d waits %s, want returned attempts not to count", i+1, wait)
+		}
+		limiter.Return(link)
+	}
+}
+
+func TestAttemptLimiterWindowEnds(t *testing.T) {
+	limiter := NewAttemptLimiter(20*time.Millisecond, 100)
+	link := Limit{Key: "link:1", Max: 1}
+
+	limiter.Take(link)
+	if wait := limiter.Take(link); wait == 0 {
+		t.Fatal("second attempt was counted, want it blocked")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if wait := limiter.Take(link); wait != 0 {
+		t.Fatalf("attempt after the window waits %s, want it counted", wait)
+	}
+}
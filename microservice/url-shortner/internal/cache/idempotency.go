@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrRequestInFlight is returned while the first request with a key is still running
+	ErrRequestInFlight = errors.New("a request with this idempotency key is in progress")
+	// ErrKeyReused is returned when a key is sent again with a different request
+	ErrKeyReused = errors.New("idempotency key was used for a different request")
+)
+
+// StoredResponse is the response replayed for a repeated idempotency key
+type StoredResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+type idempotencyEntry struct {
+	fingerprint string
+	// response is nil while the first request is running
+	response  *StoredResponse
+	expiresAt time.Time
+}
+
+// IdempotencyStore remembers the responses of requests sent with an
+// idempotency key so retries are answered without running them again. Keys
+// are kept per instance for the TTL.
+type IdempotencyStore struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]idempotencyEntry
+}
+
+func NewIdempotencyStore(ttl time.Duration, maxEntries int) *IdempotencyStore {
+	return &IdempotencyStore{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]idempotencyEntry),
+	}
+}
+
+// Enabled reports whether responses are kept at all
+func (s *IdempotencyStore) Enabled() bool {
+	return s != nil && s.ttl > 0
+}
+
+// Begin claims a key for a request identified by its fingerprint. It returns
+// the stored response when the request was answered already, nil when the
+// caller has to run it and then Complete or Release the key.
+func (s *IdempotencyStore) Begin(key, fingerprint string) (*StoredResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if entry, ok := s.entries[key]; ok && now.Before(entry.expiresAt) {
+		switch {
+		case entry.fingerprint != fingerprint:
+			return nil, ErrKeyReused
+		case entry.response == nil:
+			return nil, ErrRequestInFlight
+		default:
+			return entry.response, nil
+		}
+	}
+
+	if _, ok := s.entries[key]; !ok && len(s.entries) >= s.maxEntries {
+		s.evict(now)
+	}
+	s.entries[key] = idempotencyEntry{fingerprint: fingerprint, expiresAt: now.Add(s.ttl)}
+	return nil, nil
+}
+
+// Complete stores the response of a claimed key
+func (s *IdempotencyStore) Complete(key string, response StoredResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.entries[key]; ok && entry.response == nil {
+		entry.response = &response
+		entry.expiresAt = time.Now().Add(s.ttl)
+		s.entries[key] = entry
+	}
+}
+
+// Release drops a claimed key whose request failed, so it can be retried
+func (s *IdempotencyStore) Release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.entries[key]; ok && entry.response == nil {
+		delete(s.entries, key)
+	}
+}
+
+// evict removes expired entries, or an arbitrary answered one when none has expired
+func (s *IdempotencyStore) evict(now time.Time) {
+	for k, entry := range s.entries {
+		if now.After(entry.expiresAt) {
+			delete(s.entries, k)
+		}
+	}
+	if len(s.entries) < s.maxEntries {
+		return
+	}
+	for k, entry := range s.entries {
+		if entry.response != nil {
+			delete(s.entries, k)
+			return
+		}
+	}
+}
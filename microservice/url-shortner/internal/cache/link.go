@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"coding2fun.in/url-shortner/internal/domain"
+	"coding2fun.in/url-shortner/internal/events"
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type linkEntry struct {
+	link      domain.ShortUrl
+	expiresAt time.Time
+}
+
+// LinkCache keeps resolved links in memory for the redirect hot path. Entries
+// live for at most the TTL, changes made by other instances are picked up when
+// the entry expires.
+type LinkCache struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.RWMutex
+	entries map[string]linkEntry
+}
+
+func NewLinkCache(ttl time.Duration, maxEntries int) *LinkCache {
+	return &LinkCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]linkEntry),
+	}
+}
+
+// key identifies a short code on a domain, codes are only unique per domain
+func key(domainId uint, code string) string {
+	return strconv.FormatUint(uint64(domainId), 10) + "/" + code
+}
+
+// Get returns a copy of the cached link of a short code on a domain
+func (c *LinkCache) Get(domainId uint, code string) (*domain.ShortUrl, bool) {
+	if c.ttl <= 0 {
+		return nil, false
+	}
+
+	c.mu.RLock()
+	entry, ok := c.entries[key(domainId, code)]
+	c.mu.RUnlock()
+
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+	link := entry.link
+	return &link, true
+}
+
+func (c *LinkCache) Set(link *domain.ShortUrl) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	k := key(link.DomainId, link.ShortCode)
+	if _, ok := c.entries[k]; !ok && len(c.entries) >= c.maxEntries {
+		c.evict()
+	}
+	c.entries[k] = linkEntry{link: *link, expiresAt: time.Now().Add(c.ttl)}
+}
+
+// Invalidate drops the cached link of a short code on a domain
+func (c *LinkCache) Invalidate(domainId uint, code string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, key(domainId, code))
+}
+
+// Subscribe drops links from the cache when events change them, which covers
+// changes made outside the request path such as by the admin command. Events
+// are relayed by one instance, the others still wait for the TTL.
+func (c *LinkCache) Subscribe(bus *events.Bus) {
+	bus.Subscribe("link-cache", c.handleEvent, domain.EventLinkUpdated, domain.EventLinkDeactivated, domain.EventLinkExpired, domain.EventLinkReassigned)
+}
+
+func (c *LinkCache) handleEvent(_ context.Context, e events.Envelope) error {
+	switch event := e.Event.(type) {
+	case domain.LinkUpdated:
+		c.Invalidate(event.Link.DomainId, event.Link.ShortCode)
+	case domain.LinkDeactivated:
+		c.Invalidate(event.Link.DomainId, event.Link.ShortCode)
+	case domain.LinkExpired:
+		c.Invalidate(event.Link.DomainId, event.Link.ShortCode)
+	case domain.LinkReassigned:
+		c.Invalidate(event.Link.DomainId, event.Link.ShortCode)
+	}
+	return nil
+}
+
+// evict removes expired entries, or an arbitrary one when none has expired
+func (c *LinkCache) evict() {
+	now := time.Now()
+	for k, entry := range c.entries {
+		if now.After(entry.expiresAt) {
+			delete(c.entries, k)
+		}
+	}
+	if len(c.entries) < c.maxEntries {
+		return
+	}
+	for k := range c.entries {
+		delete(c.entries, k)
+		return
+	}
+}
@@ -3,16 +3,35 @@ package config
 import (
 	"errors"
 	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
 
 	"gopkg.in/ini.v1"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
+	Server      ServerConfig
+	Database    DatabaseConfig
+	Analytics   AnalyticsConfig
+	Links       LinksConfig
+	Cache       CacheConfig
+	Jobs        JobsConfig
+	Threat      ThreatConfig
+	GeoIP       GeoIPConfig
+	QR          QRConfig
+	APIKeys     APIKeysConfig
+	Idempotency IdempotencyConfig
+	Unlock      UnlockConfig
+	Webhooks    WebhooksConfig
+	Events      EventsConfig
 }
 
 type DatabaseConfig struct {
+	// Driver is postgres or sqlite, sqlite keeps the database in the file at Path
+	Driver   string
+	Path     string
 	Host     string
 	Port     string
 	User     string
@@ -21,17 +40,186 @@ type DatabaseConfig struct {
 	Schema   string
 	SSLMode  string
 	Timezone string
+
+	// Connection pool tuning
+	MaxOpenConns     int
+	MaxIdleConns     int
+	ConnMaxIdleTime  time.Duration
+	ConnMaxLifetime  time.Duration
+	StatementTimeout time.Duration
+	// LogQueries prints every statement
+	LogQueries bool
+
+	// Startup retry policy, the first connect is retried with exponential
+	// backoff and jitter until ConnectDeadline elapses
+	ConnectDeadline   time.Duration
+	RetryInitialDelay time.Duration
+	RetryMaxDelay     time.Duration
+
+	// Read replicas serve redirect lookups and analytics reads. A key written
+	// within ReadYourWritesWindow is read from the primary instead.
+	Replicas              []string
+	ReplicaHealthInterval time.Duration
+	ReadYourWritesWindow  time.Duration
+}
+
+// SQLiteURL enforces foreign keys and waits for the single writer instead of failing
+func (d *DatabaseConfig) SQLiteURL() string {
+	return d.Path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
 }
 
 func (d *DatabaseConfig) ConnectionURL() string {
-	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s search_path=%s port=%s sslmode=%s TimeZone=%s",
+	url := fmt.Sprintf("host=%s user=%s password=%s dbname=%s search_path=%s port=%s sslmode=%s TimeZone=%s",
 		d.Host, d.User, d.Password, d.Name, d.Schema, d.Port, d.SSLMode, d.Timezone)
+	if d.StatementTimeout > 0 {
+		url += fmt.Sprintf(" statement_timeout=%d", d.StatementTimeout.Milliseconds())
+	}
+	return url
+}
+
+type AnalyticsConfig struct {
+	// Clicks are buffered in memory and written to the primary in batches
+	FlushInterval time.Duration
+	MaxPending    int
+}
+
+type LinksConfig struct {
+	// BaseURL is the public address short codes are appended to
+	BaseURL    string
+	CodeLength int
+
+	// Destination safety checks
+	AllowedSchemes       []string
+	BlockPrivateNetworks bool
+	ResolveTimeout       time.Duration
+	// BlockedHosts are rejected as destinations in addition to the BaseURL host
+	BlockedHosts []string
+
+	// Bulk requests up to BulkSyncLimit rows are processed inline, larger
+	// ones run as background jobs. So do requests whose destination lookups
+	// could exceed BulkSyncBudget, each row may take up to ResolveTimeout.
+	BulkSyncLimit  int
+	BulkSyncBudget time.Duration
+	BulkMaxRows    int
+	BulkBatchSize  int
+	BulkWorkers    int
+}
+
+type CacheConfig struct {
+	// LinkTTL bounds how long a resolved link is served from memory, 0 disables the cache
+	LinkTTL        time.Duration
+	LinkMaxEntries int
+	// DomainMaxEntries bounds the request hosts remembered with the domain
+	// serving them, hosts without one included
+	DomainMaxEntries int
+}
+
+type JobsConfig struct {
+	Enabled bool
+	// BatchSize caps the rows a job touches per statement
+	BatchSize      int
+	ExpiryInterval time.Duration
+	PurgeInterval  time.Duration
+	// Soft deleted rows older than PurgeRetention are removed for good
+	PurgeRetention time.Duration
+}
+
+type ThreatConfig struct {
+	// HashLists hold hex encoded SHA-256 hashes or hash prefixes of url
+	// expressions, DomainLists one domain per line
+	HashLists      []string
+	DomainLists    []string
+	ReloadInterval time.Duration
+}
+
+type GeoIPConfig struct {
+	// Database is a MaxMind country database, redirect rules on countries
+	// never match without one
+	Database       string
+	ReloadInterval time.Duration
+}
+
+type QRConfig struct {
+	// LogoPath is a PNG or JPEG drawn in the center of codes that ask for a logo
+	LogoPath string
+	MaxSize  int
+}
+
+type APIKeysConfig struct {
+	// A rotated key keeps working for RotationOverlap unless the rotation asks
+	// for another window, which may not exceed MaxRotationOverlap
+	RotationOverlap    time.Duration
+	MaxRotationOverlap time.Duration
+}
+
+type IdempotencyConfig struct {
+	// Responses to requests sent with an Idempotency-Key are replayed for TTL, 0 disables replays
+	TTL        time.Duration
+	MaxEntries int
+}
+
+type UnlockConfig struct {
+	// Wrong passwords of protected links are limited per link and per client
+	// address, a limit used up within Window blocks further attempts until
+	// the window ends
+	Window       time.Duration
+	LinkAttempts int
+	IPAttempts   int
+	MaxEntries   int
+}
+
+type WebhooksConfig struct {
+	// Due deliveries are sent every DeliveryInterval, at most BatchSize per run
+	// over Workers concurrent requests
+	DeliveryInterval time.Duration
+	BatchSize        int
+	Workers          int
+	Timeout          time.Duration
+	// A failed delivery is retried after BackoffBase, doubling up to BackoffMax,
+	// and is dead once MaxAttempts requests failed
+	MaxAttempts int
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// ClickMilestones are the click counts announced by link.click_milestone events
+	ClickMilestones []int64
+	// Finished deliveries are purged with their attempts after LogRetention
+	LogRetention time.Duration
+}
+
+type EventsConfig struct {
+	// The outbox is relayed every RelayInterval, BatchSize events per claim
+	RelayInterval time.Duration
+	BatchSize     int
+	// An event a subscriber fails on is retried after BackoffBase, doubling up
+	// to BackoffMax, and is failed once MaxAttempts dispatches failed
+	MaxAttempts int
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// Dispatched events are purged after Retention
+	Retention time.Duration
+	// NATSURL publishes every event to a NATS server under SubjectPrefix, an
+	// empty url publishes nothing. With NATSEmbedded the service runs the
+	// server itself, listening on NATSListen.
+	NATSURL       string
+	NATSEmbedded  bool
+	NATSListen    string
+	SubjectPrefix string
 }
 
 type ServerConfig struct {
 	Port     string
 	Mode     string
 	LogLevel string
+	// ValidateAPI checks /v1 traffic against the OpenAPI spec. Violating
+	// requests and responses fail in debug mode and are only logged in release.
+	ValidateAPI bool
+	// GRPCPort serves the gRPC API, empty leaves it off
+	GRPCPort string
+	// GRPCReflection lets clients like grpcurl list the gRPC services
+	GRPCReflection bool
+	// TrustedProxies are the addresses and ranges whose X-Forwarded-For and
+	// X-Real-IP headers name the client, none trusts only the peer address
+	TrustedProxies []string
 }
 
 func Load(fileName string) (*Config, error) {
@@ -47,10 +235,19 @@ func Load(fileName string) (*Config, error) {
 		Port:     serverSection.Key("port").MustString("8080"),
 		Mode:     serverSection.Key("mode").MustString("debug"),
 		LogLevel: serverSection.Key("logLevel").MustString("info"),
+
+		ValidateAPI: serverSection.Key("validate_api").MustBool(true),
+
+		GRPCPort:       serverSection.Key("grpc_port").MustString(""),
+		GRPCReflection: serverSection.Key("grpc_reflection").MustBool(true),
+
+		TrustedProxies: splitList(serverSection.Key("trusted_proxies").String(), ","),
 	}
 
 	dbSection := cfg.Section("database")
 	config.Database = DatabaseConfig{
+		Driver:   dbSection.Key("driver").MustString("postgres"),
+		Path:     dbSection.Key("path").MustString("url-shortner.db"),
 		Host:     dbSection.Key("host").MustString("localhost"),
 		Port:     dbSection.Key("port").MustString("5432"),
 		User:     dbSection.Key("user").MustString("postgres"),
@@ -59,7 +256,237 @@ func Load(fileName string) (*Config, error) {
 		Schema:   dbSection.Key("schema").MustString("public"),
 		SSLMode:  dbSection.Key("sslmode").MustString("disable"),
 		Timezone: dbSection.Key("timezone").MustString("UTC"),
+
+		MaxOpenConns:     dbSection.Key("max_open_conns").MustInt(64),
+		MaxIdleConns:     dbSection.Key("max_idle_conns").MustInt(16),
+		ConnMaxIdleTime:  dbSection.Key("conn_max_idle_time").MustDuration(5 * time.Minute),
+		ConnMaxLifetime:  dbSection.Key("conn_max_lifetime").MustDuration(time.Hour),
+		StatementTimeout: dbSection.Key("statement_timeout").MustDuration(0),
+		LogQueries:       dbSection.Key("log_queries").MustBool(true),
+
+		ConnectDeadline:   dbSection.Key("connect_deadline").MustDuration(time.Minute),
+		RetryInitialDelay: dbSection.Key("retry_initial_delay").MustDuration(500 * time.Millisecond),
+		RetryMaxDelay:     dbSection.Key("retry_max_delay").MustDuration(10 * time.Second),
+
+		Replicas:              splitList(dbSection.Key("replicas").String(), ","),
+		ReplicaHealthInterval: dbSection.Key("replica_health_interval").MustDuration(10 * time.Second),
+		ReadYourWritesWindow:  dbSection.Key("read_your_writes_window").MustDuration(5 * time.Second),
 	}
 
+	analyticsSection := cfg.Section("analytics")
+	config.Analytics = AnalyticsConfig{
+		FlushInterval: analyticsSection.Key("flush_interval").MustDuration(5 * time.Second),
+		MaxPending:    analyticsSection.Key("max_pending").MustInt(10000),
+	}
+
+	linksSection := cfg.Section("links")
+	config.Links = LinksConfig{
+		BaseURL:    strings.TrimRight(linksSection.Key("base_url").MustString("http://localhost:8080"), "/"),
+		CodeLength: linksSection.Key("code_length").MustInt(7),
+
+		AllowedSchemes:       splitList(linksSection.Key("allowed_schemes").MustString("http,https"), ","),
+		BlockPrivateNetworks: linksSection.Key("block_private_networks").MustBool(true),
+		ResolveTimeout:       linksSection.Key("resolve_timeout").MustDuration(2 * time.Second),
+		BlockedHosts:         splitList(linksSection.Key("blocked_hosts").String(), ","),
+
+		BulkSyncLimit:  linksSection.Key("bulk_sync_limit").MustInt(100),
+		BulkSyncBudget: linksSection.Key("bulk_sync_budget").MustDuration(10 * time.Second),
+		BulkMaxRows:    linksSection.Key("bulk_max_rows").MustInt(10000),
+		BulkBatchSize:  linksSection.Key("bulk_batch_size").MustInt(500),
+		BulkWorkers:    linksSection.Key("bulk_workers").MustInt(2),
+	}
+
+	cacheSection := cfg.Section("cache")
+	config.Cache = CacheConfig{
+		LinkTTL:          cacheSection.Key("link_ttl").MustDuration(30 * time.Second),
+		LinkMaxEntries:   cacheSection.Key("link_max_entries").MustInt(100000),
+		DomainMaxEntries: cacheSection.Key("domain_max_entries").MustInt(10000),
+	}
+
+	jobsSection := cfg.Section("jobs")
+	config.Jobs = JobsConfig{
+		Enabled:        jobsSection.Key("enabled").MustBool(true),
+		BatchSize:      jobsSection.Key("batch_size").MustInt(500),
+		ExpiryInterval: jobsSection.Key("expiry_interval").MustDuration(time.Minute),
+		PurgeInterval:  jobsSection.Key("purge_interval").MustDuration(time.Hour),
+		PurgeRetention: jobsSection.Key("purge_retention").MustDuration(30 * 24 * time.Hour),
+	}
+
+	threatSection := cfg.Section("threat")
+	config.Threat = ThreatConfig{
+		HashLists:      splitList(threatSection.Key("hash_lists").String(), ","),
+		DomainLists:    splitList(threatSection.Key("domain_lists").String(), ","),
+		ReloadInterval: threatSection.Key("reload_interval").MustDuration(30 * time.Second),
+	}
+
+	geoIPSection := cfg.Section("geoip")
+	config.GeoIP = GeoIPConfig{
+		Database:       geoIPSection.Key("database").String(),
+		ReloadInterval: geoIPSection.Key("reload_interval").MustDuration(time.Hour),
+	}
+
+	qrSection := cfg.Section("qr")
+	config.QR = QRConfig{
+		LogoPath: qrSection.Key("logo_path").String(),
+		MaxSize:  qrSection.Key("max_size").MustInt(2048),
+	}
+
+	apiKeysSection := cfg.Section("api_keys")
+	config.APIKeys = APIKeysConfig{
+		RotationOverlap:    apiKeysSection.Key("rotation_overlap").MustDuration(24 * time.Hour),
+		MaxRotationOverlap: apiKeysSection.Key("max_rotation_overlap").MustDuration(7 * 24 * time.Hour),
+	}
+
+	idempotencySection := cfg.Section("idempotency")
+	config.Idempotency = IdempotencyConfig{
+		TTL:        idempotencySection.Key("ttl").MustDuration(24 * time.Hour),
+		MaxEntries: idempotencySection.Key("max_entries").MustInt(100000),
+	}
+
+	unlockSection := cfg.Section("unlock")
+	config.Unlock = UnlockConfig{
+		Window:       unlockSection.Key("window").MustDuration(15 * time.Minute),
+		LinkAttempts: unlockSection.Key("link_attempts").MustInt(50),
+		IPAttempts:   unlockSection.Key("ip_attempts").MustInt(10),
+		MaxEntries:   unlockSection.Key("max_entries").MustInt(100000),
+	}
+
+	webhooksSection := cfg.Section("webhooks")
+	config.Webhooks = WebhooksConfig{
+		DeliveryInterval: webhooksSection.Key("delivery_interval").MustDuration(5 * time.Second),
+		BatchSize:        webhooksSection.Key("batch_size").MustInt(100),
+		Workers:          webhooksSection.Key("workers").MustInt(4),
+		Timeout:          webhooksSection.Key("timeout").MustDuration(10 * time.Second),
+		MaxAttempts:      webhooksSection.Key("max_attempts").MustInt(8),
+		BackoffBase:      webhooksSection.Key("backoff_base").MustDuration(30 * time.Second),
+		BackoffMax:       webhooksSection.Key("backoff_max").MustDuration(6 * time.Hour),
+		LogRetention:     webhooksSection.Key("log_retention").MustDuration(30 * 24 * time.Hour),
+	}
+	milestones, err := parseMilestones(webhooksSection.Key("click_milestones").MustString("100,1000,10000,100000,1000000"))
+	if err != nil {
+		return nil, err
+	}
+	config.Webhooks.ClickMilestones = milestones
+
+	eventsSection := cfg.Section("events")
+	config.Events = EventsConfig{
+		RelayInterval: eventsSection.Key("relay_interval").MustDuration(time.Second),
+		BatchSize:     eventsSection.Key("batch_size").MustInt(200),
+		MaxAttempts:   eventsSection.Key("max_attempts").MustInt(10),
+		BackoffBase:   eventsSection.Key("backoff_base").MustDuration(5 * time.Second),
+		BackoffMax:    eventsSection.Key("backoff_max").MustDuration(30 * time.Minute),
+		Retention:     eventsSection.Key("retention").MustDuration(7 * 24 * time.Hour),
+		NATSURL:       eventsSection.Key("nats_url").String(),
+		NATSEmbedded:  eventsSection.Key("nats_embedded").MustBool(false),
+		NATSListen:    eventsSection.Key("nats_listen").MustString("127.0.0.1:4222"),
+		SubjectPrefix: eventsSection.Key("subject_prefix").MustString("shortener"),
+	}
+
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
 	return config, nil
 }
+
+// validate rejects settings that would stall or crash the service, a zero
+// interval panics a ticker, a zero batch size never finishes a batch and a
+// zero timeout fails every lookup, an unparsable proxy would fail every request
+func (c *Config) validate() error {
+	durations := []struct {
+		key   string
+		value time.Duration
+	}{
+		{"links resolve_timeout", c.Links.ResolveTimeout},
+		{"links bulk_sync_budget", c.Links.BulkSyncBudget},
+		{"analytics flush_interval", c.Analytics.FlushInterval},
+		{"jobs expiry_interval", c.Jobs.ExpiryInterval},
+		{"jobs purge_interval", c.Jobs.PurgeInterval},
+		{"threat reload_interval", c.Threat.ReloadInterval},
+		{"geoip reload_interval", c.GeoIP.ReloadInterval},
+		{"webhooks delivery_interval", c.Webhooks.DeliveryInterval},
+		{"webhooks timeout", c.Webhooks.Timeout},
+		{"webhooks backoff_base", c.Webhooks.BackoffBase},
+		{"webhooks backoff_max", c.Webhooks.BackoffMax},
+		{"webhooks log_retention", c.Webhooks.LogRetention},
+		{"events relay_interval", c.Events.RelayInterval},
+		{"events backoff_base", c.Events.BackoffBase},
+		{"events backoff_max", c.Events.BackoffMax},
+		{"events retention", c.Events.Retention},
+		{"unlock window", c.Unlock.Window},
+		{"database connect_deadline", c.Database.ConnectDeadline},
+		{"database retry_initial_delay", c.Database.RetryInitialDelay},
+		{"database retry_max_delay", c.Database.RetryMaxDelay},
+	}
+	for _, duration := range durations {
+		if duration.value <= 0 {
+			return fmt.Errorf("invalid %s %s: must be positive", duration.key, duration.value)
+		}
+	}
+
+	// A backoff doubles from its first delay up to the maximum, which may not be lower
+	backoffs := []struct {
+		key, lowerKey string
+		value, lower  time.Duration
+	}{
+		{"database retry_max_delay", "retry_initial_delay", c.Database.RetryMaxDelay, c.Database.RetryInitialDelay},
+		{"webhooks backoff_max", "backoff_base", c.Webhooks.BackoffMax, c.Webhooks.BackoffBase},
+		{"events backoff_max", "backoff_base", c.Events.BackoffMax, c.Events.BackoffBase},
+	}
+	for _, backoff := range backoffs {
+		if backoff.value < backoff.lower {
+			return fmt.Errorf("invalid %s %s: must not be below %s %s", backoff.key, backoff.value, backoff.lowerKey, backoff.lower)
+		}
+	}
+
+	sizes := []struct {
+		key   string
+		value int
+	}{
+		{"jobs batch_size", c.Jobs.BatchSize},
+		{"webhooks batch_size", c.Webhooks.BatchSize},
+		{"webhooks workers", c.Webhooks.Workers},
+		{"webhooks max_attempts", c.Webhooks.MaxAttempts},
+		{"events batch_size", c.Events.BatchSize},
+		{"events max_attempts", c.Events.MaxAttempts},
+		{"unlock link_attempts", c.Unlock.LinkAttempts},
+		{"unlock ip_attempts", c.Unlock.IPAttempts},
+		{"unlock max_entries", c.Unlock.MaxEntries},
+		{"cache domain_max_entries", c.Cache.DomainMaxEntries},
+	}
+	for _, size := range sizes {
+		if size.value <= 0 {
+			return fmt.Errorf("invalid %s %d: must be positive", size.key, size.value)
+		}
+	}
+
+	for _, proxy := range c.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			return fmt.Errorf("invalid server trusted_proxies %q: want an address or a CIDR range", proxy)
+		}
+	}
+	return nil
+}
+
+// parseMilestones reads a comma separated list of positive click counts in ascending order
+func parseMilestones(value string) ([]int64, error) {
+	var milestones []int64
+	for _, item := range splitList(value, ",") {
+		n, err := strconv.ParseInt(item, 10, 64)
+		if err != nil || n <= 0 || (len(milestones) > 0 && n <= milestones[len(milestones)-1]) {
+			return nil, fmt.Errorf("invalid webhooks click_milestones %q: want ascending positive counts", value)
+		}
+		milestones = append(milestones, n)
+	}
+	return milestones, nil
+}
+
+// splitList splits a separated config value and drops empty entries
+func splitList(value, sep string) []string {
+	var items []string
+	for _, item := range strings.Split(value, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func load(t *testing.T, ini string) (*Config, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.ini")
+	if err := os.WriteFile(path, []byte(ini), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return Load(path)
+}
+
+func TestLoadDefaults(t *testing.T) {
+	if _, err := load(t, ""); err != nil {
+		t.Fatalf("the defaults are rejected: %v", err)
+	}
+}
+
+func TestLoadRejectsNonPositiveSettings(t *testing.T) {
+	tests := []struct {
+		ini  string
+		want string
+	}{
+		{"[database]\nconnect_deadline = 0s", "database connect_deadline"},
+		{"[database]\nretry_initial_delay = 0s", "database retry_initial_delay"},
+		{"[database]\nretry_max_delay = -1s", "database retry_max_delay"},
+		{"[database]\nretry_initial_delay = 5s\nretry_max_delay = 1s", "database retry_max_delay"},
+		{"[events]\nrelay_interval = 0s", "events relay_interval"},
+		{"[events]\nmax_attempts = 0", "events max_attempts"},
+		{"[events]\nbackoff_base = 0s", "events backoff_base"},
+		{"[events]\nbackoff_base = 1h\nbackoff_max = 1m", "events backoff_max"},
+		{"[jobs]\nexpiry_interval = -1m", "jobs expiry_interval"},
+		{"[jobs]\nbatch_size = 0", "jobs batch_size"},
+		{"[links]\nresolve_timeout = 0s", "links resolve_timeout"},
+		{"[webhooks]\nbatch_size = -5", "webhooks batch_size"},
+		{"[webhooks]\nworkers = 0", "webhooks workers"},
+		{"[webhooks]\nmax_attempts = -1", "webhooks max_attempts"},
+		{"[webhooks]\ntimeout = 0s", "webhooks timeout"},
+		{"[webhooks]\nbackoff_max = 0s", "webhooks backoff_max"},
+		{"[webhooks]\nbackoff_base = 1h\nbackoff_max = 1m", "webhooks backoff_max"},
+	}
+	for _, tt := range tests {
+		_, err := load(t, tt.ini)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("loading %q returned %v, want an error about %s", tt.ini, err, tt.want)
+		}
+	}
+}
+
+func TestLoadTrustedProxies(t *testing.T) {
+	cfg, err := load(t, "[server]\ntrusted_proxies = 10.0.0.1, 172.16.0.0/12,fd00::/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(cfg.Server.TrustedProxies, " "); got != "10.0.0.1 172.16.0.0/12 fd00::/8" {
+		t.Errorf("trusted proxies = %q", got)
+	}
+
+	if cfg, err := load(t, ""); err != nil {
+		t.Fatal(err)
+	} else if cfg.Server.TrustedProxies != nil {
+		t.Errorf("trusted proxies default to %v, want none", cfg.Server.TrustedProxies)
+	}
+	if _, err := load(t, "[server]\ntrusted_proxies = lb.internal"); err == nil || !strings.Contains(err.Error(), "trusted_proxies") {
+		t.Errorf("a host name as trusted proxy returned %v", err)
+	}
+}
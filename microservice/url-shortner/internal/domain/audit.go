@@ -0,0 +1,107 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// Audited actions
+const (
+	AuditAccountCreate    = "account.create"
+	AuditAccountActivate  = "account.activate"
+	AuditAPIKeyCreate     = "api_key.create"
+	AuditAPIKeyDeactivate = "api_key.deactivate"
+	AuditAPIKeyRotate     = "api_key.rotate"
+	AuditAPIKeyRevokeAll  = "api_key.revoke_all"
+	AuditLinkCreate       = "link.create"
+	AuditLinkUpdate       = "link.update"
+	AuditLinkRevert       = "link.revert"
+	AuditLinkDisable      = "link.disable"
+	AuditLinkReassign     = "link.reassign"
+	AuditFolderCreate     = "folder.create"
+	AuditFolderUpdate     = "folder.update"
+	AuditFolderDelete     = "folder.delete"
+	AuditDomainCreate     = "domain.create"
+	AuditDomainVerify     = "domain.verify"
+	AuditDomainUpdate     = "domain.update"
+	AuditDomainDelete     = "domain.delete"
+	AuditOrgCreate        = "organization.create"
+	AuditMemberUpdate     = "member.update"
+	AuditMemberRemove     = "member.remove"
+	AuditInvitationCreate = "invitation.create"
+	AuditInvitationRevoke = "invitation.revoke"
+	AuditInvitationAccept = "invitation.accept"
+	AuditDataExport       = "data.export"
+	AuditDataImport       = "data.import"
+	AuditWebhookCreate    = "webhook.create"
+	AuditWebhookUpdate    = "webhook.update"
+	AuditWebhookDelete    = "webhook.delete"
+	AuditWebhookRotate    = "webhook.rotate"
+	AuditWebhookReplay    = "webhook.replay"
+)
+
+// Kinds of actors behind an audited action
+const (
+	ActorAPIKey = "api_key"
+	ActorAdmin  = "admin"
+	ActorSystem = "system"
+)
+
+// AuditEvent is an append only record of a sensitive action. Every event stores
+// the hash of its predecessor, so editing or removing a row breaks the chain.
+type AuditEvent struct {
+	ID        uint `gorm:"primarykey"`
+	AccountId uint `gorm:"index:idx_audit_events_account_time"`
+	// OrganizationId is the organization owning the resource, 0 for account level actions
+	OrganizationId uint `gorm:"index:idx_audit_events_org_time;not null;default:0"`
+	Actor          string
+	APIKeyId       uint
+	IP             string
+	RequestID      string
+	Action         string `gorm:"not null"`
+	// Resource names the affected row, e.g. link:abc1234
+	Resource string
+	// Before and After hold JSON objects of the changed fields
+	Before    string    `gorm:"type:text"`
+	After     string    `gorm:"type:text"`
+	CreatedAt time.Time `gorm:"index:idx_audit_events_account_time;index:idx_audit_events_org_time"`
+	PrevHash  string
+	Hash      string `gorm:"uniqueIndex;not null"`
+}
+
+// ComputeHash hashes the event content together with the hash of its predecessor
+func (e *AuditEvent) ComputeHash() string {
+	// OrganizationId is omitted when 0 so events recorded before organizations keep their hash
+	payload, _ := json.Marshal(struct {
+		AccountId      uint
+		OrganizationId uint `json:",omitempty"`
+		Actor          string
+		APIKeyId       uint
+		IP             string
+		RequestID      string
+		Action         string
+		Resource       string
+		Before         string
+		After          string
+		CreatedAt      string
+	}{
+		e.AccountId, e.OrganizationId, e.Actor, e.APIKeyId, e.IP, e.RequestID, e.Action, e.Resource,
+		e.Before, e.After, e.CreatedAt.UTC().Format(time.RFC3339Nano),
+	})
+	sum := sha256.Sum256(append([]byte(e.PrevHash), payload...))
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditFilter selects the events of an organization in [From, To), newest
+// first, along with the account level events of AccountId. BeforeId continues
+// a listing after the last event of the previous page.
+type AuditFilter struct {
+	OrganizationId uint
+	AccountId      uint
+	From           time.Time
+	To             time.Time
+	BeforeId       uint
+	Limit          int
+}
@@ -2,15 +2,43 @@ package domain
 
 import (
 	"gorm.io/gorm"
+	"net"
+	"net/url"
+	"strings"
 	"time"
 )
 
 type Account struct {
 	gorm.Model
-	Email     string     `gorm:"uniqueIndex;not null"`
-	IsActive  bool       `gorm:"default:false"`
-	APIKeys   []APIKey   `gorm:"foreignKey:AccountId"`
-	ShortUrls []ShortUrl `gorm:"foreignKey:AccountId"`
+	Email    string `gorm:"uniqueIndex;not null"`
+	IsActive bool   `gorm:"default:false"`
+	// OrganizationId is the personal organization created with the account, API
+	// requests act in it unless they name another organization
+	OrganizationId uint       `gorm:"not null;default:0"`
+	APIKeys        []APIKey   `gorm:"foreignKey:AccountId"`
+	ShortUrls      []ShortUrl `gorm:"foreignKey:AccountId"`
+}
+
+// Domain is a custom host owned by an organization. It serves links once a
+// member proved ownership with a DNS TXT record carrying the token.
+type Domain struct {
+	gorm.Model
+	// AccountId is the member who added the domain
+	AccountId      uint `gorm:"index;not null"`
+	OrganizationId uint `gorm:"index;uniqueIndex:idx_domains_org_host;not null;default:0"`
+	// Host is unique per organization, only one verified domain may hold it
+	Host              string `gorm:"uniqueIndex:idx_domains_org_host;not null"`
+	VerificationToken string `gorm:"not null"`
+	VerifiedAt        *time.Time
+	// FallbackURL receives visitors of unknown or inactive codes, without it
+	// NotFoundPage is served, or a plain page when that is empty too
+	FallbackURL  string
+	NotFoundPage string `gorm:"type:text"`
+}
+
+// Verified reports whether the domain's ownership was proven
+func (d *Domain) Verified() bool {
+	return d.VerifiedAt != nil
 }
 
 type APIKey struct {
@@ -20,20 +48,360 @@ type APIKey struct {
 	Name      string
 	IsActive  bool `gorm:"default:true"`
 	LastUsed  time.Time
-	ExpiresAt time.Time
+	ExpiresAt time.Time `gorm:"index"`
+	Scopes    Scopes    `gorm:"serializer:json"`
+	// AllowedDomains limits the key to links on these hosts, the shared domain
+	// is named by the host of the base url. AllowedCIDRs limits the client
+	// addresses it is accepted from. Empty lists do not restrict.
+	AllowedDomains []string `gorm:"serializer:json"`
+	AllowedCIDRs   []string `gorm:"serializer:json"`
+	// RotatedToId is the key that replaced this one, the old key stays valid
+	// until its ExpiresAt
+	RotatedToId uint
+}
+
+// AllowsIP reports whether the key is accepted from the client address
+func (k *APIKey) AllowsIP(ip net.IP) bool {
+	if len(k.AllowedCIDRs) == 0 {
+		return true
+	}
+	if ip == nil {
+		return false
+	}
+	for _, cidr := range k.AllowedCIDRs {
+		if _, network, err := net.ParseCIDR(cidr); err == nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
 }
 
 type ShortUrl struct {
 	gorm.Model
-	AccountId     uint
-	APIKeyId      uint
-	OriginalURL   string `gorm:"not null"`
-	ShortCode     string `gorm:"uniqueIndex;not null"`
-	ExpiresAt     time.Time
-	IsActive      bool  `gorm:"default:true"`
-	Clicks        int64 `gorm:"default:0"`
+	// AccountId is the member who created the link, OrganizationId owns it
+	AccountId      uint
+	OrganizationId uint `gorm:"index;not null;default:0"`
+	APIKeyId       uint
+	OriginalURL    string `gorm:"not null"`
+	// DestinationHost is the host name of OriginalURL, links are filtered by it
+	DestinationHost string `gorm:"not null;default:''"`
+	Title           string
+	// FolderId is the folder of the organization holding the link, 0 is none
+	FolderId uint `gorm:"not null;default:0"`
+	// SearchText is the lower case text links are searched by, see SearchDocument
+	SearchText string `gorm:"type:text;not null;default:''"`
+	// DomainId is the custom domain serving the link, 0 is the shared domain.
+	// Short codes and custom slugs are unique per domain.
+	DomainId      uint      `gorm:"uniqueIndex:idx_short_urls_domain_code;uniqueIndex:idx_short_urls_domain_slug;not null;default:0"`
+	ShortCode     string    `gorm:"uniqueIndex:idx_short_urls_domain_code;not null"`
+	ExpiresAt     time.Time `gorm:"index"`
+	IsActive      bool      `gorm:"default:true"`
+	Clicks        int64     `gorm:"default:0"`
 	LastClickedAt time.Time
-	CustomSlug    string `gorm:"uniqueIndex"`
+	CustomSlug    *string `gorm:"uniqueIndex:idx_short_urls_domain_slug"`
+	Tags          []Tag   `gorm:"many2many:short_url_tags"`
+	// DisabledReason records why the service deactivated the link
+	DisabledReason string
+	DisabledAt     *time.Time
+	// PasswordHash is the bcrypt hash of the link password, visitors unlock
+	// a protected link with a form before they are redirected
+	PasswordHash string
+	// MaxClicks deactivates the link once ConsumedClicks reaches it, 0 means no
+	// limit. ConsumedClicks is counted on redirect unlike the batched Clicks.
+	MaxClicks      int64 `gorm:"default:0"`
+	ConsumedClicks int64 `gorm:"default:0"`
+	// RedirectType is the HTTP status of the redirect, 301, 302, 307 or 308
+	RedirectType int `gorm:"default:302"`
+	// Version counts the edits of the link, prior versions are kept as LinkVersion rows
+	Version int `gorm:"default:1"`
+	// UTM parameters are merged into the destination on redirect
+	UTM UTM `gorm:"embedded;embeddedPrefix:utm_"`
+	// QueryPassthrough forwards the query string of the short url to the destination
+	QueryPassthrough QueryPassthrough `gorm:"not null;default:''"`
+	// Rules redirect matching visitors elsewhere, ordered by Position
+	Rules []RedirectRule `gorm:"foreignKey:ShortURLId"`
+	// Variants split the visitors no rule matched over weighted destinations,
+	// replacing OriginalURL as their target
+	Variants []LinkVariant `gorm:"foreignKey:ShortURLId"`
+}
+
+// IsExpired reports whether the link has an expiry that has passed
+func (u *ShortUrl) IsExpired(now time.Time) bool {
+	return !u.ExpiresAt.IsZero() && !now.Before(u.ExpiresAt)
+}
+
+// Redirectable reports whether the link may currently be followed
+func (u *ShortUrl) Redirectable(now time.Time) bool {
+	return u.IsActive && !u.IsExpired(now)
+}
+
+// Protected reports whether the link needs a password before redirecting
+func (u *ShortUrl) Protected() bool {
+	return u.PasswordHash != ""
+}
+
+// SearchDocument is the text a link is found by: its code, destination, title and tags
+func (u *ShortUrl) SearchDocument() string {
+	parts := []string{u.ShortCode, u.OriginalURL, u.Title}
+	for _, tag := range u.Tags {
+		parts = append(parts, tag.Name)
+	}
+	return strings.ToLower(strings.Join(parts, " "))
+}
+
+// HostOf returns the lower case host name of a destination url, without the port
+func HostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+}
+
+// UTM holds the campaign parameters of a link, empty ones are left out
+type UTM struct {
+	Source   string `json:"source,omitempty"`
+	Medium   string `json:"medium,omitempty"`
+	Campaign string `json:"campaign,omitempty"`
+	Term     string `json:"term,omitempty"`
+	Content  string `json:"content,omitempty"`
+}
+
+// Params returns the query parameters of the set UTM values
+func (u UTM) Params() url.Values {
+	params := url.Values{}
+	for _, p := range []struct{ name, value string }{
+		{"utm_source", u.Source}, {"utm_medium", u.Medium}, {"utm_campaign", u.Campaign},
+		{"utm_term", u.Term}, {"utm_content", u.Content},
+	} {
+		if p.value != "" {
+			params.Set(p.name, p.value)
+		}
+	}
+	return params
+}
+
+func (u UTM) IsZero() bool {
+	return u == UTM{}
+}
+
+// QueryPassthrough decides how the query string of a short url request is
+// merged into the destination
+type QueryPassthrough string
+
+const (
+	// PassthroughOff drops the query string of the request
+	PassthroughOff QueryPassthrough = ""
+	// PassthroughShortLink lets request parameters replace those of the destination
+	PassthroughShortLink QueryPassthrough = "shortLink"
+	// PassthroughDestination only adds request parameters the destination does not set
+	PassthroughDestination QueryPassthrough = "destination"
+)
+
+func (q QueryPassthrough) Valid() bool {
+	return q == PassthroughOff || q == PassthroughShortLink || q == PassthroughDestination
+}
+
+// Destination returns the url a visitor is redirected to: the target, the
+// original url or that of a matching rule, with the UTM parameters of the link
+// replacing its own and the request query merged as QueryPassthrough decides.
+// The query of the target keeps its order and encoding, replaced parameters
+// are taken out of it and the added ones appended sorted by name.
+func (u *ShortUrl) Destination(target string, query url.Values) string {
+	if u.QueryPassthrough == PassthroughOff {
+		query = nil
+	}
+	if u.UTM.IsZero() && len(query) == 0 {
+		return target
+	}
+
+	dest, err := url.Parse(target)
+	if err != nil {
+		return target
+	}
+	own := dest.Query()
+	added := u.UTM.Params()
+	for name, values := range query {
+		if u.QueryPassthrough == PassthroughDestination && (own.Has(name) || added.Has(name)) {
+			continue
+		}
+		added[name] = values
+	}
+	if len(added) == 0 {
+		return target
+	}
+	dest.RawQuery = mergeQuery(dest.RawQuery, added)
+	return dest.String()
+}
+
+// mergeQuery drops the pairs of the raw query that added replaces and appends
+// the added parameters
+func mergeQuery(raw string, added url.Values) string {
+	var pairs []string
+	for _, pair := range strings.Split(raw, "&") {
+		if pair == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(pair, "=")
+		if name, err := url.QueryUnescape(name); err == nil && added.Has(name) {
+			continue
+		}
+		pairs = append(pairs, pair)
+	}
+	return strings.Join(append(pairs, added.Encode()), "&")
+}
+
+// LinkVersion is the state of a link's editable fields at one version along
+// with the edit that replaced it
+type LinkVersion struct {
+	ID           uint   `gorm:"primarykey"`
+	ShortURLId   uint   `gorm:"uniqueIndex:idx_link_versions_url_version;not null"`
+	Version      int    `gorm:"uniqueIndex:idx_link_versions_url_version;not null"`
+	OriginalURL  string `gorm:"not null"`
+	ExpiresAt    time.Time
+	IsActive     bool
+	RedirectType int
+	// Snapshot holds the other editable fields, versions saved before it was
+	// added have none
+	Snapshot *LinkSnapshot `gorm:"serializer:json"`
+	// ChangedBy is the API key that made the edit, ChangedFields what it changed
+	ChangedBy     uint
+	ChangedFields []string `gorm:"serializer:json"`
+	CreatedAt     time.Time
+}
+
+// LinkSnapshot is the state of the editable fields of a link a LinkVersion
+// keeps beside its own columns
+type LinkSnapshot struct {
+	Title            string           `json:"title,omitempty"`
+	FolderId         uint             `json:"folderId,omitempty"`
+	Tags             []string         `json:"tags,omitempty"`
+	UTM              UTM              `json:"utm"`
+	QueryPassthrough QueryPassthrough `json:"queryPassthrough,omitempty"`
+	Rules            []RedirectRule   `json:"rules,omitempty"`
+	Variants         []LinkVariant    `json:"variants,omitempty"`
+}
+
+// NewLinkSnapshot captures the fields of the link a version keeps in its snapshot
+func NewLinkSnapshot(url *ShortUrl) *LinkSnapshot {
+	snapshot := &LinkSnapshot{
+		Title:            url.Title,
+		FolderId:         url.FolderId,
+		UTM:              url.UTM,
+		QueryPassthrough: url.QueryPassthrough,
+		Rules:            url.Rules,
+		Variants:         url.Variants,
+	}
+	for _, tag := range url.Tags {
+		snapshot.Tags = append(snapshot.Tags, tag.Name)
+	}
+	return snapshot
+}
+
+// LinkChanges is an edit of a link, nil fields are left unchanged
+type LinkChanges struct {
+	OriginalURL  *string
+	ExpiresAt    *time.Time
+	IsActive     *bool
+	RedirectType *int
+	Title        *string
+	FolderId     *uint
+	// Tags replaces the tags of the link
+	Tags             *[]Tag
+	UTM              *UTM
+	QueryPassthrough *QueryPassthrough
+	// Rules replaces the redirect rules of the link
+	Rules *[]RedirectRule
+	// Variants replaces the A/B split of the link
+	Variants *[]LinkVariant
+}
+
+// LinkSort is the order of a link listing
+type LinkSort string
+
+const (
+	SortCreated     LinkSort = "created"
+	SortClicks      LinkSort = "clicks"
+	SortLastClicked LinkSort = "lastClicked"
+)
+
+// LinkFilter selects the links of an organization for a listing, zero fields
+// do not filter. DomainIds limits the listing to these domains when it is not
+// nil. After continues a listing behind the last link of the previous page.
+type LinkFilter struct {
+	OrganizationId uint
+	DomainIds      []uint
+	Active         *bool
+	Expired        *bool
+	Tag            string
+	// FolderId lists the links of a folder, 0 the links in none
+	FolderId        *uint
+	DestinationHost string
+	CreatedFrom     time.Time
+	CreatedTo       time.Time
+	Sort            LinkSort
+	Ascending       bool
+	After           *LinkCursor
+	Limit           int
+	// WithTags, WithRules and WithVariants load the tags, redirect rules and
+	// A/B split of the listed links
+	WithTags     bool
+	WithRules    bool
+	WithVariants bool
+	// Now is the time expiry is judged at
+	Now time.Time
+}
+
+// LinkCursor is the position of a link in a listing, its id and the value it
+// is sorted by. Time is the creation or last click time depending on the sort.
+type LinkCursor struct {
+	ID     uint
+	Time   time.Time
+	Clicks int64
+}
+
+// CursorOf returns the position of the link in a listing sorted by sort
+func CursorOf(link *ShortUrl, sort LinkSort) LinkCursor {
+	cursor := LinkCursor{ID: link.ID}
+	switch sort {
+	case SortClicks:
+		cursor.Clicks = link.Clicks
+	case SortLastClicked:
+		cursor.Time = link.LastClickedAt
+	default:
+		cursor.Time = link.CreatedAt
+	}
+	return cursor
+}
+
+// LinkSearch finds the links of an organization matching a free text query,
+// best matches first. DomainIds limits the search like in LinkFilter.
+type LinkSearch struct {
+	OrganizationId uint
+	DomainIds      []uint
+	Query          string
+	Limit          int
+}
+
+// Folder groups links of an organization, a link is in at most one folder
+type Folder struct {
+	gorm.Model
+	OrganizationId uint   `gorm:"uniqueIndex:idx_folders_org_name;not null"`
+	Name           string `gorm:"uniqueIndex:idx_folders_org_name;not null"`
+	// LinkCount is the number of links in the folder, only set by listings
+	LinkCount int64 `gorm:"->;-:migration"`
+}
+
+// TagCount is a tag used in an organization with the number of its links
+type TagCount struct {
+	Name  string
+	Links int64
+}
+
+type Tag struct {
+	gorm.Model
+	AccountId uint   `gorm:"uniqueIndex:idx_tags_account_name;not null"`
+	Name      string `gorm:"uniqueIndex:idx_tags_account_name;not null"`
 }
 
 type URLAnalytics struct {
@@ -41,4 +409,66 @@ type URLAnalytics struct {
 	ShortURLId    uint  `gorm:"uniqueIndex"`
 	TotalClicks   int64 `gorm:"default:0"`
 	LastClickedAt time.Time
+	// Variants are the A/B split of the link with their clicks, only set by GetStats
+	Variants []LinkVariant `gorm:"-"`
+}
+
+// ClickDelta is the number of clicks a short url received since the last flush
+type ClickDelta struct {
+	Count         int64
+	LastClickedAt time.Time
+	// Variants counts the clicks by the id of the variant they were sent to
+	Variants map[uint]int64
+}
+
+type BulkJobStatus string
+
+const (
+	BulkJobPending   BulkJobStatus = "pending"
+	BulkJobRunning   BulkJobStatus = "running"
+	BulkJobCompleted BulkJobStatus = "completed"
+	BulkJobFailed    BulkJobStatus = "failed"
+)
+
+// BulkJob tracks an asynchronous bulk link creation and its per-row report
+type BulkJob struct {
+	gorm.Model
+	// AccountId submitted the job, every member of the organization may follow it
+	AccountId      uint `gorm:"index;not null"`
+	OrganizationId uint `gorm:"index;not null;default:0"`
+	APIKeyId       uint
+	Status         BulkJobStatus `gorm:"not null"`
+	Total          int
+	Succeeded      int
+	Failed         int
+	Results        []BulkRowResult `gorm:"serializer:json"`
+	Error          string
+	FinishedAt     *time.Time
+}
+
+// BulkRowResult reports the outcome of a single bulk input row, rows are numbered from 1
+type BulkRowResult struct {
+	Row       int       `json:"row"`
+	ShortCode string    `json:"shortCode,omitempty"`
+	Error     *RowError `json:"error,omitempty"`
+}
+
+type RowError struct {
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Details []FieldError `json:"details,omitempty"`
+}
+
+// ServiceStats are the totals across all organizations reported to operators
+type ServiceStats struct {
+	Accounts        int64
+	ActiveAccounts  int64
+	APIKeys         int64
+	ActiveAPIKeys   int64
+	Organizations   int64
+	Links           int64
+	ActiveLinks     int64
+	Domains         int64
+	VerifiedDomains int64
+	Clicks          int64
 }
@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"net"
+	"net/url"
+	"testing"
+)
+
+func TestDestination(t *testing.T) {
+	campaign := UTM{Source: "news", Campaign: "fall"}
+	tests := []struct {
+		name        string
+		target      string
+		utm         UTM
+		passthrough QueryPassthrough
+		query       string
+		want        string
+	}{
+		{"nothing to merge", "https://example.com/a?b=2&a=1", UTM{}, PassthroughShortLink, "", "https://example.com/a?b=2&a=1"},
+		{"utm replaces the target's", "https://example.com/?z=1&utm_source=old&a=2", campaign, PassthroughOff, "", "https://example.com/?z=1&a=2&utm_campaign=fall&utm_source=news"},
+		{"passthrough off drops the request query", "https://example.com/?z=1", UTM{}, PassthroughOff, "ref=mail", "https://example.com/?z=1"},
+		{"short link wins", "https://example.com/?z=1&ref=site&a=2", UTM{}, PassthroughShortLink, "ref=mail&new=1", "https://example.com/?z=1&a=2&new=1&ref=mail"},
+		{"short link wins over the utm", "https://example.com/", campaign, PassthroughShortLink, "utm_source=mail", "https://example.com/?utm_campaign=fall&utm_source=mail"},
+		{"destination wins", "https://example.com/?z=1&ref=site", UTM{}, PassthroughDestination, "ref=mail&new=1", "https://example.com/?z=1&ref=site&new=1"},
+		{"destination keeps the utm", "https://example.com/", campaign, PassthroughDestination, "utm_source=mail", "https://example.com/?utm_campaign=fall&utm_source=news"},
+		{"destination already sets everything", "https://example.com/?ref=site&x", UTM{}, PassthroughDestination, "ref=mail", "https://example.com/?ref=site&x"},
+		{"encoding of the target is kept", "https://example.com/?q=a%20b&s=a+b", UTM{}, PassthroughShortLink, "n=1", "https://example.com/?q=a%20b&s=a+b&n=1"},
+		{"repeated parameters are replaced together", "https://example.com/?t=1&k=2&t=3", UTM{}, PassthroughShortLink, "t=9", "https://example.com/?k=2&t=9"},
+		{"fragment stays last", "https://example.com/p?a=1#top", UTM{Medium: "email"}, PassthroughOff, "", "https://example.com/p?a=1&utm_medium=email#top"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			link := ShortUrl{UTM: tt.utm, QueryPassthrough: tt.passthrough}
+			if got := link.Destination(tt.target, query); got != tt.want {
+				t.Errorf("Destination() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPIKeyAllowsIP(t *testing.T) {
+	tests := []struct {
+		name  string
+		cidrs []string
+		ip    string
+		want  bool
+	}{
+		{"no restriction", nil, "203.0.113.7", true},
+		{"no restriction without an address", nil, "", true},
+		{"inside a range", []string{"10.0.0.0/8", "203.0.113.0/24"}, "203.0.113.7", true},
+		{"outside every range", []string{"10.0.0.0/8", "203.0.113.0/24"}, "198.51.100.1", false},
+		{"unknown address", []string{"0.0.0.0/0"}, "", false},
+		{"single address", []string{"192.0.2.1/32"}, "192.0.2.1", true},
+		{"ipv6 range", []string{"2001:db8::/32"}, "2001:db8::1", true},
+		{"ipv4 mapped ipv6 address", []string{"192.0.2.0/24"}, "::ffff:192.0.2.5", true},
+		{"malformed entries are skipped", []string{"nonsense", "192.0.2.0/24"}, "192.0.2.5", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := APIKey{AllowedCIDRs: tt.cidrs}
+			if got := key.AllowsIP(net.ParseIP(tt.ip)); got != tt.want {
+				t.Errorf("AllowsIP(%s) = %t, want %t", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
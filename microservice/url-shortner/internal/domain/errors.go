@@ -0,0 +1,121 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrUnauthorized  = errors.New("missing or invalid credentials")
+	ErrNotFound      = errors.New("resource not found")
+	ErrConflict      = errors.New("resource already exists")
+	ErrForbidden     = errors.New("operation not permitted")
+	ErrValidation    = errors.New("validation failed")
+	ErrQuotaExceeded = errors.New("quota exceeded")
+)
+
+// Machine readable error codes shared by every API surface
+const (
+	CodeUnauthorized  = "unauthorized"
+	CodeNotFound      = "not_found"
+	CodeConflict      = "conflict"
+	CodeForbidden     = "forbidden"
+	CodeValidation    = "validation_failed"
+	CodeQuotaExceeded = "quota_exceeded"
+	CodeInternal      = "internal_error"
+)
+
+// ErrorCode returns the machine readable code of a domain error
+func ErrorCode(err error) string {
+	switch {
+	case errors.Is(err, ErrValidation):
+		return CodeValidation
+	case errors.Is(err, ErrUnauthorized):
+		return CodeUnauthorized
+	case errors.Is(err, ErrNotFound):
+		return CodeNotFound
+	case errors.Is(err, ErrConflict):
+		return CodeConflict
+	case errors.Is(err, ErrForbidden):
+		return CodeForbidden
+	case errors.Is(err, ErrQuotaExceeded):
+		return CodeQuotaExceeded
+	default:
+		return CodeInternal
+	}
+}
+
+// ErrorMessage returns the public message of an error code, it never exposes internal details
+func ErrorMessage(code string) string {
+	switch code {
+	case CodeValidation:
+		return ErrValidation.Error()
+	case CodeUnauthorized:
+		return ErrUnauthorized.Error()
+	case CodeNotFound:
+		return ErrNotFound.Error()
+	case CodeConflict:
+		return ErrConflict.Error()
+	case CodeForbidden:
+		return ErrForbidden.Error()
+	case CodeQuotaExceeded:
+		return ErrQuotaExceeded.Error()
+	default:
+		return "internal server error"
+	}
+}
+
+// ErrorText returns the message of err cut to at most n bytes without
+// splitting a character, for the last error kept on a delivery or an event
+func ErrorText(err error, n int) string {
+	text := err.Error()
+	if len(text) <= n {
+		return text
+	}
+	return strings.ToValidUTF8(text[:n], "")
+}
+
+// FieldError describes a single invalid input field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError carries the field details of a failed validation and matches ErrValidation
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func NewValidationError(field, message string) *ValidationError {
+	return &ValidationError{Fields: []FieldError{{Field: field, Message: message}}}
+}
+
+// Add appends a field error to the validation error
+func (e *ValidationError) Add(field, message string) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: message})
+}
+
+// HasErrors reports whether any field error was recorded
+func (e *ValidationError) HasErrors() bool {
+	return len(e.Fields) > 0
+}
+
+// OrNil returns the validation error only when field errors were recorded
+func (e *ValidationError) OrNil() error {
+	if !e.HasErrors() {
+		return nil
+	}
+	return e
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		parts = append(parts, f.Field+": "+f.Message)
+	}
+	return ErrValidation.Error() + ": " + strings.Join(parts, ", ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
@@ -0,0 +1,26 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestErrorText(t *testing.T) {
+	tests := []struct {
+		err  string
+		n    int
+		want string
+	}{
+		{"refused", 10, "refused"},
+		{"refused", 7, "refused"},
+		{"connection refused", 10, "connection"},
+		// é is two bytes, a cut through it drops the character
+		{"café closed", 4, "caf"},
+		{"café closed", 5, "café"},
+	}
+	for _, tt := range tests {
+		if got := ErrorText(errors.New(tt.err), tt.n); got != tt.want {
+			t.Errorf("ErrorText(%q, %d) = %q, want %q", tt.err, tt.n, got, tt.want)
+		}
+	}
+}
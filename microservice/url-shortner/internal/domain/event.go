@@ -0,0 +1,201 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Types of the domain events
+const (
+	EventLinkCreated      = "link.created"
+	EventLinkUpdated      = "link.updated"
+	EventLinkDeactivated  = "link.deactivated"
+	EventLinkExpired      = "link.expired"
+	EventLinkReassigned   = "link.reassigned"
+	EventClickRecorded    = "click.recorded"
+	EventAPIKeyCreated    = "api_key.created"
+	EventAPIKeyRevoked    = "api_key.revoked"
+	EventAccountActivated = "account.activated"
+)
+
+// Event is a change other parts of the service react to. Repositories record
+// events in the outbox within the transaction of the change, a relay hands
+// them to the subscribers of the event bus once it is committed.
+type Event interface {
+	EventType() string
+}
+
+// LinkState is a link as it was when an event was raised
+type LinkState struct {
+	ID             uint       `json:"id"`
+	OrganizationId uint       `json:"organizationId"`
+	DomainId       uint       `json:"domainId"`
+	ShortCode      string     `json:"shortCode"`
+	OriginalURL    string     `json:"originalUrl"`
+	Title          string     `json:"title,omitempty"`
+	Tags           []string   `json:"tags"`
+	IsActive       bool       `json:"isActive"`
+	ExpiresAt      *time.Time `json:"expiresAt,omitempty"`
+	DisabledReason string     `json:"disabledReason,omitempty"`
+	Clicks         int64      `json:"clicks"`
+	Version        int        `json:"version"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+func NewLinkState(url *ShortUrl) LinkState {
+	state := LinkState{
+		ID:             url.ID,
+		OrganizationId: url.OrganizationId,
+		DomainId:       url.DomainId,
+		ShortCode:      url.ShortCode,
+		OriginalURL:    url.OriginalURL,
+		Title:          url.Title,
+		Tags:           make([]string, 0, len(url.Tags)),
+		IsActive:       url.IsActive,
+		DisabledReason: url.DisabledReason,
+		Clicks:         url.Clicks,
+		Version:        url.Version,
+		CreatedAt:      url.CreatedAt,
+	}
+	for _, tag := range url.Tags {
+		state.Tags = append(state.Tags, tag.Name)
+	}
+	if !url.ExpiresAt.IsZero() {
+		expiresAt := url.ExpiresAt
+		state.ExpiresAt = &expiresAt
+	}
+	return state
+}
+
+type LinkCreated struct {
+	Link LinkState `json:"link"`
+}
+
+// LinkUpdated is an edit of a link that left it active or activated it again
+type LinkUpdated struct {
+	Link LinkState `json:"link"`
+	// Fields names the changed fields as the API calls them
+	Fields []string `json:"fields"`
+}
+
+// LinkDeactivated is a link turned off by a member or, with a DisabledReason,
+// by the service itself
+type LinkDeactivated struct {
+	Link LinkState `json:"link"`
+}
+
+// LinkExpired is a link deactivated at its expiry
+type LinkExpired struct {
+	Link LinkState `json:"link"`
+}
+
+// LinkReassigned is a link an admin moved to another organization, Link is
+// its state in the new one
+type LinkReassigned struct {
+	Link               LinkState `json:"link"`
+	FromOrganizationId uint      `json:"fromOrganizationId"`
+	AccountId          uint      `json:"accountId"`
+}
+
+// ClickRecorded is a batch of clicks added to a link's count
+type ClickRecorded struct {
+	LinkId         uint  `json:"linkId"`
+	OrganizationId uint  `json:"organizationId"`
+	Count          int64 `json:"count"`
+	// Clicks is the link's count including the batch
+	Clicks        int64     `json:"clicks"`
+	LastClickedAt time.Time `json:"lastClickedAt"`
+}
+
+type APIKeyCreated struct {
+	KeyId     uint   `json:"keyId"`
+	AccountId uint   `json:"accountId"`
+	Name      string `json:"name,omitempty"`
+	// RotatedFromId is the key the new key succeeds, 0 for a new key
+	RotatedFromId uint `json:"rotatedFromId,omitempty"`
+}
+
+// APIKeyRevoked is a key that stopped working, revoked by its account or
+// deactivated at its expiry
+type APIKeyRevoked struct {
+	KeyId     uint   `json:"keyId"`
+	AccountId uint   `json:"accountId"`
+	Reason    string `json:"reason"`
+}
+
+type AccountActivated struct {
+	AccountId uint `json:"accountId"`
+}
+
+// Reasons of APIKeyRevoked
+const (
+	KeyRevoked = "revoked"
+	KeyExpired = "expired"
+)
+
+func (LinkCreated) EventType() string      { return EventLinkCreated }
+func (LinkUpdated) EventType() string      { return EventLinkUpdated }
+func (LinkDeactivated) EventType() string  { return EventLinkDeactivated }
+func (LinkExpired) EventType() string      { return EventLinkExpired }
+func (LinkReassigned) EventType() string   { return EventLinkReassigned }
+func (ClickRecorded) EventType() string    { return EventClickRecorded }
+func (APIKeyCreated) EventType() string    { return EventAPIKeyCreated }
+func (APIKeyRevoked) EventType() string    { return EventAPIKeyRevoked }
+func (AccountActivated) EventType() string { return EventAccountActivated }
+
+var eventDecoders = map[string]func([]byte) (Event, error){
+	EventLinkCreated:      decodeEvent[LinkCreated],
+	EventLinkUpdated:      decodeEvent[LinkUpdated],
+	EventLinkDeactivated:  decodeEvent[LinkDeactivated],
+	EventLinkExpired:      decodeEvent[LinkExpired],
+	EventLinkReassigned:   decodeEvent[LinkReassigned],
+	EventClickRecorded:    decodeEvent[ClickRecorded],
+	EventAPIKeyCreated:    decodeEvent[APIKeyCreated],
+	EventAPIKeyRevoked:    decodeEvent[APIKeyRevoked],
+	EventAccountActivated: decodeEvent[AccountActivated],
+}
+
+// DecodeEvent restores an event from its outbox payload
+func DecodeEvent(eventType string, payload []byte) (Event, error) {
+	decode, ok := eventDecoders[eventType]
+	if !ok {
+		return nil, fmt.Errorf("unknown event type %q", eventType)
+	}
+	return decode(payload)
+}
+
+func decodeEvent[T Event](payload []byte) (Event, error) {
+	var event T
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// OutboxStatus is the state of an event in the outbox
+type OutboxStatus string
+
+const (
+	OutboxPending    OutboxStatus = "pending"
+	OutboxDispatched OutboxStatus = "dispatched"
+	// OutboxFailed is an event a subscriber failed on every attempt, it is
+	// only dispatched again when retried by hand
+	OutboxFailed OutboxStatus = "failed"
+)
+
+// OutboxEvent is an event waiting in the outbox or kept after its dispatch
+type OutboxEvent struct {
+	ID   uint   `gorm:"primarykey"`
+	Type string `gorm:"not null"`
+	// Payload is the JSON encoded event
+	Payload       string       `gorm:"type:text;not null"`
+	Status        OutboxStatus `gorm:"index:idx_outbox_events_due,priority:1;not null"`
+	NextAttemptAt time.Time    `gorm:"index:idx_outbox_events_due,priority:2"`
+	Attempts      int
+	// Handled names the subscribers that processed the event, a retry skips them
+	Handled      []string `gorm:"serializer:json"`
+	LastError    string
+	CreatedAt    time.Time `gorm:"index"`
+	DispatchedAt *time.Time
+}
@@ -0,0 +1,90 @@
+package domain
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// Role is the level of access a member has to an organization
+type Role string
+
+const (
+	RoleOwner  Role = "owner"
+	RoleAdmin  Role = "admin"
+	RoleEditor Role = "editor"
+	RoleViewer Role = "viewer"
+)
+
+// Permission is an action a role may be allowed to perform
+type Permission string
+
+const (
+	PermLinksRead     Permission = "links:read"
+	PermLinksWrite    Permission = "links:write"
+	PermAnalyticsRead Permission = "analytics:read"
+	PermDomainsWrite  Permission = "domains:write"
+	PermMembersWrite  Permission = "members:write"
+	PermAuditRead     Permission = "audit:read"
+	PermWebhooksWrite Permission = "webhooks:write"
+)
+
+// rolePermissions grants every role the permissions of the roles below it plus its own
+var rolePermissions = map[Role][]Permission{
+	RoleViewer: {PermLinksRead, PermAnalyticsRead},
+	RoleEditor: {PermLinksRead, PermAnalyticsRead, PermLinksWrite},
+	RoleAdmin:  {PermLinksRead, PermAnalyticsRead, PermLinksWrite, PermDomainsWrite, PermMembersWrite, PermAuditRead, PermWebhooksWrite},
+	RoleOwner:  {PermLinksRead, PermAnalyticsRead, PermLinksWrite, PermDomainsWrite, PermMembersWrite, PermAuditRead, PermWebhooksWrite},
+}
+
+// Valid reports whether the role is one of the known roles
+func (r Role) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Can reports whether the role grants the permission
+func (r Role) Can(perm Permission) bool {
+	for _, granted := range rolePermissions[r] {
+		if granted == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// Organization owns links and domains, accounts act on them as members
+type Organization struct {
+	gorm.Model
+	Name string `gorm:"not null"`
+}
+
+// Membership gives an account a role in an organization. Every organization
+// keeps at least one owner.
+type Membership struct {
+	ID             uint         `gorm:"primarykey"`
+	OrganizationId uint         `gorm:"uniqueIndex:idx_memberships_org_account;not null"`
+	AccountId      uint         `gorm:"uniqueIndex:idx_memberships_org_account;index;not null"`
+	Role           Role         `gorm:"not null"`
+	Organization   Organization `gorm:"foreignKey:OrganizationId"`
+	Account        Account      `gorm:"foreignKey:AccountId"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// Invitation offers a role in an organization to whoever accepts it with the
+// token while signed in with the invited email. Only the token's hash is stored.
+type Invitation struct {
+	gorm.Model
+	OrganizationId uint   `gorm:"index;not null"`
+	Email          string `gorm:"not null"`
+	Role           Role   `gorm:"not null"`
+	TokenHash      string `gorm:"uniqueIndex;not null"`
+	InvitedBy      uint
+	ExpiresAt      time.Time
+	AcceptedAt     *time.Time
+}
+
+// Pending reports whether the invitation can still be accepted
+func (i *Invitation) Pending(now time.Time) bool {
+	return i.AcceptedAt == nil && now.Before(i.ExpiresAt)
+}
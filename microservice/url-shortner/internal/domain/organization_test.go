@@ -0,0 +1,37 @@
+package domain
+
+import "testing"
+
+func TestRolePermissions(t *testing.T) {
+	perms := []Permission{PermLinksRead, PermAnalyticsRead, PermLinksWrite, PermDomainsWrite, PermMembersWrite, PermAuditRead, PermWebhooksWrite}
+	granted := map[Role]int{
+		// the number of leading permissions above each role holds
+		RoleViewer: 2,
+		RoleEditor: 3,
+		RoleAdmin:  len(perms),
+		RoleOwner:  len(perms),
+		Role(""):   0,
+		"guest":    0,
+	}
+
+	for role, n := range granted {
+		for i, perm := range perms {
+			if got, want := role.Can(perm), i < n; got != want {
+				t.Errorf("%q.Can(%s) = %t, want %t", role, perm, got, want)
+			}
+		}
+	}
+}
+
+func TestRoleValid(t *testing.T) {
+	for _, role := range []Role{RoleOwner, RoleAdmin, RoleEditor, RoleViewer} {
+		if !role.Valid() {
+			t.Errorf("%q is not valid", role)
+		}
+	}
+	for _, role := range []Role{"", "Owner", "guest"} {
+		if role.Valid() {
+			t.Errorf("%q is valid", role)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package domain
+
+import "fmt"
+
+// Principal is the authenticated caller of an API request acting in one of
+// its account's organizations
+type Principal struct {
+	AccountId      uint
+	APIKeyId       uint
+	OrganizationId uint
+	Role           Role
+	// Scopes of the API key, the role and a scope both have to grant a permission
+	Scopes Scopes
+	// Domains restricts the key to links on these hosts, empty allows every domain
+	Domains []string
+	// Restricted is set when the key is limited to domains or client addresses
+	Restricted bool
+}
+
+// Authorize fails with ErrForbidden unless the caller's role and key scopes grant the permission
+func (p *Principal) Authorize(perm Permission) error {
+	if !p.Role.Can(perm) {
+		return fmt.Errorf("role %q lacks %s: %w", p.Role, perm, ErrForbidden)
+	}
+	if !p.Scopes.Covers(perm) {
+		return fmt.Errorf("api key has no scope covering %s: %w", perm, ErrForbidden)
+	}
+	return nil
+}
+
+// AllowsDomain reports whether the caller's key may act on links of the host
+func (p *Principal) AllowsDomain(host string) bool {
+	if len(p.Domains) == 0 {
+		return true
+	}
+	for _, allowed := range p.Domains {
+		if allowed == host {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthorizeAccount fails with ErrForbidden unless the caller's key may act on
+// the account itself, like managing its keys or organizations. That takes the
+// admin scope on a key without restrictions, so no key can issue a wider one.
+func (p *Principal) AuthorizeAccount() error {
+	if !p.Scopes.Has(ScopeAdmin) {
+		return fmt.Errorf("api key lacks the %s scope: %w", ScopeAdmin, ErrForbidden)
+	}
+	if p.Restricted {
+		return fmt.Errorf("api key is restricted to domains or addresses: %w", ErrForbidden)
+	}
+	return nil
+}
@@ -1,17 +1,194 @@
 package domain
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type AccountRepository interface {
+	// Create stores the account together with its personal organization, which it owns
 	Create(ctx context.Context, account *Account) error
+	Get(ctx context.Context, id uint) (*Account, error)
 	Activate(ctx context.Context, id uint) error
-	CreateAPIKey(ctx context.Context, accountId uint, name string) (string, error)
-	DeactivateAPIKey(ctx context.Context, accountId uint, apiKey string) error
+	// CreateAPIKey stores the key with a freshly generated value and returns the
+	// plain key, which is not kept
+	CreateAPIKey(ctx context.Context, key *APIKey) (string, error)
+	GetAPIKey(ctx context.Context, accountId, id uint) (*APIKey, error)
+	ListAPIKeys(ctx context.Context, accountId uint) ([]APIKey, error)
+	// RotateAPIKey replaces an active key with a new one carrying the same
+	// settings, the old key expires at graceUntil unless it expires earlier
+	RotateAPIKey(ctx context.Context, accountId, id uint, graceUntil time.Time) (*APIKey, *APIKey, string, error)
+	DeactivateAPIKey(ctx context.Context, accountId, id uint) error
+	// DeactivateAPIKeys revokes every active key of the account at once
+	DeactivateAPIKeys(ctx context.Context, accountId uint) (int64, error)
+	FindAPIKey(ctx context.Context, apiKey string) (*APIKey, *Account, error)
+	TouchAPIKey(ctx context.Context, id uint) error
 }
 
 type ShortURLRepository interface {
-	CreateURL(ctx context.Context, accountId, apiKeyId uint, sourceURL, shortCode string) (*ShortUrl, error)
-	GetSourceURL(ctx context.Context, code string) (*ShortUrl, error)
+	CreateURL(ctx context.Context, url *ShortUrl) error
+	// CreateURLs inserts the urls in one transaction, a failing row is rolled back
+	// on its own and reported at its index while the others are committed
+	CreateURLs(ctx context.Context, urls []*ShortUrl) ([]error, error)
+	// GetSourceURL resolves a short code on a domain, 0 is the shared domain
+	GetSourceURL(ctx context.Context, domainId uint, code string) (*ShortUrl, error)
+	// GetURLsByID returns the links with the ids along with their tags, in any order
+	GetURLsByID(ctx context.Context, ids []uint) ([]ShortUrl, error)
+	// GetURL returns a link owned by the organization along with its tags
+	GetURL(ctx context.Context, orgId, domainId uint, code string) (*ShortUrl, error)
+	// ListURLs returns up to filter.Limit links matching the filter in its order
+	ListURLs(ctx context.Context, filter LinkFilter) ([]ShortUrl, error)
+	// SearchURLs returns up to search.Limit links matching the query, best matches first
+	SearchURLs(ctx context.Context, search LinkSearch) ([]ShortUrl, error)
+	// ListTags returns the tags on the organization's links with their link counts, by name
+	ListTags(ctx context.Context, orgId uint) ([]TagCount, error)
 	IncrementClicks(ctx context.Context, id uint) error
-	DeactivateURL(ctx context.Context, accountId uint, code string) error
+	AddClicks(ctx context.Context, deltas map[uint]ClickDelta) error
+	DeactivateURL(ctx context.Context, orgId, domainId uint, code string) error
+	// DisableURL deactivates a link on behalf of the service and records the reason
+	DisableURL(ctx context.Context, id uint, reason string) error
+	GetStats(ctx context.Context, orgId, domainId uint, code string) (*URLAnalytics, error)
+	// UpdateURL applies the changes to an organization's link and returns it with
+	// the replaced version. Changes matching the current state are dropped, when
+	// nothing is left no version is returned.
+	UpdateURL(ctx context.Context, orgId, domainId uint, code string, changes LinkChanges, changedBy uint) (*ShortUrl, *LinkVersion, error)
+	// ListVersions returns the prior versions of an organization's link, newest first
+	ListVersions(ctx context.Context, orgId, domainId uint, code string) ([]LinkVersion, error)
+	GetVersion(ctx context.Context, orgId, domainId uint, code string, version int) (*LinkVersion, error)
+	// ConsumeClick takes one click of a click limited link and deactivates it when
+	// the limit is reached. It reports false when no click was left.
+	ConsumeClick(ctx context.Context, id uint) (bool, error)
+	// ReassignURL moves a link to another organization and creating member
+	ReassignURL(ctx context.Context, id, orgId, accountId uint) error
+}
+
+// ArchiveRepository reads an organization's links for export and stores imported ones
+type ArchiveRepository interface {
+	// Links returns up to limit links of the organization following afterId in id order, with their tags
+	Links(ctx context.Context, orgId, afterId uint, limit int) ([]ShortUrl, error)
+	Versions(ctx context.Context, urlIds []uint) ([]LinkVersion, error)
+	Analytics(ctx context.Context, urlIds []uint) ([]URLAnalytics, error)
+	// TakenCodes reports which of the codes are used on the domain, deleted links included
+	TakenCodes(ctx context.Context, domainId uint, codes []string) (map[string]bool, error)
+	// ImportURL stores a link as given together with its prior versions and analytics
+	ImportURL(ctx context.Context, url *ShortUrl, versions []LinkVersion, analytics *URLAnalytics) error
+}
+
+type DomainRepository interface {
+	Create(ctx context.Context, d *Domain) error
+	Get(ctx context.Context, orgId, id uint) (*Domain, error)
+	GetByHost(ctx context.Context, orgId uint, host string) (*Domain, error)
+	List(ctx context.Context, orgId uint) ([]Domain, error)
+	// FindVerified returns the verified domain serving a host
+	FindVerified(ctx context.Context, host string) (*Domain, error)
+	// FindByID returns a domain of any organization
+	FindByID(ctx context.Context, id uint) (*Domain, error)
+	Update(ctx context.Context, d *Domain) error
+	// Delete removes a domain, it fails with ErrConflict while links still use it
+	Delete(ctx context.Context, orgId, id uint) error
+}
+
+type FolderRepository interface {
+	Create(ctx context.Context, folder *Folder) error
+	Get(ctx context.Context, orgId, id uint) (*Folder, error)
+	// List returns the folders of the organization by name with their link counts
+	List(ctx context.Context, orgId uint) ([]Folder, error)
+	Rename(ctx context.Context, orgId, id uint, name string) (*Folder, error)
+	// Delete removes a folder, its links stay without a folder
+	Delete(ctx context.Context, orgId, id uint) (*Folder, error)
+}
+
+type OrganizationRepository interface {
+	// Create stores the organization with the account as its owner
+	Create(ctx context.Context, org *Organization, ownerId uint) error
+	// Memberships returns the memberships of an account with their organizations
+	Memberships(ctx context.Context, accountId uint) ([]Membership, error)
+	GetMembership(ctx context.Context, orgId, accountId uint) (*Membership, error)
+	// ListMembers returns the members of an organization with their accounts
+	ListMembers(ctx context.Context, orgId uint) ([]Membership, error)
+	// UpdateRole changes the role of a member and returns the prior role. It
+	// fails with ErrConflict when the last owner would be demoted.
+	UpdateRole(ctx context.Context, orgId, accountId uint, role Role) (Role, error)
+	// RemoveMember fails with ErrConflict when the member is the last owner
+	RemoveMember(ctx context.Context, orgId, accountId uint) (*Membership, error)
+	CreateInvitation(ctx context.Context, invitation *Invitation) error
+	// ListInvitations returns the invitations of an organization not accepted yet
+	ListInvitations(ctx context.Context, orgId uint) ([]Invitation, error)
+	RevokeInvitation(ctx context.Context, orgId, id uint) (*Invitation, error)
+	// AcceptInvitation turns the pending invitation with the token hash into a
+	// membership of the account, the account's email must be the invited one
+	AcceptInvitation(ctx context.Context, tokenHash string, accountId uint, now time.Time) (*Membership, error)
+}
+
+type BulkJobRepository interface {
+	Create(ctx context.Context, job *BulkJob) error
+	Get(ctx context.Context, orgId, id uint) (*BulkJob, error)
+	Update(ctx context.Context, job *BulkJob) error
+	// FailInterrupted marks jobs left pending or running by a previous process as failed
+	FailInterrupted(ctx context.Context) (int64, error)
+}
+
+// RetentionRepository enforces expiry and retention of stored rows, every call
+// handles at most limit rows so a backlog is worked off in batches
+type RetentionRepository interface {
+	// DeactivateExpiredURLs deactivates active links past their expiry and returns
+	// them with their tags as they are after the deactivation
+	DeactivateExpiredURLs(ctx context.Context, now time.Time, limit int) ([]ShortUrl, error)
+	DeactivateExpiredAPIKeys(ctx context.Context, now time.Time, limit int) (int64, error)
+	// PurgeDeleted hard deletes rows soft deleted before the cutoff, per table
+	PurgeDeleted(ctx context.Context, before time.Time, limit int) (map[string]int64, error)
+}
+
+// AuditRepository stores the audit log, events are only ever appended
+type AuditRepository interface {
+	// Append chains the events to the end of the log in order
+	Append(ctx context.Context, events []*AuditEvent) error
+	List(ctx context.Context, filter AuditFilter) ([]AuditEvent, error)
+	// Scan returns up to limit events following afterId in chain order
+	Scan(ctx context.Context, afterId uint, limit int) ([]AuditEvent, error)
+}
+
+type StatsRepository interface {
+	// Totals counts the rows of the service, soft deleted rows excluded
+	Totals(ctx context.Context) (*ServiceStats, error)
+}
+
+// WebhookRepository stores the webhook endpoints of organizations and the
+// deliveries queued for them
+type WebhookRepository interface {
+	CreateEndpoint(ctx context.Context, endpoint *WebhookEndpoint) error
+	GetEndpoint(ctx context.Context, orgId, id uint) (*WebhookEndpoint, error)
+	ListEndpoints(ctx context.Context, orgId uint) ([]WebhookEndpoint, error)
+	UpdateEndpoint(ctx context.Context, endpoint *WebhookEndpoint) error
+	DeleteEndpoint(ctx context.Context, orgId, id uint) (*WebhookEndpoint, error)
+	// Subscribers returns the active endpoints of the organization subscribed to the event
+	Subscribers(ctx context.Context, orgId uint, event string) ([]WebhookEndpoint, error)
+	// Enqueue stores the deliveries, one already queued for its endpoint and event id is skipped
+	Enqueue(ctx context.Context, deliveries []WebhookDelivery) error
+	// ClaimDue returns up to limit deliveries due at now for active endpoints
+	// with their endpoint, and holds them back from other claims until now+lease
+	ClaimDue(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]WebhookDelivery, error)
+	// RecordAttempt logs the attempt and saves the resulting state of its delivery
+	RecordAttempt(ctx context.Context, delivery *WebhookDelivery, attempt *WebhookAttempt) error
+	ListDeliveries(ctx context.Context, filter DeliveryFilter) ([]WebhookDelivery, error)
+	// GetDelivery returns a delivery of the organization's endpoint with its attempts
+	GetDelivery(ctx context.Context, orgId, endpointId, id uint) (*WebhookDelivery, error)
+	// Replay queues a delivery again as if it was new, its attempts stay in the log
+	Replay(ctx context.Context, orgId, endpointId, id uint, now time.Time) (*WebhookDelivery, error)
+	// PurgeDeliveries removes up to limit finished deliveries created before the cutoff with their attempts
+	PurgeDeliveries(ctx context.Context, before time.Time, limit int) (int64, error)
+}
+
+// OutboxRepository hands out the events the other repositories record in the
+// outbox along with their changes
+type OutboxRepository interface {
+	// ClaimDue returns up to limit pending events due at now in the order they
+	// were recorded, and holds them back from other claims until now+lease
+	ClaimDue(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]OutboxEvent, error)
+	// Save stores the dispatch state of a claimed event
+	Save(ctx context.Context, event *OutboxEvent) error
+	// Retry makes failed events pending again, all of them when no id is given
+	Retry(ctx context.Context, now time.Time, ids ...uint) (int64, error)
+	// Purge removes up to limit dispatched events recorded before the cutoff
+	Purge(ctx context.Context, before time.Time, limit int) (int64, error)
 }
@@ -0,0 +1,173 @@
+package domain
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Operating systems and device classes a redirect rule can target
+var (
+	RuleOS      = []string{"android", "chromeos", "ios", "linux", "macos", "windows"}
+	RuleDevices = []string{"bot", "desktop", "mobile", "tablet"}
+)
+
+// RedirectRule sends the visitors matching all of its set conditions to its own
+// destination. The rules of a link are evaluated by Position, the first match
+// wins and the link's OriginalURL is the fallback.
+type RedirectRule struct {
+	ID          uint   `gorm:"primarykey" json:"-"`
+	ShortURLId  uint   `gorm:"index:idx_redirect_rules_url_position;not null" json:"-"`
+	Position    int    `gorm:"index:idx_redirect_rules_url_position;not null" json:"-"`
+	Destination string `gorm:"not null" json:"url"`
+	// Countries are upper case ISO 3166 codes looked up from the visitor's IP
+	Countries []string `gorm:"serializer:json" json:"countries,omitempty"`
+	OS        []string `gorm:"serializer:json" json:"os,omitempty"`
+	Devices   []string `gorm:"serializer:json" json:"devices,omitempty"`
+	// Languages are lower case language tags matched against the preferred
+	// language of the visitor, en also matches en-us
+	Languages []string `gorm:"serializer:json" json:"languages,omitempty"`
+	// Referrers are host names, referrers on their subdomains match too
+	Referrers []string `gorm:"serializer:json" json:"referrers,omitempty"`
+	// From and Until bound the rule in time, Until is exclusive
+	From  *time.Time `json:"from,omitempty"`
+	Until *time.Time `json:"until,omitempty"`
+	// DailyFrom and DailyUntil are HH:MM clock times in TimeZone, UTC when empty.
+	// A window ending before it starts runs past midnight.
+	DailyFrom  string `json:"dailyFrom,omitempty"`
+	DailyUntil string `json:"dailyUntil,omitempty"`
+	TimeZone   string `json:"timeZone,omitempty"`
+}
+
+// Visitor describes the request redirect rules are evaluated against, an
+// attribute is only looked up when a rule needs it
+type Visitor interface {
+	// Country is the ISO 3166 code of the visitor's IP, empty when unknown
+	Country() string
+	// Platform is the operating system and device class of the user agent
+	Platform() (os, device string)
+	// Language is the most preferred language of Accept-Language
+	Language() string
+	// Referrer is the host of the Referer header
+	Referrer() string
+}
+
+// Target returns the destination of the first rule the visitor matches, the
+// original url when none does
+func (u *ShortUrl) Target(v Visitor, now time.Time) string {
+	if rule := u.MatchingRule(v, now); rule != nil {
+		return rule.Destination
+	}
+	return u.OriginalURL
+}
+
+// MatchingRule returns the first rule the visitor matches, nil when none does
+func (u *ShortUrl) MatchingRule(v Visitor, now time.Time) *RedirectRule {
+	for i := range u.Rules {
+		if u.Rules[i].Matches(v, now) {
+			return &u.Rules[i]
+		}
+	}
+	return nil
+}
+
+// Matches reports whether the visitor meets every condition of the rule. The
+// cheap conditions are checked before those that look the visitor up.
+func (r *RedirectRule) Matches(v Visitor, now time.Time) bool {
+	if r.From != nil && now.Before(*r.From) || r.Until != nil && !now.Before(*r.Until) {
+		return false
+	}
+	if r.DailyFrom != "" && !r.inDailyWindow(now) {
+		return false
+	}
+	if len(r.OS) > 0 || len(r.Devices) > 0 {
+		os, device := v.Platform()
+		if len(r.OS) > 0 && !slices.Contains(r.OS, os) || len(r.Devices) > 0 && !slices.Contains(r.Devices, device) {
+			return false
+		}
+	}
+	if len(r.Languages) > 0 && !matchesLanguage(r.Languages, v.Language()) {
+		return false
+	}
+	if len(r.Referrers) > 0 && !matchesHost(r.Referrers, v.Referrer()) {
+		return false
+	}
+	if len(r.Countries) > 0 && !slices.Contains(r.Countries, v.Country()) {
+		return false
+	}
+	return true
+}
+
+func (r *RedirectRule) inDailyWindow(now time.Time) bool {
+	local := now.In(Location(r.TimeZone))
+	minute := local.Hour()*60 + local.Minute()
+	from, until := ClockMinutes(r.DailyFrom), ClockMinutes(r.DailyUntil)
+	if from <= until {
+		return minute >= from && minute < until
+	}
+	return minute >= from || minute < until
+}
+
+func matchesLanguage(languages []string, language string) bool {
+	if language == "" {
+		return false
+	}
+	for _, l := range languages {
+		if language == l || strings.HasPrefix(language, l) && language[len(l)] == '-' {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesHost(hosts []string, host string) bool {
+	if host == "" {
+		return false
+	}
+	for _, h := range hosts {
+		if host == h || strings.HasSuffix(host, h) && host[len(host)-len(h)-1] == '.' {
+			return true
+		}
+	}
+	return false
+}
+
+// ClockMinutes returns the minutes past midnight of an HH:MM clock time, -1
+// when it is malformed
+func ClockMinutes(clock string) int {
+	if len(clock) != 5 || clock[2] != ':' {
+		return -1
+	}
+	digits := [4]int{}
+	for i, c := range []byte{clock[0], clock[1], clock[3], clock[4]} {
+		if c < '0' || c > '9' {
+			return -1
+		}
+		digits[i] = int(c - '0')
+	}
+	hour, minute := digits[0]*10+digits[1], digits[2]*10+digits[3]
+	if hour > 23 || minute > 59 {
+		return -1
+	}
+	return hour*60 + minute
+}
+
+var locations sync.Map
+
+// Location returns the named time zone, UTC when the name is empty or unknown.
+// Zones are loaded once, rules evaluate them on every redirect.
+func Location(name string) *time.Location {
+	if name == "" {
+		return time.UTC
+	}
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		loc = time.UTC
+	}
+	locations.Store(name, loc)
+	return loc
+}
@@ -0,0 +1,78 @@
+package domain
+
+import "fmt"
+
+// Scope limits what an API key may do, the key's account still needs a role
+// granting the permission in the organization it acts on
+type Scope string
+
+const (
+	ScopeLinksRead     Scope = "links:read"
+	ScopeLinksWrite    Scope = "links:write"
+	ScopeAnalyticsRead Scope = "analytics:read"
+	// ScopeAdmin covers every permission, including domains, members, webhooks and the audit log
+	ScopeAdmin Scope = "admin"
+)
+
+// scopePermissions lists the permissions each scope covers, a key able to
+// change links may read them too
+var scopePermissions = map[Scope][]Permission{
+	ScopeLinksRead:     {PermLinksRead},
+	ScopeLinksWrite:    {PermLinksRead, PermLinksWrite},
+	ScopeAnalyticsRead: {PermAnalyticsRead},
+	ScopeAdmin:         {PermLinksRead, PermLinksWrite, PermAnalyticsRead, PermDomainsWrite, PermMembersWrite, PermAuditRead, PermWebhooksWrite},
+}
+
+// Valid reports whether the scope is one of the known scopes
+func (s Scope) Valid() bool {
+	_, ok := scopePermissions[s]
+	return ok
+}
+
+// Covers reports whether the scope grants the permission
+func (s Scope) Covers(perm Permission) bool {
+	for _, granted := range scopePermissions[s] {
+		if granted == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// Scopes is the set of scopes granted to an API key
+type Scopes []Scope
+
+// Covers reports whether any of the scopes grants the permission
+func (scopes Scopes) Covers(perm Permission) bool {
+	for _, s := range scopes {
+		if s.Covers(perm) {
+			return true
+		}
+	}
+	return false
+}
+
+// Has reports whether the scope was granted itself
+func (scopes Scopes) Has(scope Scope) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseScopes validates scope names, duplicates are dropped
+func ParseScopes(names []string) (Scopes, error) {
+	scopes := make(Scopes, 0, len(names))
+	for _, name := range names {
+		scope := Scope(name)
+		if !scope.Valid() {
+			return nil, fmt.Errorf("unknown scope %q", name)
+		}
+		if !scopes.Has(scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
@@ -0,0 +1,44 @@
+package domain
+
+// LinkVariant is one destination of an A/B split. Visitors no redirect rule
+// matched are spread over the variants of a link in proportion to their
+// weights and keep their variant on later visits.
+type LinkVariant struct {
+	ID          uint   `gorm:"primarykey" json:"-"`
+	ShortURLId  uint   `gorm:"index:idx_link_variants_url_position;not null" json:"-"`
+	Position    int    `gorm:"index:idx_link_variants_url_position;not null" json:"-"`
+	Destination string `gorm:"not null" json:"url"`
+	Weight      int    `gorm:"not null" json:"weight"`
+	// Clicks counts the redirects to the variant, flushed with the link clicks
+	Clicks int64 `gorm:"default:0" json:"-"`
+}
+
+// Variant returns the variant of the link with the id, nil when the link has none
+func (u *ShortUrl) Variant(id uint) *LinkVariant {
+	for i := range u.Variants {
+		if u.Variants[i].ID == id {
+			return &u.Variants[i]
+		}
+	}
+	return nil
+}
+
+// PickVariant maps a visitor hash onto the variants by weight, so the same
+// hash keeps landing on the same variant while the variants are unchanged
+func (u *ShortUrl) PickVariant(hash uint64) *LinkVariant {
+	var total uint64
+	for _, v := range u.Variants {
+		total += uint64(v.Weight)
+	}
+	if total == 0 {
+		return nil
+	}
+	point := hash % total
+	for i := range u.Variants {
+		if point < uint64(u.Variants[i].Weight) {
+			return &u.Variants[i]
+		}
+		point -= uint64(u.Variants[i].Weight)
+	}
+	return nil
+}
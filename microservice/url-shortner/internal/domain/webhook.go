@@ -0,0 +1,102 @@
+package domain
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// EventClickMilestone is sent to webhook endpoints when a link's clicks reach
+// one of the configured milestones
+const EventClickMilestone = "link.click_milestone"
+
+// WebhookEvents lists every event an endpoint may subscribe to, the link events
+// are the domain events of the same type
+var WebhookEvents = []string{EventLinkCreated, EventLinkUpdated, EventLinkDeactivated, EventLinkExpired, EventClickMilestone}
+
+// DeliveryStatus is the state of a webhook delivery
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryRetrying  DeliveryStatus = "retrying"
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	// DeliveryDead is a delivery that failed every attempt, only a replay sends it again
+	DeliveryDead DeliveryStatus = "dead"
+)
+
+// WebhookEndpoint receives the events of an organization's links as signed POST requests
+type WebhookEndpoint struct {
+	gorm.Model
+	// AccountId is the member who added the endpoint
+	AccountId      uint   `gorm:"not null"`
+	OrganizationId uint   `gorm:"index;not null"`
+	URL            string `gorm:"not null"`
+	// Secret keys the HMAC signature of every request sent to the endpoint
+	Secret string `gorm:"not null"`
+	// Events the endpoint subscribes to, empty subscribes to all of them
+	Events      []string `gorm:"serializer:json"`
+	Description string
+	IsActive    bool `gorm:"default:true"`
+}
+
+// Subscribes reports whether the endpoint wants the event
+func (e *WebhookEndpoint) Subscribes(event string) bool {
+	if len(e.Events) == 0 {
+		return true
+	}
+	for _, subscribed := range e.Events {
+		if subscribed == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is an event queued for one endpoint. An event is delivered
+// at least once, receivers tell repeats apart by EventId.
+type WebhookDelivery struct {
+	ID             uint   `gorm:"primarykey"`
+	EndpointId     uint   `gorm:"uniqueIndex:idx_webhook_deliveries_endpoint_event;not null"`
+	OrganizationId uint   `gorm:"index;not null"`
+	EventId        string `gorm:"uniqueIndex:idx_webhook_deliveries_endpoint_event;not null"`
+	Event          string `gorm:"not null"`
+	// Payload is the JSON body sent to the endpoint
+	Payload string         `gorm:"type:text;not null"`
+	Status  DeliveryStatus `gorm:"not null"`
+	// Attempts counts the requests since the delivery was queued or last replayed
+	Attempts       int
+	NextAttemptAt  time.Time `gorm:"index"`
+	LastStatusCode int
+	LastError      string
+	DeliveredAt    *time.Time
+	CreatedAt      time.Time `gorm:"index"`
+	UpdatedAt      time.Time
+	// Endpoint is set for deliveries claimed to be sent
+	Endpoint *WebhookEndpoint `gorm:"-"`
+	// Log holds the attempts, oldest first, only set by GetDelivery
+	Log []WebhookAttempt `gorm:"-"`
+}
+
+// WebhookAttempt is one request made for a delivery
+type WebhookAttempt struct {
+	ID         uint `gorm:"primarykey"`
+	DeliveryId uint `gorm:"index;not null"`
+	EndpointId uint `gorm:"index;not null"`
+	Attempt    int
+	// StatusCode is 0 when no response arrived
+	StatusCode int
+	Error      string
+	Duration   time.Duration
+	CreatedAt  time.Time
+}
+
+// DeliveryFilter selects deliveries of an endpoint, newest first
+type DeliveryFilter struct {
+	OrganizationId uint
+	EndpointId     uint
+	// Status restricts the deliveries to one state, empty for all
+	Status DeliveryStatus
+	// BeforeId continues a listing after the last delivery of the prior page
+	BeforeId uint
+	Limit    int
+}
@@ -0,0 +1,94 @@
+// Package events relays the domain events repositories record in the outbox
+// to in-process subscribers and, optionally, to NATS.
+//
+// Every event reaches each subscriber of its type at least once. A subscriber
+// that fails is called again with backoff while the ones that succeeded are
+// skipped, unless the relay stops before it saved their success. Subscribers
+// must therefore tolerate repeats, the Envelope ID tells them apart.
+package events
+
+import (
+	"coding2fun.in/url-shortner/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Envelope is an event taken from the outbox
+type Envelope struct {
+	// ID is the outbox id, an event handed out again carries the same id
+	ID         uint
+	Type       string
+	OccurredAt time.Time
+	Event      domain.Event
+	// Payload is the JSON encoded event
+	Payload []byte
+}
+
+// Handler reacts to an event, an error has the event handed to it again later
+type Handler func(ctx context.Context, e Envelope) error
+
+type subscriber struct {
+	name   string
+	types  []string
+	handle Handler
+}
+
+// Bus hands events to the subscribers of their type in the order they subscribed
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers []subscriber
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe registers a handler for the event types, for every event when none
+// is given. The outbox remembers subscribers by name, so the name has to stay
+// the same across releases and be unique.
+func (b *Bus) Subscribe(name string, handle Handler, types ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, sub := range b.subscribers {
+		if sub.name == name {
+			panic("events: subscriber " + name + " registered twice")
+		}
+	}
+	b.subscribers = append(b.subscribers, subscriber{name: name, types: types, handle: handle})
+}
+
+// Dispatch hands the event to the subscribers of its type not named in
+// handled. It returns handled together with the subscribers that succeeded
+// and the errors of the ones that failed.
+func (b *Bus) Dispatch(ctx context.Context, e Envelope, handled []string) ([]string, error) {
+	b.mu.RLock()
+	subscribers := slices.Clone(b.subscribers)
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, sub := range subscribers {
+		if len(sub.types) > 0 && !slices.Contains(sub.types, e.Type) || slices.Contains(handled, sub.name) {
+			continue
+		}
+		if err := call(ctx, sub, e); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sub.name, err))
+			continue
+		}
+		handled = append(handled, sub.name)
+	}
+	return handled, errors.Join(errs...)
+}
+
+// call runs a handler, a panic fails the event instead of the relay
+func call(ctx context.Context, sub subscriber, e Envelope) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return sub.handle(ctx, e)
+}
@@ -0,0 +1,111 @@
+package events
+
+import (
+	"coding2fun.in/url-shortner/internal/config"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	natsStartTimeout   = 10 * time.Second
+	natsPublishTimeout = 5 * time.Second
+)
+
+// natsMessage is the body of a published event
+type natsMessage struct {
+	ID         uint            `json:"id"`
+	Type       string          `json:"type"`
+	OccurredAt time.Time       `json:"occurredAt"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// Publisher forwards events to NATS under the subject prefix followed by the
+// event type, e.g. shortener.link.created. The Nats-Msg-Id header carries the
+// outbox id, so JetStream streams drop the repeats of at-least-once delivery.
+type Publisher struct {
+	conn     *nats.Conn
+	embedded *server.Server
+	prefix   string
+}
+
+// NewPublisher connects to the configured NATS server. With NATSEmbedded it
+// first starts a server in the process, other services connect to it on
+// NATSListen, and publishes to it unless NATSURL names another one.
+func NewPublisher(cfg config.EventsConfig) (*Publisher, error) {
+	p := &Publisher{prefix: cfg.SubjectPrefix}
+	url := cfg.NATSURL
+
+	if cfg.NATSEmbedded {
+		host, port, err := net.SplitHostPort(cfg.NATSListen)
+		if err != nil {
+			return nil, fmt.Errorf("invalid events nats_listen %q: %w", cfg.NATSListen, err)
+		}
+		portNumber, err := strconv.Atoi(port)
+		if err != nil {
+			return nil, fmt.Errorf("invalid events nats_listen %q: %w", cfg.NATSListen, err)
+		}
+
+		p.embedded, err = server.NewServer(&server.Options{Host: host, Port: portNumber, NoSigs: true, NoLog: true})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the embedded nats server: %w", err)
+		}
+		go p.embedded.Start()
+		if !p.embedded.ReadyForConnections(natsStartTimeout) {
+			p.embedded.Shutdown()
+			return nil, fmt.Errorf("embedded nats server did not start listening on %s", cfg.NATSListen)
+		}
+		if url == "" {
+			url = p.embedded.ClientURL()
+		}
+	}
+
+	conn, err := nats.Connect(url, nats.Name("url-shortner"), nats.MaxReconnects(-1))
+	if err != nil {
+		p.Close()
+		return nil, fmt.Errorf("failed to connect to nats at %s: %w", url, err)
+	}
+	p.conn = conn
+	return p, nil
+}
+
+// Subscribe registers the publisher for every event
+func (p *Publisher) Subscribe(bus *Bus) {
+	bus.Subscribe("nats", p.publish)
+}
+
+// publish waits for the server to take the message, an event the connection
+// lost is published again
+func (p *Publisher) publish(ctx context.Context, e Envelope) error {
+	body, err := json.Marshal(natsMessage{ID: e.ID, Type: e.Type, OccurredAt: e.OccurredAt, Data: e.Payload})
+	if err != nil {
+		return err
+	}
+
+	msg := nats.NewMsg(p.prefix + "." + e.Type)
+	msg.Header.Set(nats.MsgIdHdr, strconv.FormatUint(uint64(e.ID), 10))
+	msg.Data = body
+	if err := p.conn.PublishMsg(msg); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, natsPublishTimeout)
+	defer cancel()
+	return p.conn.FlushWithContext(ctx)
+}
+
+// Close drains the connection and stops the embedded server
+func (p *Publisher) Close() {
+	if p.conn != nil {
+		p.conn.Close()
+	}
+	if p.embedded != nil {
+		p.embedded.Shutdown()
+		p.embedded.WaitForShutdown()
+	}
+}
@@ -0,0 +1,121 @@
+package events
+
+import (
+	"coding2fun.in/url-shortner/internal/config"
+	"coding2fun.in/url-shortner/internal/domain"
+	"coding2fun.in/url-shortner/internal/log"
+	"context"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	defaultRelayBatchSize = 200
+	// relayLease is how long claimed events are held back from other
+	// instances, the relay has that long to dispatch a batch
+	relayLease          = 5 * time.Minute
+	maxEventErrorLength = 512
+	purgeBatchSize      = 1000
+)
+
+// Relay moves events from the outbox to the bus in the order they were
+// recorded. An event that failed is retried after the events recorded later.
+type Relay struct {
+	outbox domain.OutboxRepository
+	bus    *Bus
+	cfg    config.EventsConfig
+}
+
+func NewRelay(outbox domain.OutboxRepository, bus *Bus, cfg config.EventsConfig) *Relay {
+	return &Relay{outbox: outbox, bus: bus, cfg: cfg}
+}
+
+// Run dispatches the due events until none is left
+func (r *Relay) Run(ctx context.Context) error {
+	batchSize := r.cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultRelayBatchSize
+	}
+
+	for ctx.Err() == nil {
+		events, err := r.outbox.ClaimDue(ctx, time.Now(), batchSize, relayLease)
+		if err != nil {
+			return err
+		}
+		for i := range events {
+			r.dispatch(ctx, &events[i])
+		}
+		if len(events) < batchSize {
+			return nil
+		}
+	}
+	return nil
+}
+
+// dispatch hands an event to its subscribers and saves the outcome. A dispatch
+// cut short by shutdown is not saved, the lease hands the event to the next run.
+func (r *Relay) dispatch(ctx context.Context, e *domain.OutboxEvent) {
+	event, err := domain.DecodeEvent(e.Type, []byte(e.Payload))
+	if err == nil {
+		e.Handled, err = r.bus.Dispatch(ctx, Envelope{
+			ID:         e.ID,
+			Type:       e.Type,
+			OccurredAt: e.CreatedAt,
+			Event:      event,
+			Payload:    []byte(e.Payload),
+		}, e.Handled)
+	}
+	if ctx.Err() != nil {
+		return
+	}
+
+	now := time.Now()
+	e.Attempts++
+	e.LastError = ""
+	switch {
+	case err == nil:
+		e.Status = domain.OutboxDispatched
+		e.DispatchedAt = &now
+	case e.Attempts >= r.cfg.MaxAttempts:
+		e.Status = domain.OutboxFailed
+		e.LastError = domain.ErrorText(err, maxEventErrorLength)
+		log.Error("Event failed", zap.Uint("eventId", e.ID), zap.String("type", e.Type), zap.Int("attempts", e.Attempts), zap.Error(err))
+	default:
+		e.LastError = domain.ErrorText(err, maxEventErrorLength)
+		e.NextAttemptAt = now.Add(r.backoff(e.Attempts))
+		log.Warn("Event dispatch failed", zap.Uint("eventId", e.ID), zap.String("type", e.Type), zap.Int("attempts", e.Attempts), zap.Error(err))
+	}
+
+	if err := r.outbox.Save(ctx, e); err != nil {
+		log.Error("Failed to save event state", zap.Uint("eventId", e.ID), zap.Error(err))
+	}
+}
+
+// backoff is the delay after the given number of failed dispatches, doubling from BackoffBase up to BackoffMax
+func (r *Relay) backoff(attempts int) time.Duration {
+	delay := r.cfg.BackoffBase
+	for i := 1; i < attempts && delay < r.cfg.BackoffMax; i++ {
+		delay *= 2
+	}
+	return min(delay, r.cfg.BackoffMax)
+}
+
+// Purge removes dispatched events older than the retention, failed ones are kept
+func (r *Relay) Purge(ctx context.Context) error {
+	before := time.Now().Add(-r.cfg.Retention)
+	var total int64
+	for ctx.Err() == nil {
+		n, err := r.outbox.Purge(ctx, before, purgeBatchSize)
+		if err != nil {
+			return err
+		}
+		total += n
+		if n < purgeBatchSize {
+			break
+		}
+	}
+	if total > 0 {
+		log.Info("Purged dispatched events", zap.Int64("events", total))
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package events
+
+import (
+	"coding2fun.in/url-shortner/internal/config"
+	"coding2fun.in/url-shortner/internal/domain"
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// fakeOutbox ignores the schedule so tests do not wait for the backoff
+type fakeOutbox struct {
+	events []domain.OutboxEvent
+}
+
+func (f *fakeOutbox) record(t *testing.T, event domain.Event) {
+	t.Helper()
+	payload, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.events = append(f.events, domain.OutboxEvent{
+		ID:        uint(len(f.events) + 1),
+		Type:      event.EventType(),
+		Payload:   string(payload),
+		Status:    domain.OutboxPending,
+		CreatedAt: time.Now(),
+	})
+}
+
+func (f *fakeOutbox) ClaimDue(_ context.Context, _ time.Time, limit int, _ time.Duration) ([]domain.OutboxEvent, error) {
+	var due []domain.OutboxEvent
+	for _, e := range f.events {
+		if e.Status == domain.OutboxPending && len(due) < limit {
+			e.Handled = slices.Clone(e.Handled)
+			due = append(due, e)
+		}
+	}
+	return due, nil
+}
+
+func (f *fakeOutbox) Save(_ context.Context, e *domain.OutboxEvent) error {
+	f.events[e.ID-1] = *e
+	return nil
+}
+
+func (f *fakeOutbox) Retry(context.Context, time.Time, ...uint) (int64, error) {
+	return 0, nil
+}
+
+func (f *fakeOutbox) Purge(context.Context, time.Time, int) (int64, error) {
+	return 0, nil
+}
+
+func newTestRelay(outbox *fakeOutbox, bus *Bus) *Relay {
+	return NewRelay(outbox, bus, config.EventsConfig{BatchSize: 10, MaxAttempts: 3, BackoffBase: time.Second, BackoffMax: 3 * time.Second})
+}
+
+func TestRelayDispatchesToSubscribers(t *testing.T) {
+	outbox := &fakeOutbox{}
+	outbox.record(t, domain.LinkCreated{Link: domain.LinkState{ID: 3, ShortCode: "promo"}})
+	outbox.record(t, domain.APIKeyRevoked{KeyId: 5, Reason: domain.KeyExpired})
+
+	var links, all []string
+	bus := NewBus()
+	bus.Subscribe("links", func(_ context.Context, e Envelope) error {
+		links = append(links, e.Event.(domain.LinkCreated).Link.ShortCode)
+		return nil
+	}, domain.EventLinkCreated, domain.EventLinkUpdated)
+	bus.Subscribe("all", func(_ context.Context, e Envelope) error {
+		all = append(all, e.Type)
+		return nil
+	})
+
+	if err := newTestRelay(outbox, bus).Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(links, []string{"promo"}) || !slices.Equal(all, []string{domain.EventLinkCreated, domain.EventAPIKeyRevoked}) {
+		t.Errorf("links got %v and all got %v", links, all)
+	}
+	for _, e := range outbox.events {
+		if e.Status != domain.OutboxDispatched || e.Attempts != 1 || e.DispatchedAt == nil {
+			t.Errorf("event %d is %s after %d attempts, want dispatched after 1", e.ID, e.Status, e.Attempts)
+		}
+	}
+}
+
+func TestRelayRetriesFailedSubscribersOnly(t *testing.T) {
+	outbox := &fakeOutbox{}
+	outbox.record(t, domain.LinkExpired{Link: domain.LinkState{ID: 3}})
+
+	calls := map[string]int{}
+	failures := 1
+	bus := NewBus()
+	bus.Subscribe("steady", func(context.Context, Envelope) error {
+		calls["steady"]++
+		return nil
+	})
+	bus.Subscribe("flaky", func(context.Context, Envelope) error {
+		calls["flaky"]++
+		if failures > 0 {
+			failures--
+			return errors.New("unavailable")
+		}
+		return nil
+	})
+	relay := newTestRelay(outbox, bus)
+
+	start := time.Now()
+	if err := relay.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	e := outbox.events[0]
+	if e.Status != domain.OutboxPending || e.LastError != "flaky: unavailable" || !slices.Equal(e.Handled, []string{"steady"}) {
+		t.Fatalf("after a failure the event is %s with error %q handled by %v", e.Status, e.LastError, e.Handled)
+	}
+	if wait := e.NextAttemptAt.Sub(start); wait < time.Second || wait > 2*time.Second {
+		t.Errorf("event is retried after %s, want 1s", wait)
+	}
+
+	if err := relay.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	e = outbox.events[0]
+	if e.Status != domain.OutboxDispatched || e.Attempts != 2 || e.LastError != "" {
+		t.Errorf("event is %s after %d attempts with error %q, want dispatched after 2", e.Status, e.Attempts, e.LastError)
+	}
+	if calls["steady"] != 1 || calls["flaky"] != 2 {
+		t.Errorf("subscribers were called %v times, want steady once and flaky twice", calls)
+	}
+}
+
+func TestRelayFailsAfterMaxAttempts(t *testing.T) {
+	outbox := &fakeOutbox{}
+	outbox.record(t, domain.ClickRecorded{LinkId: 3, Count: 1, Clicks: 1})
+
+	bus := NewBus()
+	bus.Subscribe("broken", func(context.Context, Envelope) error {
+		panic("nil map")
+	})
+	relay := newTestRelay(outbox, bus)
+
+	for range 4 {
+		if err := relay.Run(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e := outbox.events[0]
+	if e.Status != domain.OutboxFailed || e.Attempts != 3 || e.LastError != "broken: panic: nil map" {
+		t.Errorf("event is %s after %d attempts with error %q, want failed after 3", e.Status, e.Attempts, e.LastError)
+	}
+}
+
+func TestRelayBackoff(t *testing.T) {
+	relay := NewRelay(nil, nil, config.EventsConfig{BackoffBase: 5 * time.Second, BackoffMax: 30 * time.Second})
+	want := []time.Duration{5 * time.Second, 10 * time.Second, 20 * time.Second, 30 * time.Second, 30 * time.Second}
+	for i, w := range want {
+		if got := relay.backoff(i + 1); got != w {
+			t.Errorf("backoff(%d) = %s, want %s", i+1, got, w)
+		}
+	}
+}
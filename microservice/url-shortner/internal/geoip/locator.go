@@ -0,0 +1,117 @@
+// Package geoip resolves the country of visitors from a local MaxMind
+// database such as GeoLite2-Country or GeoIP2-Country.
+package geoip
+
+import (
+	"coding2fun.in/url-shortner/internal/config"
+	"coding2fun.in/url-shortner/internal/log"
+	"context"
+	"fmt"
+	"github.com/oschwald/maxminddb-golang"
+	"go.uber.org/zap"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+type countryRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+}
+
+// Locator looks up countries in the database file, which is reopened when it
+// changes. Without a configured database every country is unknown.
+type Locator struct {
+	path string
+
+	mu     sync.RWMutex
+	reader *maxminddb.Reader
+	stamp  time.Time
+}
+
+func NewLocator(cfg config.GeoIPConfig) (*Locator, error) {
+	l := &Locator{path: cfg.Database}
+	if l.path == "" {
+		return l, nil
+	}
+	if err := l.load(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Country returns the ISO 3166 code of the ip, empty when it is unknown
+func (l *Locator) Country(ip net.IP) string {
+	if l == nil || ip == nil {
+		return ""
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if l.reader == nil {
+		return ""
+	}
+	var record countryRecord
+	if err := l.reader.Lookup(ip, &record); err != nil {
+		return ""
+	}
+	return record.Country.ISOCode
+}
+
+// ReloadIfChanged reopens the database when its file was modified since it
+// was loaded. A database that fails to open keeps the previous one in place.
+func (l *Locator) ReloadIfChanged(_ context.Context) error {
+	if l.path == "" {
+		return nil
+	}
+	info, err := os.Stat(l.path)
+	if err != nil {
+		return fmt.Errorf("failed to stat geoip database: %w", err)
+	}
+
+	l.mu.RLock()
+	changed := !info.ModTime().Equal(l.stamp)
+	l.mu.RUnlock()
+
+	if !changed {
+		return nil
+	}
+	return l.load()
+}
+
+func (l *Locator) load() error {
+	info, err := os.Stat(l.path)
+	if err != nil {
+		return fmt.Errorf("failed to stat geoip database: %w", err)
+	}
+	reader, err := maxminddb.Open(l.path)
+	if err != nil {
+		return fmt.Errorf("failed to open geoip database: %w", err)
+	}
+	log.Info("Loaded geoip database", zap.String("type", reader.Metadata.DatabaseType), zap.Uint("build", reader.Metadata.BuildEpoch))
+
+	l.mu.Lock()
+	previous := l.reader
+	l.reader, l.stamp = reader, info.ModTime()
+	l.mu.Unlock()
+
+	if previous != nil {
+		return previous.Close()
+	}
+	return nil
+}
+
+func (l *Locator) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.reader == nil {
+		return nil
+	}
+	err := l.reader.Close()
+	l.reader = nil
+	return err
+}
@@ -0,0 +1,91 @@
+package jobs
+
+import (
+	"coding2fun.in/url-shortner/internal/cache"
+	"coding2fun.in/url-shortner/internal/config"
+	"coding2fun.in/url-shortner/internal/domain"
+	"coding2fun.in/url-shortner/internal/log"
+	"context"
+	"go.uber.org/zap"
+	"time"
+)
+
+// Retention deactivates expired links and API keys and purges soft deleted
+// rows once they are older than the retention period
+type Retention struct {
+	repo  domain.RetentionRepository
+	links *cache.LinkCache
+	cfg   config.JobsConfig
+}
+
+func NewRetention(repo domain.RetentionRepository, links *cache.LinkCache, cfg config.JobsConfig) *Retention {
+	return &Retention{repo: repo, links: links, cfg: cfg}
+}
+
+// Jobs returns the retention jobs with their configured schedules
+func (r *Retention) Jobs() []Job {
+	return []Job{
+		{Name: "expire-links", Interval: r.cfg.ExpiryInterval, Run: r.ExpireLinks},
+		{Name: "expire-api-keys", Interval: r.cfg.ExpiryInterval, Run: r.ExpireAPIKeys},
+		{Name: "purge-deleted", Interval: r.cfg.PurgeInterval, Run: r.PurgeDeleted},
+	}
+}
+
+func (r *Retention) ExpireLinks(ctx context.Context) error {
+	total := 0
+	for ctx.Err() == nil {
+		expired, err := r.repo.DeactivateExpiredURLs(ctx, time.Now(), r.cfg.BatchSize)
+		if err != nil {
+			return err
+		}
+		for _, url := range expired {
+			r.links.Invalidate(url.DomainId, url.ShortCode)
+		}
+		total += len(expired)
+		if len(expired) < r.cfg.BatchSize {
+			break
+		}
+	}
+	if total > 0 {
+		log.Info("Deactivated expired links", zap.Int("links", total))
+	}
+	return nil
+}
+
+func (r *Retention) ExpireAPIKeys(ctx context.Context) error {
+	var total int64
+	for ctx.Err() == nil {
+		n, err := r.repo.DeactivateExpiredAPIKeys(ctx, time.Now(), r.cfg.BatchSize)
+		if err != nil {
+			return err
+		}
+		total += n
+		if n < int64(r.cfg.BatchSize) {
+			break
+		}
+	}
+	if total > 0 {
+		log.Info("Deactivated expired api keys", zap.Int64("keys", total))
+	}
+	return nil
+}
+
+func (r *Retention) PurgeDeleted(ctx context.Context) error {
+	before := time.Now().Add(-r.cfg.PurgeRetention)
+	for ctx.Err() == nil {
+		purged, err := r.repo.PurgeDeleted(ctx, before, r.cfg.BatchSize)
+		if err != nil {
+			return err
+		}
+
+		more := false
+		for table, n := range purged {
+			log.Info("Purged soft deleted rows", zap.String("table", table), zap.Int64("rows", n))
+			more = more || n >= int64(r.cfg.BatchSize)
+		}
+		if !more {
+			break
+		}
+	}
+	return nil
+}
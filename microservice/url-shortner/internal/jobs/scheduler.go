@@ -0,0 +1,85 @@
+package jobs
+
+import (
+	"coding2fun.in/url-shortner/internal/log"
+	"context"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+// Job is a unit of background work run periodically by the Scheduler
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Scheduler runs every registered job on its own interval. A job never overlaps
+// with itself, a run that takes longer than the interval delays the next one.
+type Scheduler struct {
+	jobs   []Job
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewScheduler() *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{ctx: ctx, cancel: cancel}
+}
+
+// Register adds a job, jobs must be registered before Start. A job without a
+// positive interval is never run.
+func (s *Scheduler) Register(job Job) {
+	if job.Interval <= 0 {
+		log.Warn("Not scheduling background job without a positive interval", zap.String("job", job.Name), zap.Duration("interval", job.Interval))
+		return
+	}
+	s.jobs = append(s.jobs, job)
+}
+
+func (s *Scheduler) Start() {
+	for _, job := range s.jobs {
+		log.Info("Scheduling background job", zap.String("job", job.Name), zap.Duration("interval", job.Interval))
+		s.wg.Add(1)
+		go s.loop(job)
+	}
+}
+
+// Stop cancels running jobs and waits for them to return
+func (s *Scheduler) Stop() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(job Job) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			s.run(job)
+		}
+	}
+}
+
+func (s *Scheduler) run(job Job) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error("Background job panicked", zap.String("job", job.Name), zap.Any("panic", r))
+		}
+	}()
+
+	start := time.Now()
+	if err := job.Run(s.ctx); err != nil {
+		log.Error("Background job failed", zap.String("job", job.Name), zap.Error(err))
+		return
+	}
+	log.Debug("Background job finished", zap.String("job", job.Name), zap.Duration("took", time.Since(start)))
+}
@@ -5,7 +5,8 @@ import (
 	"go.uber.org/zap/zapcore"
 )
 
-var Logger *zap.Logger
+// Logger discards messages until InitLogger replaces it
+var Logger = zap.NewNop()
 
 // InitLogger initializes the logger based on the config
 func InitLogger(logLevel, mode string) {
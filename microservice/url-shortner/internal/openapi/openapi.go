@@ -0,0 +1,193 @@
+// Package openapi holds the OpenAPI 3 description of the /v1 API, the page
+// rendering it and a validator matching requests and responses against it.
+package openapi
+
+import (
+	"bytes"
+	"coding2fun.in/url-shortner/internal/domain"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+//go:embed openapi.yaml
+var Spec []byte
+
+//go:embed docs.html
+var DocsPage []byte
+
+var (
+	// ErrUnknownRoute is returned for requests the spec does not describe
+	ErrUnknownRoute = errors.New("route is not described by the spec")
+	// ErrMalformedBody is returned for request bodies that cannot be decoded
+	ErrMalformedBody = errors.New("malformed request body")
+)
+
+func init() {
+	// Violations are logged, the full schema in every message drowns the reason
+	openapi3.SchemaErrorDetailsDisabled = true
+}
+
+// Load parses and validates the embedded spec
+func Load() (*openapi3.T, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(Spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the openapi spec: %w", err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("invalid openapi spec: %w", err)
+	}
+	return doc, nil
+}
+
+// JSON is the embedded spec converted to JSON
+var JSON = sync.OnceValues(func() ([]byte, error) {
+	doc, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	return doc.MarshalJSON()
+})
+
+// Validator checks traffic against the spec
+type Validator struct {
+	router routers.Router
+}
+
+func NewValidator() (*Validator, error) {
+	doc, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to route the openapi spec: %w", err)
+	}
+	return &Validator{router: router}, nil
+}
+
+// Operation is a request matched to the operation of the spec serving it
+type Operation struct {
+	input *openapi3filter.RequestValidationInput
+}
+
+// Match finds the operation of a request
+func (v *Validator) Match(req *http.Request) (*Operation, error) {
+	route, params, err := v.router.FindRoute(req)
+	if errors.Is(err, routers.ErrPathNotFound) || errors.Is(err, routers.ErrMethodNotAllowed) {
+		return nil, ErrUnknownRoute
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Operation{input: &openapi3filter.RequestValidationInput{
+		Request:    req,
+		PathParams: params,
+		Route:      route,
+	}}, nil
+}
+
+func (op *Operation) ID() string {
+	return op.input.Route.Operation.OperationID
+}
+
+// ValidateRequest checks the parameters of the request and, when it is JSON,
+// its body. Mismatches are returned as a domain.ValidationError listing every
+// offending field, undecodable bodies as ErrMalformedBody.
+func (op *Operation) ValidateRequest(ctx context.Context) error {
+	op.input.Options = &openapi3filter.Options{
+		ExcludeRequestBody:  !isJSON(op.input.Request.Header.Get("Content-Type")),
+		MultiError:          true,
+		SkipSettingDefaults: true,
+		AuthenticationFunc:  openapi3filter.NoopAuthenticationFunc,
+	}
+	err := openapi3filter.ValidateRequest(ctx, op.input)
+	if err == nil {
+		return nil
+	}
+
+	verr := &domain.ValidationError{}
+	if malformed := collect(verr, "", err); malformed {
+		return ErrMalformedBody
+	}
+	if !verr.HasErrors() {
+		verr.Add("request", err.Error())
+	}
+	return verr
+}
+
+// ValidateResponse checks the status, headers and, when it is JSON, the body
+// of the response to the operation
+func (op *Operation) ValidateResponse(ctx context.Context, status int, header http.Header, body []byte) error {
+	return openapi3filter.ValidateResponse(ctx, &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: op.input,
+		Status:                 status,
+		Header:                 header,
+		Body:                   io.NopCloser(bytes.NewReader(body)),
+		Options: &openapi3filter.Options{
+			ExcludeResponseBody:   !isJSON(header.Get("Content-Type")),
+			IncludeResponseStatus: true,
+		},
+	})
+}
+
+// collect flattens the validation errors into field errors, it reports whether
+// the body could not be decoded at all
+func collect(verr *domain.ValidationError, field string, err error) bool {
+	switch e := err.(type) {
+	case openapi3.MultiError:
+		malformed := false
+		for _, inner := range e {
+			malformed = collect(verr, field, inner) || malformed
+		}
+		return malformed
+	case *openapi3filter.RequestError:
+		if e.Parameter != nil {
+			field = e.Parameter.Name
+		}
+		switch inner := e.Err.(type) {
+		case nil:
+			verr.Add(fieldOr(field, "body"), e.Reason)
+		case openapi3.MultiError, *openapi3.SchemaError:
+			return collect(verr, field, inner)
+		default:
+			// A body that could not be read or decoded
+			if e.Parameter == nil {
+				return true
+			}
+			verr.Add(field, inner.Error())
+		}
+		return false
+	case *openapi3.SchemaError:
+		if pointer := e.JSONPointer(); len(pointer) > 0 {
+			field = strings.Join(pointer, ".")
+		}
+		verr.Add(fieldOr(field, "body"), e.Reason)
+		return false
+	default:
+		verr.Add(fieldOr(field, "request"), err.Error())
+		return false
+	}
+}
+
+func fieldOr(field, fallback string) string {
+	if field == "" {
+		return fallback
+	}
+	return field
+}
+
+func isJSON(contentType string) bool {
+	media, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (media == "application/json" || strings.HasSuffix(media, "+json"))
+}
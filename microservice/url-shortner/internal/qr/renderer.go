@@ -0,0 +1,215 @@
+package qr
+
+import (
+	"bytes"
+	"coding2fun.in/url-shortner/internal/config"
+	"coding2fun.in/url-shortner/internal/domain"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/skip2/go-qrcode"
+	xdraw "golang.org/x/image/draw"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg"
+	"image/png"
+	"os"
+	"strings"
+)
+
+// logoRatio is the share of the symbol width a center logo may cover, small
+// enough for the high recovery levels to restore the modules underneath
+const logoRatio = 0.2
+
+type Format string
+
+const (
+	PNG Format = "png"
+	SVG Format = "svg"
+)
+
+func (f Format) ContentType() string {
+	if f == SVG {
+		return "image/svg+xml"
+	}
+	return "image/png"
+}
+
+// Options describes a single rendering of a QR code
+type Options struct {
+	Format Format
+	// Size is the edge length of the square output in pixels
+	Size  int
+	Level qrcode.RecoveryLevel
+	// Margin is the quiet zone around the symbol in modules
+	Margin     int
+	Foreground color.RGBA
+	Background color.RGBA
+	Logo       bool
+}
+
+// Renderer draws QR codes as PNG or SVG with an optional center logo
+type Renderer struct {
+	logo    image.Image
+	logoPNG []byte
+	logoSum string
+}
+
+func NewRenderer(cfg config.QRConfig) (*Renderer, error) {
+	r := &Renderer{}
+	if cfg.LogoPath == "" {
+		return r, nil
+	}
+
+	raw, err := os.ReadFile(cfg.LogoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read qr logo: %w", err)
+	}
+	logo, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode qr logo %s: %w", cfg.LogoPath, err)
+	}
+
+	// SVG output embeds the logo, re-encoding keeps it to a single image type
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, logo); err != nil {
+		return nil, fmt.Errorf("failed to encode qr logo: %w", err)
+	}
+
+	sum := sha256.Sum256(raw)
+	r.logo = logo
+	r.logoPNG = buf.Bytes()
+	r.logoSum = hex.EncodeToString(sum[:8])
+	return r, nil
+}
+
+// HasLogo reports whether a center logo is configured
+func (r *Renderer) HasLogo() bool {
+	return r.logo != nil
+}
+
+// ETag identifies the output of Render for the same content and options
+func (r *Renderer) ETag(content string, opts Options) string {
+	opts = r.effective(opts)
+	key := fmt.Sprintf("%s|%s|%d|%d|%d|%s|%s|%t|%s",
+		content, opts.Format, opts.Size, opts.Level, opts.Margin,
+		hexColor(opts.Foreground), hexColor(opts.Background), opts.Logo, r.logoSum)
+	sum := sha256.Sum256([]byte(key))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+func (r *Renderer) Render(content string, opts Options) ([]byte, error) {
+	opts = r.effective(opts)
+
+	code, err := qrcode.New(content, opts.Level)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode qr code: %w", err)
+	}
+	code.DisableBorder = true
+	modules := code.Bitmap()
+
+	if opts.Format == SVG {
+		return r.svg(modules, opts), nil
+	}
+
+	total := len(modules) + 2*opts.Margin
+	if opts.Size < total {
+		return nil, domain.NewValidationError("size", fmt.Sprintf("must be at least %d pixels for this code", total))
+	}
+	return r.png(modules, opts)
+}
+
+// effective raises the recovery level when a logo hides part of the symbol
+func (r *Renderer) effective(opts Options) Options {
+	opts.Logo = opts.Logo && r.logo != nil
+	if opts.Logo && opts.Level < qrcode.High {
+		opts.Level = qrcode.High
+	}
+	return opts
+}
+
+func (r *Renderer) png(modules [][]bool, opts Options) ([]byte, error) {
+	total := len(modules) + 2*opts.Margin
+	scale := opts.Size / total
+	// Spread the pixels left over by the integer scale evenly around the symbol
+	offset := (opts.Size-scale*total)/2 + opts.Margin*scale
+
+	img := image.NewRGBA(image.Rect(0, 0, opts.Size, opts.Size))
+	draw.Draw(img, img.Bounds(), image.NewUniform(opts.Background), image.Point{}, draw.Src)
+	dark := image.NewUniform(opts.Foreground)
+	for y, row := range modules {
+		for x, set := range row {
+			if set {
+				rect := image.Rect(offset+x*scale, offset+y*scale, offset+(x+1)*scale, offset+(y+1)*scale)
+				draw.Draw(img, rect, dark, image.Point{}, draw.Src)
+			}
+		}
+	}
+
+	if opts.Logo {
+		box := int(float64(len(modules)*scale) * logoRatio)
+		origin := (opts.Size - box) / 2
+		area := image.Rect(origin, origin, origin+box, origin+box)
+		draw.Draw(img, area.Inset(-scale), image.NewUniform(opts.Background), image.Point{}, draw.Src)
+		xdraw.CatmullRom.Scale(img, fit(area, r.logo.Bounds()), r.logo, r.logo.Bounds(), draw.Over, nil)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode qr png: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// svg draws the symbol in module units, each run of dark modules in a row is one path segment
+func (r *Renderer) svg(modules [][]bool, opts Options) []byte {
+	total := len(modules) + 2*opts.Margin
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		opts.Size, opts.Size, total, total)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="%s"/>`, total, total, hexColor(opts.Background))
+	fmt.Fprintf(&b, `<path fill="%s" d="`, hexColor(opts.Foreground))
+	for y, row := range modules {
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+			fmt.Fprintf(&b, "M%d %dh%dv1h-%dz", start+opts.Margin, y+opts.Margin, x-start, x-start)
+		}
+	}
+	b.WriteString(`"/>`)
+
+	if opts.Logo {
+		box := float64(len(modules)) * logoRatio
+		origin := (float64(total) - box) / 2
+		fmt.Fprintf(&b, `<rect x="%g" y="%g" width="%g" height="%g" fill="%s"/>`,
+			origin-1, origin-1, box+2, box+2, hexColor(opts.Background))
+		fmt.Fprintf(&b, `<image x="%g" y="%g" width="%g" height="%g" href="data:image/png;base64,%s"/>`,
+			origin, origin, box, box, base64.StdEncoding.EncodeToString(r.logoPNG))
+	}
+	b.WriteString("</svg>")
+	return []byte(b.String())
+}
+
+// fit centers a rectangle with the aspect ratio of src inside area
+func fit(area, src image.Rectangle) image.Rectangle {
+	w, h := area.Dx(), area.Dy()
+	if src.Dx()*h > src.Dy()*w {
+		h = w * src.Dy() / src.Dx()
+	} else {
+		w = h * src.Dx() / src.Dy()
+	}
+	origin := image.Pt(area.Min.X+(area.Dx()-w)/2, area.Min.Y+(area.Dy()-h)/2)
+	return image.Rectangle{Min: origin, Max: origin.Add(image.Pt(w, h))}
+}
+
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
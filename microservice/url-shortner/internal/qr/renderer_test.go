@@ -0,0 +1,203 @@
+package qr
+
+import (
+	"bytes"
+	"coding2fun.in/url-shortner/internal/config"
+	"coding2fun.in/url-shortner/internal/domain"
+	"encoding/xml"
+	"errors"
+	"github.com/skip2/go-qrcode"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+const testContent = "https://sho.rt/abc"
+
+func testOptions(format Format, size int) Options {
+	return Options{
+		Format:     format,
+		Size:       size,
+		Level:      qrcode.Medium,
+		Margin:     4,
+		Foreground: color.RGBA{A: 0xff},
+		Background: color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+	}
+}
+
+// newLogoRenderer configures a plain red square as the logo
+func newLogoRenderer(t *testing.T) *Renderer {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for i := range img.Pix {
+		if i%4 == 0 || i%4 == 3 {
+			img.Pix[i] = 0xff
+		}
+	}
+	path := filepath.Join(t.TempDir(), "logo.png")
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewRenderer(config.QRConfig{LogoPath: path})
+	if err != nil {
+		t.Fatalf("NewRenderer() error = %v", err)
+	}
+	return r
+}
+
+func TestRenderPNGAtTheRequestedSize(t *testing.T) {
+	r, err := NewRenderer(config.QRConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, size := range []int{64, 257, 1024} {
+		out, err := r.Render(testContent, testOptions(PNG, size))
+		if err != nil {
+			t.Fatalf("Render() at %d pixels error = %v", size, err)
+		}
+		img, err := png.Decode(bytes.NewReader(out))
+		if err != nil {
+			t.Fatalf("Render() at %d pixels is no png: %v", size, err)
+		}
+		if bounds := img.Bounds(); bounds.Dx() != size || bounds.Dy() != size {
+			t.Errorf("Render() at %d pixels is %dx%d", size, bounds.Dx(), bounds.Dy())
+		}
+		// The quiet zone keeps the corners in the background color
+		if got := color.RGBAModel.Convert(img.At(0, 0)); got != (color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}) {
+			t.Errorf("Render() at %d pixels has corner %v, want the background", size, got)
+		}
+	}
+}
+
+func TestRenderSVGAtTheRequestedSize(t *testing.T) {
+	r, err := NewRenderer(config.QRConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := r.Render(testContent, testOptions(SVG, 300))
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	var svg struct {
+		XMLName xml.Name `xml:"svg"`
+		Width   string   `xml:"width,attr"`
+		Height  string   `xml:"height,attr"`
+		Path    struct {
+			Fill string `xml:"fill,attr"`
+		} `xml:"path"`
+	}
+	if err := xml.Unmarshal(out, &svg); err != nil {
+		t.Fatalf("Render() is no svg: %v", err)
+	}
+	if svg.Width != "300" || svg.Height != "300" {
+		t.Errorf("Render() svg is %sx%s, want 300x300", svg.Width, svg.Height)
+	}
+	if svg.Path.Fill != "#000000" {
+		t.Errorf("Render() svg modules are %s, want #000000", svg.Path.Fill)
+	}
+}
+
+func TestRenderRejectsASizeBelowTheSymbol(t *testing.T) {
+	r, err := NewRenderer(config.QRConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := testOptions(PNG, 20)
+	if _, err := r.Render(testContent, opts); !errors.Is(err, domain.ErrValidation) {
+		t.Errorf("Render() at 20 pixels error = %v, want a validation error", err)
+	}
+	// An svg scales, any size fits the symbol
+	opts.Format = SVG
+	if _, err := r.Render(testContent, opts); err != nil {
+		t.Errorf("Render() svg at 20 pixels error = %v", err)
+	}
+}
+
+func TestLogoRaisesTheRecoveryLevel(t *testing.T) {
+	r := newLogoRenderer(t)
+	tests := []struct {
+		level qrcode.RecoveryLevel
+		logo  bool
+		want  qrcode.RecoveryLevel
+	}{
+		{qrcode.Low, true, qrcode.High},
+		{qrcode.Medium, true, qrcode.High},
+		{qrcode.High, true, qrcode.High},
+		{qrcode.Highest, true, qrcode.Highest},
+		{qrcode.Low, false, qrcode.Low},
+	}
+	for _, tt := range tests {
+		opts := testOptions(PNG, 256)
+		opts.Level, opts.Logo = tt.level, tt.logo
+		if got := r.effective(opts).Level; got != tt.want {
+			t.Errorf("effective() of level %d with logo %t = %d, want %d", tt.level, tt.logo, got, tt.want)
+		}
+	}
+
+	// Without a configured logo the level is kept
+	plain, err := NewRenderer(config.QRConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := testOptions(PNG, 256)
+	opts.Logo = true
+	if got := plain.effective(opts); got.Logo || got.Level != qrcode.Medium {
+		t.Errorf("effective() without a logo = logo %t, level %d", got.Logo, got.Level)
+	}
+
+	for _, format := range []Format{PNG, SVG} {
+		opts := testOptions(format, 256)
+		opts.Logo = true
+		if _, err := r.Render(testContent, opts); err != nil {
+			t.Errorf("Render() %s with a logo error = %v", format, err)
+		}
+	}
+}
+
+func TestETag(t *testing.T) {
+	r := newLogoRenderer(t)
+	base := testOptions(PNG, 256)
+	etag := r.ETag(testContent, base)
+	if again := r.ETag(testContent, base); again != etag {
+		t.Errorf("ETag() changed between calls: %s, %s", etag, again)
+	}
+	if _, err := strconv.Unquote(etag); err != nil {
+		t.Errorf("ETag() = %s, want a quoted tag", etag)
+	}
+
+	changes := map[string]func(*Options){
+		"format":     func(o *Options) { o.Format = SVG },
+		"size":       func(o *Options) { o.Size = 512 },
+		"level":      func(o *Options) { o.Level = qrcode.Low },
+		"margin":     func(o *Options) { o.Margin = 0 },
+		"foreground": func(o *Options) { o.Foreground = color.RGBA{B: 0xff, A: 0xff} },
+		"background": func(o *Options) { o.Background = color.RGBA{G: 0xff, A: 0xff} },
+		"logo":       func(o *Options) { o.Logo = true },
+	}
+	for name, change := range changes {
+		opts := base
+		change(&opts)
+		if r.ETag(testContent, opts) == etag {
+			t.Errorf("ETag() ignores the %s", name)
+		}
+	}
+	if r.ETag("https://sho.rt/other", base) == etag {
+		t.Error("ETag() ignores the content")
+	}
+
+	// Levels the logo raises render the same image
+	low, high := base, base
+	low.Logo, low.Level = true, qrcode.Low
+	high.Logo, high.Level = true, qrcode.High
+	if r.ETag(testContent, low) != r.ETag(testContent, high) {
+		t.Error("ETag() differs for levels the logo raises to the same one")
+	}
+}
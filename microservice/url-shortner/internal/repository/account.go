@@ -0,0 +1,252 @@
+package repository
+
+import (
+	"coding2fun.in/url-shortner/database"
+	"coding2fun.in/url-shortner/internal/domain"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+const apiKeyPrefix = "usk_"
+
+type accountRepository struct {
+	db database.Service
+}
+
+func NewAccountRepository(db database.Service) domain.AccountRepository {
+	return &accountRepository{db: db}
+}
+
+func (r *accountRepository) Create(ctx context.Context, account *domain.Account) error {
+	err := r.db.GetConnection().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(account).Error; err != nil {
+			return err
+		}
+		org := &domain.Organization{Name: account.Email}
+		if err := createOrganization(tx, org, account.ID); err != nil {
+			return err
+		}
+		account.OrganizationId = org.ID
+		return tx.Model(account).Update("organization_id", org.ID).Error
+	})
+	return database.TranslateError(err)
+}
+
+func (r *accountRepository) Get(ctx context.Context, id uint) (*domain.Account, error) {
+	var account domain.Account
+	if err := r.db.GetConnection().WithContext(ctx).First(&account, id).Error; err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return &account, nil
+}
+
+// Activate turns the account on and records AccountActivated with the change
+func (r *accountRepository) Activate(ctx context.Context, id uint) error {
+	err := r.db.GetConnection().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&domain.Account{}).
+			Where("id = ?", id).
+			Update("is_active", true)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("account %d: %w", id, domain.ErrNotFound)
+		}
+		return recordEvents(tx, domain.AccountActivated{AccountId: id})
+	})
+	if err != nil {
+		return database.TranslateError(err)
+	}
+	return nil
+}
+
+// CreateAPIKey issues a new key for an active account. Only the SHA-256 hash is
+// stored, the plain key is returned once to the caller.
+func (r *accountRepository) CreateAPIKey(ctx context.Context, key *domain.APIKey) (string, error) {
+	var plain string
+	err := r.db.GetConnection().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if plain, err = createAPIKey(tx, key); err != nil {
+			return err
+		}
+		return recordEvents(tx, domain.APIKeyCreated{KeyId: key.ID, AccountId: key.AccountId, Name: key.Name})
+	})
+	if err != nil {
+		return "", database.TranslateError(err)
+	}
+	return plain, nil
+}
+
+func (r *accountRepository) GetAPIKey(ctx context.Context, accountId, id uint) (*domain.APIKey, error) {
+	var key domain.APIKey
+	err := r.db.GetConnection().WithContext(ctx).Where("account_id = ?", accountId).First(&key, id).Error
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return &key, nil
+}
+
+func (r *accountRepository) ListAPIKeys(ctx context.Context, accountId uint) ([]domain.APIKey, error) {
+	var keys []domain.APIKey
+	err := r.db.GetConnection().WithContext(ctx).Where("account_id = ?", accountId).Order("id").Find(&keys).Error
+	return keys, database.TranslateError(err)
+}
+
+// RotateAPIKey locks the old key so concurrent rotations issue a single successor
+func (r *accountRepository) RotateAPIKey(ctx context.Context, accountId, id uint, graceUntil time.Time) (*domain.APIKey, *domain.APIKey, string, error) {
+	var old, key domain.APIKey
+	var plain string
+	err := r.db.GetConnection().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("account_id = ?", accountId).
+			First(&old, id).Error
+		if err != nil {
+			return err
+		}
+		if !old.IsActive || (!old.ExpiresAt.IsZero() && !time.Now().Before(old.ExpiresAt)) {
+			return fmt.Errorf("api key %d is not active: %w", id, domain.ErrConflict)
+		}
+		if old.RotatedToId != 0 {
+			return fmt.Errorf("api key %d was already rotated to %d: %w", id, old.RotatedToId, domain.ErrConflict)
+		}
+
+		key = domain.APIKey{
+			AccountId:      old.AccountId,
+			Name:           old.Name,
+			ExpiresAt:      old.ExpiresAt,
+			Scopes:         old.Scopes,
+			AllowedDomains: old.AllowedDomains,
+			AllowedCIDRs:   old.AllowedCIDRs,
+		}
+		if plain, err = createAPIKey(tx, &key); err != nil {
+			return err
+		}
+		err = recordEvents(tx, domain.APIKeyCreated{KeyId: key.ID, AccountId: key.AccountId, Name: key.Name, RotatedFromId: old.ID})
+		if err != nil {
+			return err
+		}
+
+		if old.ExpiresAt.IsZero() || graceUntil.Before(old.ExpiresAt) {
+			old.ExpiresAt = graceUntil
+		}
+		old.RotatedToId = key.ID
+		return tx.Model(&old).Select("expires_at", "rotated_to_id").Updates(&old).Error
+	})
+	if err != nil {
+		return nil, nil, "", database.TranslateError(err)
+	}
+	return &old, &key, plain, nil
+}
+
+func (r *accountRepository) DeactivateAPIKey(ctx context.Context, accountId, id uint) error {
+	err := r.db.GetConnection().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var key domain.APIKey
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "account_id", "is_active").
+			Where("account_id = ?", accountId).
+			First(&key, id).Error
+		if err != nil {
+			return err
+		}
+		if !key.IsActive {
+			return nil
+		}
+		if err := tx.Model(&key).Update("is_active", false).Error; err != nil {
+			return err
+		}
+		return recordEvents(tx, domain.APIKeyRevoked{KeyId: key.ID, AccountId: accountId, Reason: domain.KeyRevoked})
+	})
+	return database.TranslateError(err)
+}
+
+func (r *accountRepository) DeactivateAPIKeys(ctx context.Context, accountId uint) (int64, error) {
+	var n int64
+	err := r.db.GetConnection().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var ids []uint
+		err := tx.Model(&domain.APIKey{}).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("account_id = ? AND is_active = ?", accountId, true).
+			Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+
+		result := tx.Model(&domain.APIKey{}).Where("id IN ?", ids).Update("is_active", false)
+		if result.Error != nil {
+			return result.Error
+		}
+		n = result.RowsAffected
+
+		events := make([]domain.Event, 0, len(ids))
+		for _, id := range ids {
+			events = append(events, domain.APIKeyRevoked{KeyId: id, AccountId: accountId, Reason: domain.KeyRevoked})
+		}
+		return recordEvents(tx, events...)
+	})
+	return n, database.TranslateError(err)
+}
+
+// FindAPIKey looks up a key by its plain value together with the owning account
+func (r *accountRepository) FindAPIKey(ctx context.Context, apiKey string) (*domain.APIKey, *domain.Account, error) {
+	db := r.db.GetConnection().WithContext(ctx)
+
+	var key domain.APIKey
+	if err := db.Where("key = ?", hashAPIKey(apiKey)).First(&key).Error; err != nil {
+		return nil, nil, database.TranslateError(err)
+	}
+
+	var account domain.Account
+	if err := db.First(&account, key.AccountId).Error; err != nil {
+		return nil, nil, database.TranslateError(err)
+	}
+	return &key, &account, nil
+}
+
+func (r *accountRepository) TouchAPIKey(ctx context.Context, id uint) error {
+	err := r.db.GetConnection().WithContext(ctx).
+		Model(&domain.APIKey{}).
+		Where("id = ?", id).
+		UpdateColumn("last_used", time.Now()).Error
+	return database.TranslateError(err)
+}
+
+// createAPIKey generates the key's value within tx, the account has to be active
+func createAPIKey(tx *gorm.DB, key *domain.APIKey) (string, error) {
+	var account domain.Account
+	if err := tx.First(&account, key.AccountId).Error; err != nil {
+		return "", err
+	}
+	if !account.IsActive {
+		return "", fmt.Errorf("account %d is not active: %w", key.AccountId, domain.ErrForbidden)
+	}
+
+	plain, err := generateAPIKey()
+	if err != nil {
+		return "", err
+	}
+	key.Key = hashAPIKey(plain)
+	key.IsActive = true
+	if err := tx.Create(key).Error; err != nil {
+		return "", err
+	}
+	return plain, nil
+}
+
+func generateAPIKey() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	return apiKeyPrefix + hex.EncodeToString(b), nil
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"coding2fun.in/url-shortner/database"
+	"coding2fun.in/url-shortner/internal/domain"
+	"context"
+	"gorm.io/gorm"
+)
+
+type archiveRepository struct {
+	db database.Service
+}
+
+func NewArchiveRepository(db database.Service) domain.ArchiveRepository {
+	return &archiveRepository{db: db}
+}
+
+// Links reads the primary like Versions and Analytics, an export holds every
+// change made before it was asked for
+func (r *archiveRepository) Links(ctx context.Context, orgId, afterId uint, limit int) ([]domain.ShortUrl, error) {
+	var urls []domain.ShortUrl
+	err := r.db.GetConnection().WithContext(ctx).
+		Preload("Tags").
+		Preload("Rules", orderedRules).
+		Preload("Variants", orderedRules).
+		Where("organization_id = ? AND id > ?", orgId, afterId).
+		Order("id").
+		Limit(limit).
+		Find(&urls).Error
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return urls, nil
+}
+
+func (r *archiveRepository) Versions(ctx context.Context, urlIds []uint) ([]domain.LinkVersion, error) {
+	var versions []domain.LinkVersion
+	if len(urlIds) == 0 {
+		return versions, nil
+	}
+	err := r.db.GetConnection().WithContext(ctx).
+		Where("short_url_id IN ?", urlIds).
+		Order("short_url_id, version").
+		Find(&versions).Error
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return versions, nil
+}
+
+func (r *archiveRepository) Analytics(ctx context.Context, urlIds []uint) ([]domain.URLAnalytics, error) {
+	var analytics []domain.URLAnalytics
+	if len(urlIds) == 0 {
+		return analytics, nil
+	}
+	err := r.db.GetConnection().WithContext(ctx).
+		Where("short_url_id IN ?", urlIds).
+		Order("short_url_id").
+		Find(&analytics).Error
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return analytics, nil
+}
+
+// TakenCodes reads the primary, a deleted link keeps its code until it is purged
+func (r *archiveRepository) TakenCodes(ctx context.Context, domainId uint, codes []string) (map[string]bool, error) {
+	taken := make(map[string]bool)
+	if len(codes) == 0 {
+		return taken, nil
+	}
+
+	var used []string
+	err := r.db.GetConnection().WithContext(ctx).Unscoped().
+		Model(&domain.ShortUrl{}).
+		Where("domain_id = ? AND short_code IN ?", domainId, codes).
+		Pluck("short_code", &used).Error
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	for _, code := range used {
+		taken[code] = true
+	}
+	return taken, nil
+}
+
+func (r *archiveRepository) ImportURL(ctx context.Context, url *domain.ShortUrl, versions []domain.LinkVersion, analytics *domain.URLAnalytics) error {
+	// The column default turns a false is_active into true on insert
+	active := url.IsActive
+	err := r.db.GetConnection().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := createURL(tx, url); err != nil {
+			return err
+		}
+		if !active {
+			if err := tx.Model(url).Update("is_active", false).Error; err != nil {
+				return err
+			}
+		}
+		for i := range versions {
+			versions[i].ShortURLId = url.ID
+		}
+		if len(versions) > 0 {
+			if err := tx.Create(&versions).Error; err != nil {
+				return err
+			}
+		}
+		if analytics != nil {
+			analytics.ShortURLId = url.ID
+			if err := tx.Create(analytics).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return database.TranslateError(err)
+	}
+	r.db.MarkWritten(linkKey(url.DomainId, url.ShortCode))
+	return nil
+}
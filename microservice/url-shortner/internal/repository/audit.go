@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"coding2fun.in/url-shortner/database"
+	"coding2fun.in/url-shortner/internal/domain"
+	"context"
+	"gorm.io/gorm"
+	"sync"
+)
+
+// auditChainLock is the Postgres advisory lock key that serializes appends
+// across instances, two appends must never chain to the same predecessor
+const auditChainLock = 0x61756469
+
+type auditRepository struct {
+	db database.Service
+	mu sync.Mutex
+}
+
+func NewAuditRepository(db database.Service) domain.AuditRepository {
+	return &auditRepository{db: db}
+}
+
+func (r *auditRepository) Append(ctx context.Context, events []*domain.AuditEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err := r.db.GetConnection().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if tx.Dialector.Name() == "postgres" {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", auditChainLock).Error; err != nil {
+				return err
+			}
+		}
+
+		var last domain.AuditEvent
+		if err := tx.Select("hash").Order("id DESC").Limit(1).Find(&last).Error; err != nil {
+			return err
+		}
+
+		prev := last.Hash
+		for _, event := range events {
+			event.PrevHash = prev
+			event.Hash = event.ComputeHash()
+			prev = event.Hash
+		}
+		return tx.Create(events).Error
+	})
+	return database.TranslateError(err)
+}
+
+// List reads the primary, an action shows up in the log as soon as it was taken
+func (r *auditRepository) List(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEvent, error) {
+	query := r.db.GetConnection().WithContext(ctx).
+		Where("organization_id = ? OR (organization_id = 0 AND account_id = ?)", filter.OrganizationId, filter.AccountId)
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
+	if filter.BeforeId > 0 {
+		query = query.Where("id < ?", filter.BeforeId)
+	}
+
+	var events []domain.AuditEvent
+	err := query.Order("id DESC").Limit(filter.Limit).Find(&events).Error
+	return events, database.TranslateError(err)
+}
+
+func (r *auditRepository) Scan(ctx context.Context, afterId uint, limit int) ([]domain.AuditEvent, error) {
+	var events []domain.AuditEvent
+	err := r.db.GetConnection().WithContext(ctx).
+		Where("id > ?", afterId).
+		Order("id").
+		Limit(limit).
+		Find(&events).Error
+	return events, database.TranslateError(err)
+}
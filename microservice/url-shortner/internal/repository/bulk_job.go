@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"coding2fun.in/url-shortner/database"
+	"coding2fun.in/url-shortner/internal/domain"
+	"context"
+	"time"
+)
+
+type bulkJobRepository struct {
+	db database.Service
+}
+
+func NewBulkJobRepository(db database.Service) domain.BulkJobRepository {
+	return &bulkJobRepository{db: db}
+}
+
+func (r *bulkJobRepository) Create(ctx context.Context, job *domain.BulkJob) error {
+	return database.TranslateError(r.db.GetConnection().WithContext(ctx).Create(job).Error)
+}
+
+func (r *bulkJobRepository) Get(ctx context.Context, orgId, id uint) (*domain.BulkJob, error) {
+	var job domain.BulkJob
+	err := r.db.GetConnection().WithContext(ctx).
+		Where("organization_id = ?", orgId).
+		First(&job, id).Error
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return &job, nil
+}
+
+func (r *bulkJobRepository) Update(ctx context.Context, job *domain.BulkJob) error {
+	return database.TranslateError(r.db.GetConnection().WithContext(ctx).Save(job).Error)
+}
+
+func (r *bulkJobRepository) FailInterrupted(ctx context.Context) (int64, error) {
+	result := r.db.GetConnection().WithContext(ctx).
+		Model(&domain.BulkJob{}).
+		Where("status IN ?", []domain.BulkJobStatus{domain.BulkJobPending, domain.BulkJobRunning}).
+		Updates(map[string]interface{}{
+			"status":      domain.BulkJobFailed,
+			"error":       "interrupted by a server restart",
+			"finished_at": time.Now(),
+		})
+	return result.RowsAffected, database.TranslateError(result.Error)
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"coding2fun.in/url-shortner/database"
+	"coding2fun.in/url-shortner/internal/domain"
+	"context"
+	"fmt"
+	"gorm.io/gorm"
+)
+
+type domainRepository struct {
+	db database.Service
+}
+
+func NewDomainRepository(db database.Service) domain.DomainRepository {
+	return &domainRepository{db: db}
+}
+
+func (r *domainRepository) Create(ctx context.Context, d *domain.Domain) error {
+	return database.TranslateError(r.db.GetConnection().WithContext(ctx).Create(d).Error)
+}
+
+func (r *domainRepository) Get(ctx context.Context, orgId, id uint) (*domain.Domain, error) {
+	var d domain.Domain
+	err := r.db.GetConnection().WithContext(ctx).Where("organization_id = ?", orgId).First(&d, id).Error
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return &d, nil
+}
+
+func (r *domainRepository) GetByHost(ctx context.Context, orgId uint, host string) (*domain.Domain, error) {
+	var d domain.Domain
+	err := r.db.GetConnection().WithContext(ctx).Where("organization_id = ? AND host = ?", orgId, host).First(&d).Error
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return &d, nil
+}
+
+func (r *domainRepository) List(ctx context.Context, orgId uint) ([]domain.Domain, error) {
+	var domains []domain.Domain
+	err := r.db.GetConnection().WithContext(ctx).Where("organization_id = ?", orgId).Order("host").Find(&domains).Error
+	return domains, database.TranslateError(err)
+}
+
+// FindVerified serves the redirect path and reads from a replica
+func (r *domainRepository) FindVerified(ctx context.Context, host string) (*domain.Domain, error) {
+	var d domain.Domain
+	err := r.db.Reader("domain:"+host).WithContext(ctx).
+		Where("host = ? AND verified_at IS NOT NULL", host).
+		First(&d).Error
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return &d, nil
+}
+
+// FindByID reads the primary, verifying or removing a domain follows right
+// after it was added
+func (r *domainRepository) FindByID(ctx context.Context, id uint) (*domain.Domain, error) {
+	var d domain.Domain
+	if err := r.db.GetConnection().WithContext(ctx).First(&d, id).Error; err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return &d, nil
+}
+
+func (r *domainRepository) Update(ctx context.Context, d *domain.Domain) error {
+	err := r.db.GetConnection().WithContext(ctx).Save(d).Error
+	if err != nil {
+		return database.TranslateError(err)
+	}
+	r.db.MarkWritten("domain:" + d.Host)
+	return nil
+}
+
+func (r *domainRepository) Delete(ctx context.Context, orgId, id uint) error {
+	err := r.db.GetConnection().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var d domain.Domain
+		if err := tx.Where("organization_id = ?", orgId).First(&d, id).Error; err != nil {
+			return err
+		}
+
+		var links int64
+		if err := tx.Model(&domain.ShortUrl{}).Where("domain_id = ?", id).Count(&links).Error; err != nil {
+			return err
+		}
+		if links > 0 {
+			return fmt.Errorf("domain %s still serves %d links: %w", d.Host, links, domain.ErrConflict)
+		}
+		// Removed for good, the unique host may be registered again
+		return tx.Unscoped().Delete(&d).Error
+	})
+	return database.TranslateError(err)
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"coding2fun.in/url-shortner/database"
+	"coding2fun.in/url-shortner/internal/domain"
+	"context"
+	"gorm.io/gorm"
+)
+
+type folderRepository struct {
+	db database.Service
+}
+
+func NewFolderRepository(db database.Service) domain.FolderRepository {
+	return &folderRepository{db: db}
+}
+
+func (r *folderRepository) Create(ctx context.Context, folder *domain.Folder) error {
+	return database.TranslateError(r.db.GetConnection().WithContext(ctx).Create(folder).Error)
+}
+
+func (r *folderRepository) Get(ctx context.Context, orgId, id uint) (*domain.Folder, error) {
+	var folder domain.Folder
+	err := r.db.GetConnection().WithContext(ctx).Where("organization_id = ?", orgId).First(&folder, id).Error
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return &folder, nil
+}
+
+// List reads the primary, a new folder and its link counts show at once
+func (r *folderRepository) List(ctx context.Context, orgId uint) ([]domain.Folder, error) {
+	var folders []domain.Folder
+	err := r.db.GetConnection().WithContext(ctx).
+		Select(`folders.*, (SELECT COUNT(*) FROM short_urls
+			WHERE short_urls.folder_id = folders.id AND short_urls.deleted_at IS NULL) AS link_count`).
+		Where("organization_id = ?", orgId).
+		Order("name").
+		Find(&folders).Error
+	return folders, database.TranslateError(err)
+}
+
+func (r *folderRepository) Rename(ctx context.Context, orgId, id uint, name string) (*domain.Folder, error) {
+	var folder domain.Folder
+	err := r.db.GetConnection().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("organization_id = ?", orgId).First(&folder, id).Error; err != nil {
+			return err
+		}
+		return tx.Model(&folder).Update("name", name).Error
+	})
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return &folder, nil
+}
+
+func (r *folderRepository) Delete(ctx context.Context, orgId, id uint) (*domain.Folder, error) {
+	var folder domain.Folder
+	err := r.db.GetConnection().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("organization_id = ?", orgId).First(&folder, id).Error; err != nil {
+			return err
+		}
+		err := tx.Model(&domain.ShortUrl{}).Unscoped().Where("folder_id = ?", id).UpdateColumn("folder_id", 0).Error
+		if err != nil {
+			return err
+		}
+		// Removed for good, the unique name may be used again
+		return tx.Unscoped().Delete(&folder).Error
+	})
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return &folder, nil
+}
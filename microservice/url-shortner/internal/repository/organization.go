@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"coding2fun.in/url-shortner/database"
+	"coding2fun.in/url-shortner/internal/domain"
+	"context"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strings"
+	"time"
+)
+
+type organizationRepository struct {
+	db database.Service
+}
+
+func NewOrganizationRepository(db database.Service) domain.OrganizationRepository {
+	return &organizationRepository{db: db}
+}
+
+func (r *organizationRepository) Create(ctx context.Context, org *domain.Organization, ownerId uint) error {
+	err := r.db.GetConnection().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return createOrganization(tx, org, ownerId)
+	})
+	return database.TranslateError(err)
+}
+
+func createOrganization(tx *gorm.DB, org *domain.Organization, ownerId uint) error {
+	if err := tx.Create(org).Error; err != nil {
+		return err
+	}
+	return tx.Create(&domain.Membership{OrganizationId: org.ID, AccountId: ownerId, Role: domain.RoleOwner}).Error
+}
+
+func (r *organizationRepository) Memberships(ctx context.Context, accountId uint) ([]domain.Membership, error) {
+	var memberships []domain.Membership
+	err := r.db.GetConnection().WithContext(ctx).
+		Preload("Organization").
+		Where("account_id = ?", accountId).
+		Order("organization_id").
+		Find(&memberships).Error
+	return memberships, database.TranslateError(err)
+}
+
+func (r *organizationRepository) GetMembership(ctx context.Context, orgId, accountId uint) (*domain.Membership, error) {
+	var membership domain.Membership
+	err := r.db.GetConnection().WithContext(ctx).
+		Where("organization_id = ? AND account_id = ?", orgId, accountId).
+		First(&membership).Error
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return &membership, nil
+}
+
+func (r *organizationRepository) ListMembers(ctx context.Context, orgId uint) ([]domain.Membership, error) {
+	var members []domain.Membership
+	err := r.db.GetConnection().WithContext(ctx).
+		Preload("Account").
+		Where("organization_id = ?", orgId).
+		Order("id").
+		Find(&members).Error
+	return members, database.TranslateError(err)
+}
+
+func (r *organizationRepository) UpdateRole(ctx context.Context, orgId, accountId uint, role domain.Role) (domain.Role, error) {
+	var prior domain.Role
+	err := r.db.GetConnection().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		member, err := lockMember(tx, orgId, accountId)
+		if err != nil {
+			return err
+		}
+		prior = member.Role
+		if prior == domain.RoleOwner && role != domain.RoleOwner {
+			if err := keepOwner(tx, orgId); err != nil {
+				return err
+			}
+		}
+		return tx.Model(member).Update("role", role).Error
+	})
+	return prior, database.TranslateError(err)
+}
+
+func (r *organizationRepository) RemoveMember(ctx context.Context, orgId, accountId uint) (*domain.Membership, error) {
+	var removed *domain.Membership
+	err := r.db.GetConnection().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		member, err := lockMember(tx, orgId, accountId)
+		if err != nil {
+			return err
+		}
+		if member.Role == domain.RoleOwner {
+			if err := keepOwner(tx, orgId); err != nil {
+				return err
+			}
+		}
+		removed = member
+		return tx.Delete(member).Error
+	})
+	return removed, database.TranslateError(err)
+}
+
+func lockMember(tx *gorm.DB, orgId, accountId uint) (*domain.Membership, error) {
+	var member domain.Membership
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("organization_id = ? AND account_id = ?", orgId, accountId).
+		First(&member).Error
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// keepOwner fails unless the organization has another owner besides the one
+// about to be demoted or removed. The owners are locked so two concurrent
+// changes cannot both pass the check.
+func keepOwner(tx *gorm.DB, orgId uint) error {
+	var owners []domain.Membership
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id").
+		Where("organization_id = ? AND role = ?", orgId, domain.RoleOwner).
+		Find(&owners).Error
+	if err != nil {
+		return err
+	}
+	if len(owners) < 2 {
+		return fmt.Errorf("organization %d needs an owner: %w", orgId, domain.ErrConflict)
+	}
+	return nil
+}
+
+func (r *organizationRepository) CreateInvitation(ctx context.Context, invitation *domain.Invitation) error {
+	return database.TranslateError(r.db.GetConnection().WithContext(ctx).Create(invitation).Error)
+}
+
+func (r *organizationRepository) ListInvitations(ctx context.Context, orgId uint) ([]domain.Invitation, error) {
+	var invitations []domain.Invitation
+	err := r.db.GetConnection().WithContext(ctx).
+		Where("organization_id = ? AND accepted_at IS NULL", orgId).
+		Order("id DESC").
+		Find(&invitations).Error
+	return invitations, database.TranslateError(err)
+}
+
+func (r *organizationRepository) RevokeInvitation(ctx context.Context, orgId, id uint) (*domain.Invitation, error) {
+	var invitation domain.Invitation
+	err := r.db.GetConnection().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("organization_id = ? AND accepted_at IS NULL", orgId).First(&invitation, id).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&invitation).Error
+	})
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return &invitation, nil
+}
+
+func (r *organizationRepository) AcceptInvitation(ctx context.Context, tokenHash string, accountId uint, now time.Time) (*domain.Membership, error) {
+	var membership domain.Membership
+	err := r.db.GetConnection().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var invitation domain.Invitation
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", tokenHash).
+			First(&invitation).Error
+		if err != nil {
+			return err
+		}
+		if !invitation.Pending(now) {
+			return fmt.Errorf("invitation %d was accepted or expired: %w", invitation.ID, domain.ErrNotFound)
+		}
+
+		var account domain.Account
+		if err := tx.First(&account, accountId).Error; err != nil {
+			return err
+		}
+		// Invitations store the email lower cased, accounts as it was registered
+		if !strings.EqualFold(account.Email, invitation.Email) {
+			return fmt.Errorf("invitation %d is for another email: %w", invitation.ID, domain.ErrForbidden)
+		}
+
+		membership = domain.Membership{OrganizationId: invitation.OrganizationId, AccountId: accountId, Role: invitation.Role}
+		if err := tx.Create(&membership).Error; err != nil {
+			return err
+		}
+		return tx.Model(&invitation).Update("accepted_at", now).Error
+	})
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return &membership, nil
+}
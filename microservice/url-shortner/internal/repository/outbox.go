@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"coding2fun.in/url-shortner/database"
+	"coding2fun.in/url-shortner/internal/domain"
+	"context"
+	"encoding/json"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type outboxRepository struct {
+	db database.Service
+}
+
+func NewOutboxRepository(db database.Service) domain.OutboxRepository {
+	return &outboxRepository{db: db}
+}
+
+// recordEvents adds the events to the outbox within tx, they are relayed once
+// tx commits and dropped with it when it rolls back
+func recordEvents(tx *gorm.DB, events ...domain.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	rows := make([]domain.OutboxEvent, 0, len(events))
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s event: %w", event.EventType(), err)
+		}
+		rows = append(rows, domain.OutboxEvent{
+			Type:          event.EventType(),
+			Payload:       string(payload),
+			Status:        domain.OutboxPending,
+			NextAttemptAt: now,
+		})
+	}
+	return tx.CreateInBatches(&rows, enqueueBatchSize).Error
+}
+
+// ClaimDue moves the next attempt of the claimed events past the lease like
+// the webhook deliveries. Postgres skips events another instance is claiming,
+// so the order only holds within the events one relay run claims.
+func (r *outboxRepository) ClaimDue(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]domain.OutboxEvent, error) {
+	var events []domain.OutboxEvent
+	err := r.db.GetConnection().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("status = ? AND next_attempt_at <= ?", domain.OutboxPending, now).
+			Order("id").
+			Limit(limit)
+		if tx.Dialector.Name() == database.DriverPostgres {
+			query = query.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+		}
+		if err := query.Find(&events).Error; err != nil || len(events) == 0 {
+			return err
+		}
+
+		ids := make([]uint, 0, len(events))
+		for _, e := range events {
+			ids = append(ids, e.ID)
+		}
+		return tx.Model(&domain.OutboxEvent{}).
+			Where("id IN ?", ids).
+			UpdateColumn("next_attempt_at", now.Add(lease)).Error
+	})
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return events, nil
+}
+
+func (r *outboxRepository) Save(ctx context.Context, event *domain.OutboxEvent) error {
+	err := r.db.GetConnection().WithContext(ctx).
+		Model(event).
+		Select("status", "next_attempt_at", "attempts", "handled", "last_error", "dispatched_at").
+		Updates(event).Error
+	return database.TranslateError(err)
+}
+
+// Retry keeps the subscribers that handled an event, only the failed ones see it again
+func (r *outboxRepository) Retry(ctx context.Context, now time.Time, ids ...uint) (int64, error) {
+	query := r.db.GetConnection().WithContext(ctx).
+		Model(&domain.OutboxEvent{}).
+		Where("status = ?", domain.OutboxFailed)
+	if len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+	}
+	result := query.Updates(map[string]interface{}{
+		"status":          domain.OutboxPending,
+		"attempts":        0,
+		"next_attempt_at": now,
+	})
+	return result.RowsAffected, database.TranslateError(result.Error)
+}
+
+func (r *outboxRepository) Purge(ctx context.Context, before time.Time, limit int) (int64, error) {
+	db := r.db.GetConnection().WithContext(ctx)
+
+	var ids []uint
+	err := db.Model(&domain.OutboxEvent{}).
+		Where("status = ? AND created_at < ?", domain.OutboxDispatched, before).
+		Order("id").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return 0, database.TranslateError(err)
+	}
+
+	result := db.Where("id IN ?", ids).Delete(&domain.OutboxEvent{})
+	return result.RowsAffected, database.TranslateError(result.Error)
+}
@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"coding2fun.in/url-shortner/database"
+	"coding2fun.in/url-shortner/internal/domain"
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type retentionRepository struct {
+	db database.Service
+}
+
+func NewRetentionRepository(db database.Service) domain.RetentionRepository {
+	return &retentionRepository{db: db}
+}
+
+// DeactivateExpiredURLs records LinkExpired for every link it deactivates
+func (r *retentionRepository) DeactivateExpiredURLs(ctx context.Context, now time.Time, limit int) ([]domain.ShortUrl, error) {
+	var expired []domain.ShortUrl
+	err := r.db.GetConnection().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := tx.Preload("Tags").
+			Where("is_active = ? AND expires_at > ? AND expires_at <= ?", true, time.Time{}, now).
+			Limit(limit)
+		if tx.Dialector.Name() == database.DriverPostgres {
+			query = query.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+		}
+		if err := query.Find(&expired).Error; err != nil || len(expired) == 0 {
+			return err
+		}
+
+		ids := make([]uint, 0, len(expired))
+		for _, url := range expired {
+			ids = append(ids, url.ID)
+		}
+		err := tx.Model(&domain.ShortUrl{}).
+			Where("id IN ?", ids).
+			Update("is_active", false).Error
+		if err != nil {
+			return err
+		}
+
+		events := make([]domain.Event, 0, len(expired))
+		for i := range expired {
+			expired[i].IsActive = false
+			events = append(events, domain.LinkExpired{Link: domain.NewLinkState(&expired[i])})
+		}
+		return recordEvents(tx, events...)
+	})
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+
+	for _, url := range expired {
+		r.db.MarkWritten(linkKey(url.DomainId, url.ShortCode))
+	}
+	return expired, nil
+}
+
+// DeactivateExpiredAPIKeys records APIKeyRevoked for every key it deactivates
+func (r *retentionRepository) DeactivateExpiredAPIKeys(ctx context.Context, now time.Time, limit int) (int64, error) {
+	var n int64
+	err := r.db.GetConnection().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := tx.Select("id", "account_id").
+			Where("is_active = ? AND expires_at > ? AND expires_at <= ?", true, time.Time{}, now).
+			Limit(limit)
+		if tx.Dialector.Name() == database.DriverPostgres {
+			query = query.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+		}
+		var keys []domain.APIKey
+		if err := query.Find(&keys).Error; err != nil || len(keys) == 0 {
+			return err
+		}
+
+		ids := make([]uint, 0, len(keys))
+		events := make([]domain.Event, 0, len(keys))
+		for _, key := range keys {
+			ids = append(ids, key.ID)
+			events = append(events, domain.APIKeyRevoked{KeyId: key.ID, AccountId: key.AccountId, Reason: domain.KeyExpired})
+		}
+		result := tx.Model(&domain.APIKey{}).Where("id IN ?", ids).Update("is_active", false)
+		if result.Error != nil {
+			return result.Error
+		}
+		n = result.RowsAffected
+		return recordEvents(tx, events...)
+	})
+	return n, database.TranslateError(err)
+}
+
+// purgeTarget is a soft deletable table and the rows that reference it by id.
+// Rows matching a guard are kept until the guard no longer holds.
+type purgeTarget struct {
+	table      string
+	model      interface{}
+	dependents []dependentTable
+	guards     []string
+}
+
+type dependentTable struct {
+	table  string
+	column string
+}
+
+// purgeTargets lists children before their parents
+var purgeTargets = []purgeTarget{
+	{table: "url_analytics", model: &domain.URLAnalytics{}},
+	{table: "bulk_jobs", model: &domain.BulkJob{}},
+	{
+		table: "short_urls",
+		model: &domain.ShortUrl{},
+		dependents: []dependentTable{
+			{table: "url_analytics", column: "short_url_id"},
+			{table: "short_url_tags", column: "short_url_id"},
+			{table: "link_versions", column: "short_url_id"},
+			{table: "redirect_rules", column: "short_url_id"},
+			{table: "link_variants", column: "short_url_id"},
+		},
+	},
+	{
+		table:      "tags",
+		model:      &domain.Tag{},
+		dependents: []dependentTable{{table: "short_url_tags", column: "tag_id"}},
+	},
+	{table: "api_keys", model: &domain.APIKey{}},
+	{table: "invitations", model: &domain.Invitation{}},
+	{
+		table:      "accounts",
+		model:      &domain.Account{},
+		dependents: []dependentTable{{table: "memberships", column: "account_id"}},
+		guards: []string{
+			"NOT EXISTS (SELECT 1 FROM short_urls WHERE short_urls.account_id = accounts.id)",
+			"NOT EXISTS (SELECT 1 FROM api_keys WHERE api_keys.account_id = accounts.id)",
+		},
+	},
+}
+
+func (r *retentionRepository) PurgeDeleted(ctx context.Context, before time.Time, limit int) (map[string]int64, error) {
+	purged := make(map[string]int64)
+
+	for _, target := range purgeTargets {
+		var n int64
+		err := r.db.GetConnection().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			query := tx.Unscoped().Model(target.model).
+				Where(target.table+".deleted_at IS NOT NULL AND "+target.table+".deleted_at < ?", before)
+			for _, guard := range target.guards {
+				query = query.Where(guard)
+			}
+
+			var ids []uint
+			err := query.Limit(limit).Pluck(target.table+".id", &ids).Error
+			if err != nil || len(ids) == 0 {
+				return err
+			}
+
+			for _, dep := range target.dependents {
+				if err := tx.Exec("DELETE FROM "+dep.table+" WHERE "+dep.column+" IN ?", ids).Error; err != nil {
+					return err
+				}
+			}
+
+			result := tx.Unscoped().Where("id IN ?", ids).Delete(target.model)
+			n = result.RowsAffected
+			return result.Error
+		})
+		if err != nil {
+			return purged, database.TranslateError(err)
+		}
+		if n > 0 {
+			purged[target.table] = n
+		}
+	}
+	return purged, nil
+}
@@ -0,0 +1,727 @@
+package repository
+
+import (
+	"coding2fun.in/url-shortner/database"
+	"coding2fun.in/url-shortner/internal/domain"
+	"context"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type shortURLRepository struct {
+	db database.Service
+}
+
+func NewShortURLRepository(db database.Service) domain.ShortURLRepository {
+	return &shortURLRepository{db: db}
+}
+
+// linkKey is the read-your-writes key of a short code on a domain
+func linkKey(domainId uint, code string) string {
+	return "link:" + strconv.FormatUint(uint64(domainId), 10) + ":" + code
+}
+
+func (r *shortURLRepository) CreateURL(ctx context.Context, url *domain.ShortUrl) error {
+	err := r.db.GetConnection().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return createURL(tx, url)
+	})
+	if err != nil {
+		return database.TranslateError(err)
+	}
+	r.db.MarkWritten(linkKey(url.DomainId, url.ShortCode))
+	return nil
+}
+
+func (r *shortURLRepository) CreateURLs(ctx context.Context, urls []*domain.ShortUrl) ([]error, error) {
+	rowErrs := make([]error, len(urls))
+
+	err := r.db.GetConnection().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i, url := range urls {
+			// The nested transaction is a savepoint, a failing row only rolls back itself
+			err := tx.Transaction(func(rowTx *gorm.DB) error {
+				return createURL(rowTx, url)
+			})
+			rowErrs[i] = database.TranslateError(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+
+	for i, url := range urls {
+		if rowErrs[i] == nil {
+			r.db.MarkWritten(linkKey(url.DomainId, url.ShortCode))
+		}
+	}
+	return rowErrs, nil
+}
+
+// createURL inserts the url and links its tags, creating the tags the account
+// does not have yet, and records LinkCreated
+func createURL(tx *gorm.DB, url *domain.ShortUrl) error {
+	url.DestinationHost = domain.HostOf(url.OriginalURL)
+	url.SearchText = url.SearchDocument()
+	if err := upsertTags(tx, url.AccountId, url.Tags); err != nil {
+		return err
+	}
+	if err := tx.Omit("Tags.*").Create(url).Error; err != nil {
+		return err
+	}
+	return recordEvents(tx, domain.LinkCreated{Link: domain.NewLinkState(url)})
+}
+
+// upsertTags stores the tags of the account that do not exist yet and sets their ids
+func upsertTags(tx *gorm.DB, accountId uint, tags []domain.Tag) error {
+	for i := range tags {
+		tag := &tags[i]
+		tag.AccountId = accountId
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "account_id"}, {Name: "name"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"updated_at": time.Now(), "deleted_at": nil}),
+		}).Create(tag).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetSourceURL resolves a short code, it is served by a replica unless the code was just written
+func (r *shortURLRepository) GetSourceURL(ctx context.Context, domainId uint, code string) (*domain.ShortUrl, error) {
+	var url domain.ShortUrl
+	err := r.db.Reader(linkKey(domainId, code)).WithContext(ctx).
+		Preload("Rules", orderedRules).
+		Preload("Variants", orderedRules).
+		Where("domain_id = ? AND short_code = ?", domainId, code).
+		First(&url).Error
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return &url, nil
+}
+
+func (r *shortURLRepository) GetURL(ctx context.Context, orgId, domainId uint, code string) (*domain.ShortUrl, error) {
+	var url domain.ShortUrl
+	err := r.db.Reader(linkKey(domainId, code)).WithContext(ctx).
+		Preload("Tags").
+		Preload("Rules", orderedRules).
+		Preload("Variants", orderedRules).
+		Where("organization_id = ? AND domain_id = ? AND short_code = ?", orgId, domainId, code).
+		First(&url).Error
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return &url, nil
+}
+
+// GetURLsByID reads the primary, callers act on counts they have just written
+func (r *shortURLRepository) GetURLsByID(ctx context.Context, ids []uint) ([]domain.ShortUrl, error) {
+	var urls []domain.ShortUrl
+	err := r.db.GetConnection().WithContext(ctx).
+		Preload("Tags").
+		Where("id IN ?", ids).
+		Find(&urls).Error
+	return urls, database.TranslateError(err)
+}
+
+// orderedRules loads the redirect rules and variants of links in their order
+func orderedRules(db *gorm.DB) *gorm.DB {
+	return db.Order("position")
+}
+
+// linkSortColumns are the columns of the listing orders, ties are broken by id
+var linkSortColumns = map[domain.LinkSort]string{
+	domain.SortCreated:     "created_at",
+	domain.SortClicks:      "clicks",
+	domain.SortLastClicked: "last_clicked_at",
+}
+
+// ListURLs pages through the links with keyset pagination. Listings read the
+// primary, a replica may not have the link created a moment ago yet.
+func (r *shortURLRepository) ListURLs(ctx context.Context, filter domain.LinkFilter) ([]domain.ShortUrl, error) {
+	query := r.db.GetConnection().WithContext(ctx).Where("organization_id = ?", filter.OrganizationId)
+	if filter.DomainIds != nil {
+		query = query.Where("domain_id IN ?", filter.DomainIds)
+	}
+	if filter.Active != nil {
+		query = query.Where("is_active = ?", *filter.Active)
+	}
+	if filter.Expired != nil {
+		if *filter.Expired {
+			query = query.Where("expires_at > ? AND expires_at <= ?", time.Time{}, filter.Now)
+		} else {
+			query = query.Where("(expires_at <= ? OR expires_at > ?)", time.Time{}, filter.Now)
+		}
+	}
+	if filter.Tag != "" {
+		query = query.Where(`id IN (SELECT short_url_tags.short_url_id FROM short_url_tags
+			JOIN tags ON tags.id = short_url_tags.tag_id WHERE tags.name = ? AND tags.deleted_at IS NULL)`, filter.Tag)
+	}
+	if filter.FolderId != nil {
+		query = query.Where("folder_id = ?", *filter.FolderId)
+	}
+	if filter.DestinationHost != "" {
+		query = query.Where("destination_host = ?", filter.DestinationHost)
+	}
+	if !filter.CreatedFrom.IsZero() {
+		query = query.Where("created_at >= ?", filter.CreatedFrom)
+	}
+	if !filter.CreatedTo.IsZero() {
+		query = query.Where("created_at < ?", filter.CreatedTo)
+	}
+
+	column, ok := linkSortColumns[filter.Sort]
+	if !ok {
+		column = linkSortColumns[domain.SortCreated]
+	}
+	direction, beyond := "DESC", "<"
+	if filter.Ascending {
+		direction, beyond = "ASC", ">"
+	}
+	if after := filter.After; after != nil {
+		var value interface{} = after.Time
+		if filter.Sort == domain.SortClicks {
+			value = after.Clicks
+		}
+		query = query.Where(fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", column, beyond), value, value, after.ID)
+	}
+	if filter.WithTags {
+		query = query.Preload("Tags")
+	}
+	if filter.WithRules {
+		query = query.Preload("Rules", orderedRules)
+	}
+	if filter.WithVariants {
+		query = query.Preload("Variants", orderedRules)
+	}
+
+	var urls []domain.ShortUrl
+	err := query.Order(column + " " + direction).Order("id " + direction).Limit(filter.Limit).Find(&urls).Error
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return urls, nil
+}
+
+// SearchURLs ranks the links by full text relevance on Postgres, where the
+// tsvector and trigram indexes serve the query, with substring matches on the
+// whole query as a fallback. Other databases match every word as a substring.
+func (r *shortURLRepository) SearchURLs(ctx context.Context, search domain.LinkSearch) ([]domain.ShortUrl, error) {
+	reader := r.db.GetConnection().WithContext(ctx)
+	query := reader.Where("organization_id = ?", search.OrganizationId)
+	if search.DomainIds != nil {
+		query = query.Where("domain_id IN ?", search.DomainIds)
+	}
+
+	text := strings.ToLower(search.Query)
+	if reader.Dialector.Name() == database.DriverPostgres {
+		const document = "to_tsvector('simple', search_text)"
+		query = query.
+			Where("("+document+" @@ plainto_tsquery('simple', ?) OR search_text LIKE ? ESCAPE '\\')", text, containsPattern(text)).
+			Order(clause.OrderBy{Expression: clause.Expr{
+				SQL:  "ts_rank(" + document + ", plainto_tsquery('simple', ?)) DESC, id DESC",
+				Vars: []interface{}{text},
+			}})
+	} else {
+		for _, word := range strings.Fields(text) {
+			query = query.Where("search_text LIKE ? ESCAPE '\\'", containsPattern(word))
+		}
+		query = query.Order("id DESC")
+	}
+
+	var urls []domain.ShortUrl
+	err := query.Preload("Tags").Preload("Rules", orderedRules).Preload("Variants", orderedRules).Limit(search.Limit).Find(&urls).Error
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return urls, nil
+}
+
+// containsPattern is a LIKE pattern matching text anywhere, its wildcards escaped
+func containsPattern(text string) string {
+	return "%" + strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(text) + "%"
+}
+
+func (r *shortURLRepository) ListTags(ctx context.Context, orgId uint) ([]domain.TagCount, error) {
+	var tags []domain.TagCount
+	err := r.db.GetConnection().WithContext(ctx).
+		Table("tags").
+		Select("tags.name AS name, COUNT(DISTINCT short_urls.id) AS links").
+		Joins("JOIN short_url_tags ON short_url_tags.tag_id = tags.id").
+		Joins("JOIN short_urls ON short_urls.id = short_url_tags.short_url_id").
+		Where("short_urls.organization_id = ? AND short_urls.deleted_at IS NULL AND tags.deleted_at IS NULL", orgId).
+		Group("tags.name").
+		Order("tags.name").
+		Scan(&tags).Error
+	return tags, database.TranslateError(err)
+}
+
+func (r *shortURLRepository) IncrementClicks(ctx context.Context, id uint) error {
+	return r.AddClicks(ctx, map[uint]domain.ClickDelta{id: {Count: 1, LastClickedAt: time.Now()}})
+}
+
+// AddClicks applies a batch of click counts to the links and their analytics
+// rows in one transaction and records ClickRecorded for every link
+func (r *shortURLRepository) AddClicks(ctx context.Context, deltas map[uint]domain.ClickDelta) error {
+	if len(deltas) == 0 {
+		return nil
+	}
+
+	err := r.db.GetConnection().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		ids := make([]uint, 0, len(deltas))
+		for id, delta := range deltas {
+			ids = append(ids, id)
+			err := tx.Model(&domain.ShortUrl{}).
+				Where("id = ?", id).
+				UpdateColumns(map[string]interface{}{
+					"clicks":          gorm.Expr("clicks + ?", delta.Count),
+					"last_clicked_at": delta.LastClickedAt,
+				}).Error
+			if err != nil {
+				return err
+			}
+
+			analytics := domain.URLAnalytics{
+				ShortURLId:    id,
+				TotalClicks:   delta.Count,
+				LastClickedAt: delta.LastClickedAt,
+			}
+			err = tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "short_url_id"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{
+					"total_clicks":    gorm.Expr("url_analytics.total_clicks + ?", delta.Count),
+					"last_clicked_at": delta.LastClickedAt,
+					"updated_at":      time.Now(),
+				}),
+			}).Create(&analytics).Error
+			if err != nil {
+				return err
+			}
+
+			// A variant removed since the click was counted is no longer updated
+			for variantId, count := range delta.Variants {
+				err := tx.Model(&domain.LinkVariant{}).
+					Where("id = ? AND short_url_id = ?", variantId, id).
+					UpdateColumn("clicks", gorm.Expr("clicks + ?", count)).Error
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		// The updated rows stay locked until the commit, the counts read are the ones written
+		var urls []domain.ShortUrl
+		if err := tx.Select("id", "organization_id", "clicks").Where("id IN ?", ids).Find(&urls).Error; err != nil {
+			return err
+		}
+		events := make([]domain.Event, 0, len(urls))
+		for _, url := range urls {
+			delta := deltas[url.ID]
+			events = append(events, domain.ClickRecorded{
+				LinkId:         url.ID,
+				OrganizationId: url.OrganizationId,
+				Count:          delta.Count,
+				Clicks:         url.Clicks,
+				LastClickedAt:  delta.LastClickedAt,
+			})
+		}
+		return recordEvents(tx, events...)
+	})
+	return database.TranslateError(err)
+}
+
+func (r *shortURLRepository) DeactivateURL(ctx context.Context, orgId, domainId uint, code string) error {
+	err := r.db.GetConnection().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var url domain.ShortUrl
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Preload("Tags").
+			Where("organization_id = ? AND domain_id = ? AND short_code = ?", orgId, domainId, code).
+			First(&url).Error
+		if err != nil {
+			return err
+		}
+		if !url.IsActive {
+			return nil
+		}
+		if err := tx.Model(&url).Update("is_active", false).Error; err != nil {
+			return err
+		}
+		url.IsActive = false
+		return recordEvents(tx, domain.LinkDeactivated{Link: domain.NewLinkState(&url)})
+	})
+	if err != nil {
+		return database.TranslateError(err)
+	}
+	r.db.MarkWritten(linkKey(domainId, code))
+	return nil
+}
+
+func (r *shortURLRepository) DisableURL(ctx context.Context, id uint, reason string) error {
+	var url domain.ShortUrl
+	err := r.db.GetConnection().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Tags").First(&url, id).Error; err != nil {
+			return err
+		}
+		wasActive := url.IsActive
+		url.IsActive, url.DisabledReason = false, reason
+		err := tx.Model(&url).Updates(map[string]interface{}{
+			"is_active":       false,
+			"disabled_reason": reason,
+			"disabled_at":     time.Now(),
+		}).Error
+		if err != nil || !wasActive {
+			return err
+		}
+		return recordEvents(tx, domain.LinkDeactivated{Link: domain.NewLinkState(&url)})
+	})
+	if err != nil {
+		return database.TranslateError(err)
+	}
+	r.db.MarkWritten(linkKey(url.DomainId, url.ShortCode))
+	return nil
+}
+
+// UpdateURL locks the link row so concurrent edits are applied one after the other
+func (r *shortURLRepository) UpdateURL(ctx context.Context, orgId, domainId uint, code string, changes domain.LinkChanges, changedBy uint) (*domain.ShortUrl, *domain.LinkVersion, error) {
+	var url domain.ShortUrl
+	var replaced *domain.LinkVersion
+	err := r.db.GetConnection().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Preload("Tags").
+			Preload("Rules", orderedRules).
+			Preload("Variants", orderedRules).
+			Where("organization_id = ? AND domain_id = ? AND short_code = ?", orgId, domainId, code).
+			First(&url).Error
+		if err != nil {
+			return err
+		}
+
+		updates, fields := linkUpdates(&url, changes)
+		if len(fields) == 0 {
+			return nil
+		}
+
+		version := domain.LinkVersion{
+			ShortURLId:    url.ID,
+			Version:       url.Version,
+			OriginalURL:   url.OriginalURL,
+			ExpiresAt:     url.ExpiresAt,
+			IsActive:      url.IsActive,
+			RedirectType:  url.RedirectType,
+			Snapshot:      domain.NewLinkSnapshot(&url),
+			ChangedBy:     changedBy,
+			ChangedFields: fields,
+		}
+		if err := tx.Create(&version).Error; err != nil {
+			return err
+		}
+		replaced = &version
+
+		if changes.Tags != nil && slices.Contains(fields, "tags") {
+			tags := *changes.Tags
+			if err := upsertTags(tx, url.AccountId, tags); err != nil {
+				return err
+			}
+			if err := tx.Model(&url).Omit("Tags.*").Association("Tags").Replace(tags); err != nil {
+				return err
+			}
+			url.Tags = tags
+		}
+		if changes.Rules != nil && slices.Contains(fields, "rules") {
+			if err := replaceRules(tx, &url, *changes.Rules); err != nil {
+				return err
+			}
+		}
+		if changes.Variants != nil && slices.Contains(fields, "variants") {
+			if err := replaceVariants(tx, &url, *changes.Variants); err != nil {
+				return err
+			}
+		}
+
+		updates["version"] = url.Version + 1
+		if err := tx.Model(&url).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&url).UpdateColumn("search_text", url.SearchDocument()).Error; err != nil {
+			return err
+		}
+
+		state := domain.NewLinkState(&url)
+		if version.IsActive && !url.IsActive {
+			return recordEvents(tx, domain.LinkDeactivated{Link: state})
+		}
+		return recordEvents(tx, domain.LinkUpdated{Link: state, Fields: fields})
+	})
+	if err != nil {
+		return nil, nil, database.TranslateError(err)
+	}
+	r.db.MarkWritten(linkKey(domainId, code))
+	return &url, replaced, nil
+}
+
+// linkUpdates returns the columns the changes modify and the names of the changed fields
+func linkUpdates(url *domain.ShortUrl, changes domain.LinkChanges) (map[string]interface{}, []string) {
+	updates := make(map[string]interface{})
+	var fields []string
+
+	if changes.OriginalURL != nil && *changes.OriginalURL != url.OriginalURL {
+		updates["original_url"] = *changes.OriginalURL
+		updates["destination_host"] = domain.HostOf(*changes.OriginalURL)
+		fields = append(fields, "originalUrl")
+	}
+	if changes.ExpiresAt != nil && !changes.ExpiresAt.Equal(url.ExpiresAt) {
+		updates["expires_at"] = *changes.ExpiresAt
+		fields = append(fields, "expiresAt")
+	}
+	if changes.IsActive != nil && *changes.IsActive != url.IsActive {
+		updates["is_active"] = *changes.IsActive
+		if *changes.IsActive {
+			updates["disabled_reason"] = ""
+			updates["disabled_at"] = nil
+		}
+		fields = append(fields, "isActive")
+	}
+	if changes.RedirectType != nil && *changes.RedirectType != url.RedirectType {
+		updates["redirect_type"] = *changes.RedirectType
+		fields = append(fields, "redirectType")
+	}
+	if changes.Title != nil && *changes.Title != url.Title {
+		updates["title"] = *changes.Title
+		fields = append(fields, "title")
+	}
+	if changes.FolderId != nil && *changes.FolderId != url.FolderId {
+		updates["folder_id"] = *changes.FolderId
+		fields = append(fields, "folderId")
+	}
+	if changes.Tags != nil && !sameTags(url.Tags, *changes.Tags) {
+		fields = append(fields, "tags")
+	}
+	if changes.UTM != nil && *changes.UTM != url.UTM {
+		updates["utm_source"] = changes.UTM.Source
+		updates["utm_medium"] = changes.UTM.Medium
+		updates["utm_campaign"] = changes.UTM.Campaign
+		updates["utm_term"] = changes.UTM.Term
+		updates["utm_content"] = changes.UTM.Content
+		fields = append(fields, "utm")
+	}
+	if changes.Rules != nil && !sameRules(url.Rules, *changes.Rules) {
+		fields = append(fields, "rules")
+	}
+	if changes.Variants != nil && !sameVariants(url.Variants, *changes.Variants) {
+		fields = append(fields, "variants")
+	}
+	if changes.QueryPassthrough != nil && *changes.QueryPassthrough != url.QueryPassthrough {
+		updates["query_passthrough"] = *changes.QueryPassthrough
+		fields = append(fields, "queryPassthrough")
+	}
+	return updates, fields
+}
+
+// replaceRules deletes the redirect rules of the link and stores the new ones in their order
+func replaceRules(tx *gorm.DB, url *domain.ShortUrl, rules []domain.RedirectRule) error {
+	if err := tx.Where("short_url_id = ?", url.ID).Delete(&domain.RedirectRule{}).Error; err != nil {
+		return err
+	}
+	for i := range rules {
+		rules[i].ID, rules[i].ShortURLId, rules[i].Position = 0, url.ID, i
+	}
+	if len(rules) > 0 {
+		if err := tx.Create(&rules).Error; err != nil {
+			return err
+		}
+	}
+	url.Rules = rules
+	return nil
+}
+
+// replaceVariants stores the new A/B split of the link. A variant whose url
+// stays keeps its row, so its clicks and the visitors assigned to it carry over.
+func replaceVariants(tx *gorm.DB, url *domain.ShortUrl, variants []domain.LinkVariant) error {
+	kept := make(map[string]domain.LinkVariant, len(url.Variants))
+	for _, v := range url.Variants {
+		kept[v.Destination] = v
+	}
+
+	for i := range variants {
+		variants[i].ID, variants[i].ShortURLId, variants[i].Position, variants[i].Clicks = 0, url.ID, i, 0
+		current, ok := kept[variants[i].Destination]
+		if !ok {
+			continue
+		}
+		delete(kept, current.Destination)
+		variants[i].ID, variants[i].Clicks = current.ID, current.Clicks
+		err := tx.Model(&current).Updates(map[string]interface{}{"position": i, "weight": variants[i].Weight}).Error
+		if err != nil {
+			return err
+		}
+	}
+	for _, v := range kept {
+		if err := tx.Delete(&v).Error; err != nil {
+			return err
+		}
+	}
+	for i := range variants {
+		if variants[i].ID == 0 {
+			if err := tx.Create(&variants[i]).Error; err != nil {
+				return err
+			}
+		}
+	}
+	url.Variants = variants
+	return nil
+}
+
+func sameVariants(current, next []domain.LinkVariant) bool {
+	return slices.EqualFunc(current, next, func(a, b domain.LinkVariant) bool {
+		return a.Destination == b.Destination && a.Weight == b.Weight
+	})
+}
+
+func sameRules(current, next []domain.RedirectRule) bool {
+	return slices.EqualFunc(current, next, func(a, b domain.RedirectRule) bool {
+		return a.Destination == b.Destination &&
+			slices.Equal(a.Countries, b.Countries) && slices.Equal(a.OS, b.OS) && slices.Equal(a.Devices, b.Devices) &&
+			slices.Equal(a.Languages, b.Languages) && slices.Equal(a.Referrers, b.Referrers) &&
+			sameTime(a.From, b.From) && sameTime(a.Until, b.Until) &&
+			a.DailyFrom == b.DailyFrom && a.DailyUntil == b.DailyUntil && a.TimeZone == b.TimeZone
+	})
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func sameTags(current, next []domain.Tag) bool {
+	if len(current) != len(next) {
+		return false
+	}
+	names := make(map[string]bool, len(current))
+	for _, tag := range current {
+		names[tag.Name] = true
+	}
+	for _, tag := range next {
+		if !names[tag.Name] {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *shortURLRepository) ListVersions(ctx context.Context, orgId, domainId uint, code string) ([]domain.LinkVersion, error) {
+	reader := r.db.Reader(linkKey(domainId, code)).WithContext(ctx)
+
+	var url domain.ShortUrl
+	if err := reader.Select("id").Where("organization_id = ? AND domain_id = ? AND short_code = ?", orgId, domainId, code).First(&url).Error; err != nil {
+		return nil, database.TranslateError(err)
+	}
+
+	var versions []domain.LinkVersion
+	err := reader.Where("short_url_id = ?", url.ID).Order("version DESC").Find(&versions).Error
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return versions, nil
+}
+
+func (r *shortURLRepository) GetVersion(ctx context.Context, orgId, domainId uint, code string, version int) (*domain.LinkVersion, error) {
+	var v domain.LinkVersion
+	err := r.db.Reader(linkKey(domainId, code)).WithContext(ctx).
+		Joins("JOIN short_urls ON short_urls.id = link_versions.short_url_id").
+		Where("short_urls.organization_id = ? AND short_urls.domain_id = ? AND short_urls.short_code = ?", orgId, domainId, code).
+		Where("short_urls.deleted_at IS NULL").
+		Where("link_versions.version = ?", version).
+		First(&v).Error
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return &v, nil
+}
+
+// ConsumeClick relies on the row lock of a single conditional update, concurrent
+// redirects queue on the row and re-check the limit before taking a click. The
+// click that exhausts the limit records LinkDeactivated.
+func (r *shortURLRepository) ConsumeClick(ctx context.Context, id uint) (bool, error) {
+	const exhausted = "consumed_clicks + 1 >= max_clicks"
+	var granted bool
+	err := r.db.GetConnection().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&domain.ShortUrl{}).
+			Where("id = ? AND is_active AND consumed_clicks < max_clicks", id).
+			UpdateColumns(map[string]interface{}{
+				"consumed_clicks": gorm.Expr("consumed_clicks + 1"),
+				"is_active":       gorm.Expr("NOT (" + exhausted + ")"),
+				"disabled_reason": gorm.Expr("CASE WHEN "+exhausted+" THEN ? ELSE disabled_reason END", "click limit reached"),
+				"disabled_at":     gorm.Expr("CASE WHEN "+exhausted+" THEN ? ELSE disabled_at END", time.Now()),
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		granted = true
+
+		var url domain.ShortUrl
+		if err := tx.Preload("Tags").First(&url, id).Error; err != nil {
+			return err
+		}
+		if url.IsActive {
+			return nil
+		}
+		return recordEvents(tx, domain.LinkDeactivated{Link: domain.NewLinkState(&url)})
+	})
+	if err != nil {
+		return false, database.TranslateError(err)
+	}
+	return granted, nil
+}
+
+func (r *shortURLRepository) ReassignURL(ctx context.Context, id, orgId, accountId uint) error {
+	var url domain.ShortUrl
+	err := r.db.GetConnection().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Preload("Tags").First(&url, id).Error; err != nil {
+			return err
+		}
+		from := url.OrganizationId
+		err := tx.Model(&url).Updates(map[string]interface{}{
+			"organization_id": orgId,
+			"account_id":      accountId,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return recordEvents(tx, domain.LinkReassigned{Link: domain.NewLinkState(&url), FromOrganizationId: from, AccountId: accountId})
+	})
+	if err != nil {
+		return database.TranslateError(err)
+	}
+	r.db.MarkWritten(linkKey(url.DomainId, url.ShortCode))
+	return nil
+}
+
+// GetStats returns the analytics of an account's short url with the clicks of
+// its A/B variants, read from a replica. A link that was never clicked has no
+// analytics row yet and reports zero clicks.
+func (r *shortURLRepository) GetStats(ctx context.Context, orgId, domainId uint, code string) (*domain.URLAnalytics, error) {
+	reader := r.db.Reader(linkKey(domainId, code)).WithContext(ctx)
+
+	var url domain.ShortUrl
+	if err := reader.Where("organization_id = ? AND domain_id = ? AND short_code = ?", orgId, domainId, code).First(&url).Error; err != nil {
+		return nil, database.TranslateError(err)
+	}
+
+	stats := domain.URLAnalytics{ShortURLId: url.ID}
+	err := reader.Where("short_url_id = ?", url.ID).Limit(1).Find(&stats).Error
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	if err := reader.Where("short_url_id = ?", url.ID).Order("position").Find(&stats.Variants).Error; err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return &stats, nil
+}
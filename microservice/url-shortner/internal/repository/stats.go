@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"coding2fun.in/url-shortner/database"
+	"coding2fun.in/url-shortner/internal/domain"
+	"context"
+)
+
+type statsRepository struct {
+	db database.Service
+}
+
+func NewStatsRepository(db database.Service) domain.StatsRepository {
+	return &statsRepository{db: db}
+}
+
+func (r *statsRepository) Totals(ctx context.Context) (*domain.ServiceStats, error) {
+	db := r.db.Reader("").WithContext(ctx)
+
+	var stats domain.ServiceStats
+	counts := []struct {
+		model any
+		where string
+		into  *int64
+	}{
+		{&domain.Account{}, "", &stats.Accounts},
+		{&domain.Account{}, "is_active = true", &stats.ActiveAccounts},
+		{&domain.APIKey{}, "", &stats.APIKeys},
+		{&domain.APIKey{}, "is_active = true", &stats.ActiveAPIKeys},
+		{&domain.Organization{}, "", &stats.Organizations},
+		{&domain.ShortUrl{}, "", &stats.Links},
+		{&domain.ShortUrl{}, "is_active = true", &stats.ActiveLinks},
+		{&domain.Domain{}, "", &stats.Domains},
+		{&domain.Domain{}, "verified_at IS NOT NULL", &stats.VerifiedDomains},
+	}
+	for _, c := range counts {
+		query := db.Model(c.model)
+		if c.where != "" {
+			query = query.Where(c.where)
+		}
+		if err := query.Count(c.into).Error; err != nil {
+			return nil, database.TranslateError(err)
+		}
+	}
+
+	err := db.Model(&domain.ShortUrl{}).Select("COALESCE(SUM(clicks), 0)").Scan(&stats.Clicks).Error
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return &stats, nil
+}
@@ -0,0 +1,239 @@
+package repository
+
+import (
+	"coding2fun.in/url-shortner/database"
+	"coding2fun.in/url-shortner/internal/domain"
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// enqueueBatchSize keeps the bound parameters of an insert below the sqlite limit
+const enqueueBatchSize = 500
+
+// finishedDeliveries are the states no attempt follows without a replay
+var finishedDeliveries = []domain.DeliveryStatus{domain.DeliverySucceeded, domain.DeliveryDead}
+
+type webhookRepository struct {
+	db database.Service
+}
+
+func NewWebhookRepository(db database.Service) domain.WebhookRepository {
+	return &webhookRepository{db: db}
+}
+
+func (r *webhookRepository) CreateEndpoint(ctx context.Context, endpoint *domain.WebhookEndpoint) error {
+	return database.TranslateError(r.db.GetConnection().WithContext(ctx).Create(endpoint).Error)
+}
+
+func (r *webhookRepository) GetEndpoint(ctx context.Context, orgId, id uint) (*domain.WebhookEndpoint, error) {
+	var endpoint domain.WebhookEndpoint
+	err := r.db.GetConnection().WithContext(ctx).Where("organization_id = ?", orgId).First(&endpoint, id).Error
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return &endpoint, nil
+}
+
+func (r *webhookRepository) ListEndpoints(ctx context.Context, orgId uint) ([]domain.WebhookEndpoint, error) {
+	var endpoints []domain.WebhookEndpoint
+	err := r.db.GetConnection().WithContext(ctx).Where("organization_id = ?", orgId).Order("id").Find(&endpoints).Error
+	return endpoints, database.TranslateError(err)
+}
+
+func (r *webhookRepository) UpdateEndpoint(ctx context.Context, endpoint *domain.WebhookEndpoint) error {
+	err := r.db.GetConnection().WithContext(ctx).
+		Select("url", "secret", "events", "description", "is_active", "updated_at").
+		Save(endpoint).Error
+	return database.TranslateError(err)
+}
+
+// DeleteEndpoint removes the endpoint for good together with its deliveries and their attempts
+func (r *webhookRepository) DeleteEndpoint(ctx context.Context, orgId, id uint) (*domain.WebhookEndpoint, error) {
+	var endpoint domain.WebhookEndpoint
+	err := r.db.GetConnection().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("organization_id = ?", orgId).First(&endpoint, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("endpoint_id = ?", id).Delete(&domain.WebhookAttempt{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("endpoint_id = ?", id).Delete(&domain.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&endpoint).Error
+	})
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return &endpoint, nil
+}
+
+// Subscribers filters the events in Go, an organization has a handful of endpoints at most
+func (r *webhookRepository) Subscribers(ctx context.Context, orgId uint, event string) ([]domain.WebhookEndpoint, error) {
+	var endpoints []domain.WebhookEndpoint
+	err := r.db.GetConnection().WithContext(ctx).
+		Where("organization_id = ? AND is_active = ?", orgId, true).
+		Order("id").
+		Find(&endpoints).Error
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+
+	subscribed := endpoints[:0]
+	for _, endpoint := range endpoints {
+		if endpoint.Subscribes(event) {
+			subscribed = append(subscribed, endpoint)
+		}
+	}
+	return subscribed, nil
+}
+
+func (r *webhookRepository) Enqueue(ctx context.Context, deliveries []domain.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	err := r.db.GetConnection().WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		CreateInBatches(&deliveries, enqueueBatchSize).Error
+	return database.TranslateError(err)
+}
+
+// ClaimDue moves the next attempt of the claimed deliveries past the lease, an
+// instance that dies while sending leaves them to be claimed again afterwards.
+// Postgres skips rows another instance is claiming, sqlite has a single writer.
+func (r *webhookRepository) ClaimDue(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]domain.WebhookDelivery, error) {
+	var deliveries []domain.WebhookDelivery
+	err := r.db.GetConnection().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("status IN ? AND next_attempt_at <= ?", []domain.DeliveryStatus{domain.DeliveryPending, domain.DeliveryRetrying}, now).
+			Where("endpoint_id IN (?)", tx.Model(&domain.WebhookEndpoint{}).Select("id").Where("is_active = ?", true)).
+			Order("next_attempt_at").
+			Limit(limit)
+		if tx.Dialector.Name() == database.DriverPostgres {
+			query = query.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+		}
+		if err := query.Find(&deliveries).Error; err != nil || len(deliveries) == 0 {
+			return err
+		}
+
+		ids := make([]uint, 0, len(deliveries))
+		endpointIds := make([]uint, 0, len(deliveries))
+		for _, d := range deliveries {
+			ids = append(ids, d.ID)
+			endpointIds = append(endpointIds, d.EndpointId)
+		}
+		err := tx.Model(&domain.WebhookDelivery{}).
+			Where("id IN ?", ids).
+			UpdateColumn("next_attempt_at", now.Add(lease)).Error
+		if err != nil {
+			return err
+		}
+
+		var endpoints []domain.WebhookEndpoint
+		if err := tx.Where("id IN ?", endpointIds).Find(&endpoints).Error; err != nil {
+			return err
+		}
+		byId := make(map[uint]*domain.WebhookEndpoint, len(endpoints))
+		for i := range endpoints {
+			byId[endpoints[i].ID] = &endpoints[i]
+		}
+		for i := range deliveries {
+			deliveries[i].Endpoint = byId[deliveries[i].EndpointId]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return deliveries, nil
+}
+
+func (r *webhookRepository) RecordAttempt(ctx context.Context, delivery *domain.WebhookDelivery, attempt *domain.WebhookAttempt) error {
+	err := r.db.GetConnection().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(attempt).Error; err != nil {
+			return err
+		}
+		return tx.Model(delivery).Updates(map[string]interface{}{
+			"status":           delivery.Status,
+			"attempts":         delivery.Attempts,
+			"next_attempt_at":  delivery.NextAttemptAt,
+			"last_status_code": delivery.LastStatusCode,
+			"last_error":       delivery.LastError,
+			"delivered_at":     delivery.DeliveredAt,
+		}).Error
+	})
+	return database.TranslateError(err)
+}
+
+func (r *webhookRepository) ListDeliveries(ctx context.Context, filter domain.DeliveryFilter) ([]domain.WebhookDelivery, error) {
+	query := r.db.GetConnection().WithContext(ctx).
+		Where("organization_id = ? AND endpoint_id = ?", filter.OrganizationId, filter.EndpointId)
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.BeforeId > 0 {
+		query = query.Where("id < ?", filter.BeforeId)
+	}
+
+	var deliveries []domain.WebhookDelivery
+	err := query.Order("id DESC").Limit(filter.Limit).Find(&deliveries).Error
+	return deliveries, database.TranslateError(err)
+}
+
+func (r *webhookRepository) GetDelivery(ctx context.Context, orgId, endpointId, id uint) (*domain.WebhookDelivery, error) {
+	db := r.db.GetConnection().WithContext(ctx)
+
+	var delivery domain.WebhookDelivery
+	err := db.Where("organization_id = ? AND endpoint_id = ?", orgId, endpointId).First(&delivery, id).Error
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	if err := db.Where("delivery_id = ?", id).Order("id").Find(&delivery.Log).Error; err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return &delivery, nil
+}
+
+func (r *webhookRepository) Replay(ctx context.Context, orgId, endpointId, id uint, now time.Time) (*domain.WebhookDelivery, error) {
+	var delivery domain.WebhookDelivery
+	err := r.db.GetConnection().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("organization_id = ? AND endpoint_id = ?", orgId, endpointId).
+			First(&delivery, id).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&delivery).Updates(map[string]interface{}{
+			"status":          domain.DeliveryPending,
+			"attempts":        0,
+			"next_attempt_at": now,
+		}).Error
+	})
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return &delivery, nil
+}
+
+func (r *webhookRepository) PurgeDeliveries(ctx context.Context, before time.Time, limit int) (int64, error) {
+	var n int64
+	err := r.db.GetConnection().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var ids []uint
+		err := tx.Model(&domain.WebhookDelivery{}).
+			Where("status IN ? AND created_at < ?", finishedDeliveries, before).
+			Order("id").
+			Limit(limit).
+			Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+		if err := tx.Where("delivery_id IN ?", ids).Delete(&domain.WebhookAttempt{}).Error; err != nil {
+			return err
+		}
+		result := tx.Where("id IN ?", ids).Delete(&domain.WebhookDelivery{})
+		n = result.RowsAffected
+		return result.Error
+	})
+	return n, database.TranslateError(err)
+}
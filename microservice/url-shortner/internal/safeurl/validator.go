@@ -0,0 +1,227 @@
+package safeurl
+
+import (
+	"coding2fun.in/url-shortner/internal/config"
+	"coding2fun.in/url-shortner/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/net/idna"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const field = "url"
+
+// Resolver looks up the addresses of a host, net.DefaultResolver satisfies it
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// ShortDomains resolves the custom domains serving short links, the domain
+// service satisfies it
+type ShortDomains interface {
+	Resolve(ctx context.Context, host string) (*domain.Domain, error)
+}
+
+// Validator normalizes destination urls and rejects the ones that are unsafe to
+// redirect to: unsupported schemes, private network targets and links back to
+// the short domain or a verified custom domain
+type Validator struct {
+	resolver       Resolver
+	schemes        map[string]bool
+	blockedHosts   map[string]bool
+	shortDomains   ShortDomains
+	blockPrivate   bool
+	resolveTimeout time.Duration
+}
+
+func NewValidator(cfg config.LinksConfig, resolver Resolver) (*Validator, error) {
+	base, err := url.Parse(cfg.BaseURL)
+	if err != nil || base.Hostname() == "" {
+		return nil, fmt.Errorf("invalid links base url %q", cfg.BaseURL)
+	}
+
+	v := &Validator{
+		resolver:       resolver,
+		schemes:        make(map[string]bool),
+		blockedHosts:   make(map[string]bool),
+		blockPrivate:   cfg.BlockPrivateNetworks,
+		resolveTimeout: cfg.ResolveTimeout,
+	}
+	for _, scheme := range cfg.AllowedSchemes {
+		v.schemes[strings.ToLower(scheme)] = true
+	}
+	for _, host := range append([]string{base.Hostname()}, cfg.BlockedHosts...) {
+		normalized, err := normalizeHost(host)
+		if err != nil {
+			return nil, fmt.Errorf("invalid blocked host %q: %w", host, err)
+		}
+		v.blockedHosts[normalized] = true
+	}
+	return v, nil
+}
+
+// BlockShortDomains rejects destinations on the verified custom domains too,
+// links between two short domains could redirect in a loop
+func (v *Validator) BlockShortDomains(domains ShortDomains) {
+	v.shortDomains = domains
+}
+
+// Validate returns the normalized form of a destination url, or a validation
+// error on the url field when it must not be shortened
+func (v *Validator) Validate(ctx context.Context, raw string) (string, error) {
+	// Check the scheme first, opaque urls such as javascript: are not absolute
+	if u, err := url.Parse(strings.TrimSpace(raw)); err == nil && u.Scheme != "" && !v.schemes[strings.ToLower(u.Scheme)] {
+		return "", domain.NewValidationError(field, fmt.Sprintf("scheme %q is not allowed", strings.ToLower(u.Scheme)))
+	}
+
+	u, err := Normalize(raw)
+	if err != nil {
+		return "", domain.NewValidationError(field, err.Error())
+	}
+	if v.blockedHosts[u.Hostname()] {
+		return "", domain.NewValidationError(field, "must not point to the short link domain")
+	}
+	if v.shortDomains != nil {
+		site, err := v.shortDomains.Resolve(ctx, u.Hostname())
+		if err != nil {
+			return "", err
+		}
+		if site != nil {
+			return "", domain.NewValidationError(field, "must not point to a short link domain")
+		}
+	}
+	if v.blockPrivate {
+		if err := v.checkAddresses(ctx, u.Hostname()); err != nil {
+			return "", err
+		}
+	}
+	return u.String(), nil
+}
+
+// checkAddresses resolves the host and rejects it when any address is not publicly routable
+func (v *Validator) checkAddresses(ctx context.Context, host string) error {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !IsPublic(addr) {
+			return domain.NewValidationError(field, "must not point to a private or local network address")
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, v.resolveTimeout)
+	defer cancel()
+
+	addrs, err := v.resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("%w: %w", domain.NewValidationError(field, "host could not be resolved"), err)
+	}
+	if len(addrs) == 0 {
+		return domain.NewValidationError(field, "host could not be resolved")
+	}
+	for _, a := range addrs {
+		addr, ok := netip.AddrFromSlice(a.IP)
+		if !ok || !IsPublic(addr) {
+			return domain.NewValidationError(field, "must not point to a private or local network address")
+		}
+	}
+	return nil
+}
+
+// nonPublicPrefixes are the special purpose ranges netip has no predicate for
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // this network
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // documentation
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // documentation
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved and broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64, embeds any IPv4 address
+	netip.MustParsePrefix("100::/64"),        // discard
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+}
+
+// IsPublic reports whether the address is publicly routable
+func IsPublic(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() ||
+		addr.IsLoopback() ||
+		addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() ||
+		addr.IsUnspecified() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// Normalize parses an absolute url into its canonical form: lower case scheme,
+// ASCII (punycode) host without trailing dots or default port and a path with
+// canonical percent-encoding
+func Normalize(raw string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, errors.New("is not a valid url")
+	}
+	if !u.IsAbs() || u.Host == "" {
+		return nil, errors.New("must be an absolute url")
+	}
+	if u.User != nil {
+		return nil, errors.New("must not contain credentials")
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+
+	host, err := normalizeHost(u.Hostname())
+	if err != nil {
+		return nil, errors.New("has an invalid host")
+	}
+	port := u.Port()
+	if port == defaultPorts[u.Scheme] {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+
+	// Re-encode the path from its decoded form unless that would turn an
+	// escaped slash into a path separator
+	if !strings.Contains(strings.ToUpper(u.RawPath), "%2F") {
+		u.RawPath = ""
+	}
+	return u, nil
+}
+
+// normalizeHost lower cases the host, strips trailing dots and converts
+// internationalized names to their ASCII form
+func normalizeHost(host string) (string, error) {
+	host = strings.TrimRight(strings.ToLower(host), ".")
+	if host == "" {
+		return "", errors.New("empty host")
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return addr.String(), nil
+	}
+	return idna.Lookup.ToASCII(host)
+}
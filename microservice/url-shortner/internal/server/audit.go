@@ -0,0 +1,123 @@
+package server
+
+import (
+	"coding2fun.in/url-shortner/internal/domain"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+type auditEventResponse struct {
+	ID        uint            `json:"id"`
+	Actor     string          `json:"actor"`
+	APIKeyId  uint            `json:"apiKeyId,omitempty"`
+	IP        string          `json:"ip,omitempty"`
+	RequestID string          `json:"requestId,omitempty"`
+	Action    string          `json:"action"`
+	Resource  string          `json:"resource"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	CreatedAt time.Time       `json:"createdAt"`
+	PrevHash  string          `json:"prevHash"`
+	Hash      string          `json:"hash"`
+}
+
+type auditListResponse struct {
+	Events     []auditEventResponse `json:"events"`
+	NextCursor string               `json:"nextCursor,omitempty"`
+}
+
+// auditHandler lists the audit events of the caller's organization in a time range,
+// newest first. The cursor of a full page continues with older events.
+func (s *Server) auditHandler(ctx *gin.Context) {
+	filter, err := auditFilter(ctx)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	p := principal(ctx)
+	filter.OrganizationId = p.OrganizationId
+	filter.AccountId = p.AccountId
+
+	events, err := s.audit.List(ctx, filter)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	resp := auditListResponse{Events: make([]auditEventResponse, 0, len(events))}
+	for _, e := range events {
+		resp.Events = append(resp.Events, auditEventResponse{
+			ID:        e.ID,
+			Actor:     e.Actor,
+			APIKeyId:  e.APIKeyId,
+			IP:        e.IP,
+			RequestID: e.RequestID,
+			Action:    e.Action,
+			Resource:  e.Resource,
+			Before:    rawJSON(e.Before),
+			After:     rawJSON(e.After),
+			CreatedAt: e.CreatedAt,
+			PrevHash:  e.PrevHash,
+			Hash:      e.Hash,
+		})
+	}
+	if len(events) == filter.Limit {
+		resp.NextCursor = strconv.FormatUint(uint64(events[len(events)-1].ID), 10)
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+func auditFilter(ctx *gin.Context) (domain.AuditFilter, error) {
+	verr := &domain.ValidationError{}
+	filter := domain.AuditFilter{Limit: defaultAuditLimit}
+
+	bounds := []struct {
+		field  string
+		target *time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}}
+	for _, b := range bounds {
+		if value := ctx.Query(b.field); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				verr.Add(b.field, "must be an RFC 3339 timestamp")
+			}
+			*b.target = t
+		}
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		verr.Add("to", "must be after from")
+	}
+
+	if value := ctx.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxAuditLimit {
+			verr.Add("limit", "must be between 1 and "+strconv.Itoa(maxAuditLimit))
+		}
+		filter.Limit = limit
+	}
+
+	if value := ctx.Query("cursor"); value != "" {
+		cursor, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			verr.Add("cursor", "is invalid")
+		}
+		filter.BeforeId = uint(cursor)
+	}
+
+	return filter, verr.OrNil()
+}
+
+func rawJSON(value string) json.RawMessage {
+	if value == "" {
+		return nil
+	}
+	return json.RawMessage(value)
+}
@@ -0,0 +1,187 @@
+package server
+
+import (
+	"coding2fun.in/url-shortner/internal/domain"
+	"coding2fun.in/url-shortner/internal/service"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxBulkBodyBytes = 10 << 20 // 10 MB
+
+// csvColumns maps the accepted CSV header names to the link input fields
+var csvColumns = map[string]string{
+	"url":         "url",
+	"long_url":    "url",
+	"destination": "url",
+	"slug":        "slug",
+	"custom_slug": "slug",
+	"expiry":      "expiresAt",
+	"expires_at":  "expiresAt",
+	"expiresat":   "expiresAt",
+	"tags":        "tags",
+}
+
+// positionalColumns is the column order of a CSV upload without a header row
+var positionalColumns = []string{"url", "slug", "expiresAt", "tags"}
+
+type bulkJobResponse struct {
+	ID         uint                   `json:"id"`
+	Status     domain.BulkJobStatus   `json:"status"`
+	Total      int                    `json:"total"`
+	Succeeded  int                    `json:"succeeded"`
+	Failed     int                    `json:"failed"`
+	Error      string                 `json:"error,omitempty"`
+	Results    []domain.BulkRowResult `json:"results,omitempty"`
+	CreatedAt  time.Time              `json:"createdAt"`
+	FinishedAt *time.Time             `json:"finishedAt,omitempty"`
+	StatusURL  string                 `json:"statusUrl"`
+}
+
+func newBulkJobResponse(job *domain.BulkJob) bulkJobResponse {
+	return bulkJobResponse{
+		ID:         job.ID,
+		Status:     job.Status,
+		Total:      job.Total,
+		Succeeded:  job.Succeeded,
+		Failed:     job.Failed,
+		Error:      job.Error,
+		Results:    job.Results,
+		CreatedAt:  job.CreatedAt,
+		FinishedAt: job.FinishedAt,
+		StatusURL:  "/v1/urls/bulk/" + strconv.FormatUint(uint64(job.ID), 10),
+	}
+}
+
+// bulkCreateHandler accepts a JSON array or a CSV upload of links. Small requests
+// answer with the per-row report, large ones with 202 and a job to poll.
+func (s *Server) bulkCreateHandler(ctx *gin.Context) {
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxBulkBodyBytes)
+
+	inputs, err := parseBulkRequest(ctx)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	report, job, err := s.bulk.Submit(ctx, principal(ctx), inputs)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	if job != nil {
+		resp := newBulkJobResponse(job)
+		ctx.Header("Location", resp.StatusURL)
+		ctx.JSON(http.StatusAccepted, resp)
+		return
+	}
+	ctx.JSON(http.StatusOK, report)
+}
+
+func (s *Server) bulkJobHandler(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		abortWithError(ctx, domain.ErrNotFound)
+		return
+	}
+
+	job, err := s.bulk.Job(ctx, principal(ctx), uint(id))
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, newBulkJobResponse(job))
+}
+
+func parseBulkRequest(ctx *gin.Context) ([]service.LinkInput, error) {
+	mediaType, _, _ := mime.ParseMediaType(ctx.GetHeader("Content-Type"))
+
+	switch mediaType {
+	case "application/json":
+		var inputs []service.LinkInput
+		if err := json.NewDecoder(ctx.Request.Body).Decode(&inputs); err != nil {
+			return nil, fmt.Errorf("%w: %w", domain.NewValidationError("body", "must be a JSON array of links"), err)
+		}
+		return inputs, nil
+	case "text/csv":
+		return parseCSV(ctx.Request.Body)
+	case "multipart/form-data":
+		file, _, err := ctx.Request.FormFile("file")
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", domain.NewValidationError("file", "a CSV file upload is required"), err)
+		}
+		defer file.Close()
+		return parseCSV(file)
+	default:
+		return nil, domain.NewValidationError("Content-Type", "must be application/json, text/csv or multipart/form-data")
+	}
+}
+
+// parseCSV reads links from CSV with an optional header row. Multiple tags in
+// one cell are separated by '|' or ';'.
+func parseCSV(r io.Reader) ([]service.LinkInput, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, domain.NewValidationError("file", "is too large")
+		}
+		return nil, fmt.Errorf("%w: %w", domain.NewValidationError("file", "is not valid CSV"), err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := positionalColumns
+	if header, ok := csvHeader(records[0]); ok {
+		columns = header
+		records = records[1:]
+	}
+
+	inputs := make([]service.LinkInput, 0, len(records))
+	for _, record := range records {
+		var in service.LinkInput
+		for i, value := range record {
+			if i >= len(columns) {
+				break
+			}
+			switch columns[i] {
+			case "url":
+				in.URL = value
+			case "slug":
+				in.Slug = value
+			case "expiresAt":
+				in.ExpiresAt = value
+			case "tags":
+				in.Tags = strings.FieldsFunc(value, func(r rune) bool { return r == '|' || r == ';' })
+			}
+		}
+		inputs = append(inputs, in)
+	}
+	return inputs, nil
+}
+
+// csvHeader recognizes a header row by its url column
+func csvHeader(record []string) ([]string, bool) {
+	columns := make([]string, len(record))
+	hasURL := false
+	for i, name := range record {
+		columns[i] = csvColumns[strings.ToLower(strings.TrimSpace(name))]
+		hasURL = hasURL || columns[i] == "url"
+	}
+	return columns, hasURL
+}
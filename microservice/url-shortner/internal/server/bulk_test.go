@@ -0,0 +1,124 @@
+package server
+
+import (
+	"coding2fun.in/url-shortner/internal/config"
+	"coding2fun.in/url-shortner/internal/domain"
+	"coding2fun.in/url-shortner/internal/service"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCSV(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+		want []service.LinkInput
+	}{
+		{"empty", "", nil},
+		{
+			"positional",
+			"https://example.com/a,promo,2030-01-01T00:00:00Z,x|y;z\nhttps://example.com/b\n",
+			[]service.LinkInput{
+				{URL: "https://example.com/a", Slug: "promo", ExpiresAt: "2030-01-01T00:00:00Z", Tags: []string{"x", "y", "z"}},
+				{URL: "https://example.com/b"},
+			},
+		},
+		{
+			"header in another order",
+			"tags,url\nnews,https://example.com/a\n",
+			[]service.LinkInput{{URL: "https://example.com/a", Tags: []string{"news"}}},
+		},
+		{
+			"header aliases",
+			"Long_URL, Custom_Slug ,EXPIRES_AT,unknown\nhttps://example.com/a,promo,2030-01-01T00:00:00Z,ignored\n",
+			[]service.LinkInput{{URL: "https://example.com/a", Slug: "promo", ExpiresAt: "2030-01-01T00:00:00Z"}},
+		},
+		{
+			"destination header",
+			"destination,expiry\nhttps://example.com/a,2030-01-01T00:00:00Z\n",
+			[]service.LinkInput{{URL: "https://example.com/a", ExpiresAt: "2030-01-01T00:00:00Z"}},
+		},
+		{
+			"header without a url column is a row",
+			"slug,tags\n",
+			[]service.LinkInput{{URL: "slug", Slug: "tags"}},
+		},
+		{
+			"quoted cells",
+			"url,tags\n\"https://example.com/?a=1,b=2\", \"say \"\"hi\"\"|x\"\n",
+			[]service.LinkInput{{URL: "https://example.com/?a=1,b=2", Tags: []string{`say "hi"`, "x"}}},
+		},
+		{
+			"rows of different lengths",
+			"https://example.com/a,promo,,,extra\nhttps://example.com/b\n",
+			// The empty tags cell reads as no tags
+			[]service.LinkInput{{URL: "https://example.com/a", Slug: "promo", Tags: []string{}}, {URL: "https://example.com/b"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCSV(strings.NewReader(tt.csv))
+			if err != nil {
+				t.Fatalf("parseCSV() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCSV() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCSVRejectsMalformedRows(t *testing.T) {
+	tests := []string{
+		"url\n\"https://example.com/a\n",
+		"url\nhttps://example.com/\"a\"\n",
+	}
+	for _, csv := range tests {
+		_, err := parseCSV(strings.NewReader(csv))
+		var verr *domain.ValidationError
+		if !errors.As(err, &verr) || verr.Fields[0].Field != "file" {
+			t.Errorf("parseCSV(%q) error = %v, want a validation error on file", csv, err)
+		}
+	}
+}
+
+func TestBulkRunsLargeOrSlowRequestsAsJobs(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.Links.BulkSyncLimit = 3
+		cfg.Links.ResolveTimeout = 2 * time.Second
+		cfg.Links.BulkSyncBudget = 5 * time.Second
+	})
+	t.Cleanup(func() {
+		if err := ts.bulk.Shutdown(context.Background()); err != nil {
+			t.Error(err)
+		}
+	})
+	rows := func(n int) string {
+		links := make([]string, n)
+		for i := range links {
+			links[i] = `{"url":"https://example.com/` + strings.Repeat("x", i+1) + `"}`
+		}
+		return "[" + strings.Join(links, ",") + "]"
+	}
+
+	// Two lookups of 2s fit the 5s budget
+	w := ts.do(http.MethodPost, "/v1/urls/bulk", ts.key, rows(2))
+	var report service.BulkReport
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &report) != nil || report.Succeeded != 2 {
+		t.Fatalf("two rows answered %d: %s, want the report of two links", w.Code, w.Body)
+	}
+
+	// Three are within the row limit, but could take 6s
+	for _, n := range []int{3, 4} {
+		w := ts.do(http.MethodPost, "/v1/urls/bulk", ts.key, rows(n))
+		if w.Code != http.StatusAccepted || !strings.HasPrefix(w.Header().Get("Location"), "/v1/urls/bulk/") {
+			t.Fatalf("%d rows answered %d: %s, want a job", n, w.Code, w.Body)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package server
+
+import (
+	"bytes"
+	"coding2fun.in/url-shortner/internal/domain"
+	"coding2fun.in/url-shortner/internal/log"
+	"coding2fun.in/url-shortner/internal/openapi"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// maxRecordedBytes bounds the response bodies kept for validation in release mode
+const maxRecordedBytes = 1 << 20
+
+// contractMiddleware checks /v1 traffic against the OpenAPI spec. In debug
+// mode invalid requests are rejected before they reach a handler and responses
+// breaking the spec are replaced by an internal error, so they surface in
+// development. Release mode only logs both and leaves the request to the
+// handlers, a spec lagging behind them must not break clients.
+func (s *Server) contractMiddleware() gin.HandlerFunc {
+	strict := s.config.Server.Mode != gin.ReleaseMode
+	var undocumented sync.Map
+
+	return func(ctx *gin.Context) {
+		op, err := s.contract.Match(ctx.Request)
+		if errors.Is(err, openapi.ErrUnknownRoute) {
+			route := ctx.Request.Method + " " + ctx.FullPath()
+			if _, seen := undocumented.LoadOrStore(route, true); !seen {
+				log.Warn("Route is missing from the openapi spec", zap.String("route", route))
+			}
+			ctx.Next()
+			return
+		}
+		if err != nil {
+			if strict {
+				abortWithError(ctx, err)
+				return
+			}
+			log.Warn("Request could not be matched to the openapi spec", zap.String("requestId", requestID(ctx)), zap.Error(err))
+			ctx.Next()
+			return
+		}
+
+		if isJSONContent(ctx.ContentType()) {
+			ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxBulkBodyBytes)
+		}
+		if err := op.ValidateRequest(ctx); err != nil {
+			switch {
+			case !strict:
+				log.Warn("Request violates the openapi spec",
+					zap.String("requestId", requestID(ctx)),
+					zap.String("operation", op.ID()),
+					zap.Error(err),
+				)
+			case errors.Is(err, openapi.ErrMalformedBody):
+				abortWithBindError(ctx, err)
+				return
+			default:
+				abortWithError(ctx, err)
+				return
+			}
+		}
+
+		writer := &contractWriter{ResponseWriter: ctx.Writer, hold: strict, status: http.StatusOK}
+		ctx.Writer = writer
+		ctx.Next()
+		ctx.Writer = writer.ResponseWriter
+
+		if !writer.written {
+			// Handlers that only set a status leave it to gin to write the header
+			if writer.statusSet {
+				ctx.Writer.WriteHeader(writer.status)
+			}
+			return
+		}
+		if writer.truncated {
+			return
+		}
+
+		err = op.ValidateResponse(ctx, writer.status, ctx.Writer.Header(), writer.body.Bytes())
+		if err == nil {
+			writer.flush()
+			return
+		}
+		log.Error("Response violates the openapi spec",
+			zap.String("requestId", requestID(ctx)),
+			zap.String("operation", op.ID()),
+			zap.Int("status", writer.status),
+			zap.Error(err),
+		)
+		if writer.held() {
+			ctx.Writer.Header().Del("Content-Length")
+			ctx.Writer.Header().Del("Content-Disposition")
+			ctx.JSON(http.StatusInternalServerError, errorEnvelope{Error: errorBody{
+				Code:      domain.CodeInternal,
+				Message:   domain.ErrorMessage(domain.CodeInternal),
+				RequestID: requestID(ctx),
+			}})
+		}
+	}
+}
+
+// contractWriter records the response of a handler for validation. JSON
+// responses are held back until they are validated when hold is set, other
+// responses are passed through.
+type contractWriter struct {
+	gin.ResponseWriter
+	hold bool
+
+	status    int
+	statusSet bool
+	written   bool
+	// capture is set when the body is JSON and recorded
+	capture   bool
+	truncated bool
+	body      bytes.Buffer
+}
+
+func (w *contractWriter) held() bool {
+	return w.hold && w.capture
+}
+
+func (w *contractWriter) WriteHeader(code int) {
+	if code > 0 && !w.written {
+		w.status = code
+		w.statusSet = true
+	}
+}
+
+func (w *contractWriter) WriteHeaderNow() {
+	if w.written {
+		return
+	}
+	w.written = true
+	w.capture = isJSONContent(w.Header().Get("Content-Type"))
+	if !w.held() {
+		w.ResponseWriter.WriteHeader(w.status)
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *contractWriter) Write(data []byte) (int, error) {
+	w.WriteHeaderNow()
+	if w.capture && !w.truncated {
+		if !w.hold && w.body.Len()+len(data) > maxRecordedBytes {
+			w.truncated = true
+			w.body.Reset()
+		} else {
+			w.body.Write(data)
+		}
+	}
+	if w.held() {
+		return len(data), nil
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *contractWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *contractWriter) Status() int {
+	return w.status
+}
+
+func (w *contractWriter) Written() bool {
+	return w.written
+}
+
+func (w *contractWriter) Size() int {
+	if !w.written {
+		return -1
+	}
+	if w.held() {
+		return w.body.Len()
+	}
+	return w.ResponseWriter.Size()
+}
+
+func (w *contractWriter) Flush() {
+	if !w.held() {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// flush sends a held response once it passed validation
+func (w *contractWriter) flush() {
+	if !w.held() {
+		return
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	_, _ = w.ResponseWriter.Write(w.body.Bytes())
+}
+
+func isJSONContent(contentType string) bool {
+	media, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (media == "application/json" || strings.HasSuffix(media, "+json"))
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"coding2fun.in/url-shortner/internal/config"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// elevenTags break the spec's limit of ten tags, the service drops the duplicate and accepts them
+const elevenTags = `{"url":"https://example.com/","tags":["a","b","c","d","e","f","g","h","i","j","j"]}`
+
+func TestContractRejectsInvalidRequestsInDebug(t *testing.T) {
+	ts := newTestServer(t)
+
+	if w := ts.do(http.MethodPost, "/v1/urls", ts.key, elevenTags); w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), `"tags"`) {
+		t.Errorf("too many tags answered %d: %s", w.Code, w.Body)
+	}
+	if w := ts.do(http.MethodPost, "/v1/urls", ts.key, `{"url":`); w.Code != http.StatusBadRequest {
+		t.Errorf("a malformed body answered %d: %s", w.Code, w.Body)
+	}
+	if w := ts.do(http.MethodGet, "/v1/urls?sort=random", ts.key, ""); w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), `"sort"`) {
+		t.Errorf("an unknown sort answered %d: %s", w.Code, w.Body)
+	}
+	if w := ts.do(http.MethodPost, "/v1/urls", ts.key, `{"url":"https://example.com/"}`); w.Code != http.StatusCreated {
+		t.Errorf("a valid request answered %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestContractOnlyLogsInvalidRequestsInRelease(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.Server.Mode = gin.ReleaseMode
+	})
+	t.Cleanup(func() { gin.SetMode(gin.TestMode) })
+
+	if w := ts.do(http.MethodPost, "/v1/urls", ts.key, elevenTags); w.Code != http.StatusCreated {
+		t.Errorf("a request the handler accepts answered %d: %s", w.Code, w.Body)
+	}
+	// The handlers still reject what they cannot serve
+	if w := ts.do(http.MethodPost, "/v1/urls", ts.key, `{"url":`); w.Code != http.StatusBadRequest {
+		t.Errorf("a malformed body answered %d: %s", w.Code, w.Body)
+	}
+	if w := ts.do(http.MethodGet, "/v1/urls?sort=random", ts.key, ""); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("an unknown sort answered %d: %s", w.Code, w.Body)
+	}
+}
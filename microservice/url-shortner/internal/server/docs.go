@@ -0,0 +1,25 @@
+package server
+
+import (
+	"coding2fun.in/url-shortner/internal/openapi"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+func (s *Server) specHandler(ctx *gin.Context) {
+	ctx.Data(http.StatusOK, "application/yaml", openapi.Spec)
+}
+
+func (s *Server) specJSONHandler(ctx *gin.Context) {
+	spec, err := openapi.JSON()
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.Data(http.StatusOK, "application/json", spec)
+}
+
+// docsHandler serves the API reference rendered from the spec
+func (s *Server) docsHandler(ctx *gin.Context) {
+	ctx.Data(http.StatusOK, "text/html; charset=utf-8", openapi.DocsPage)
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"coding2fun.in/url-shortner/internal/domain"
+	"coding2fun.in/url-shortner/internal/service"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+type verificationRecord struct {
+	Type  string `json:"type"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type domainResponse struct {
+	ID           uint               `json:"id"`
+	Host         string             `json:"host"`
+	Verified     bool               `json:"verified"`
+	VerifiedAt   *time.Time         `json:"verifiedAt,omitempty"`
+	Verification verificationRecord `json:"verification"`
+	FallbackURL  string             `json:"fallbackUrl,omitempty"`
+	NotFoundPage string             `json:"notFoundPage,omitempty"`
+	CreatedAt    time.Time          `json:"createdAt"`
+}
+
+func newDomainResponse(d *domain.Domain) domainResponse {
+	name, value := service.VerificationRecord(d)
+	return domainResponse{
+		ID:           d.ID,
+		Host:         d.Host,
+		Verified:     d.Verified(),
+		VerifiedAt:   d.VerifiedAt,
+		Verification: verificationRecord{Type: "TXT", Name: name, Value: value},
+		FallbackURL:  d.FallbackURL,
+		NotFoundPage: d.NotFoundPage,
+		CreatedAt:    d.CreatedAt,
+	}
+}
+
+// createDomainHandler registers a custom domain, the response holds the TXT
+// record to publish before calling verify
+func (s *Server) createDomainHandler(ctx *gin.Context) {
+	var in service.DomainInput
+	if err := ctx.ShouldBindJSON(&in); err != nil {
+		abortWithBindError(ctx, err)
+		return
+	}
+
+	d, err := s.domains.Create(ctx, principal(ctx), in)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, newDomainResponse(d))
+}
+
+func (s *Server) listDomainsHandler(ctx *gin.Context) {
+	domains, err := s.domains.List(ctx, principal(ctx))
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	resp := make([]domainResponse, 0, len(domains))
+	for i := range domains {
+		resp = append(resp, newDomainResponse(&domains[i]))
+	}
+	ctx.JSON(http.StatusOK, gin.H{"domains": resp})
+}
+
+func (s *Server) domainHandler(ctx *gin.Context) {
+	id, ok := pathID(ctx, "id")
+	if !ok {
+		return
+	}
+
+	d, err := s.domains.Get(ctx, principal(ctx), id)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, newDomainResponse(d))
+}
+
+func (s *Server) updateDomainHandler(ctx *gin.Context) {
+	id, ok := pathID(ctx, "id")
+	if !ok {
+		return
+	}
+
+	var patch service.DomainPatch
+	if err := ctx.ShouldBindJSON(&patch); err != nil {
+		abortWithBindError(ctx, err)
+		return
+	}
+
+	d, err := s.domains.Update(ctx, principal(ctx), id, patch)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, newDomainResponse(d))
+}
+
+// deleteDomainHandler removes a domain, domains that still have links are refused with 409
+func (s *Server) deleteDomainHandler(ctx *gin.Context) {
+	id, ok := pathID(ctx, "id")
+	if !ok {
+		return
+	}
+
+	if err := s.domains.Delete(ctx, principal(ctx), id); err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// verifyDomainHandler checks the TXT record of the domain, an unverified domain answers with 422
+func (s *Server) verifyDomainHandler(ctx *gin.Context) {
+	id, ok := pathID(ctx, "id")
+	if !ok {
+		return
+	}
+
+	d, err := s.domains.Verify(ctx, principal(ctx), id)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, newDomainResponse(d))
+}
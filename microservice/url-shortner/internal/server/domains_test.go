@@ -0,0 +1,54 @@
+package server
+
+import (
+	"coding2fun.in/url-shortner/internal/domain"
+	"coding2fun.in/url-shortner/internal/repository"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifiedHostsAreUnique(t *testing.T) {
+	ts := newTestServer(t)
+	domains := repository.NewDomainRepository(ts.db)
+	ctx := context.Background()
+
+	claims := make([]*domain.Domain, 3)
+	for i := range claims {
+		claims[i] = &domain.Domain{AccountId: ts.account.ID, OrganizationId: uint(100 + i), Host: "go.example.com", VerificationToken: "token"}
+		if err := domains.Create(ctx, claims[i]); err != nil {
+			t.Fatalf("claim %d of a pending host failed: %v", i+1, err)
+		}
+	}
+	duplicate := &domain.Domain{AccountId: ts.account.ID, OrganizationId: 100, Host: "go.example.com", VerificationToken: "token"}
+	if err := domains.Create(ctx, duplicate); !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("a second claim by one organization returned %v, want ErrConflict", err)
+	}
+
+	now := time.Now()
+	claims[1].VerifiedAt = &now
+	if err := domains.Update(ctx, claims[1]); err != nil {
+		t.Fatalf("verifying the first claim failed: %v", err)
+	}
+	claims[2].VerifiedAt = &now
+	if err := domains.Update(ctx, claims[2]); !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("verifying a host twice returned %v, want ErrConflict", err)
+	}
+}
+
+func TestLinksMayNotTargetCustomDomains(t *testing.T) {
+	ts := newTestServer(t)
+	now := time.Now()
+	site := &domain.Domain{AccountId: ts.account.ID, OrganizationId: 100, Host: "go.example.com", VerificationToken: "token", VerifiedAt: &now}
+	if err := repository.NewDomainRepository(ts.db).Create(context.Background(), site); err != nil {
+		t.Fatal(err)
+	}
+
+	w := ts.do(http.MethodPost, "/v1/urls", ts.key, `{"url":"https://go.example.com/abc"}`)
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "short link domain") {
+		t.Errorf("a link to a custom short domain answered %d: %s, want 422", w.Code, w.Body)
+	}
+}
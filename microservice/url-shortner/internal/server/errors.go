@@ -0,0 +1,107 @@
+package server
+
+import (
+	"coding2fun.in/url-shortner/internal/domain"
+	"coding2fun.in/url-shortner/internal/log"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
+	"net/http"
+	"strings"
+)
+
+var statusByCode = map[string]int{
+	domain.CodeUnauthorized:  http.StatusUnauthorized,
+	domain.CodeNotFound:      http.StatusNotFound,
+	domain.CodeConflict:      http.StatusConflict,
+	domain.CodeForbidden:     http.StatusForbidden,
+	domain.CodeValidation:    http.StatusUnprocessableEntity,
+	domain.CodeQuotaExceeded: http.StatusTooManyRequests,
+	domain.CodeInternal:      http.StatusInternalServerError,
+}
+
+type errorBody struct {
+	Code      string              `json:"code"`
+	Message   string              `json:"message"`
+	Details   []domain.FieldError `json:"details,omitempty"`
+	RequestID string              `json:"requestId"`
+}
+
+type errorEnvelope struct {
+	Error errorBody `json:"error"`
+}
+
+// abortWithError attaches the error to the request and stops the handler chain,
+// the error mapper renders the response
+func abortWithError(ctx *gin.Context, err error) {
+	_ = ctx.Error(err)
+	ctx.Abort()
+}
+
+// abortWithBindError marks a request decoding error so it is reported as a malformed request
+func abortWithBindError(ctx *gin.Context, err error) {
+	_ = ctx.Error(err).SetType(gin.ErrorTypeBind)
+	ctx.Abort()
+}
+
+// errorMapper renders the last error attached to the request as the API error envelope
+func errorMapper() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Next()
+
+		if len(ctx.Errors) == 0 || ctx.Writer.Written() {
+			return
+		}
+
+		err := ctx.Errors.Last()
+		status, body := mapError(err)
+		body.RequestID = requestID(ctx)
+		if status >= http.StatusInternalServerError {
+			log.Error("Request failed",
+				zap.String("requestId", body.RequestID),
+				zap.String("path", ctx.FullPath()),
+				zap.Error(err.Err),
+			)
+		}
+		ctx.JSON(status, errorEnvelope{Error: body})
+	}
+}
+
+func mapError(ginErr *gin.Error) (int, errorBody) {
+	err := ginErr.Err
+
+	var bindErr validator.ValidationErrors
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &bindErr):
+		details := make([]domain.FieldError, 0, len(bindErr))
+		for _, fe := range bindErr {
+			details = append(details, domain.FieldError{Field: fieldName(fe), Message: "failed on the '" + fe.Tag() + "' rule"})
+		}
+		return http.StatusUnprocessableEntity, errorBody{Code: domain.CodeValidation, Message: domain.ErrValidation.Error(), Details: details}
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr), ginErr.IsType(gin.ErrorTypeBind):
+		return http.StatusBadRequest, errorBody{Code: domain.CodeValidation, Message: "malformed request body"}
+	}
+
+	code := domain.ErrorCode(err)
+	body := errorBody{Code: code, Message: domain.ErrorMessage(code)}
+
+	var validationErr *domain.ValidationError
+	if errors.As(err, &validationErr) {
+		body.Details = validationErr.Fields
+	}
+	return statusByCode[code], body
+}
+
+// fieldName strips the top level struct name from the validator namespace
+func fieldName(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if i := strings.Index(ns, "."); i >= 0 {
+		return ns[i+1:]
+	}
+	return ns
+}
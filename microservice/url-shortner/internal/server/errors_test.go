@@ -0,0 +1,93 @@
+package server
+
+import (
+	"coding2fun.in/url-shortner/internal/domain"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+type bindRequest struct {
+	Link struct {
+		URL string `validate:"required"`
+	}
+	Slug string `validate:"max=3"`
+}
+
+func TestMapError(t *testing.T) {
+	bindErr := validator.New().Struct(bindRequest{Slug: "toolong"})
+	var syntaxErr error = &json.SyntaxError{Offset: 1}
+
+	tests := []struct {
+		name    string
+		err     *gin.Error
+		status  int
+		code    string
+		message string
+		details []domain.FieldError
+	}{
+		{
+			"validation", &gin.Error{Err: fmt.Errorf("create: %w", domain.NewValidationError("url", "must be an absolute url"))},
+			http.StatusUnprocessableEntity, domain.CodeValidation, "validation failed",
+			[]domain.FieldError{{Field: "url", Message: "must be an absolute url"}},
+		},
+		{
+			"binding rules", &gin.Error{Err: bindErr, Type: gin.ErrorTypeBind},
+			http.StatusUnprocessableEntity, domain.CodeValidation, "validation failed",
+			[]domain.FieldError{{Field: "Link.URL", Message: "failed on the 'required' rule"}, {Field: "Slug", Message: "failed on the 'max' rule"}},
+		},
+		{"malformed json", &gin.Error{Err: syntaxErr}, http.StatusBadRequest, domain.CodeValidation, "malformed request body", nil},
+		{"wrong json type", &gin.Error{Err: &json.UnmarshalTypeError{Value: "string"}}, http.StatusBadRequest, domain.CodeValidation, "malformed request body", nil},
+		{"bind", &gin.Error{Err: errors.New("EOF"), Type: gin.ErrorTypeBind}, http.StatusBadRequest, domain.CodeValidation, "malformed request body", nil},
+		{"unauthorized", &gin.Error{Err: domain.ErrUnauthorized}, http.StatusUnauthorized, domain.CodeUnauthorized, "missing or invalid credentials", nil},
+		{"forbidden", &gin.Error{Err: fmt.Errorf("%w: missing scope", domain.ErrForbidden)}, http.StatusForbidden, domain.CodeForbidden, "operation not permitted", nil},
+		{"not found", &gin.Error{Err: fmt.Errorf("%w: record not found", domain.ErrNotFound)}, http.StatusNotFound, domain.CodeNotFound, "resource not found", nil},
+		{"conflict", &gin.Error{Err: domain.ErrConflict}, http.StatusConflict, domain.CodeConflict, "resource already exists", nil},
+		{"quota", &gin.Error{Err: domain.ErrQuotaExceeded}, http.StatusTooManyRequests, domain.CodeQuotaExceeded, "quota exceeded", nil},
+		{"internal", &gin.Error{Err: errors.New("dial tcp 10.0.0.5:5432: connection refused")}, http.StatusInternalServerError, domain.CodeInternal, "internal server error", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := mapError(tt.err)
+			if status != tt.status || body.Code != tt.code || body.Message != tt.message {
+				t.Errorf("mapError() = %d %s %q, want %d %s %q", status, body.Code, body.Message, tt.status, tt.code, tt.message)
+			}
+			if !reflect.DeepEqual(body.Details, tt.details) {
+				t.Errorf("mapError() details = %+v, want %+v", body.Details, tt.details)
+			}
+		})
+	}
+}
+
+func TestErrorEnvelope(t *testing.T) {
+	ts := newTestServer(t)
+
+	w := ts.do(http.MethodGet, "/v1/urls/missing", ts.key, "")
+	var envelope map[string]map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil {
+		t.Fatalf("answer %s is no JSON: %v", w.Body, err)
+	}
+	body := envelope["error"]
+	if w.Code != http.StatusNotFound || body["code"] != domain.CodeNotFound || body["message"] != "resource not found" {
+		t.Errorf("a missing link answered %d: %s", w.Code, w.Body)
+	}
+	if id, _ := body["requestId"].(string); id == "" || id != w.Header().Get(requestIDHeader) {
+		t.Errorf("requestId = %v, want the request id header %q", body["requestId"], w.Header().Get(requestIDHeader))
+	}
+	if _, ok := body["details"]; ok {
+		t.Errorf("a missing link answered details: %s", w.Body)
+	}
+
+	w = ts.do(http.MethodPost, "/v1/urls", ts.key, `{"url":`)
+	if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil {
+		t.Fatalf("answer %s is no JSON: %v", w.Body, err)
+	}
+	if w.Code != http.StatusBadRequest || envelope["error"]["code"] != domain.CodeValidation {
+		t.Errorf("a malformed body answered %d: %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"coding2fun.in/url-shortner/internal/domain"
+	"coding2fun.in/url-shortner/internal/service"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+type folderResponse struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	Links     int64     `json:"links"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func newFolderResponse(f *domain.Folder) folderResponse {
+	return folderResponse{ID: f.ID, Name: f.Name, Links: f.LinkCount, CreatedAt: f.CreatedAt}
+}
+
+func (s *Server) createFolderHandler(ctx *gin.Context) {
+	var in service.FolderInput
+	if err := ctx.ShouldBindJSON(&in); err != nil {
+		abortWithBindError(ctx, err)
+		return
+	}
+
+	folder, err := s.folders.Create(ctx, principal(ctx), in)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, newFolderResponse(folder))
+}
+
+func (s *Server) listFoldersHandler(ctx *gin.Context) {
+	folders, err := s.folders.List(ctx, principal(ctx))
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	resp := make([]folderResponse, 0, len(folders))
+	for i := range folders {
+		resp = append(resp, newFolderResponse(&folders[i]))
+	}
+	ctx.JSON(http.StatusOK, gin.H{"folders": resp})
+}
+
+func (s *Server) renameFolderHandler(ctx *gin.Context) {
+	id, ok := pathID(ctx, "id")
+	if !ok {
+		return
+	}
+
+	var in service.FolderInput
+	if err := ctx.ShouldBindJSON(&in); err != nil {
+		abortWithBindError(ctx, err)
+		return
+	}
+
+	folder, err := s.folders.Rename(ctx, principal(ctx), id, in)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, newFolderResponse(folder))
+}
+
+// deleteFolderHandler removes a folder, its links are kept outside of any folder
+func (s *Server) deleteFolderHandler(ctx *gin.Context) {
+	id, ok := pathID(ctx, "id")
+	if !ok {
+		return
+	}
+
+	if err := s.folders.Delete(ctx, principal(ctx), id); err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
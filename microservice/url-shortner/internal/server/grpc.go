@@ -0,0 +1,259 @@
+package server
+
+import (
+	"coding2fun.in/url-shortner/internal/audit"
+	"coding2fun.in/url-shortner/internal/domain"
+	"coding2fun.in/url-shortner/internal/log"
+	shortenerv1 "coding2fun.in/url-shortner/pkg/proto/shortener/v1"
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// gRPC metadata keys are the lower case REST headers
+const (
+	apiKeyMetadata       = "x-api-key"
+	organizationMetadata = "x-organization-id"
+	requestIDMetadata    = "x-request-id"
+)
+
+const (
+	// errorDomain names the service in the ErrorInfo of an error status
+	errorDomain = "shortener"
+	// shortenerServices prefixes the methods of the API, unlike health and
+	// reflection they need an API key
+	shortenerServices = "/shortener.v1."
+)
+
+var codeByErrorCode = map[string]codes.Code{
+	domain.CodeUnauthorized:  codes.Unauthenticated,
+	domain.CodeNotFound:      codes.NotFound,
+	domain.CodeConflict:      codes.AlreadyExists,
+	domain.CodeForbidden:     codes.PermissionDenied,
+	domain.CodeValidation:    codes.InvalidArgument,
+	domain.CodeQuotaExceeded: codes.ResourceExhausted,
+	domain.CodeInternal:      codes.Internal,
+}
+
+// rpcPermissions are checked before a method runs, like the authorize
+// middleware of the REST routes
+var rpcPermissions = map[string]domain.Permission{
+	shortenerv1.LinkService_CreateLink_FullMethodName:        domain.PermLinksWrite,
+	shortenerv1.LinkService_GetLink_FullMethodName:           domain.PermLinksRead,
+	shortenerv1.LinkService_ListLinks_FullMethodName:         domain.PermLinksRead,
+	shortenerv1.LinkService_UpdateLink_FullMethodName:        domain.PermLinksWrite,
+	shortenerv1.ResolveService_Resolve_FullMethodName:        domain.PermLinksRead,
+	shortenerv1.AnalyticsService_GetLinkStats_FullMethodName: domain.PermAnalyticsRead,
+}
+
+type rpcContextKey int
+
+const (
+	principalContextKey rpcContextKey = iota
+	requestIDContextKey
+)
+
+// setUpGRPC registers the gRPC API with the health service and, when
+// configured, reflection
+func (s *Server) setUpGRPC() {
+	s.grpc = grpc.NewServer(grpc.UnaryInterceptor(s.unaryInterceptor))
+	shortenerv1.RegisterLinkServiceServer(s.grpc, &linkRPC{s: s})
+	shortenerv1.RegisterResolveServiceServer(s.grpc, &resolveRPC{s: s})
+	shortenerv1.RegisterAnalyticsServiceServer(s.grpc, &analyticsRPC{s: s})
+
+	s.health = health.NewServer()
+	healthpb.RegisterHealthServer(s.grpc, s.health)
+	for name := range s.grpc.GetServiceInfo() {
+		s.health.SetServingStatus(name, healthpb.HealthCheckResponse_SERVING)
+	}
+	if s.config.Server.GRPCReflection {
+		reflection.Register(s.grpc)
+	}
+}
+
+// RunGRPC serves the gRPC API until Shutdown stops it
+func (s *Server) RunGRPC() error {
+	listener, err := net.Listen("tcp", s.config.Server.GRPCPort)
+	if err != nil {
+		return fmt.Errorf("failed to listen for grpc: %w", err)
+	}
+	log.Info("Starting gRPC server",
+		zap.String("port", s.config.Server.GRPCPort),
+		zap.Bool("reflection", s.config.Server.GRPCReflection),
+	)
+
+	if err := s.grpc.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return fmt.Errorf("failed to start grpc server: %w", err)
+	}
+	return nil
+}
+
+// shutdownGRPC reports the services as not serving and waits for the running
+// calls, the ones still running when ctx ends are cancelled
+func (s *Server) shutdownGRPC(ctx context.Context) {
+	s.health.Shutdown()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.grpc.Stop()
+	}
+	log.Info("gRPC server stopped")
+}
+
+// unaryInterceptor is the gRPC counterpart of the gin middleware: it propagates
+// the request ID, authenticates and authorizes the caller, recovers from panics
+// and maps errors to gRPC statuses
+func (s *Server) unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	id := firstMetadata(md, requestIDMetadata)
+	if id == "" || len(id) > maxRequestIDLen {
+		id = newRequestID()
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDMetadata, id))
+	ctx = context.WithValue(ctx, requestIDContextKey, id)
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			log.Error("Recovered from panic", zap.String("requestId", id), zap.Any("panic", recovered))
+			err = errors.New("panic recovered")
+		}
+		if err != nil {
+			err = rpcError(ctx, info.FullMethod, err)
+		}
+	}()
+
+	if strings.HasPrefix(info.FullMethod, shortenerServices) {
+		if ctx, err = s.authenticateRPC(ctx, md); err != nil {
+			return nil, err
+		}
+		if perm := rpcPermissions[info.FullMethod]; perm != "" {
+			if err := rpcPrincipal(ctx).Authorize(perm); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return handler(ctx, req)
+}
+
+// authenticateRPC authenticates the API key of the call the way authMiddleware
+// does for REST requests
+func (s *Server) authenticateRPC(ctx context.Context, md metadata.MD) (context.Context, error) {
+	key := firstMetadata(md, apiKeyMetadata)
+	if key == "" {
+		if auth := firstMetadata(md, "authorization"); strings.HasPrefix(auth, "Bearer ") {
+			key = strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+		}
+	}
+
+	var orgId uint
+	if value := firstMetadata(md, organizationMetadata); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || id == 0 {
+			return ctx, domain.NewValidationError(organizationMetadata, "must be an organization id")
+		}
+		orgId = uint(id)
+	}
+
+	ip := peerIP(ctx)
+	p, err := s.auth.Authenticate(ctx, key, orgId, ip)
+	if err != nil {
+		return ctx, err
+	}
+	ctx = context.WithValue(ctx, principalContextKey, p)
+	return audit.WithActor(ctx, audit.Actor{
+		Kind:      domain.ActorAPIKey,
+		APIKeyId:  p.APIKeyId,
+		IP:        ip,
+		RequestID: rpcRequestID(ctx),
+	}), nil
+}
+
+// rpcError maps an error to a gRPC status carrying the domain error code and
+// request ID, validation errors list their fields. Statuses pass unchanged.
+func rpcError(ctx context.Context, method string, err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+
+	code := domain.ErrorCode(err)
+	if code == domain.CodeInternal {
+		log.Error("Request failed",
+			zap.String("requestId", rpcRequestID(ctx)),
+			zap.String("method", method),
+			zap.Error(err),
+		)
+	}
+
+	details := []protoadapt.MessageV1{&errdetails.ErrorInfo{
+		Reason:   code,
+		Domain:   errorDomain,
+		Metadata: map[string]string{"requestId": rpcRequestID(ctx)},
+	}}
+	var validationErr *domain.ValidationError
+	if errors.As(err, &validationErr) {
+		violations := make([]*errdetails.BadRequest_FieldViolation, 0, len(validationErr.Fields))
+		for _, f := range validationErr.Fields {
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{Field: f.Field, Description: f.Message})
+		}
+		details = append(details, &errdetails.BadRequest{FieldViolations: violations})
+	}
+
+	st := status.New(codeByErrorCode[code], domain.ErrorMessage(code))
+	if detailed, err := st.WithDetails(details...); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// rpcPrincipal returns the caller authenticated by the interceptor
+func rpcPrincipal(ctx context.Context) *domain.Principal {
+	return ctx.Value(principalContextKey).(*domain.Principal)
+}
+
+func rpcRequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey).(string)
+	return id
+}
+
+func firstMetadata(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// peerIP is the address of the caller, gRPC clients connect directly
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
@@ -0,0 +1,295 @@
+package server
+
+import (
+	"coding2fun.in/url-shortner/internal/domain"
+	"coding2fun.in/url-shortner/internal/service"
+	shortenerv1 "coding2fun.in/url-shortner/pkg/proto/shortener/v1"
+	"context"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"strconv"
+	"time"
+)
+
+// rpcLinkSorts maps the sort orders of ListLinks, unspecified sorts by creation
+var rpcLinkSorts = map[shortenerv1.LinkSort]domain.LinkSort{
+	shortenerv1.LinkSort_LINK_SORT_UNSPECIFIED:  domain.SortCreated,
+	shortenerv1.LinkSort_LINK_SORT_CREATED:      domain.SortCreated,
+	shortenerv1.LinkSort_LINK_SORT_CLICKS:       domain.SortClicks,
+	shortenerv1.LinkSort_LINK_SORT_LAST_CLICKED: domain.SortLastClicked,
+}
+
+// linkRPC serves the LinkService of the gRPC API with the link service of the REST handlers
+type linkRPC struct {
+	shortenerv1.UnimplementedLinkServiceServer
+	s *Server
+}
+
+func (r *linkRPC) CreateLink(ctx context.Context, req *shortenerv1.CreateLinkRequest) (*shortenerv1.Link, error) {
+	in := service.LinkInput{
+		URL:              req.Url,
+		Slug:             req.Slug,
+		Tags:             req.Tags,
+		Password:         req.Password,
+		MaxClicks:        req.MaxClicks,
+		OneTime:          req.OneTime,
+		RedirectType:     int(req.RedirectType),
+		Domain:           req.Domain,
+		Title:            req.Title,
+		FolderId:         uint(req.FolderId),
+		UTM:              utmOf(req.Utm),
+		QueryPassthrough: req.QueryPassthrough,
+		Rules:            rulesOf(req.Rules),
+		Variants:         variantsOf(req.Variants),
+	}
+	if req.ExpiresAt != nil {
+		in.ExpiresAt = req.ExpiresAt.AsTime().Format(time.RFC3339)
+	}
+
+	link, err := r.s.links.Create(ctx, rpcPrincipal(ctx), in)
+	if err != nil {
+		return nil, err
+	}
+	return r.s.linkMessage(ctx, link)
+}
+
+func (r *linkRPC) GetLink(ctx context.Context, req *shortenerv1.GetLinkRequest) (*shortenerv1.Link, error) {
+	link, err := r.s.links.Get(ctx, rpcPrincipal(ctx), req.Domain, req.Code)
+	if err != nil {
+		return nil, err
+	}
+	return r.s.linkMessage(ctx, link)
+}
+
+func (r *linkRPC) ListLinks(ctx context.Context, req *shortenerv1.ListLinksRequest) (*shortenerv1.ListLinksResponse, error) {
+	filter, err := rpcLinkFilter(req)
+	if err != nil {
+		return nil, err
+	}
+
+	links, err := r.s.links.List(ctx, rpcPrincipal(ctx), req.Domain, lookahead(filter))
+	if err != nil {
+		return nil, err
+	}
+	links, next := nextLinkPage(filter, links)
+
+	resp := &shortenerv1.ListLinksResponse{Links: make([]*shortenerv1.Link, 0, len(links)), NextCursor: next}
+	for i := range links {
+		link, err := r.s.linkMessage(ctx, &links[i])
+		if err != nil {
+			return nil, err
+		}
+		resp.Links = append(resp.Links, link)
+	}
+	return resp, nil
+}
+
+// UpdateLink edits a link, the cached copy is dropped so redirects pick up the change
+func (r *linkRPC) UpdateLink(ctx context.Context, req *shortenerv1.UpdateLinkRequest) (*shortenerv1.Link, error) {
+	patch := service.LinkPatch{
+		URL:              req.Url,
+		IsActive:         req.IsActive,
+		Title:            req.Title,
+		QueryPassthrough: req.QueryPassthrough,
+	}
+	switch {
+	case req.RemoveExpiry && req.ExpiresAt != nil:
+		return nil, domain.NewValidationError("expiresAt", "cannot be set when removeExpiry is")
+	case req.RemoveExpiry:
+		patch.ExpiresAt = new(string)
+	case req.ExpiresAt != nil:
+		expiresAt := req.ExpiresAt.AsTime().Format(time.RFC3339)
+		patch.ExpiresAt = &expiresAt
+	}
+	if req.RedirectType != nil {
+		redirectType := int(*req.RedirectType)
+		patch.RedirectType = &redirectType
+	}
+	if req.FolderId != nil {
+		folderId := uint(*req.FolderId)
+		patch.FolderId = &folderId
+	}
+	if req.Tags != nil {
+		patch.Tags = &req.Tags.Names
+	}
+	if req.Utm != nil {
+		utm := utmOf(req.Utm)
+		patch.UTM = &utm
+	}
+	if req.Rules != nil {
+		rules := rulesOf(req.Rules.Rules)
+		patch.Rules = &rules
+	}
+	if req.Variants != nil {
+		variants := variantsOf(req.Variants.Variants)
+		patch.Variants = &variants
+	}
+
+	link, err := r.s.links.Update(ctx, rpcPrincipal(ctx), req.Domain, req.Code, patch)
+	if err != nil {
+		return nil, err
+	}
+	r.s.cache.Invalidate(link.DomainId, link.ShortCode)
+	return r.s.linkMessage(ctx, link)
+}
+
+// rpcLinkFilter checks a ListLinks request like linkFilter checks the query of a listing
+func rpcLinkFilter(req *shortenerv1.ListLinksRequest) (domain.LinkFilter, error) {
+	verr := &domain.ValidationError{}
+	filter := domain.LinkFilter{
+		Active:          req.Active,
+		Expired:         req.Expired,
+		Tag:             req.Tag,
+		DestinationHost: req.Host,
+		Ascending:       req.Ascending,
+		Limit:           defaultLinkLimit,
+		WithTags:        true,
+		WithRules:       true,
+		WithVariants:    true,
+	}
+	if req.FolderId != nil {
+		folderId := uint(*req.FolderId)
+		filter.FolderId = &folderId
+	}
+
+	if req.CreatedFrom != nil {
+		filter.CreatedFrom = req.CreatedFrom.AsTime()
+	}
+	if req.CreatedTo != nil {
+		filter.CreatedTo = req.CreatedTo.AsTime()
+	}
+	if !filter.CreatedFrom.IsZero() && !filter.CreatedTo.IsZero() && !filter.CreatedFrom.Before(filter.CreatedTo) {
+		verr.Add("createdTo", "must be after createdFrom")
+	}
+
+	sort, ok := rpcLinkSorts[req.Sort]
+	if !ok {
+		verr.Add("sort", "is not a link sort")
+	}
+	filter.Sort = sort
+
+	if req.Limit != 0 {
+		if req.Limit < 1 || req.Limit > maxLinkLimit {
+			verr.Add("limit", "must be between 1 and "+strconv.Itoa(maxLinkLimit))
+		}
+		filter.Limit = int(req.Limit)
+	}
+
+	if req.Cursor != "" {
+		cursor, err := decodeLinkCursor(req.Cursor)
+		switch {
+		case err != nil:
+			verr.Add("cursor", "is invalid")
+		case cursor.Sort != filter.Sort || cursor.Ascending != filter.Ascending:
+			verr.Add("cursor", "was issued for another sort order")
+		default:
+			filter.After = &domain.LinkCursor{ID: cursor.ID, Time: cursor.Time, Clicks: cursor.Clicks}
+		}
+	}
+
+	return filter, verr.OrNil()
+}
+
+// linkMessage renders a link as the REST API does
+func (s *Server) linkMessage(ctx context.Context, link *domain.ShortUrl) (*shortenerv1.Link, error) {
+	resp, err := s.newLinkResponse(ctx, link)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &shortenerv1.Link{
+		ShortCode:         resp.ShortCode,
+		ShortUrl:          resp.ShortURL,
+		OriginalUrl:       resp.OriginalURL,
+		Title:             resp.Title,
+		FolderId:          uint64(resp.FolderId),
+		IsActive:          resp.IsActive,
+		ExpiresAt:         timestamp(resp.ExpiresAt),
+		Clicks:            resp.Clicks,
+		LastClickedAt:     timestamp(resp.LastClickedAt),
+		Tags:              resp.Tags,
+		PasswordProtected: resp.Protected,
+		MaxClicks:         resp.MaxClicks,
+		RedirectType:      int32(resp.RedirectType),
+		Version:           int32(resp.Version),
+		CreatedAt:         timestamppb.New(resp.CreatedAt),
+		QueryPassthrough:  resp.QueryPassthrough,
+	}
+	if resp.UTM != nil {
+		msg.Utm = &shortenerv1.UTM{
+			Source:   resp.UTM.Source,
+			Medium:   resp.UTM.Medium,
+			Campaign: resp.UTM.Campaign,
+			Term:     resp.UTM.Term,
+			Content:  resp.UTM.Content,
+		}
+	}
+	for _, rule := range resp.Rules {
+		msg.Rules = append(msg.Rules, &shortenerv1.RedirectRule{
+			Url:        rule.Destination,
+			Countries:  rule.Countries,
+			Os:         rule.OS,
+			Devices:    rule.Devices,
+			Languages:  rule.Languages,
+			Referrers:  rule.Referrers,
+			From:       timestamp(rule.From),
+			Until:      timestamp(rule.Until),
+			DailyFrom:  rule.DailyFrom,
+			DailyUntil: rule.DailyUntil,
+			TimeZone:   rule.TimeZone,
+		})
+	}
+	for _, variant := range resp.Variants {
+		msg.Variants = append(msg.Variants, &shortenerv1.LinkVariant{Url: variant.Destination, Weight: int32(variant.Weight)})
+	}
+	return msg, nil
+}
+
+func utmOf(msg *shortenerv1.UTM) domain.UTM {
+	if msg == nil {
+		return domain.UTM{}
+	}
+	return domain.UTM{Source: msg.Source, Medium: msg.Medium, Campaign: msg.Campaign, Term: msg.Term, Content: msg.Content}
+}
+
+func rulesOf(msgs []*shortenerv1.RedirectRule) []domain.RedirectRule {
+	var rules []domain.RedirectRule
+	for _, msg := range msgs {
+		rules = append(rules, domain.RedirectRule{
+			Destination: msg.Url,
+			Countries:   msg.Countries,
+			OS:          msg.Os,
+			Devices:     msg.Devices,
+			Languages:   msg.Languages,
+			Referrers:   msg.Referrers,
+			From:        optionalTimestamp(msg.From),
+			Until:       optionalTimestamp(msg.Until),
+			DailyFrom:   msg.DailyFrom,
+			DailyUntil:  msg.DailyUntil,
+			TimeZone:    msg.TimeZone,
+		})
+	}
+	return rules
+}
+
+func variantsOf(msgs []*shortenerv1.LinkVariant) []domain.LinkVariant {
+	var variants []domain.LinkVariant
+	for _, msg := range msgs {
+		variants = append(variants, domain.LinkVariant{Destination: msg.Url, Weight: int(msg.Weight)})
+	}
+	return variants
+}
+
+func timestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+func optionalTimestamp(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+	t := ts.AsTime()
+	return &t
+}
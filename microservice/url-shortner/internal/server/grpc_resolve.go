@@ -0,0 +1,90 @@
+package server
+
+import (
+	"coding2fun.in/url-shortner/internal/audit"
+	"coding2fun.in/url-shortner/internal/domain"
+	"coding2fun.in/url-shortner/internal/targeting"
+	shortenerv1 "coding2fun.in/url-shortner/pkg/proto/shortener/v1"
+	"context"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"net/http"
+	"net/url"
+)
+
+// resolveRPC serves the ResolveService of the gRPC API
+type resolveRPC struct {
+	shortenerv1.UnimplementedResolveServiceServer
+	s *Server
+}
+
+// Resolve follows a short code the way redirectHandler does. The domain is
+// looked up like the Host header of a redirect, a host that is not a custom
+// domain resolves on the shared one.
+func (r *resolveRPC) Resolve(ctx context.Context, req *shortenerv1.ResolveRequest) (*shortenerv1.ResolveResponse, error) {
+	query, err := url.ParseQuery(req.Query)
+	if err != nil {
+		return nil, domain.NewValidationError("query", "is not a query string")
+	}
+
+	site, err := r.s.domains.Resolve(ctx, req.Domain)
+	if err != nil {
+		return nil, err
+	}
+	var domainId uint
+	if site != nil {
+		domainId = site.ID
+	}
+
+	actor := audit.Actor{Kind: domain.ActorSystem, IP: req.ClientIp, RequestID: rpcRequestID(ctx)}
+	link, err := r.s.lookup(ctx, domainId, req.Code, actor)
+	if err != nil {
+		return nil, err
+	}
+	if link.Protected() {
+		if req.Password == "" {
+			return nil, status.Error(codes.PermissionDenied, "the link is password protected")
+		}
+		ip := req.ClientIp
+		if ip == "" {
+			ip = peerIP(ctx)
+		}
+		unlocked, wait := r.s.unlock(link, req.Password, ip)
+		if wait > 0 {
+			st := status.New(codes.ResourceExhausted, "too many incorrect passwords")
+			if detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(wait)}); err == nil {
+				st = detailed
+			}
+			return nil, st.Err()
+		}
+		if !unlocked {
+			return nil, status.Error(codes.PermissionDenied, "the password is incorrect")
+		}
+	}
+	if err := r.s.consumeClick(ctx, link); err != nil {
+		return nil, err
+	}
+
+	// The visitor is described by the request fields instead of the headers of a redirect
+	header := http.Header{}
+	header.Set("User-Agent", req.UserAgent)
+	header.Set("Accept-Language", req.AcceptLanguage)
+	header.Set("Referer", req.Referrer)
+	visitor := targeting.NewVisitor(&http.Request{Header: header}, req.ClientIp, r.s.geo)
+
+	target, variantId := r.s.target(link, visitor, func() *domain.LinkVariant {
+		if variant := link.Variant(uint(req.VariantId)); variant != nil {
+			return variant
+		}
+		return link.PickVariant(targeting.StickyHash(link.ShortCode, req.ClientIp, req.UserAgent))
+	})
+	r.s.clicks.Record(link.ID, variantId)
+
+	return &shortenerv1.ResolveResponse{
+		Destination:  link.Destination(target, query),
+		RedirectType: int32(redirectStatus(link)),
+		VariantId:    uint64(variantId),
+	}, nil
+}
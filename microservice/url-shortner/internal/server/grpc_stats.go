@@ -0,0 +1,37 @@
+package server
+
+import (
+	shortenerv1 "coding2fun.in/url-shortner/pkg/proto/shortener/v1"
+	"context"
+)
+
+// analyticsRPC serves the AnalyticsService of the gRPC API
+type analyticsRPC struct {
+	shortenerv1.UnimplementedAnalyticsServiceServer
+	s *Server
+}
+
+// GetLinkStats reports the clicks of a link like statsHandler
+func (r *analyticsRPC) GetLinkStats(ctx context.Context, req *shortenerv1.GetLinkStatsRequest) (*shortenerv1.LinkStats, error) {
+	stats, err := r.s.links.Stats(ctx, rpcPrincipal(ctx), req.Domain, req.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := newLinkStatsResponse(req.Code, stats)
+	msg := &shortenerv1.LinkStats{
+		ShortCode:     resp.ShortCode,
+		TotalClicks:   resp.TotalClicks,
+		LastClickedAt: timestamp(resp.LastClickedAt),
+	}
+	for _, v := range resp.Variants {
+		msg.Variants = append(msg.Variants, &shortenerv1.VariantStats{
+			Url:           v.URL,
+			Weight:        int32(v.Weight),
+			Clicks:        v.Clicks,
+			Share:         v.Share,
+			ExpectedShare: v.ExpectedShare,
+		})
+	}
+	return msg, nil
+}
@@ -0,0 +1,235 @@
+package server
+
+import (
+	"coding2fun.in/url-shortner/internal/config"
+	"coding2fun.in/url-shortner/internal/domain"
+	"coding2fun.in/url-shortner/internal/service"
+	shortenerv1 "coding2fun.in/url-shortner/pkg/proto/shortener/v1"
+	"context"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	"testing"
+)
+
+// dialGRPC serves the gRPC API of the test server in memory
+func (ts *testServer) dialGRPC(t *testing.T) *grpc.ClientConn {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	go func() { _ = ts.grpc.Serve(listener) }()
+	t.Cleanup(ts.grpc.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func newGRPCTestServer(t *testing.T, configure ...func(*config.Config)) (*testServer, *grpc.ClientConn) {
+	t.Helper()
+	ts := newTestServer(t, append([]func(*config.Config){func(cfg *config.Config) { cfg.Server.GRPCPort = ":0" }}, configure...)...)
+	return ts, ts.dialGRPC(t)
+}
+
+// withKey sends the call with the API key, pairs add more metadata
+func withKey(key string, pairs ...string) context.Context {
+	if key != "" {
+		pairs = append(pairs, apiKeyMetadata, key)
+	}
+	return metadata.AppendToOutgoingContext(context.Background(), pairs...)
+}
+
+// errorReason returns the code of the status and the domain error code of its ErrorInfo
+func errorReason(err error) (codes.Code, string) {
+	st := status.Convert(err)
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return st.Code(), info.Reason
+		}
+	}
+	return st.Code(), ""
+}
+
+func TestRPCAuthentication(t *testing.T) {
+	ts, conn := newGRPCTestServer(t)
+	links := shortenerv1.NewLinkServiceClient(conn)
+	resolve := shortenerv1.NewResolveServiceClient(conn)
+	ts.createLink(t, `{"url":"https://example.com/","slug":"authed"}`)
+
+	// Health checks are not part of the API
+	if _, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Errorf("health check failed: %v", err)
+	}
+
+	calls := []struct {
+		name string
+		call func(ctx context.Context) error
+	}{
+		{"GetLink", func(ctx context.Context) error {
+			_, err := links.GetLink(ctx, &shortenerv1.GetLinkRequest{Code: "authed"})
+			return err
+		}},
+		{"Resolve", func(ctx context.Context) error {
+			_, err := resolve.Resolve(ctx, &shortenerv1.ResolveRequest{Code: "authed"})
+			return err
+		}},
+	}
+	for _, c := range calls {
+		for name, ctx := range map[string]context.Context{"no key": withKey(""), "unknown key": withKey("sk_unknown")} {
+			if code, reason := errorReason(c.call(ctx)); code != codes.Unauthenticated || reason != domain.CodeUnauthorized {
+				t.Errorf("%s with %s failed with %s %q, want unauthenticated", c.name, name, code, reason)
+			}
+		}
+		if err := c.call(withKey("", "authorization", "Bearer "+ts.key)); err != nil {
+			t.Errorf("%s with a bearer token failed: %v", c.name, err)
+		}
+	}
+}
+
+func TestRPCPermissions(t *testing.T) {
+	ts, conn := newGRPCTestServer(t)
+	links := shortenerv1.NewLinkServiceClient(conn)
+	resolve := shortenerv1.NewResolveServiceClient(conn)
+	analytics := shortenerv1.NewAnalyticsServiceClient(conn)
+	ts.createLink(t, `{"url":"https://example.com/","slug":"scoped"}`)
+
+	reader := ts.newKey(t, service.APIKeyInput{Scopes: []string{string(domain.ScopeLinksRead)}})
+	counter := ts.newKey(t, service.APIKeyInput{Scopes: []string{string(domain.ScopeAnalyticsRead)}})
+
+	denied := func(name string, err error) {
+		t.Helper()
+		if code, reason := errorReason(err); code != codes.PermissionDenied || reason != domain.CodeForbidden {
+			t.Errorf("%s failed with %s %q, want permission denied", name, code, reason)
+		}
+	}
+	_, err := links.CreateLink(withKey(reader), &shortenerv1.CreateLinkRequest{Url: "https://example.com/new"})
+	denied("CreateLink with links:read", err)
+	_, err = resolve.Resolve(withKey(counter), &shortenerv1.ResolveRequest{Code: "scoped"})
+	denied("Resolve with analytics:read", err)
+	_, err = links.GetLink(withKey(counter), &shortenerv1.GetLinkRequest{Code: "scoped"})
+	denied("GetLink with analytics:read", err)
+
+	if _, err := resolve.Resolve(withKey(reader), &shortenerv1.ResolveRequest{Code: "scoped"}); err != nil {
+		t.Errorf("Resolve with links:read failed: %v", err)
+	}
+	if _, err := analytics.GetLinkStats(withKey(counter), &shortenerv1.GetLinkStatsRequest{Code: "scoped"}); err != nil {
+		t.Errorf("GetLinkStats with analytics:read failed: %v", err)
+	}
+}
+
+func TestRPCErrorStatuses(t *testing.T) {
+	ts, conn := newGRPCTestServer(t)
+	links := shortenerv1.NewLinkServiceClient(conn)
+
+	var header metadata.MD
+	_, err := links.GetLink(withKey(ts.key, requestIDMetadata, "req-42"), &shortenerv1.GetLinkRequest{Code: "missing"}, grpc.Header(&header))
+	st := status.Convert(err)
+	if code, reason := errorReason(err); code != codes.NotFound || reason != domain.CodeNotFound {
+		t.Errorf("a missing link failed with %s %q", code, reason)
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && (info.Domain != errorDomain || info.Metadata["requestId"] != "req-42") {
+			t.Errorf("the error info is %+v, want the request id", info)
+		}
+	}
+	if got := header.Get(requestIDMetadata); len(got) != 1 || got[0] != "req-42" {
+		t.Errorf("the request id header is %q", got)
+	}
+
+	_, err = links.CreateLink(withKey(ts.key), &shortenerv1.CreateLinkRequest{Url: "not a url"})
+	if code, reason := errorReason(err); code != codes.InvalidArgument || reason != domain.CodeValidation {
+		t.Errorf("an invalid link failed with %s %q", code, reason)
+	}
+	var fields []string
+	for _, detail := range status.Convert(err).Details() {
+		if bad, ok := detail.(*errdetails.BadRequest); ok {
+			for _, v := range bad.FieldViolations {
+				fields = append(fields, v.Field)
+			}
+		}
+	}
+	if len(fields) != 1 || fields[0] != "url" {
+		t.Errorf("the invalid link reported the fields %q, want url", fields)
+	}
+
+	_, err = links.GetLink(withKey(ts.key, organizationMetadata, "acme"), &shortenerv1.GetLinkRequest{Code: "missing"})
+	if code, _ := errorReason(err); code != codes.InvalidArgument {
+		t.Errorf("a malformed organization failed with %s, want invalid argument", code)
+	}
+
+	if _, err := links.CreateLink(withKey(ts.key), &shortenerv1.CreateLinkRequest{Url: "https://example.com/", Slug: "taken"}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = links.CreateLink(withKey(ts.key), &shortenerv1.CreateLinkRequest{Url: "https://example.com/", Slug: "taken"})
+	if code, reason := errorReason(err); code != codes.InvalidArgument || reason != domain.CodeValidation {
+		t.Errorf("a taken slug failed with %s %q, want invalid argument", code, reason)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	ts, conn := newGRPCTestServer(t, func(cfg *config.Config) { cfg.Unlock.IPAttempts = 2 })
+	resolve := shortenerv1.NewResolveServiceClient(conn)
+	reader := withKey(ts.newKey(t, service.APIKeyInput{Scopes: []string{string(domain.ScopeLinksRead)}}))
+	ts.createLink(t, `{"url":"https://example.com/a?z=1","slug":"open","utm":{"source":"grpc"},"queryPassthrough":"shortLink","redirectType":302}`)
+	ts.createLink(t, `{"url":"https://example.com/secret","slug":"locked","password":"s3cret"}`)
+
+	resp, err := resolve.Resolve(reader, &shortenerv1.ResolveRequest{Code: "open", Query: "ref=mail"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Destination != "https://example.com/a?z=1&ref=mail&utm_source=grpc" || resp.RedirectType != 302 {
+		t.Errorf("resolved %+v", resp)
+	}
+	if _, err := resolve.Resolve(reader, &shortenerv1.ResolveRequest{Code: "gone"}); status.Code(err) != codes.NotFound {
+		t.Errorf("an unknown code failed with %v", err)
+	}
+	if _, err := resolve.Resolve(reader, &shortenerv1.ResolveRequest{Code: "open", Query: "%zz"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("a malformed query failed with %v", err)
+	}
+
+	visitor := &shortenerv1.ResolveRequest{Code: "locked", ClientIp: "192.0.2.1"}
+	if _, err := resolve.Resolve(reader, visitor); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("resolving without the password failed with %v", err)
+	}
+	visitor.Password = "s3cret"
+	if resp, err := resolve.Resolve(reader, visitor); err != nil || resp.Destination != "https://example.com/secret" {
+		t.Errorf("resolving with the password returned %+v, %v", resp, err)
+	}
+
+	// Wrong passwords run out per client, then even the right one waits
+	visitor.Password = "guess"
+	for range 2 {
+		if _, err := resolve.Resolve(reader, visitor); status.Code(err) != codes.PermissionDenied {
+			t.Fatalf("a wrong password failed with %v", err)
+		}
+	}
+	visitor.Password = "s3cret"
+	_, err = resolve.Resolve(reader, visitor)
+	st := status.Convert(err)
+	var retry *errdetails.RetryInfo
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			retry = info
+		}
+	}
+	if st.Code() != codes.ResourceExhausted || retry == nil || retry.RetryDelay.AsDuration() <= 0 {
+		t.Errorf("the limited client failed with %v and retry info %v", err, retry)
+	}
+
+	// Another client still gets its own attempts
+	other := &shortenerv1.ResolveRequest{Code: "locked", ClientIp: "192.0.2.2", Password: "s3cret"}
+	if _, err := resolve.Resolve(reader, other); err != nil {
+		t.Errorf("another client was limited too: %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"bytes"
+	"coding2fun.in/url-shortner/internal/cache"
+	"coding2fun.in/url-shortner/internal/domain"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+const (
+	idempotencyHeader = "Idempotency-Key"
+	// idempotentReplayHeader marks a response answered from the store
+	idempotentReplayHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLen   = 255
+)
+
+// replayedHeaders are the response headers stored with a response for replays
+var replayedHeaders = []string{"Content-Type", "Content-Disposition", "Location"}
+
+// idempotencyMiddleware answers a POST or PATCH repeated with the same
+// Idempotency-Key from the stored response instead of running it again. Keys
+// are scoped to the calling API key, reusing one for a different request is
+// rejected. Requests ending in an error are not stored so they can be retried.
+func (s *Server) idempotencyMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		key := ctx.GetHeader(idempotencyHeader)
+		method := ctx.Request.Method
+		if key == "" || !s.idempotency.Enabled() || (method != http.MethodPost && method != http.MethodPatch) {
+			ctx.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			abortWithError(ctx, domain.NewValidationError(idempotencyHeader, fmt.Sprintf("must be at most %d characters", maxIdempotencyKeyLen)))
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxImportBodyBytes))
+		if err != nil {
+			abortWithBindError(ctx, err)
+			return
+		}
+		ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		p := principal(ctx)
+		storeKey := strconv.FormatUint(uint64(p.APIKeyId), 10) + "/" + key
+		sum := sha256.Sum256(append([]byte(method+" "+ctx.Request.URL.RequestURI()+" "+strconv.FormatUint(uint64(p.OrganizationId), 10)+"\n"), body...))
+
+		stored, err := s.idempotency.Begin(storeKey, hex.EncodeToString(sum[:]))
+		switch {
+		case errors.Is(err, cache.ErrRequestInFlight):
+			abortWithError(ctx, fmt.Errorf("%w: %w", domain.ErrConflict, err))
+			return
+		case errors.Is(err, cache.ErrKeyReused):
+			abortWithError(ctx, domain.NewValidationError(idempotencyHeader, "was already used for a different request"))
+			return
+		case stored != nil:
+			for name, values := range stored.Header {
+				ctx.Writer.Header()[name] = values
+			}
+			ctx.Header(idempotentReplayHeader, "true")
+			ctx.Data(stored.Status, stored.Header.Get("Content-Type"), stored.Body)
+			ctx.Abort()
+			return
+		}
+
+		completed := false
+		defer func() {
+			if !completed {
+				s.idempotency.Release(storeKey)
+			}
+		}()
+
+		writer := &teeWriter{ResponseWriter: ctx.Writer}
+		ctx.Writer = writer
+		ctx.Next()
+		ctx.Writer = writer.ResponseWriter
+
+		status := ctx.Writer.Status()
+		if len(ctx.Errors) > 0 || status >= http.StatusInternalServerError {
+			return
+		}
+		header := http.Header{}
+		for _, name := range replayedHeaders {
+			if value := ctx.Writer.Header().Get(name); value != "" {
+				header.Set(name, value)
+			}
+		}
+		s.idempotency.Complete(storeKey, cache.StoredResponse{Status: status, Header: header, Body: writer.body.Bytes()})
+		completed = true
+	}
+}
+
+// teeWriter keeps a copy of the response body while writing it
+type teeWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *teeWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *teeWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
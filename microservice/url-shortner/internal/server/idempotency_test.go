@@ -0,0 +1,64 @@
+package server
+
+import (
+	"coding2fun.in/url-shortner/internal/service"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func (ts *testServer) post(path, key, idempotencyKey, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	return ts.serve(req, map[string]string{"X-API-Key": key, "Content-Type": "application/json", "Idempotency-Key": idempotencyKey})
+}
+
+func TestIdempotentReplay(t *testing.T) {
+	ts := newTestServer(t)
+	body := `{"url":"https://example.com/"}`
+
+	first := ts.post("/v1/urls", ts.key, "create-1", body)
+	if first.Code != http.StatusCreated || first.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("the first request answered %d: %s", first.Code, first.Body)
+	}
+	again := ts.post("/v1/urls", ts.key, "create-1", body)
+	if again.Code != http.StatusCreated || again.Header().Get("Idempotent-Replayed") != "true" || again.Body.String() != first.Body.String() {
+		t.Errorf("the retry answered %d replayed %q: %s", again.Code, again.Header().Get("Idempotent-Replayed"), again.Body)
+	}
+	if again.Header().Get("Content-Type") != first.Header().Get("Content-Type") {
+		t.Errorf("the retry has content type %q", again.Header().Get("Content-Type"))
+	}
+
+	if w := ts.post("/v1/urls", ts.key, "create-1", `{"url":"https://example.com/other"}`); w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "Idempotency-Key") {
+		t.Errorf("reusing the key for another request answered %d: %s", w.Code, w.Body)
+	}
+	if w := ts.post("/v1/urls", ts.key, strings.Repeat("k", 256), body); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("an overlong key answered %d: %s", w.Code, w.Body)
+	}
+
+	// Keys belong to the API key sending them
+	other := ts.newKey(t, service.APIKeyInput{Scopes: []string{"links:write"}})
+	if w := ts.post("/v1/urls", other, "create-1", body); w.Code != http.StatusCreated || w.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("another API key with the same idempotency key answered %d replayed %q", w.Code, w.Header().Get("Idempotent-Replayed"))
+	}
+	if got := len(shortCodes(t, ts, ts.key)); got != 2 {
+		t.Errorf("%d links were created, want 2", got)
+	}
+}
+
+func TestIdempotencyKeepsNoErrors(t *testing.T) {
+	ts := newTestServer(t)
+	// The folder only exists once the first attempt failed
+	body := `{"url":"https://example.com/","folderId":1}`
+
+	failed := ts.post("/v1/urls", ts.key, "retry-me", body)
+	if failed.Code < 400 {
+		t.Fatalf("a missing folder answered %d: %s", failed.Code, failed.Body)
+	}
+	if w := ts.do(http.MethodPost, "/v1/folders", ts.key, `{"name":"campaigns"}`); w.Code != http.StatusCreated {
+		t.Fatalf("creating the folder answered %d: %s", w.Code, w.Body)
+	}
+	if w := ts.post("/v1/urls", ts.key, "retry-me", body); w.Code != http.StatusCreated || w.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("the retry after the failure answered %d replayed %q: %s", w.Code, w.Header().Get("Idempotent-Replayed"), w.Body)
+	}
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"coding2fun.in/url-shortner/internal/domain"
+	"coding2fun.in/url-shortner/internal/service"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+type apiKeyResponse struct {
+	ID     uint          `json:"id"`
+	Name   string        `json:"name"`
+	Scopes domain.Scopes `json:"scopes"`
+	// Key is only returned when the key is created or rotated
+	Key            string     `json:"key,omitempty"`
+	AllowedDomains []string   `json:"allowedDomains"`
+	AllowedCIDRs   []string   `json:"allowedCidrs"`
+	IsActive       bool       `json:"isActive"`
+	ExpiresAt      *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt     *time.Time `json:"lastUsedAt,omitempty"`
+	RotatedToId    uint       `json:"rotatedToId,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+func newAPIKeyResponse(key *domain.APIKey, plain string) apiKeyResponse {
+	resp := apiKeyResponse{
+		ID:             key.ID,
+		Name:           key.Name,
+		Scopes:         key.Scopes,
+		Key:            plain,
+		AllowedDomains: key.AllowedDomains,
+		AllowedCIDRs:   key.AllowedCIDRs,
+		IsActive:       key.IsActive,
+		ExpiresAt:      optionalTime(key.ExpiresAt),
+		LastUsedAt:     optionalTime(key.LastUsed),
+		RotatedToId:    key.RotatedToId,
+		CreatedAt:      key.CreatedAt,
+	}
+	if resp.AllowedDomains == nil {
+		resp.AllowedDomains = []string{}
+	}
+	if resp.AllowedCIDRs == nil {
+		resp.AllowedCIDRs = []string{}
+	}
+	return resp
+}
+
+func (s *Server) createAPIKeyHandler(ctx *gin.Context) {
+	var in service.APIKeyInput
+	if err := ctx.ShouldBindJSON(&in); err != nil {
+		abortWithBindError(ctx, err)
+		return
+	}
+
+	key, plain, err := s.accounts.CreateAPIKey(ctx, principal(ctx).AccountId, in)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, newAPIKeyResponse(key, plain))
+}
+
+func (s *Server) listAPIKeysHandler(ctx *gin.Context) {
+	keys, err := s.accounts.APIKeys(ctx, principal(ctx).AccountId)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	resp := make([]apiKeyResponse, 0, len(keys))
+	for i := range keys {
+		resp = append(resp, newAPIKeyResponse(&keys[i], ""))
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+type rotateAPIKeyRequest struct {
+	// Overlap is how long the old key keeps working, like "12h", the configured window by default
+	Overlap string `json:"overlap"`
+}
+
+// rotateAPIKeyHandler issues the successor of a key, the old one stays valid for the overlap
+func (s *Server) rotateAPIKeyHandler(ctx *gin.Context) {
+	id, ok := pathID(ctx, "id")
+	if !ok {
+		return
+	}
+	var req rotateAPIKeyRequest
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			abortWithBindError(ctx, err)
+			return
+		}
+	}
+
+	var overlap *time.Duration
+	if req.Overlap != "" {
+		d, err := time.ParseDuration(req.Overlap)
+		if err != nil {
+			abortWithError(ctx, domain.NewValidationError("overlap", "must be a duration like 12h"))
+			return
+		}
+		overlap = &d
+	}
+
+	key, plain, err := s.accounts.RotateAPIKey(ctx, principal(ctx).AccountId, id, overlap)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, newAPIKeyResponse(key, plain))
+}
+
+func (s *Server) deactivateAPIKeyHandler(ctx *gin.Context) {
+	id, ok := pathID(ctx, "id")
+	if !ok {
+		return
+	}
+	if err := s.accounts.DeactivateAPIKey(ctx, principal(ctx).AccountId, id); err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// revokeAPIKeysHandler deactivates every key of the account at once, the
+// calling key included
+func (s *Server) revokeAPIKeysHandler(ctx *gin.Context) {
+	revoked, err := s.accounts.RevokeAPIKeys(ctx, principal(ctx).AccountId)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"revoked": revoked})
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"coding2fun.in/url-shortner/internal/config"
+	"coding2fun.in/url-shortner/internal/service"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestKeyScopes(t *testing.T) {
+	ts := newTestServer(t)
+	read := ts.newKey(t, service.APIKeyInput{Scopes: []string{"links:read"}})
+	write := ts.newKey(t, service.APIKeyInput{Scopes: []string{"links:write"}})
+	analytics := ts.newKey(t, service.APIKeyInput{Scopes: []string{"analytics:read"}})
+
+	tests := []struct {
+		name         string
+		key          string
+		method, path string
+		body         string
+		want         int
+	}{
+		{"read lists links", read, http.MethodGet, "/v1/urls", "", http.StatusOK},
+		{"read creates a link", read, http.MethodPost, "/v1/urls", `{"url":"https://example.com/"}`, http.StatusForbidden},
+		{"write creates a link", write, http.MethodPost, "/v1/urls", `{"url":"https://example.com/"}`, http.StatusCreated},
+		{"write lists links", write, http.MethodGet, "/v1/urls", "", http.StatusOK},
+		{"analytics lists links", analytics, http.MethodGet, "/v1/urls", "", http.StatusForbidden},
+		{"write reads the audit log", write, http.MethodGet, "/v1/audit", "", http.StatusForbidden},
+		{"write issues a key", write, http.MethodPost, "/v1/keys", `{"name":"wider","scopes":["admin"]}`, http.StatusForbidden},
+		{"admin issues a key", ts.key, http.MethodPost, "/v1/keys", `{"name":"narrow","scopes":["links:read"]}`, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := ts.do(tt.method, tt.path, tt.key, tt.body); w.Code != tt.want {
+				t.Errorf("answered %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func rotate(t *testing.T, ts *testServer, id uint, body string) string {
+	t.Helper()
+	w := ts.do(http.MethodPost, fmt.Sprintf("/v1/keys/%d/rotate", id), ts.key, body)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("rotating answered %d: %s", w.Code, w.Body)
+	}
+	var resp apiKeyResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Key
+}
+
+func TestKeyRotationOverlap(t *testing.T) {
+	ts := newTestServer(t)
+	keys, err := ts.accounts.APIKeys(context.Background(), ts.account.ID)
+	if err != nil || len(keys) != 1 {
+		t.Fatalf("want the admin key, got %d keys: %v", len(keys), err)
+	}
+	old := keys[0].ID
+
+	if w := ts.do(http.MethodPost, fmt.Sprintf("/v1/keys/%d/rotate", old), ts.key, `{"overlap":"9999h"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("an overlap above the maximum answered %d, want 422", w.Code)
+	}
+
+	successor := rotate(t, ts, old, `{"overlap":"1h"}`)
+	if w := ts.do(http.MethodGet, "/v1/urls", ts.key, ""); w.Code != http.StatusOK {
+		t.Errorf("the old key within the overlap answered %d, want 200", w.Code)
+	}
+	if w := ts.do(http.MethodGet, "/v1/urls", successor, ""); w.Code != http.StatusOK {
+		t.Errorf("the successor answered %d, want 200", w.Code)
+	}
+
+	// Rotating without overlap retires the key at once
+	ts.key = successor
+	keys, err = ts.accounts.APIKeys(context.Background(), ts.account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var current uint
+	for _, key := range keys {
+		if key.ID != old {
+			current = key.ID
+		}
+	}
+	third := rotate(t, ts, current, `{"overlap":"0s"}`)
+	if w := ts.do(http.MethodGet, "/v1/urls", successor, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("a key rotated without overlap answered %d, want 401", w.Code)
+	}
+	if w := ts.do(http.MethodGet, "/v1/urls", third, ""); w.Code != http.StatusOK {
+		t.Errorf("the newest key answered %d, want 200", w.Code)
+	}
+}
+
+func TestKeyCIDRs(t *testing.T) {
+	get := func(ts *testServer, key, remoteAddr, forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodGet, "/v1/urls", nil)
+		req.RemoteAddr = remoteAddr
+		header := map[string]string{"X-API-Key": key}
+		if forwardedFor != "" {
+			header["X-Forwarded-For"] = forwardedFor
+		}
+		return ts.serve(req, header).Code
+	}
+
+	t.Run("direct", func(t *testing.T) {
+		ts := newTestServer(t)
+		key := ts.newKey(t, service.APIKeyInput{Scopes: []string{"links:read"}, AllowedCIDRs: []string{"10.0.0.0/8", "2001:db8::1"}})
+
+		tests := []struct {
+			name                     string
+			remoteAddr, forwardedFor string
+			want                     int
+		}{
+			{"inside the range", "10.1.2.3:4000", "", http.StatusOK},
+			{"the single address", "[2001:db8::1]:4000", "", http.StatusOK},
+			{"outside the ranges", "192.0.2.1:4000", "", http.StatusForbidden},
+			{"another ipv6 address", "[2001:db8::2]:4000", "", http.StatusForbidden},
+			// Without trusted proxies a forwarded address is ignored
+			{"spoofed forwarding", "192.0.2.1:4000", "10.1.2.3", http.StatusForbidden},
+		}
+		for _, tt := range tests {
+			if got := get(ts, key, tt.remoteAddr, tt.forwardedFor); got != tt.want {
+				t.Errorf("%s answered %d, want %d", tt.name, got, tt.want)
+			}
+		}
+	})
+
+	t.Run("behind a trusted proxy", func(t *testing.T) {
+		ts := newTestServer(t, func(cfg *config.Config) {
+			cfg.Server.TrustedProxies = []string{"192.0.2.0/24"}
+		})
+		key := ts.newKey(t, service.APIKeyInput{Scopes: []string{"links:read"}, AllowedCIDRs: []string{"10.0.0.0/8"}})
+
+		if got := get(ts, key, "192.0.2.1:4000", "10.1.2.3"); got != http.StatusOK {
+			t.Errorf("a client forwarded by the proxy answered %d, want 200", got)
+		}
+		if got := get(ts, key, "192.0.2.1:4000", "198.51.100.7"); got != http.StatusForbidden {
+			t.Errorf("an outside client forwarded by the proxy answered %d, want 403", got)
+		}
+		if got := get(ts, key, "203.0.113.9:4000", "10.1.2.3"); got != http.StatusForbidden {
+			t.Errorf("forwarding by an untrusted peer answered %d, want 403", got)
+		}
+	})
+}
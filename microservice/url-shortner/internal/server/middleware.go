@@ -0,0 +1,45 @@
+package server
+
+import (
+	"coding2fun.in/url-shortner/internal/log"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	requestIDHeader = "X-Request-ID"
+	requestIDKey    = "requestId"
+	maxRequestIDLen = 128
+)
+
+// requestIDMiddleware propagates the caller supplied request ID or generates a new one
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.GetHeader(requestIDHeader)
+		if id == "" || len(id) > maxRequestIDLen {
+			id = newRequestID()
+		}
+		ctx.Set(requestIDKey, id)
+		ctx.Header(requestIDHeader, id)
+		ctx.Next()
+	}
+}
+
+func requestID(ctx *gin.Context) string {
+	return ctx.GetString(requestIDKey)
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// recoveryHandler turns a panic into an internal error rendered by the error mapper
+func recoveryHandler(ctx *gin.Context, recovered any) {
+	log.Error("Recovered from panic", zap.String("requestId", requestID(ctx)), zap.Any("panic", recovered))
+	abortWithError(ctx, errors.New("panic recovered"))
+}
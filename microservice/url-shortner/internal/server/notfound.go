@@ -0,0 +1,71 @@
+package server
+
+import (
+	"coding2fun.in/url-shortner/internal/domain"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"html/template"
+	"net"
+	"net/http"
+)
+
+var notFoundPage = template.Must(template.New("notfound").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Link not found</title>
+<style>
+body { font-family: system-ui, sans-serif; display: flex; justify-content: center; margin-top: 15vh; }
+</style>
+</head>
+<body>
+<main>
+<h1>Link not found</h1>
+<p>There is no link at this address on {{.}}.</p>
+</main>
+</body>
+</html>
+`))
+
+// site returns the custom domain the request was sent to, nil for the shared domain
+func (s *Server) site(ctx *gin.Context) (*domain.Domain, error) {
+	host := ctx.Request.Host
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+	return s.domains.Resolve(ctx, host)
+}
+
+// notFound answers a request for a missing link. Custom domains redirect to
+// their fallback url or serve their own page, other errors go to the error mapper.
+func (s *Server) notFound(ctx *gin.Context, site *domain.Domain, err error) {
+	if site == nil || !errors.Is(err, domain.ErrNotFound) {
+		abortWithError(ctx, err)
+		return
+	}
+
+	ctx.Header("Cache-Control", "no-store")
+	switch {
+	case site.FallbackURL != "":
+		ctx.Redirect(http.StatusFound, site.FallbackURL)
+	case site.NotFoundPage != "":
+		ctx.Data(http.StatusNotFound, "text/html; charset=utf-8", []byte(site.NotFoundPage))
+	default:
+		ctx.Status(http.StatusNotFound)
+		ctx.Header("Content-Type", "text/html; charset=utf-8")
+		_ = notFoundPage.Execute(ctx.Writer, site.Host)
+	}
+	ctx.Abort()
+}
+
+// noRouteHandler serves the not found page of a custom domain for paths that are not a short code
+func (s *Server) noRouteHandler(ctx *gin.Context) {
+	site, err := s.site(ctx)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	s.notFound(ctx, site, domain.ErrNotFound)
+}
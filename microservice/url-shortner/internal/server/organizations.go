@@ -0,0 +1,207 @@
+package server
+
+import (
+	"coding2fun.in/url-shortner/internal/domain"
+	"coding2fun.in/url-shortner/internal/service"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type organizationResponse struct {
+	ID        uint        `json:"id"`
+	Name      string      `json:"name"`
+	Role      domain.Role `json:"role"`
+	CreatedAt time.Time   `json:"createdAt"`
+}
+
+type memberResponse struct {
+	AccountId uint        `json:"accountId"`
+	Email     string      `json:"email"`
+	Role      domain.Role `json:"role"`
+	JoinedAt  time.Time   `json:"joinedAt"`
+}
+
+type invitationResponse struct {
+	ID        uint        `json:"id"`
+	Email     string      `json:"email"`
+	Role      domain.Role `json:"role"`
+	ExpiresAt time.Time   `json:"expiresAt"`
+	// Token is only returned when the invitation is created
+	Token     string    `json:"token,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func newInvitationResponse(invitation *domain.Invitation, token string) invitationResponse {
+	return invitationResponse{
+		ID:        invitation.ID,
+		Email:     invitation.Email,
+		Role:      invitation.Role,
+		ExpiresAt: invitation.ExpiresAt,
+		Token:     token,
+		CreatedAt: invitation.CreatedAt,
+	}
+}
+
+type createOrganizationRequest struct {
+	Name string `json:"name"`
+}
+
+func (s *Server) createOrganizationHandler(ctx *gin.Context) {
+	var req createOrganizationRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		abortWithBindError(ctx, err)
+		return
+	}
+
+	org, err := s.orgs.Create(ctx, principal(ctx), req.Name)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, organizationResponse{ID: org.ID, Name: org.Name, Role: domain.RoleOwner, CreatedAt: org.CreatedAt})
+}
+
+// listOrganizationsHandler lists the organizations of the caller's account, any
+// of them can be selected with the X-Organization-Id header
+func (s *Server) listOrganizationsHandler(ctx *gin.Context) {
+	memberships, err := s.orgs.Memberships(ctx, principal(ctx))
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	resp := make([]organizationResponse, 0, len(memberships))
+	for _, m := range memberships {
+		resp = append(resp, organizationResponse{
+			ID:        m.OrganizationId,
+			Name:      m.Organization.Name,
+			Role:      m.Role,
+			CreatedAt: m.Organization.CreatedAt,
+		})
+	}
+	ctx.JSON(http.StatusOK, gin.H{"organizations": resp})
+}
+
+func (s *Server) listMembersHandler(ctx *gin.Context) {
+	members, err := s.orgs.Members(ctx, principal(ctx))
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	resp := make([]memberResponse, 0, len(members))
+	for _, m := range members {
+		resp = append(resp, memberResponse{AccountId: m.AccountId, Email: m.Account.Email, Role: m.Role, JoinedAt: m.CreatedAt})
+	}
+	ctx.JSON(http.StatusOK, gin.H{"members": resp})
+}
+
+type updateMemberRequest struct {
+	Role domain.Role `json:"role" binding:"required"`
+}
+
+func (s *Server) updateMemberHandler(ctx *gin.Context) {
+	accountId, ok := pathID(ctx, "accountId")
+	if !ok {
+		return
+	}
+
+	var req updateMemberRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		abortWithBindError(ctx, err)
+		return
+	}
+
+	if err := s.orgs.UpdateRole(ctx, principal(ctx), accountId, req.Role); err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// removeMemberHandler removes a member, callers may always remove themselves to leave the organization
+func (s *Server) removeMemberHandler(ctx *gin.Context) {
+	accountId, ok := pathID(ctx, "accountId")
+	if !ok {
+		return
+	}
+
+	if err := s.orgs.RemoveMember(ctx, principal(ctx), accountId); err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+func (s *Server) inviteHandler(ctx *gin.Context) {
+	var in service.InvitationInput
+	if err := ctx.ShouldBindJSON(&in); err != nil {
+		abortWithBindError(ctx, err)
+		return
+	}
+
+	invitation, token, err := s.orgs.Invite(ctx, principal(ctx), in)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, newInvitationResponse(invitation, token))
+}
+
+func (s *Server) listInvitationsHandler(ctx *gin.Context) {
+	invitations, err := s.orgs.Invitations(ctx, principal(ctx))
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	resp := make([]invitationResponse, 0, len(invitations))
+	for i := range invitations {
+		resp = append(resp, newInvitationResponse(&invitations[i], ""))
+	}
+	ctx.JSON(http.StatusOK, gin.H{"invitations": resp})
+}
+
+func (s *Server) revokeInvitationHandler(ctx *gin.Context) {
+	id, ok := pathID(ctx, "id")
+	if !ok {
+		return
+	}
+
+	if err := s.orgs.RevokeInvitation(ctx, principal(ctx), id); err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+type acceptInvitationRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+func (s *Server) acceptInvitationHandler(ctx *gin.Context) {
+	var req acceptInvitationRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		abortWithBindError(ctx, err)
+		return
+	}
+
+	membership, err := s.orgs.Accept(ctx, principal(ctx), req.Token)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"organizationId": membership.OrganizationId, "role": membership.Role})
+}
+
+// pathID parses a numeric path parameter, anything else cannot name a row
+func pathID(ctx *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(ctx.Param(name), 10, 64)
+	if err != nil {
+		abortWithError(ctx, domain.ErrNotFound)
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"coding2fun.in/url-shortner/internal/domain"
+	"coding2fun.in/url-shortner/internal/service"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func invite(t *testing.T, ts *testServer, email, role string) string {
+	t.Helper()
+	w := ts.do(http.MethodPost, "/v1/org/invitations", ts.key, fmt.Sprintf(`{"email":%q,"role":%q}`, email, role))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("inviting %s answered %d: %s", email, w.Code, w.Body)
+	}
+	var resp invitationResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Token
+}
+
+func TestInvitationFlow(t *testing.T) {
+	ts := newTestServer(t)
+	org := ts.account.OrganizationId
+
+	// Invited emails are lower cased, the account keeps the case it registered with
+	token := invite(t, ts, "Alice@Example.com", "admin")
+	_, aliceKey := ts.newAccount(t, "Alice@Example.com")
+	_, bobKey := ts.newAccount(t, "bob@example.com")
+
+	if w := ts.do(http.MethodPost, "/v1/invitations/accept", bobKey, `{"token":"`+token+`"}`); w.Code != http.StatusForbidden {
+		t.Errorf("accepting another email's invitation answered %d, want 403", w.Code)
+	}
+	if w := ts.doIn(org, http.MethodGet, "/v1/org/members", aliceKey, ""); w.Code != http.StatusForbidden {
+		t.Errorf("acting in the organization before accepting answered %d, want 403", w.Code)
+	}
+	w := ts.do(http.MethodPost, "/v1/invitations/accept", aliceKey, `{"token":"`+token+`"}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"role":"admin"`) {
+		t.Fatalf("accepting the invitation answered %d: %s", w.Code, w.Body)
+	}
+	if w := ts.do(http.MethodPost, "/v1/invitations/accept", aliceKey, `{"token":"`+token+`"}`); w.Code != http.StatusNotFound {
+		t.Errorf("accepting an invitation twice answered %d, want 404", w.Code)
+	}
+	if w := ts.doIn(org, http.MethodGet, "/v1/org/members", aliceKey, ""); w.Code != http.StatusOK {
+		t.Errorf("listing members as a new admin answered %d, want 200", w.Code)
+	}
+}
+
+func TestOwnerRoleIsKeptByOwners(t *testing.T) {
+	ts := newTestServer(t)
+	org := ts.account.OrganizationId
+	owner := fmt.Sprintf("/v1/org/members/%d", ts.account.ID)
+
+	alice, aliceKey := ts.newAccount(t, "alice@example.com")
+	if w := ts.do(http.MethodPost, "/v1/invitations/accept", aliceKey, `{"token":"`+invite(t, ts, "alice@example.com", "admin")+`"}`); w.Code != http.StatusOK {
+		t.Fatalf("accepting the invitation answered %d: %s", w.Code, w.Body)
+	}
+	member := fmt.Sprintf("/v1/org/members/%d", alice.ID)
+
+	// Admins manage members but not the owner role
+	if w := ts.doIn(org, http.MethodPatch, member, aliceKey, `{"role":"owner"}`); w.Code != http.StatusForbidden {
+		t.Errorf("an admin promoting itself to owner answered %d, want 403", w.Code)
+	}
+	if w := ts.doIn(org, http.MethodPost, "/v1/org/invitations", aliceKey, `{"email":"eve@example.com","role":"owner"}`); w.Code != http.StatusForbidden {
+		t.Errorf("an admin inviting an owner answered %d, want 403", w.Code)
+	}
+	if w := ts.doIn(org, http.MethodDelete, owner, aliceKey, ""); w.Code != http.StatusForbidden {
+		t.Errorf("an admin removing the owner answered %d, want 403", w.Code)
+	}
+
+	// The last owner can neither step down nor leave
+	if w := ts.do(http.MethodPatch, owner, ts.key, `{"role":"admin"}`); w.Code != http.StatusConflict {
+		t.Errorf("demoting the last owner answered %d, want 409", w.Code)
+	}
+	if w := ts.do(http.MethodDelete, owner, ts.key, ""); w.Code != http.StatusConflict {
+		t.Errorf("the last owner leaving answered %d, want 409", w.Code)
+	}
+
+	if w := ts.do(http.MethodPatch, member, ts.key, `{"role":"owner"}`); w.Code != http.StatusNoContent {
+		t.Fatalf("the owner promoting an admin answered %d: %s", w.Code, w.Body)
+	}
+	if w := ts.do(http.MethodPatch, owner, ts.key, `{"role":"viewer"}`); w.Code != http.StatusNoContent {
+		t.Errorf("an owner stepping down next to another owner answered %d: %s", w.Code, w.Body)
+	}
+	if w := ts.do(http.MethodPost, "/v1/urls", ts.key, `{"url":"https://example.com/"}`); w.Code != http.StatusForbidden {
+		t.Errorf("a viewer creating a link answered %d, want 403", w.Code)
+	}
+}
+
+func TestLeavingNeedsAFullAccountKey(t *testing.T) {
+	ts := newTestServer(t)
+	org := ts.account.OrganizationId
+
+	alice, aliceKey := ts.newAccount(t, "alice@example.com")
+	if w := ts.do(http.MethodPost, "/v1/invitations/accept", aliceKey, `{"token":"`+invite(t, ts, "alice@example.com", "editor")+`"}`); w.Code != http.StatusOK {
+		t.Fatalf("accepting the invitation answered %d: %s", w.Code, w.Body)
+	}
+	member := fmt.Sprintf("/v1/org/members/%d", alice.ID)
+	key := func(in service.APIKeyInput) string {
+		t.Helper()
+		in.Name = "limited"
+		_, plain, err := ts.accounts.CreateAPIKey(context.Background(), alice.ID, in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return plain
+	}
+	limited := map[string]string{
+		"a links:read key":        key(service.APIKeyInput{Scopes: []string{string(domain.ScopeLinksRead)}}),
+		"a links:write key":       key(service.APIKeyInput{Scopes: []string{string(domain.ScopeLinksRead), string(domain.ScopeLinksWrite)}}),
+		"a key limited to a CIDR": key(service.APIKeyInput{Scopes: []string{string(domain.ScopeAdmin)}, AllowedCIDRs: []string{"192.0.2.0/24"}}),
+	}
+	for name, k := range limited {
+		if w := ts.doIn(org, http.MethodDelete, member, k, ""); w.Code != http.StatusForbidden {
+			t.Errorf("leaving with %s answered %d, want 403", name, w.Code)
+		}
+	}
+
+	// An editor manages no members, whatever its key
+	owner := fmt.Sprintf("/v1/org/members/%d", ts.account.ID)
+	if w := ts.doIn(org, http.MethodDelete, owner, aliceKey, ""); w.Code != http.StatusForbidden {
+		t.Errorf("an editor removing the owner answered %d, want 403", w.Code)
+	}
+	if w := ts.doIn(org, http.MethodDelete, member, aliceKey, ""); w.Code != http.StatusNoContent {
+		t.Errorf("leaving with a full key answered %d: %s", w.Code, w.Body)
+	}
+	if w := ts.doIn(org, http.MethodGet, "/v1/org/members", aliceKey, ""); w.Code != http.StatusForbidden {
+		t.Errorf("acting in the organization after leaving answered %d, want 403", w.Code)
+	}
+}
+
+func TestBulkJobsBelongToTheOrganization(t *testing.T) {
+	ts := newTestServer(t)
+	org := ts.account.OrganizationId
+	_, aliceKey := ts.newAccount(t, "alice@example.com")
+	if w := ts.do(http.MethodPost, "/v1/invitations/accept", aliceKey, `{"token":"`+invite(t, ts, "alice@example.com", "viewer")+`"}`); w.Code != http.StatusOK {
+		t.Fatalf("accepting the invitation answered %d: %s", w.Code, w.Body)
+	}
+
+	rows := make([]string, ts.config.Links.BulkSyncLimit+1)
+	for i := range rows {
+		rows[i] = fmt.Sprintf(`{"url":"https://example.com/%d"}`, i)
+	}
+	w := ts.do(http.MethodPost, "/v1/urls/bulk", ts.key, `[`+strings.Join(rows, ",")+`]`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("submitting a bulk job answered %d: %s", w.Code, w.Body)
+	}
+	var job struct {
+		ID uint `json:"id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &job); err != nil {
+		t.Fatal(err)
+	}
+
+	path := fmt.Sprintf("/v1/urls/bulk/%d", job.ID)
+	if w := ts.doIn(org, http.MethodGet, path, aliceKey, ""); w.Code != http.StatusOK {
+		t.Errorf("another member reading the job answered %d: %s", w.Code, w.Body)
+	}
+	if w := ts.do(http.MethodGet, path, aliceKey, ""); w.Code != http.StatusNotFound {
+		t.Errorf("reading the job from another organization answered %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"coding2fun.in/url-shortner/internal/archive"
+	"coding2fun.in/url-shortner/internal/domain"
+	"coding2fun.in/url-shortner/internal/service"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+)
+
+const maxImportBodyBytes = 32 << 20 // 32 MB
+
+// exportHandler downloads the caller's account data as an archive, JSON lines unless ?format=csv
+func (s *Server) exportHandler(ctx *gin.Context) {
+	format, err := archive.ParseFormat(ctx.DefaultQuery("format", string(archive.FormatJSONL)))
+	if err != nil || format == "" || format == archive.FormatBitly {
+		abortWithError(ctx, domain.NewValidationError("format", "must be jsonl or csv"))
+		return
+	}
+
+	p := principal(ctx)
+	name := fmt.Sprintf("export-%d-%s%s", p.AccountId, time.Now().UTC().Format("20060102T150405Z"), archive.Extension(format))
+	ctx.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	ctx.Header("Content-Type", archive.ContentType(format))
+	ctx.Status(http.StatusOK)
+
+	// Password hashes are only exported through the admin command
+	if _, err := s.portability.Export(ctx, p, ctx.Writer, service.ExportOptions{Format: format}); err != nil {
+		if ctx.Writer.Written() {
+			// The archive is streamed, the truncated body is all the client gets
+			_ = ctx.Error(err)
+			return
+		}
+		ctx.Writer.Header().Del("Content-Disposition")
+		abortWithError(ctx, err)
+	}
+}
+
+// importHandler imports an archive sent as the body or as the multipart file
+// "file". The format is detected unless ?format= names it, ?conflict= picks
+// the conflict strategy and ?domain= moves the links onto a custom domain.
+func (s *Server) importHandler(ctx *gin.Context) {
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxImportBodyBytes)
+
+	format, err := archive.ParseFormat(ctx.Query("format"))
+	if err != nil {
+		abortWithError(ctx, domain.NewValidationError("format", "must be jsonl, csv or bitly"))
+		return
+	}
+	conflict, err := service.ParseConflictStrategy(ctx.Query("conflict"))
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	data, err := readImportBody(ctx)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	report, err := s.portability.Import(ctx, principal(ctx), data, service.ImportOptions{
+		Format:   format,
+		Conflict: conflict,
+		Domain:   ctx.Query("domain"),
+		MaxLinks: s.config.Links.BulkMaxRows,
+	})
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	status := http.StatusOK
+	if report.Aborted {
+		status = http.StatusConflict
+	}
+	ctx.JSON(status, report)
+}
+
+func readImportBody(ctx *gin.Context) ([]byte, error) {
+	mediaType, _, _ := mime.ParseMediaType(ctx.GetHeader("Content-Type"))
+	body := io.Reader(ctx.Request.Body)
+	if mediaType == "multipart/form-data" {
+		file, _, err := ctx.Request.FormFile("file")
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", domain.NewValidationError("file", "is required"), err)
+		}
+		defer file.Close()
+		body = file
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", domain.NewValidationError("body", "could not be read"), err)
+	}
+	if len(data) == 0 {
+		return nil, domain.NewValidationError("body", "must hold an archive")
+	}
+	return data, nil
+}
@@ -0,0 +1,198 @@
+package server
+
+import (
+	"bytes"
+	"coding2fun.in/url-shortner/internal/archive"
+	"coding2fun.in/url-shortner/internal/service"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// importArchive posts an archive to the import endpoint
+func importArchive(t *testing.T, ts *testServer, key, query string, data []byte) (int, service.ImportReport) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/v1/import"+query, bytes.NewReader(data))
+	w := ts.serve(req, map[string]string{"X-API-Key": key, "Content-Type": "application/octet-stream"})
+	var report service.ImportReport
+	if w.Code == http.StatusOK || w.Code == http.StatusConflict {
+		if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return w.Code, report
+}
+
+// statuses lists the outcome of every row of a report as "source:status:code"
+func statuses(report service.ImportReport) []string {
+	var out []string
+	for _, r := range report.Results {
+		out = append(out, r.SourceCode+":"+r.Status+":"+r.ShortCode)
+	}
+	return out
+}
+
+// shortCodes lists the short codes of the key's organization in order
+func shortCodes(t *testing.T, ts *testServer, key string) []string {
+	t.Helper()
+	w := ts.do(http.MethodGet, "/v1/urls?limit=100", key, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("listing answered %d: %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Links []struct {
+			ShortCode string `json:"shortCode"`
+		} `json:"links"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	for _, l := range resp.Links {
+		out = append(out, l.ShortCode)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	ts := newTestServer(t)
+	ts.createLink(t, `{"url":"https://example.com/promo","slug":"promo","tags":["spring"],"title":"Promo"}`)
+	ts.createLink(t, `{"url":"https://example.com/locked","slug":"locked","password":"hunter22"}`)
+	if w := ts.do(http.MethodPatch, "/v1/urls/promo", ts.key, `{"url":"https://example.com/promo2"}`); w.Code != http.StatusOK {
+		t.Fatalf("updating answered %d: %s", w.Code, w.Body)
+	}
+
+	read := ts.newKey(t, service.APIKeyInput{Scopes: []string{"links:read"}})
+	if w := ts.do(http.MethodGet, "/v1/export", read, ""); w.Code != http.StatusForbidden || w.Header().Get("Content-Disposition") != "" {
+		t.Errorf("exporting with a narrow key answered %d with %q", w.Code, w.Header().Get("Content-Disposition"))
+	}
+
+	for _, format := range []string{"jsonl", "csv"} {
+		t.Run(format, func(t *testing.T) {
+			w := ts.do(http.MethodGet, "/v1/export?format="+format, ts.key, "")
+			if w.Code != http.StatusOK || !strings.Contains(w.Header().Get("Content-Disposition"), "attachment") {
+				t.Fatalf("exporting answered %d: %s", w.Code, w.Body)
+			}
+			a, err := archive.Read(w.Body.Bytes(), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(a.Links) != 2 || len(a.Versions) != 1 || a.Account == nil || len(a.APIKeys) != 2 {
+				t.Fatalf("the archive holds %d links, %d versions and %d keys", len(a.Links), len(a.Versions), len(a.APIKeys))
+			}
+			for _, l := range a.Links {
+				if l.PasswordHash != "" || l.Protected != (l.ShortCode == "locked") {
+					t.Errorf("link %s exported protected %t with hash %q", l.ShortCode, l.Protected, l.PasswordHash)
+				}
+			}
+
+			// Another instance takes the links, the protected one needs its hash
+			target := newTestServer(t)
+			code, report := importArchive(t, target, target.key, "", w.Body.Bytes())
+			if code != http.StatusOK || report.Imported != 1 || report.Failed != 1 {
+				t.Fatalf("importing answered %d: %v", code, statuses(report))
+			}
+			if got := strings.Join(shortCodes(t, target, target.key), ","); got != "promo" {
+				t.Errorf("imported codes %s", got)
+			}
+			history := target.do(http.MethodGet, "/v1/urls/promo/history", target.key, "")
+			if history.Code != http.StatusOK || !strings.Contains(history.Body.String(), "https://example.com/promo\"") {
+				t.Errorf("the imported history answered %d: %s", history.Code, history.Body)
+			}
+		})
+	}
+}
+
+func TestExportPasswordHashesOnRequest(t *testing.T) {
+	ts := newTestServer(t)
+	ts.createLink(t, `{"url":"https://example.com/locked","slug":"locked","password":"hunter22"}`)
+
+	ctx := context.Background()
+	p, err := ts.auth.Operator(ctx, ts.account.ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	summary, err := ts.portability.Export(ctx, p, &buf, service.ExportOptions{Format: archive.FormatJSONL, PasswordHashes: true})
+	if err != nil || summary.Links != 1 {
+		t.Fatalf("exported %+v: %v", summary, err)
+	}
+
+	target := newTestServer(t)
+	if code, report := importArchive(t, target, target.key, "", buf.Bytes()); code != http.StatusOK || report.Imported != 1 {
+		t.Fatalf("importing answered %d: %v", code, statuses(report))
+	}
+	if w := postPassword(target, "locked", "hunter22", "192.0.2.1:1000"); w.Code != http.StatusSeeOther {
+		t.Errorf("the imported password answered %d", w.Code)
+	}
+}
+
+func TestImportConflictStrategies(t *testing.T) {
+	ts := newTestServer(t)
+	ts.createLink(t, `{"url":"https://example.com/promo","slug":"promo"}`)
+
+	// Two links of the archive share a code, one of them is taken already
+	data := []byte(`{"type":"header","data":{"version":1}}
+{"type":"link","data":{"id":1,"shortCode":"promo","customSlug":true,"originalUrl":"https://example.com/1","isActive":true}}
+{"type":"link","data":{"id":2,"shortCode":"fresh","customSlug":true,"originalUrl":"https://example.com/2","isActive":true}}
+{"type":"link","data":{"id":3,"shortCode":"fresh","customSlug":true,"originalUrl":"https://example.com/3","isActive":true}}
+{"type":"link","data":{"id":4,"shortCode":"Ab3dEf9","originalUrl":"https://example.com/4","isActive":true}}
+`)
+
+	t.Run("fail", func(t *testing.T) {
+		code, report := importArchive(t, ts, ts.key, "?conflict=fail", data)
+		want := "promo:conflict: fresh:skipped: fresh:conflict: Ab3dEf9:skipped:"
+		if code != http.StatusConflict || !report.Aborted || strings.Join(statuses(report), " ") != want {
+			t.Fatalf("answered %d: %v", code, statuses(report))
+		}
+		if got := strings.Join(shortCodes(t, ts, ts.key), ","); got != "promo" {
+			t.Errorf("an aborted import stored %s", got)
+		}
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		code, report := importArchive(t, ts, ts.key, "", data)
+		want := "promo:skipped: fresh:imported:fresh fresh:skipped: Ab3dEf9:imported:Ab3dEf9"
+		if code != http.StatusOK || report.Conflict != service.ConflictSkip || strings.Join(statuses(report), " ") != want {
+			t.Fatalf("answered %d: %v", code, statuses(report))
+		}
+	})
+
+	t.Run("rename", func(t *testing.T) {
+		code, report := importArchive(t, ts, ts.key, "?conflict=rename", data)
+		if code != http.StatusOK || report.Imported != 4 || report.Renamed != 4 {
+			t.Fatalf("answered %d: %v", code, statuses(report))
+		}
+		got := statuses(report)
+		want := []string{"promo:renamed:promo-2", "fresh:renamed:fresh-2", "fresh:renamed:fresh-3"}
+		if strings.Join(got[:3], " ") != strings.Join(want, " ") {
+			t.Errorf("renamed %v, want %v", got[:3], want)
+		}
+		if generated := report.Results[3].ShortCode; generated == "Ab3dEf9" || generated == "" {
+			t.Errorf("the generated code was renamed to %q", generated)
+		}
+	})
+}
+
+func TestImportBitly(t *testing.T) {
+	ts := newTestServer(t)
+	export := "long_url,link,custom_bitlinks,title,clicks\n" +
+		"https://example.com/spring,https://bit.ly/3xYz,bit.ly/spring-sale,Spring,12\n" +
+		"https://example.com/plain,https://bit.ly/4AbCdE,,,\n"
+
+	code, report := importArchive(t, ts, ts.key, "?format=bitly", []byte(export))
+	if code != http.StatusOK || report.Format != archive.FormatBitly || report.Imported != 2 {
+		t.Fatalf("answered %d: %+v", code, report)
+	}
+	if got := strings.Join(shortCodes(t, ts, ts.key), ","); got != "4AbCdE,spring-sale" {
+		t.Errorf("imported codes %s", got)
+	}
+	if w := ts.do(http.MethodGet, "/v1/urls/spring-sale/stats", ts.key, ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "12") {
+		t.Errorf("the imported clicks answered %d: %s", w.Code, w.Body)
+	}
+}
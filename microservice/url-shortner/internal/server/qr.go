@@ -0,0 +1,157 @@
+package server
+
+import (
+	"coding2fun.in/url-shortner/internal/domain"
+	"coding2fun.in/url-shortner/internal/qr"
+	"encoding/hex"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/skip2/go-qrcode"
+	"image/color"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultQRSize   = 256
+	minQRSize       = 64
+	defaultQRMargin = 4
+	maxQRMargin     = 16
+)
+
+// qrLevels maps the standard error correction letters to the encoder levels
+var qrLevels = map[string]qrcode.RecoveryLevel{
+	"L": qrcode.Low,
+	"M": qrcode.Medium,
+	"Q": qrcode.High,
+	"H": qrcode.Highest,
+}
+
+// qrHandler renders the short url of a link as a QR code. The image only depends
+// on the short url and the options, so clients revalidate it with the ETag.
+func (s *Server) qrHandler(ctx *gin.Context) {
+	opts, err := s.qrOptions(ctx)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	link, err := s.links.Get(ctx, principal(ctx), ctx.Query("domain"), ctx.Param("code"))
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	content, err := s.links.ShortURL(ctx, link)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	etag := s.qr.ETag(content, opts)
+	ctx.Header("ETag", etag)
+	ctx.Header("Cache-Control", "private, max-age=86400")
+	if etagMatches(ctx.GetHeader("If-None-Match"), etag) {
+		ctx.Status(http.StatusNotModified)
+		return
+	}
+
+	image, err := s.qr.Render(content, opts)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.Data(http.StatusOK, opts.Format.ContentType(), image)
+}
+
+func (s *Server) qrOptions(ctx *gin.Context) (qr.Options, error) {
+	verr := &domain.ValidationError{}
+	opts := qr.Options{
+		Format:     qr.PNG,
+		Size:       defaultQRSize,
+		Level:      qrcode.Medium,
+		Margin:     defaultQRMargin,
+		Foreground: color.RGBA{A: 0xff},
+		Background: color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+	}
+
+	switch format := strings.ToLower(ctx.Query("format")); format {
+	case "", "png":
+	case "svg":
+		opts.Format = qr.SVG
+	default:
+		verr.Add("format", "must be png or svg")
+	}
+
+	if value := ctx.Query("size"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size < minQRSize || size > s.config.QR.MaxSize {
+			verr.Add("size", fmt.Sprintf("must be between %d and %d pixels", minQRSize, s.config.QR.MaxSize))
+		}
+		opts.Size = size
+	}
+
+	if value := ctx.Query("ecc"); value != "" {
+		level, ok := qrLevels[strings.ToUpper(value)]
+		if !ok {
+			verr.Add("ecc", "must be one of L, M, Q or H")
+		}
+		opts.Level = level
+	}
+
+	if value := ctx.Query("margin"); value != "" {
+		margin, err := strconv.Atoi(value)
+		if err != nil || margin < 0 || margin > maxQRMargin {
+			verr.Add("margin", fmt.Sprintf("must be between 0 and %d modules", maxQRMargin))
+		}
+		opts.Margin = margin
+	}
+
+	colors := []struct {
+		field  string
+		target *color.RGBA
+	}{{"fg", &opts.Foreground}, {"bg", &opts.Background}}
+	for _, c := range colors {
+		if value := ctx.Query(c.field); value != "" {
+			parsed, ok := parseColor(value)
+			if !ok {
+				verr.Add(c.field, "must be a hex color like #1a2b3c")
+			}
+			*c.target = parsed
+		}
+	}
+	if opts.Foreground == opts.Background {
+		verr.Add("fg", "must differ from the background color")
+	}
+
+	if value := ctx.Query("logo"); value != "" {
+		logo, err := strconv.ParseBool(value)
+		if err != nil {
+			verr.Add("logo", "must be true or false")
+		} else if logo && !s.qr.HasLogo() {
+			verr.Add("logo", "no logo is configured")
+		}
+		opts.Logo = logo
+	}
+
+	return opts, verr.OrNil()
+}
+
+// parseColor accepts a six digit hex color with or without the leading '#'
+func parseColor(value string) (color.RGBA, bool) {
+	raw, err := hex.DecodeString(strings.TrimPrefix(value, "#"))
+	if err != nil || len(raw) != 3 {
+		return color.RGBA{}, false
+	}
+	return color.RGBA{R: raw[0], G: raw[1], B: raw[2], A: 0xff}, true
+}
+
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
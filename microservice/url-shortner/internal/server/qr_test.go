@@ -0,0 +1,129 @@
+package server
+
+import (
+	"bytes"
+	"coding2fun.in/url-shortner/internal/config"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestQRRendersAtTheRequestedSize(t *testing.T) {
+	ts := newTestServer(t)
+	ts.createLink(t, `{"url":"https://example.com/qr","slug":"code"}`)
+
+	w := ts.do(http.MethodGet, "/v1/urls/code/qr?size=300&fg=1a2b3c&bg=%23ffffff&margin=2&ecc=h", ts.key, "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("png answered %d %s: %s", w.Code, w.Header().Get("Content-Type"), w.Body)
+	}
+	img, err := png.Decode(w.Body)
+	if err != nil {
+		t.Fatalf("png answer does not decode: %v", err)
+	}
+	if bounds := img.Bounds(); bounds.Dx() != 300 || bounds.Dy() != 300 {
+		t.Errorf("png is %dx%d, want 300x300", bounds.Dx(), bounds.Dy())
+	}
+
+	w = ts.do(http.MethodGet, "/v1/urls/code/qr?format=SVG&size=120", ts.key, "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/svg+xml" {
+		t.Fatalf("svg answered %d %s: %s", w.Code, w.Header().Get("Content-Type"), w.Body)
+	}
+	if !strings.HasPrefix(w.Body.String(), "<svg") || !strings.Contains(w.Body.String(), `width="120" height="120"`) {
+		t.Errorf("svg answer is not 120 pixels: %s", w.Body)
+	}
+}
+
+func TestQRRejectsInvalidOptions(t *testing.T) {
+	ts := newTestServer(t)
+	ts.createLink(t, `{"url":"https://example.com/qr","slug":"code"}`)
+
+	tests := []struct {
+		query string
+		field string
+	}{
+		{"format=gif", "format"},
+		{"size=63", "size"},
+		{"size=2049", "size"},
+		{"size=big", "size"},
+		{"margin=-1", "margin"},
+		{"margin=17", "margin"},
+		{"ecc=X", "ecc"},
+		{"fg=red", "fg"},
+		{"bg=%23fff", "bg"},
+		{"fg=ffffff", "fg"},
+		{"fg=123456&bg=123456", "fg"},
+		{"logo=maybe", "logo"},
+		{"logo=true", "logo"},
+	}
+	for _, tt := range tests {
+		w := ts.do(http.MethodGet, "/v1/urls/code/qr?"+tt.query, ts.key, "")
+		if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), `"field":"`+tt.field+`"`) {
+			t.Errorf("%s answered %d: %s, want a validation error on %s", tt.query, w.Code, w.Body, tt.field)
+		}
+	}
+}
+
+func TestQRRevalidatesWithTheETag(t *testing.T) {
+	ts := newTestServer(t)
+	ts.createLink(t, `{"url":"https://example.com/qr","slug":"code"}`)
+
+	first := ts.do(http.MethodGet, "/v1/urls/code/qr", ts.key, "")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("first request answered %d with ETag %q", first.Code, etag)
+	}
+	if again := ts.do(http.MethodGet, "/v1/urls/code/qr", ts.key, ""); again.Header().Get("ETag") != etag || !bytes.Equal(again.Body.Bytes(), first.Body.Bytes()) {
+		t.Errorf("second request answered ETag %q, want the image of %q", again.Header().Get("ETag"), etag)
+	}
+	if other := ts.do(http.MethodGet, "/v1/urls/code/qr?size=512", ts.key, ""); other.Header().Get("ETag") == etag {
+		t.Error("another size answered the same ETag")
+	}
+
+	for _, match := range []string{etag, "W/" + etag, `"stale", ` + etag, "*"} {
+		req := httptest.NewRequest(http.MethodGet, "/v1/urls/code/qr", nil)
+		w := ts.serve(req, map[string]string{"X-API-Key": ts.key, "If-None-Match": match})
+		if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+			t.Errorf("If-None-Match %s answered %d with %d bytes, want 304 without a body", match, w.Code, w.Body.Len())
+		}
+	}
+	req := httptest.NewRequest(http.MethodGet, "/v1/urls/code/qr", nil)
+	if w := ts.serve(req, map[string]string{"X-API-Key": ts.key, "If-None-Match": `"stale"`}); w.Code != http.StatusOK {
+		t.Errorf("a stale If-None-Match answered %d, want 200", w.Code)
+	}
+}
+
+func TestQRLogoRaisesTheErrorCorrection(t *testing.T) {
+	logo := filepath.Join(t.TempDir(), "logo.png")
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 8, 8))); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(logo, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ts := newTestServer(t, func(cfg *config.Config) { cfg.QR.LogoPath = logo })
+	ts.createLink(t, `{"url":"https://example.com/qr","slug":"code"}`)
+
+	etag := func(query string) string {
+		t.Helper()
+		w := ts.do(http.MethodGet, "/v1/urls/code/qr?"+query, ts.key, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s answered %d: %s", query, w.Code, w.Body)
+		}
+		return w.Header().Get("ETag")
+	}
+	if etag("ecc=L&logo=true") != etag("ecc=Q&logo=true") {
+		t.Error("a logo did not raise L to Q")
+	}
+	if etag("ecc=L") == etag("ecc=Q") {
+		t.Error("L and Q without a logo answered the same image")
+	}
+	if etag("ecc=H&logo=true") == etag("ecc=Q&logo=true") {
+		t.Error("a logo lowered H")
+	}
+}
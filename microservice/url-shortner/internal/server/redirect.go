@@ -0,0 +1,231 @@
+package server
+
+import (
+	"coding2fun.in/url-shortner/internal/audit"
+	"coding2fun.in/url-shortner/internal/domain"
+	"coding2fun.in/url-shortner/internal/log"
+	"coding2fun.in/url-shortner/internal/targeting"
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// The variant cookie remembers the A/B variant of a visitor per link
+const (
+	variantCookiePrefix = "ab_"
+	variantCookieAge    = 90 * 24 * time.Hour
+)
+
+// redirectHandler resolves a short code and redirects to its destination. The
+// lookup is served from the link cache or a read replica and the click is
+// counted asynchronously. A password protected link answers with its unlock form.
+func (s *Server) redirectHandler(ctx *gin.Context) {
+	url, ok := s.redirectable(ctx)
+	if !ok {
+		return
+	}
+	if url.Protected() {
+		renderUnlock(ctx, http.StatusOK, url.ShortCode, "")
+		return
+	}
+	s.follow(ctx, url, redirectStatus(url))
+}
+
+// unlockHandler checks the password posted by the unlock form and redirects on
+// a match, clients and links with too many wrong passwords get 429
+func (s *Server) unlockHandler(ctx *gin.Context) {
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxUnlockBodyBytes)
+
+	url, ok := s.redirectable(ctx)
+	if !ok {
+		return
+	}
+	if url.Protected() {
+		unlocked, wait := s.unlock(url, ctx.PostForm("password"), ctx.ClientIP())
+		if wait > 0 {
+			ctx.Header("Retry-After", retryAfter(wait))
+			renderUnlock(ctx, http.StatusTooManyRequests, url.ShortCode, "Too many incorrect passwords, try again later.")
+			return
+		}
+		if !unlocked {
+			renderUnlock(ctx, http.StatusUnauthorized, url.ShortCode, "The password is incorrect.")
+			return
+		}
+	}
+	s.follow(ctx, url, http.StatusSeeOther)
+}
+
+// redirectable resolves the code of the request on the domain named by its
+// Host header and aborts unless the link may be followed
+func (s *Server) redirectable(ctx *gin.Context) (*domain.ShortUrl, bool) {
+	code := ctx.Param("code")
+
+	site, err := s.site(ctx)
+	if err != nil {
+		abortWithError(ctx, err)
+		return nil, false
+	}
+	var domainId uint
+	if site != nil {
+		domainId = site.ID
+	}
+
+	actor := audit.Actor{Kind: domain.ActorSystem, IP: ctx.ClientIP(), RequestID: requestID(ctx)}
+	url, err := s.lookup(ctx, domainId, code, actor)
+	if err != nil {
+		s.notFound(ctx, site, err)
+		return nil, false
+	}
+	return url, true
+}
+
+// lookup resolves a code on a domain and fails with ErrNotFound unless the link
+// may be followed. A link whose destination was listed after it was saved is
+// disabled on the way, actor is who the audit log records for that.
+func (s *Server) lookup(ctx context.Context, domainId uint, code string, actor audit.Actor) (*domain.ShortUrl, error) {
+	url, err := s.resolve(ctx, domainId, code)
+	if err != nil {
+		return nil, err
+	}
+	if !url.Redirectable(time.Now()) {
+		return nil, fmt.Errorf("short url %q is inactive or expired: %w", code, domain.ErrNotFound)
+	}
+
+	if reason, listed := s.threats.Check(url.OriginalURL); listed {
+		s.disableListed(audit.WithActor(ctx, actor), url, reason)
+		return nil, fmt.Errorf("short url %q is disabled: %w", code, domain.ErrNotFound)
+	}
+	return url, nil
+}
+
+// follow counts the click and redirects to the target of the visitor with the
+// link's UTM parameters and passed through query
+func (s *Server) follow(ctx *gin.Context, url *domain.ShortUrl, status int) {
+	if err := s.consumeClick(ctx, url); err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	visitor := targeting.NewVisitor(ctx.Request, ctx.ClientIP(), s.geo)
+	target, variantId := s.target(url, visitor, func() *domain.LinkVariant { return s.variant(ctx, url) })
+	s.clicks.Record(url.ID, variantId)
+	ctx.Redirect(status, url.Destination(target, ctx.Request.URL.Query()))
+}
+
+// consumeClick takes a click of a click limited link on the primary, the cached
+// copy cannot tell whether one is left. An exhausted link is not found.
+func (s *Server) consumeClick(ctx context.Context, url *domain.ShortUrl) error {
+	if url.MaxClicks <= 0 {
+		return nil
+	}
+	granted, err := s.urls.ConsumeClick(ctx, url.ID)
+	if err != nil {
+		return err
+	}
+	s.cache.Invalidate(url.DomainId, url.ShortCode)
+	if !granted {
+		return fmt.Errorf("short url %q reached its click limit: %w", url.ShortCode, domain.ErrNotFound)
+	}
+	return nil
+}
+
+// target picks the destination of the visitor, the first redirect rule it
+// matches, else its variant of the A/B split, else the original url. It returns
+// the id of the chosen variant, 0 for none. A destination listed after it was
+// saved is skipped in favor of the original url, which lookup already checked.
+func (s *Server) target(url *domain.ShortUrl, visitor domain.Visitor, variant func() *domain.LinkVariant) (string, uint) {
+	var rule *domain.RedirectRule
+	if len(url.Rules) > 0 {
+		rule = url.MatchingRule(visitor, time.Now())
+	}
+
+	target, variantId := url.OriginalURL, uint(0)
+	if rule != nil {
+		target = rule.Destination
+	} else if variant := variant(); variant != nil {
+		target, variantId = variant.Destination, variant.ID
+	}
+	if target == url.OriginalURL {
+		return target, variantId
+	}
+	if reason, listed := s.threats.Check(target); listed {
+		log.Warn("Skipping redirect target with listed destination", zap.String("code", url.ShortCode), zap.String("list", reason))
+		return url.OriginalURL, 0
+	}
+	return target, variantId
+}
+
+// variant returns the A/B variant of the visitor, nil when the link has no
+// split. The cookie of an earlier visit keeps the visitor on its variant, a
+// first visit is assigned by a hash of the client address and user agent so
+// clients dropping cookies mostly stay on theirs too.
+func (s *Server) variant(ctx *gin.Context, url *domain.ShortUrl) *domain.LinkVariant {
+	if len(url.Variants) == 0 {
+		return nil
+	}
+
+	name := variantCookiePrefix + url.ShortCode
+	if value, err := ctx.Cookie(name); err == nil {
+		if id, err := strconv.ParseUint(value, 10, 64); err == nil {
+			if variant := url.Variant(uint(id)); variant != nil {
+				return variant
+			}
+		}
+	}
+
+	variant := url.PickVariant(targeting.StickyHash(url.ShortCode, ctx.ClientIP(), ctx.Request.UserAgent()))
+	if variant != nil {
+		ctx.SetSameSite(http.SameSiteLaxMode)
+		ctx.SetCookie(name, strconv.FormatUint(uint64(variant.ID), 10), int(variantCookieAge/time.Second),
+			"/"+url.ShortCode, "", ctx.Request.TLS != nil, true)
+	}
+	return variant
+}
+
+// redirectStatus is the redirect status of a link, links stored before redirect
+// types were configurable use 302
+func redirectStatus(url *domain.ShortUrl) int {
+	if url.RedirectType == 0 {
+		return http.StatusFound
+	}
+	return url.RedirectType
+}
+
+func (s *Server) resolve(ctx context.Context, domainId uint, code string) (*domain.ShortUrl, error) {
+	if url, ok := s.cache.Get(domainId, code); ok {
+		return url, nil
+	}
+
+	url, err := s.urls.GetSourceURL(ctx, domainId, code)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.Set(url)
+	return url, nil
+}
+
+// disableListed deactivates a link whose destination appeared on a threat list
+// after it was created, the audit actor comes with ctx
+func (s *Server) disableListed(ctx context.Context, url *domain.ShortUrl, list string) {
+	log.Warn("Disabling link with listed destination", zap.String("code", url.ShortCode), zap.String("list", list))
+
+	reason := "threat list match: " + list
+	if err := s.urls.DisableURL(ctx, url.ID, reason); err != nil {
+		log.Error("Failed to disable listed link", zap.String("code", url.ShortCode), zap.Error(err))
+		return
+	}
+	s.cache.Invalidate(url.DomainId, url.ShortCode)
+
+	s.audit.Record(ctx, audit.Entry{
+		AccountId:      url.AccountId,
+		OrganizationId: url.OrganizationId,
+		Action:         domain.AuditLinkDisable,
+		Resource:       "link:" + url.ShortCode,
+		Before:         map[string]any{"isActive": true},
+		After:          map[string]any{"isActive": false, "disabledReason": reason},
+	})
+}
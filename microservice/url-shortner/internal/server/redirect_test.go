@@ -0,0 +1,68 @@
+package server
+
+import (
+	"coding2fun.in/url-shortner/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func postPassword(ts *testServer, code, password, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/"+code, strings.NewReader(url.Values{"password": {password}}.Encode()))
+	req.RemoteAddr = remoteAddr
+	return ts.serve(req, map[string]string{"Content-Type": "application/x-www-form-urlencoded"})
+}
+
+func TestUnlockLimitsWrongPasswordsPerClient(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.Unlock.IPAttempts = 2
+	})
+	ts.createLink(t, `{"url":"https://example.com/secret","slug":"locked","password":"s3cret"}`)
+
+	for range 2 {
+		if w := postPassword(ts, "locked", "guess", "192.0.2.1:1234"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("a wrong password answered %d, want 401", w.Code)
+		}
+	}
+	w := postPassword(ts, "locked", "s3cret", "192.0.2.1:1234")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("the client out of attempts got %d with Retry-After %q, want 429 with a Retry-After", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	if w := postPassword(ts, "locked", "s3cret", "192.0.2.2:1234"); w.Code != http.StatusSeeOther {
+		t.Errorf("another client with the right password got %d, want 303", w.Code)
+	}
+}
+
+func TestUnlockLimitsWrongPasswordsPerLink(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.Unlock.LinkAttempts = 3
+	})
+	ts.createLink(t, `{"url":"https://example.com/secret","slug":"locked","password":"s3cret"}`)
+	ts.createLink(t, `{"url":"https://example.com/other","slug":"other","password":"s3cret"}`)
+
+	for _, ip := range []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"} {
+		postPassword(ts, "locked", "guess", ip+":1234")
+	}
+	if w := postPassword(ts, "locked", "s3cret", "192.0.2.4:1234"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("the link out of attempts answered %d, want 429", w.Code)
+	}
+	if w := postPassword(ts, "other", "s3cret", "192.0.2.4:1234"); w.Code != http.StatusSeeOther {
+		t.Errorf("another link answered %d, want 303", w.Code)
+	}
+}
+
+func TestUnlockCorrectPasswordsDoNotCount(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.Unlock.IPAttempts = 1
+	})
+	ts.createLink(t, `{"url":"https://example.com/secret","slug":"locked","password":"s3cret"}`)
+
+	for i := range 3 {
+		if w := postPassword(ts, "locked", "s3cret", "192.0.2.1:1234"); w.Code != http.StatusSeeOther {
+			t.Fatalf("unlock %d answered %d, want 303", i+1, w.Code)
+		}
+	}
+}
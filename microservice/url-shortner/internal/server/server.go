@@ -1,14 +1,26 @@
 package server
 
 import (
+	"coding2fun.in/url-shortner/database"
+	"coding2fun.in/url-shortner/internal/analytics"
+	"coding2fun.in/url-shortner/internal/audit"
+	"coding2fun.in/url-shortner/internal/cache"
 	"coding2fun.in/url-shortner/internal/config"
+	"coding2fun.in/url-shortner/internal/domain"
+	"coding2fun.in/url-shortner/internal/geoip"
+	"coding2fun.in/url-shortner/internal/jobs"
 	"coding2fun.in/url-shortner/internal/log"
+	"coding2fun.in/url-shortner/internal/openapi"
+	"coding2fun.in/url-shortner/internal/qr"
+	"coding2fun.in/url-shortner/internal/service"
+	"coding2fun.in/url-shortner/internal/threat"
 	"context"
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
-	"gorm.io/gorm"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
 	"net/http"
 	"os"
 	"os/signal"
@@ -17,21 +29,100 @@ import (
 )
 
 type Server struct {
-	router *gin.Engine
-	config *config.Config
-	db     *gorm.DB
-	server *http.Server
+	router      *gin.Engine
+	config      *config.Config
+	db          database.Service
+	urls        domain.ShortURLRepository
+	clicks      *analytics.ClickCounter
+	cache       *cache.LinkCache
+	jobs        *jobs.Scheduler
+	threats     *threat.Checker
+	geo         *geoip.Locator
+	qr          *qr.Renderer
+	audit       *audit.Logger
+	auth        *service.AuthService
+	accounts    *service.AccountService
+	links       *service.LinkService
+	domains     *service.DomainService
+	folders     *service.FolderService
+	webhooks    *service.WebhookService
+	orgs        *service.OrganizationService
+	bulk        *service.BulkService
+	portability *service.PortabilityService
+	contract    *openapi.Validator
+	idempotency *cache.IdempotencyStore
+	unlocks     *cache.AttemptLimiter
+	server      *http.Server
+	// grpc serves the gRPC API next to the HTTP server, nil when it is off
+	grpc   *grpc.Server
+	health *health.Server
 }
 
-func NewServer(config *config.Config, db *gorm.DB) *Server {
+// Dependencies groups the collaborators used by the HTTP handlers
+type Dependencies struct {
+	DB      database.Service
+	URLs    domain.ShortURLRepository
+	Clicks  *analytics.ClickCounter
+	Cache   *cache.LinkCache
+	Jobs    *jobs.Scheduler
+	Threats *threat.Checker
+	// GeoIP resolves visitor countries for redirect rules, nil leaves them unknown
+	GeoIP       *geoip.Locator
+	QR          *qr.Renderer
+	Audit       *audit.Logger
+	Auth        *service.AuthService
+	Accounts    *service.AccountService
+	Links       *service.LinkService
+	Domains     *service.DomainService
+	Folders     *service.FolderService
+	Webhooks    *service.WebhookService
+	Orgs        *service.OrganizationService
+	Bulk        *service.BulkService
+	Portability *service.PortabilityService
+	// Contract validates /v1 traffic against the OpenAPI spec, nil skips validation
+	Contract    *openapi.Validator
+	Idempotency *cache.IdempotencyStore
+	// Unlocks limits the wrong passwords of protected links
+	Unlocks *cache.AttemptLimiter
+}
+
+func NewServer(config *config.Config, deps Dependencies) *Server {
 	gin.SetMode(config.Server.Mode)
 
-	router := gin.Default()
+	router := gin.New()
+	// Handlers pass the gin context on, values like the audit actor live in the request context
+	router.ContextWithFallback = true
+	// ClientIP follows forwarding headers only from the configured proxies,
+	// without any it is the peer address and cannot be spoofed
+	if err := router.SetTrustedProxies(config.Server.TrustedProxies); err != nil {
+		log.Fatal("Invalid trusted proxies", zap.Error(err))
+	}
+	router.Use(gin.Logger(), requestIDMiddleware(), errorMapper(), gin.CustomRecovery(recoveryHandler))
 
 	server := &Server{
-		router: router,
-		config: config,
-		db:     db,
+		router:      router,
+		config:      config,
+		db:          deps.DB,
+		urls:        deps.URLs,
+		clicks:      deps.Clicks,
+		cache:       deps.Cache,
+		jobs:        deps.Jobs,
+		threats:     deps.Threats,
+		geo:         deps.GeoIP,
+		qr:          deps.QR,
+		audit:       deps.Audit,
+		auth:        deps.Auth,
+		accounts:    deps.Accounts,
+		links:       deps.Links,
+		domains:     deps.Domains,
+		folders:     deps.Folders,
+		webhooks:    deps.Webhooks,
+		orgs:        deps.Orgs,
+		bulk:        deps.Bulk,
+		portability: deps.Portability,
+		contract:    deps.Contract,
+		idempotency: deps.Idempotency,
+		unlocks:     deps.Unlocks,
 		server: &http.Server{
 			Addr:    config.Server.Port,
 			Handler: router,
@@ -45,33 +136,100 @@ func NewServer(config *config.Config, db *gorm.DB) *Server {
 
 	// Setup routes
 	server.setUp()
+	if config.Server.GRPCPort != "" {
+		server.setUpGRPC()
+	}
 
 	return server
 }
 
 func (s *Server) setUp() {
+	s.router.NoRoute(s.noRouteHandler)
 	s.router.GET("/health", s.defaultHandler)
+	s.router.GET("/openapi.yaml", s.specHandler)
+	s.router.GET("/openapi.json", s.specJSONHandler)
+	s.router.GET("/docs", s.docsHandler)
+	s.router.GET("/:code", s.redirectHandler)
+	s.router.POST("/:code", s.unlockHandler)
+
+	read := authorize(domain.PermLinksRead)
+	write := authorize(domain.PermLinksWrite)
+	manageDomains := authorize(domain.PermDomainsWrite)
+	manageMembers := authorize(domain.PermMembersWrite)
+	manageWebhooks := authorize(domain.PermWebhooksWrite)
+	manageAccount := authorizeAccount()
+
+	v1 := s.router.Group("/v1", s.authMiddleware())
+	if s.contract != nil {
+		v1.Use(s.contractMiddleware())
+	}
+	v1.Use(s.idempotencyMiddleware())
+	v1.GET("/urls", read, s.listURLsHandler)
+	v1.GET("/urls/search", read, s.searchURLsHandler)
+	v1.POST("/urls", write, s.createURLHandler)
+	v1.POST("/urls/bulk", write, s.bulkCreateHandler)
+	v1.GET("/urls/bulk/:id", read, s.bulkJobHandler)
+	v1.PATCH("/urls/:code", write, s.updateURLHandler)
+	v1.GET("/urls/:code/history", read, s.historyHandler)
+	v1.POST("/urls/:code/revert", write, s.revertHandler)
+	v1.GET("/urls/:code/qr", read, s.qrHandler)
+	v1.GET("/urls/:code/stats", authorize(domain.PermAnalyticsRead), s.statsHandler)
+	v1.GET("/tags", read, s.listTagsHandler)
+	v1.POST("/folders", write, s.createFolderHandler)
+	v1.GET("/folders", read, s.listFoldersHandler)
+	v1.PATCH("/folders/:id", write, s.renameFolderHandler)
+	v1.DELETE("/folders/:id", write, s.deleteFolderHandler)
+	v1.POST("/domains", manageDomains, s.createDomainHandler)
+	v1.GET("/domains", read, s.listDomainsHandler)
+	v1.GET("/domains/:id", read, s.domainHandler)
+	v1.PATCH("/domains/:id", manageDomains, s.updateDomainHandler)
+	v1.DELETE("/domains/:id", manageDomains, s.deleteDomainHandler)
+	v1.POST("/domains/:id/verify", manageDomains, s.verifyDomainHandler)
+	v1.GET("/audit", authorize(domain.PermAuditRead), s.auditHandler)
+	v1.POST("/webhooks", manageWebhooks, s.createWebhookHandler)
+	v1.GET("/webhooks", manageWebhooks, s.listWebhooksHandler)
+	v1.GET("/webhooks/:id", manageWebhooks, s.webhookHandler)
+	v1.PATCH("/webhooks/:id", manageWebhooks, s.updateWebhookHandler)
+	v1.DELETE("/webhooks/:id", manageWebhooks, s.deleteWebhookHandler)
+	v1.POST("/webhooks/:id/rotate", manageWebhooks, s.rotateWebhookSecretHandler)
+	v1.GET("/webhooks/:id/deliveries", manageWebhooks, s.listDeliveriesHandler)
+	v1.GET("/webhooks/:id/deliveries/:deliveryId", manageWebhooks, s.deliveryHandler)
+	v1.POST("/webhooks/:id/deliveries/:deliveryId/replay", manageWebhooks, s.replayDeliveryHandler)
+
+	// Keys and organizations of the account, the other routes act on the organization of the request
+	v1.POST("/keys", manageAccount, s.createAPIKeyHandler)
+	v1.GET("/keys", manageAccount, s.listAPIKeysHandler)
+	v1.POST("/keys/:id/rotate", manageAccount, s.rotateAPIKeyHandler)
+	v1.DELETE("/keys/:id", manageAccount, s.deactivateAPIKeyHandler)
+	v1.DELETE("/keys", manageAccount, s.revokeAPIKeysHandler)
+	v1.GET("/export", manageAccount, read, s.exportHandler)
+	v1.POST("/import", manageAccount, write, s.importHandler)
+	v1.POST("/orgs", manageAccount, s.createOrganizationHandler)
+	v1.GET("/orgs", s.listOrganizationsHandler)
+	v1.POST("/invitations/accept", manageAccount, s.acceptInvitationHandler)
+	v1.GET("/org/members", read, s.listMembersHandler)
+	v1.PATCH("/org/members/:accountId", manageMembers, s.updateMemberHandler)
+	// Members leave with a full account key, removing another one needs members:write
+	v1.DELETE("/org/members/:accountId", read, s.removeMemberHandler)
+	v1.POST("/org/invitations", manageMembers, s.inviteHandler)
+	v1.GET("/org/invitations", manageMembers, s.listInvitationsHandler)
+	v1.DELETE("/org/invitations/:id", manageMembers, s.revokeInvitationHandler)
 }
 
 func (s *Server) defaultHandler(ctx *gin.Context) {
-	sqlDB, err := s.db.DB()
+	sqlDB, err := s.db.GetConnection().DB()
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"status":  "error",
-			"message": "Failed to connect to database",
-		})
+		abortWithError(ctx, fmt.Errorf("failed to get database instance: %w", err))
 		return
 	}
-	if err := sqlDB.Ping(); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"status":  "error",
-			"message": "Database connection failed",
-		})
+	if err := sqlDB.PingContext(ctx); err != nil {
+		abortWithError(ctx, fmt.Errorf("database connection failed: %w", err))
 		return
 	}
 	ctx.JSON(http.StatusOK, gin.H{
-		"status":  "ok",
-		"message": "Successfully connected to database",
+		"status":   "ok",
+		"message":  "Successfully connected to database",
+		"replicas": s.db.ReplicaHealth(),
 	})
 }
 
@@ -99,11 +257,22 @@ func (s *Server) Shutdown(ctx context.Context) error {
 
 	log.Info("Server stopped accepting new requests")
 
+	if s.grpc != nil {
+		s.shutdownGRPC(ctx)
+	}
+
+	if err := s.bulk.Shutdown(ctx); err != nil {
+		log.Error("Error waiting for bulk jobs", zap.Error(err))
+	}
+
+	s.jobs.Stop()
+
+	// Flush buffered clicks before the database goes away
+	s.clicks.Stop()
+
 	// Close database connection if needed
-	if sqlDB, err := s.db.DB(); err == nil {
-		if err := sqlDB.Close(); err != nil {
-			log.Error("Error closing database connection", zap.Error(err))
-		}
+	if err := s.db.Close(); err != nil {
+		log.Error("Error closing database connection", zap.Error(err))
 	}
 
 	return nil
@@ -116,7 +285,7 @@ func (s *Server) StartWithGracefulShutdown() error {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
 	// Error channel to collect server errors
-	serverErrors := make(chan error, 1)
+	serverErrors := make(chan error, 2)
 
 	// Start server in background
 	go func() {
@@ -124,6 +293,13 @@ func (s *Server) StartWithGracefulShutdown() error {
 			serverErrors <- fmt.Errorf("server error: %w", err)
 		}
 	}()
+	if s.grpc != nil {
+		go func() {
+			if err := s.RunGRPC(); err != nil {
+				serverErrors <- fmt.Errorf("grpc server error: %w", err)
+			}
+		}()
+	}
 
 	// Wait for quit signal or server error
 	select {
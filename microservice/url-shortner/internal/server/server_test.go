@@ -0,0 +1,218 @@
+package server
+
+import (
+	"coding2fun.in/url-shortner/database"
+	"coding2fun.in/url-shortner/internal/analytics"
+	"coding2fun.in/url-shortner/internal/audit"
+	"coding2fun.in/url-shortner/internal/cache"
+	"coding2fun.in/url-shortner/internal/config"
+	"coding2fun.in/url-shortner/internal/domain"
+	"coding2fun.in/url-shortner/internal/jobs"
+	"coding2fun.in/url-shortner/internal/openapi"
+	"coding2fun.in/url-shortner/internal/qr"
+	"coding2fun.in/url-shortner/internal/repository"
+	"coding2fun.in/url-shortner/internal/safeurl"
+	"coding2fun.in/url-shortner/internal/service"
+	"coding2fun.in/url-shortner/internal/threat"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// publicResolver resolves every host to a public address
+type publicResolver struct{}
+
+func (publicResolver) LookupIPAddr(context.Context, string) ([]net.IPAddr, error) {
+	return []net.IPAddr{{IP: net.ParseIP("93.184.216.34")}}, nil
+}
+
+// testServer is a server on a fresh sqlite database with one active account
+type testServer struct {
+	*Server
+	db      database.Service
+	account *domain.Account
+	// key is an admin key of the account
+	key string
+}
+
+// newTestServer starts from the default config, configure adjusts it before the server is built
+func newTestServer(t *testing.T, configure ...func(*config.Config)) *testServer {
+	t.Helper()
+	dir := t.TempDir()
+	ini := filepath.Join(dir, "test.ini")
+	if err := os.WriteFile(ini, []byte("[server]\nmode = test\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(ini)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Database = config.DatabaseConfig{Driver: database.DriverSQLite, Path: filepath.Join(dir, "test.db"), MaxOpenConns: 1, MaxIdleConns: 1}
+	cfg.Links.BaseURL = "http://sho.rt"
+	for _, fn := range configure {
+		fn(cfg)
+	}
+
+	db, err := database.NewService(&cfg.Database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	validator, err := safeurl.NewValidator(cfg.Links, publicResolver{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	threats, err := threat.NewChecker(cfg.Threat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renderer, err := qr.NewRenderer(cfg.QR)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accounts := repository.NewAccountRepository(db)
+	urls := repository.NewShortURLRepository(db)
+	orgs := repository.NewOrganizationRepository(db)
+	auditLog := audit.NewLogger(repository.NewAuditRepository(db))
+	domains := service.NewDomainService(repository.NewDomainRepository(db), validator, nil, auditLog, cfg.Links, cfg.Cache)
+	folders := service.NewFolderService(repository.NewFolderRepository(db), auditLog)
+	links := service.NewLinkService(urls, domains, folders, validator, threats, auditLog, cfg.Links)
+	accountService := service.NewAccountService(accounts, auditLog, cfg.APIKeys)
+
+	var contract *openapi.Validator
+	if cfg.Server.ValidateAPI {
+		if contract, err = openapi.NewValidator(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	clicks := analytics.NewClickCounter(urls, time.Hour, cfg.Analytics.MaxPending)
+	s := NewServer(cfg, Dependencies{
+		DB:          db,
+		URLs:        urls,
+		Clicks:      clicks,
+		Cache:       cache.NewLinkCache(cfg.Cache.LinkTTL, cfg.Cache.LinkMaxEntries),
+		Jobs:        jobs.NewScheduler(),
+		Threats:     threats,
+		QR:          renderer,
+		Audit:       auditLog,
+		Orgs:        service.NewOrganizationService(orgs, auditLog),
+		Auth:        service.NewAuthService(accounts, orgs),
+		Accounts:    accountService,
+		Links:       links,
+		Domains:     domains,
+		Folders:     folders,
+		Bulk:        service.NewBulkService(links, repository.NewBulkJobRepository(db), cfg.Links),
+		Idempotency: cache.NewIdempotencyStore(cfg.Idempotency.TTL, cfg.Idempotency.MaxEntries),
+		Unlocks:     cache.NewAttemptLimiter(cfg.Unlock.Window, cfg.Unlock.MaxEntries),
+		Contract:    contract,
+		Portability: service.NewPortabilityService(accounts, repository.NewArchiveRepository(db), links, domains, auditLog, cfg.Links),
+	})
+
+	ctx := context.Background()
+	account, err := accountService.Create(ctx, "owner@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := accountService.Activate(ctx, account.ID); err != nil {
+		t.Fatal(err)
+	}
+	ts := &testServer{Server: s, db: db, account: account}
+	ts.key = ts.newKey(t, service.APIKeyInput{Scopes: []string{string(domain.ScopeAdmin)}})
+	return ts
+}
+
+// newKey issues another key of the test account
+func (ts *testServer) newKey(t *testing.T, in service.APIKeyInput) string {
+	t.Helper()
+	if in.Name == "" {
+		in.Name = "test"
+	}
+	_, plain, err := ts.accounts.CreateAPIKey(context.Background(), ts.account.ID, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return plain
+}
+
+// newAccount adds another active account with an admin key, the email is
+// stored as given
+func (ts *testServer) newAccount(t *testing.T, email string) (*domain.Account, string) {
+	t.Helper()
+	ctx := context.Background()
+	account := &domain.Account{Email: email}
+	if err := repository.NewAccountRepository(ts.db).Create(ctx, account); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.accounts.Activate(ctx, account.ID); err != nil {
+		t.Fatal(err)
+	}
+	_, plain, err := ts.accounts.CreateAPIKey(ctx, account.ID, service.APIKeyInput{Name: "test", Scopes: []string{string(domain.ScopeAdmin)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return account, plain
+}
+
+// serve runs a request through the router, header is applied before it is sent
+func (ts *testServer) serve(req *http.Request, header map[string]string) *httptest.ResponseRecorder {
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	ts.router.ServeHTTP(w, req)
+	return w
+}
+
+// do sends a JSON request with the given key, an empty key sends none
+func (ts *testServer) do(method, path, key, body string) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	header := map[string]string{}
+	if body != "" {
+		header["Content-Type"] = "application/json"
+	}
+	if key != "" {
+		header["X-API-Key"] = key
+	}
+	return ts.serve(req, header)
+}
+
+// doIn is do acting in another organization than the personal one of the key's account
+func (ts *testServer) doIn(orgId uint, method, path, key, body string) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("X-Organization-Id", strconv.FormatUint(uint64(orgId), 10))
+	header := map[string]string{"X-API-Key": key}
+	if body != "" {
+		header["Content-Type"] = "application/json"
+	}
+	return ts.serve(req, header)
+}
+
+// createLink creates a link with the admin key and fails the test unless it was created
+func (ts *testServer) createLink(t *testing.T, body string) {
+	t.Helper()
+	if w := ts.do(http.MethodPost, "/v1/urls", ts.key, body); w.Code != http.StatusCreated {
+		t.Fatalf("creating %s answered %d: %s", body, w.Code, w.Body)
+	}
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"coding2fun.in/url-shortner/internal/domain"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+type linkStatsResponse struct {
+	ShortCode     string                 `json:"shortCode"`
+	TotalClicks   int64                  `json:"totalClicks"`
+	LastClickedAt *time.Time             `json:"lastClickedAt,omitempty"`
+	Variants      []variantStatsResponse `json:"variants,omitempty"`
+}
+
+// variantStatsResponse compares the share of clicks a variant received with
+// the share its weight asks for
+type variantStatsResponse struct {
+	URL           string  `json:"url"`
+	Weight        int     `json:"weight"`
+	Clicks        int64   `json:"clicks"`
+	Share         float64 `json:"share"`
+	ExpectedShare float64 `json:"expectedShare"`
+}
+
+// statsHandler reports the clicks of a link and of each variant of its A/B
+// split. Clicks are flushed in batches, the latest ones may not be counted yet.
+func (s *Server) statsHandler(ctx *gin.Context) {
+	stats, err := s.links.Stats(ctx, principal(ctx), ctx.Query("domain"), ctx.Param("code"))
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, newLinkStatsResponse(ctx.Param("code"), stats))
+}
+
+func newLinkStatsResponse(code string, stats *domain.URLAnalytics) linkStatsResponse {
+	resp := linkStatsResponse{
+		ShortCode:     code,
+		TotalClicks:   stats.TotalClicks,
+		LastClickedAt: optionalTime(stats.LastClickedAt),
+	}
+	var clicks, weights int64
+	for _, v := range stats.Variants {
+		clicks += v.Clicks
+		weights += int64(v.Weight)
+	}
+	for _, v := range stats.Variants {
+		variant := variantStatsResponse{URL: v.Destination, Weight: v.Weight, Clicks: v.Clicks}
+		if clicks > 0 {
+			variant.Share = float64(v.Clicks) / float64(clicks)
+		}
+		if weights > 0 {
+			variant.ExpectedShare = float64(v.Weight) / float64(weights)
+		}
+		resp.Variants = append(resp.Variants, variant)
+	}
+	return resp
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"coding2fun.in/url-shortner/internal/cache"
+	"coding2fun.in/url-shortner/internal/domain"
+	"github.com/gin-gonic/gin"
+	"html/template"
+	"math"
+	"strconv"
+	"time"
+)
+
+const maxUnlockBodyBytes = 4 << 10 // 4 KB
+
+var unlockPage = template.Must(template.New("unlock").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Protected link</title>
+<style>
+body { font-family: system-ui, sans-serif; display: flex; justify-content: center; margin-top: 15vh; }
+form { display: flex; flex-direction: column; gap: .75rem; width: 18rem; }
+.error { color: #b00020; }
+</style>
+</head>
+<body>
+<form method="post" action="{{.Action}}">
+<h1>Protected link</h1>
+<label for="password">Enter the password to continue</label>
+<input id="password" name="password" type="password" autocomplete="off" autofocus required>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<button type="submit">Continue</button>
+</form>
+</body>
+</html>
+`))
+
+// renderUnlock answers with the password form of a protected link, the form
+// posts the query string of the request back for passthrough
+func renderUnlock(ctx *gin.Context, status int, code, message string) {
+	action := "/" + code
+	if query := ctx.Request.URL.RawQuery; query != "" {
+		action += "?" + query
+	}
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Status(status)
+	ctx.Header("Content-Type", "text/html; charset=utf-8")
+	_ = unlockPage.Execute(ctx.Writer, struct{ Action, Error string }{action, message})
+}
+
+// unlock checks the password of a protected link for the client at ip. Wrong
+// passwords count against the link and the client, once either has none left
+// the password is not checked and unlock returns how long to wait.
+func (s *Server) unlock(url *domain.ShortUrl, password, ip string) (bool, time.Duration) {
+	limits := []cache.Limit{
+		{Key: "link:" + strconv.FormatUint(uint64(url.ID), 10), Max: s.config.Unlock.LinkAttempts},
+		{Key: "ip:" + ip, Max: s.config.Unlock.IPAttempts},
+	}
+	if wait := s.unlocks.Take(limits...); wait > 0 {
+		return false, wait
+	}
+	if !s.links.Unlock(url, password) {
+		return false, 0
+	}
+	s.unlocks.Return(limits...)
+	return true, 0
+}
+
+// retryAfter renders a wait as the whole seconds of a Retry-After header
+func retryAfter(wait time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(wait.Seconds())))
+}
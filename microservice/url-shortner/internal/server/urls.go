@@ -0,0 +1,482 @@
+package server
+
+import (
+	"coding2fun.in/url-shortner/internal/domain"
+	"coding2fun.in/url-shortner/internal/service"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultLinkLimit   = 50
+	maxLinkLimit       = 200
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// linkSorts are the orders a link listing accepts
+var linkSorts = map[string]domain.LinkSort{
+	"created":     domain.SortCreated,
+	"clicks":      domain.SortClicks,
+	"lastClicked": domain.SortLastClicked,
+}
+
+// linkFields are the fields of linkResponse a listing can be reduced to
+var linkFields = map[string]bool{
+	"shortCode": true, "shortUrl": true, "originalUrl": true, "isActive": true, "expiresAt": true,
+	"clicks": true, "lastClickedAt": true, "tags": true, "passwordProtected": true, "maxClicks": true,
+	"redirectType": true, "version": true, "createdAt": true, "title": true, "folderId": true,
+	"utm": true, "queryPassthrough": true, "rules": true, "variants": true,
+}
+
+type linkResponse struct {
+	ShortCode        string                `json:"shortCode"`
+	ShortURL         string                `json:"shortUrl"`
+	OriginalURL      string                `json:"originalUrl"`
+	Title            string                `json:"title,omitempty"`
+	FolderId         uint                  `json:"folderId,omitempty"`
+	IsActive         bool                  `json:"isActive"`
+	ExpiresAt        *time.Time            `json:"expiresAt,omitempty"`
+	Clicks           int64                 `json:"clicks"`
+	LastClickedAt    *time.Time            `json:"lastClickedAt,omitempty"`
+	Tags             []string              `json:"tags"`
+	Protected        bool                  `json:"passwordProtected"`
+	MaxClicks        int64                 `json:"maxClicks,omitempty"`
+	RedirectType     int                   `json:"redirectType"`
+	Version          int                   `json:"version"`
+	CreatedAt        time.Time             `json:"createdAt"`
+	UTM              *domain.UTM           `json:"utm,omitempty"`
+	QueryPassthrough string                `json:"queryPassthrough,omitempty"`
+	Rules            []domain.RedirectRule `json:"rules,omitempty"`
+	Variants         []domain.LinkVariant  `json:"variants,omitempty"`
+}
+
+func (s *Server) newLinkResponse(ctx context.Context, link *domain.ShortUrl) (linkResponse, error) {
+	shortURL, err := s.links.ShortURL(ctx, link)
+	if err != nil {
+		return linkResponse{}, err
+	}
+
+	tags := make([]string, 0, len(link.Tags))
+	for _, tag := range link.Tags {
+		tags = append(tags, tag.Name)
+	}
+	return linkResponse{
+		ShortCode:        link.ShortCode,
+		ShortURL:         shortURL,
+		OriginalURL:      link.OriginalURL,
+		Title:            link.Title,
+		FolderId:         link.FolderId,
+		IsActive:         link.IsActive,
+		ExpiresAt:        optionalTime(link.ExpiresAt),
+		Clicks:           link.Clicks,
+		LastClickedAt:    optionalTime(link.LastClickedAt),
+		Tags:             tags,
+		Protected:        link.Protected(),
+		MaxClicks:        link.MaxClicks,
+		RedirectType:     redirectStatus(link),
+		Version:          link.Version,
+		CreatedAt:        link.CreatedAt,
+		UTM:              optionalUTM(link.UTM),
+		QueryPassthrough: string(link.QueryPassthrough),
+		Rules:            link.Rules,
+		Variants:         link.Variants,
+	}, nil
+}
+
+// respondWithLink renders a link, the short url of a custom domain needs its host
+func (s *Server) respondWithLink(ctx *gin.Context, status int, link *domain.ShortUrl) {
+	resp, err := s.newLinkResponse(ctx, link)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(status, resp)
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func optionalUTM(utm domain.UTM) *domain.UTM {
+	if utm.IsZero() {
+		return nil
+	}
+	return &utm
+}
+
+type linkListResponse struct {
+	// Links are linkResponse values, or maps of the selected fields
+	Links      []any  `json:"links"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// linkCursor is the decoded form of the opaque listing cursor, it remembers the
+// order it was issued for so it cannot continue a listing in another one
+type linkCursor struct {
+	Sort      domain.LinkSort `json:"s"`
+	Ascending bool            `json:"a,omitempty"`
+	ID        uint            `json:"i"`
+	Time      time.Time       `json:"t"`
+	Clicks    int64           `json:"c,omitempty"`
+}
+
+// listURLsHandler pages through the organization's links. The cursor continues
+// the listing in the same order and is only set while more links follow.
+func (s *Server) listURLsHandler(ctx *gin.Context) {
+	filter, fields, err := linkFilter(ctx)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	filter.WithTags = fields == nil || fields["tags"]
+	filter.WithRules = fields == nil || fields["rules"]
+	filter.WithVariants = fields == nil || fields["variants"]
+
+	links, err := s.links.List(ctx, principal(ctx), ctx.Query("domain"), lookahead(filter))
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	links, next := nextLinkPage(filter, links)
+
+	resp := linkListResponse{Links: make([]any, 0, len(links)), NextCursor: next}
+	for i := range links {
+		link, err := s.newLinkResponse(ctx, &links[i])
+		if err != nil {
+			abortWithError(ctx, err)
+			return
+		}
+		if fields == nil {
+			resp.Links = append(resp.Links, link)
+			continue
+		}
+		selected, err := selectFields(link, fields)
+		if err != nil {
+			abortWithError(ctx, err)
+			return
+		}
+		resp.Links = append(resp.Links, selected)
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+type linkSearchResponse struct {
+	Links []linkResponse `json:"links"`
+}
+
+// searchURLsHandler finds links by code, destination, title or tags, best matches first
+func (s *Server) searchURLsHandler(ctx *gin.Context) {
+	limit := defaultSearchLimit
+	if value := ctx.Query("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxSearchLimit {
+			abortWithError(ctx, domain.NewValidationError("limit", "must be between 1 and "+strconv.Itoa(maxSearchLimit)))
+			return
+		}
+	}
+
+	links, err := s.links.Search(ctx, principal(ctx), ctx.Query("q"), limit)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	resp := linkSearchResponse{Links: make([]linkResponse, 0, len(links))}
+	for i := range links {
+		link, err := s.newLinkResponse(ctx, &links[i])
+		if err != nil {
+			abortWithError(ctx, err)
+			return
+		}
+		resp.Links = append(resp.Links, link)
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+type tagResponse struct {
+	Name  string `json:"name"`
+	Links int64  `json:"links"`
+}
+
+// listTagsHandler lists the tags on the organization's links with their link counts
+func (s *Server) listTagsHandler(ctx *gin.Context) {
+	tags, err := s.links.Tags(ctx, principal(ctx))
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	resp := make([]tagResponse, 0, len(tags))
+	for _, tag := range tags {
+		resp = append(resp, tagResponse{Name: tag.Name, Links: tag.Links})
+	}
+	ctx.JSON(http.StatusOK, gin.H{"tags": resp})
+}
+
+// linkFilter parses the listing query, fields is nil unless a selection was asked for
+func linkFilter(ctx *gin.Context) (domain.LinkFilter, map[string]bool, error) {
+	verr := &domain.ValidationError{}
+	filter := domain.LinkFilter{Sort: domain.SortCreated, Limit: defaultLinkLimit}
+
+	flags := []struct {
+		field  string
+		target **bool
+	}{{"active", &filter.Active}, {"expired", &filter.Expired}}
+	for _, f := range flags {
+		if value := ctx.Query(f.field); value != "" {
+			flag, err := strconv.ParseBool(value)
+			if err != nil {
+				verr.Add(f.field, "must be true or false")
+			}
+			*f.target = &flag
+		}
+	}
+	filter.Tag = ctx.Query("tag")
+	if value := ctx.Query("folder"); value != "" {
+		folder, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			verr.Add("folder", "must be a folder id")
+		}
+		folderId := uint(folder)
+		filter.FolderId = &folderId
+	}
+	filter.DestinationHost = ctx.Query("host")
+
+	bounds := []struct {
+		field  string
+		target *time.Time
+	}{{"createdFrom", &filter.CreatedFrom}, {"createdTo", &filter.CreatedTo}}
+	for _, b := range bounds {
+		if value := ctx.Query(b.field); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				verr.Add(b.field, "must be an RFC 3339 timestamp")
+			}
+			*b.target = t
+		}
+	}
+	if !filter.CreatedFrom.IsZero() && !filter.CreatedTo.IsZero() && !filter.CreatedFrom.Before(filter.CreatedTo) {
+		verr.Add("createdTo", "must be after createdFrom")
+	}
+
+	if value := ctx.Query("sort"); value != "" {
+		sort, ok := linkSorts[value]
+		if !ok {
+			verr.Add("sort", "must be created, clicks or lastClicked")
+		}
+		filter.Sort = sort
+	}
+	switch ctx.Query("order") {
+	case "", "desc":
+	case "asc":
+		filter.Ascending = true
+	default:
+		verr.Add("order", "must be asc or desc")
+	}
+
+	if value := ctx.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxLinkLimit {
+			verr.Add("limit", "must be between 1 and "+strconv.Itoa(maxLinkLimit))
+		}
+		filter.Limit = limit
+	}
+
+	if value := ctx.Query("cursor"); value != "" {
+		cursor, err := decodeLinkCursor(value)
+		switch {
+		case err != nil:
+			verr.Add("cursor", "is invalid")
+		case cursor.Sort != filter.Sort || cursor.Ascending != filter.Ascending:
+			verr.Add("cursor", "was issued for another sort order")
+		default:
+			filter.After = &domain.LinkCursor{ID: cursor.ID, Time: cursor.Time, Clicks: cursor.Clicks}
+		}
+	}
+
+	var fields map[string]bool
+	if value := ctx.Query("fields"); value != "" {
+		fields = make(map[string]bool)
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if !linkFields[name] {
+				verr.Add("fields", fmt.Sprintf("%q is not a link field", name))
+				continue
+			}
+			fields[name] = true
+		}
+	}
+
+	return filter, fields, verr.OrNil()
+}
+
+// lookahead asks for one link past the page, it tells whether another page follows
+func lookahead(filter domain.LinkFilter) domain.LinkFilter {
+	filter.Limit++
+	return filter
+}
+
+// nextLinkPage trims the links fetched with lookahead to the page and returns
+// the cursor of the following page, empty when this one is the last
+func nextLinkPage(filter domain.LinkFilter, links []domain.ShortUrl) ([]domain.ShortUrl, string) {
+	if len(links) <= filter.Limit {
+		return links, ""
+	}
+	links = links[:filter.Limit]
+	return links, encodeLinkCursor(filter, &links[len(links)-1])
+}
+
+func encodeLinkCursor(filter domain.LinkFilter, last *domain.ShortUrl) string {
+	position := domain.CursorOf(last, filter.Sort)
+	data, _ := json.Marshal(linkCursor{
+		Sort:      filter.Sort,
+		Ascending: filter.Ascending,
+		ID:        position.ID,
+		Time:      position.Time,
+		Clicks:    position.Clicks,
+	})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeLinkCursor(value string) (linkCursor, error) {
+	var cursor linkCursor
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor, err
+	}
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return cursor, err
+	}
+	if cursor.ID == 0 {
+		return cursor, errors.New("cursor has no link id")
+	}
+	return cursor, nil
+}
+
+// selectFields reduces a rendered link to the selected fields, fields left out
+// of the link because they are empty stay absent
+func selectFields(link linkResponse, fields map[string]bool) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(link)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	for name := range all {
+		if !fields[name] {
+			delete(all, name)
+		}
+	}
+	return all, nil
+}
+
+func (s *Server) createURLHandler(ctx *gin.Context) {
+	var in service.LinkInput
+	if err := ctx.ShouldBindJSON(&in); err != nil {
+		abortWithBindError(ctx, err)
+		return
+	}
+
+	link, err := s.links.Create(ctx, principal(ctx), in)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	s.respondWithLink(ctx, http.StatusCreated, link)
+}
+
+// updateURLHandler edits a link, the cached copy is dropped so redirects pick up the change
+func (s *Server) updateURLHandler(ctx *gin.Context) {
+	var patch service.LinkPatch
+	if err := ctx.ShouldBindJSON(&patch); err != nil {
+		abortWithBindError(ctx, err)
+		return
+	}
+
+	link, err := s.links.Update(ctx, principal(ctx), ctx.Query("domain"), ctx.Param("code"), patch)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	s.cache.Invalidate(link.DomainId, link.ShortCode)
+	s.respondWithLink(ctx, http.StatusOK, link)
+}
+
+type linkVersionResponse struct {
+	Version       int        `json:"version"`
+	OriginalURL   string     `json:"originalUrl"`
+	ExpiresAt     *time.Time `json:"expiresAt,omitempty"`
+	IsActive      bool       `json:"isActive"`
+	RedirectType  int        `json:"redirectType"`
+	ChangedBy     uint       `json:"changedBy"`
+	ChangedFields []string   `json:"changedFields"`
+	ChangedAt     time.Time  `json:"changedAt"`
+}
+
+type linkHistoryResponse struct {
+	ShortCode      string                `json:"shortCode"`
+	CurrentVersion int                   `json:"currentVersion"`
+	Versions       []linkVersionResponse `json:"versions"`
+}
+
+func (s *Server) historyHandler(ctx *gin.Context) {
+	link, versions, err := s.links.History(ctx, principal(ctx), ctx.Query("domain"), ctx.Param("code"))
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	resp := linkHistoryResponse{
+		ShortCode:      link.ShortCode,
+		CurrentVersion: link.Version,
+		Versions:       make([]linkVersionResponse, 0, len(versions)),
+	}
+	for _, v := range versions {
+		resp.Versions = append(resp.Versions, linkVersionResponse{
+			Version:       v.Version,
+			OriginalURL:   v.OriginalURL,
+			ExpiresAt:     optionalTime(v.ExpiresAt),
+			IsActive:      v.IsActive,
+			RedirectType:  v.RedirectType,
+			ChangedBy:     v.ChangedBy,
+			ChangedFields: v.ChangedFields,
+			ChangedAt:     v.CreatedAt,
+		})
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+type revertRequest struct {
+	Version int `json:"version" binding:"required,min=1"`
+}
+
+func (s *Server) revertHandler(ctx *gin.Context) {
+	var req revertRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		abortWithBindError(ctx, err)
+		return
+	}
+
+	link, err := s.links.Revert(ctx, principal(ctx), ctx.Query("domain"), ctx.Param("code"), req.Version)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	s.cache.Invalidate(link.DomainId, link.ShortCode)
+	s.respondWithLink(ctx, http.StatusOK, link)
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"coding2fun.in/url-shortner/internal/domain"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRevertRevalidatesTheDestination(t *testing.T) {
+	ts := newTestServer(t)
+	ts.createLink(t, `{"url":"https://example.com/now","slug":"moved"}`)
+
+	var link domain.ShortUrl
+	if err := ts.db.GetConnection().First(&link, "short_code = ?", "moved").Error; err != nil {
+		t.Fatal(err)
+	}
+	// Versions saved before destinations were checked may point anywhere
+	destinations := []string{"http://127.0.0.1/admin", "https://sho.rt/loop", "https://example.com/before"}
+	for i, destination := range destinations {
+		version := domain.LinkVersion{ShortURLId: link.ID, Version: i + 1, OriginalURL: destination, IsActive: true}
+		if err := ts.db.GetConnection().Create(&version).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ts.db.GetConnection().Model(&link).Update("version", len(destinations)+1).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for _, version := range []string{"1", "2"} {
+		w := ts.do(http.MethodPost, "/v1/urls/moved/revert", ts.key, `{"version":`+version+`}`)
+		if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), `"field":"version"`) {
+			t.Errorf("reverting to version %s answered %d: %s, want a validation error on version", version, w.Code, w.Body)
+		}
+	}
+	w := ts.do(http.MethodPost, "/v1/urls/moved/revert", ts.key, `{"version":3}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "https://example.com/before") {
+		t.Errorf("reverting to a safe version answered %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestListingPagesThroughTies(t *testing.T) {
+	ts := newTestServer(t)
+	// a, b and c share their creation time, a, b and d their clicks
+	created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	links := []struct {
+		slug    string
+		created time.Time
+		clicks  int64
+	}{
+		{"tie-a", created, 5},
+		{"tie-b", created, 5},
+		{"tie-c", created, 0},
+		{"tie-d", created.Add(time.Hour), 5},
+	}
+	for _, link := range links {
+		ts.createLink(t, `{"url":"https://example.com/`+link.slug+`","slug":"`+link.slug+`"}`)
+		err := ts.db.GetConnection().Model(&domain.ShortUrl{}).Where("short_code = ?", link.slug).
+			Updates(map[string]any{"created_at": link.created, "clicks": link.clicks}).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"sort=created&order=desc", []string{"tie-d", "tie-c", "tie-b", "tie-a"}},
+		{"sort=created&order=asc", []string{"tie-a", "tie-b", "tie-c", "tie-d"}},
+		{"sort=clicks&order=desc", []string{"tie-d", "tie-b", "tie-a", "tie-c"}},
+		{"sort=clicks&order=asc", []string{"tie-c", "tie-a", "tie-b", "tie-d"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			var got []string
+			pages, cursor := 0, ""
+			for {
+				path := "/v1/urls?limit=3&fields=shortCode&" + tt.query
+				if cursor != "" {
+					path += "&cursor=" + url.QueryEscape(cursor)
+				}
+				w := ts.do(http.MethodGet, path, ts.key, "")
+				if w.Code != http.StatusOK {
+					t.Fatalf("listing answered %d: %s", w.Code, w.Body)
+				}
+				var page struct {
+					Links      []struct{ ShortCode string }
+					NextCursor string
+				}
+				if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+					t.Fatal(err)
+				}
+				pages++
+				for _, link := range page.Links {
+					got = append(got, link.ShortCode)
+				}
+				if cursor = page.NextCursor; cursor == "" || pages > len(links) {
+					break
+				}
+			}
+			if !slices.Equal(got, tt.want) || pages != 2 {
+				t.Errorf("paged through %q in %d pages, want %q in 2", got, pages, tt.want)
+			}
+		})
+	}
+}
+
+func TestListingEndsWithoutACursor(t *testing.T) {
+	ts := newTestServer(t)
+	for _, slug := range []string{"full-a", "full-b"} {
+		ts.createLink(t, `{"url":"https://example.com/`+slug+`","slug":"`+slug+`"}`)
+	}
+
+	// A page exactly as long as the listing is its last
+	w := ts.do(http.MethodGet, "/v1/urls?limit=2", ts.key, "")
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "nextCursor") {
+		t.Errorf("the last full page answered %d: %s, want no cursor", w.Code, w.Body)
+	}
+	w = ts.do(http.MethodGet, "/v1/urls?limit=1", ts.key, "")
+	var page struct{ NextCursor string }
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil || page.NextCursor == "" {
+		t.Fatalf("a partial listing answered %d: %s, want a cursor", w.Code, w.Body)
+	}
+
+	for _, query := range []string{"order=asc", "sort=clicks"} {
+		w = ts.do(http.MethodGet, "/v1/urls?limit=1&"+query+"&cursor="+url.QueryEscape(page.NextCursor), ts.key, "")
+		if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "another sort order") {
+			t.Errorf("continuing with %s answered %d: %s, want the cursor rejected", query, w.Code, w.Body)
+		}
+	}
+	w = ts.do(http.MethodGet, "/v1/urls?cursor=bm90LWEtY3Vyc29y", ts.key, "")
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), `"field":"cursor"`) {
+		t.Errorf("a forged cursor answered %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestRevertRestoresEveryEditedField(t *testing.T) {
+	ts := newTestServer(t)
+	folder := func(name string) string {
+		w := ts.do(http.MethodPost, "/v1/folders", ts.key, `{"name":"`+name+`"}`)
+		var created struct{ ID uint }
+		if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil || w.Code != http.StatusCreated {
+			t.Fatalf("creating a folder answered %d: %s", w.Code, w.Body)
+		}
+		return strconv.FormatUint(uint64(created.ID), 10)
+	}
+	spring, fall := folder("spring"), folder("fall")
+
+	// The editable fields of a link as an answer shows them
+	editable := func(w *httptest.ResponseRecorder) map[string]any {
+		t.Helper()
+		var link map[string]any
+		if err := json.Unmarshal(w.Body.Bytes(), &link); err != nil {
+			t.Fatalf("answered %d: %s", w.Code, w.Body)
+		}
+		for _, volatile := range []string{"version", "createdAt", "updatedAt"} {
+			delete(link, volatile)
+		}
+		return link
+	}
+
+	w := ts.do(http.MethodPost, "/v1/urls", ts.key, `{"url":"https://example.com/","slug":"edited","title":"Spring","folderId":`+spring+`,`+
+		`"tags":["promo","spring"],"utm":{"source":"news"},"queryPassthrough":"shortLink","rules":[{"url":"https://example.com/de","countries":["DE"]}],`+
+		`"variants":[{"url":"https://example.com/a","weight":1},{"url":"https://example.com/b","weight":3}]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("creating the link answered %d: %s", w.Code, w.Body)
+	}
+	original := editable(w)
+
+	w = ts.do(http.MethodPatch, "/v1/urls/edited", ts.key, `{"title":"Fall","folderId":`+fall+`,"tags":["fall"],"utm":{"source":"ads"},`+
+		`"queryPassthrough":"destination","rules":[],"variants":[]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("editing answered %d: %s", w.Code, w.Body)
+	}
+	w = ts.do(http.MethodPost, "/v1/urls/edited/revert", ts.key, `{"version":1}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("reverting answered %d: %s", w.Code, w.Body)
+	}
+	if reverted := editable(w); !reflect.DeepEqual(reverted, original) {
+		t.Errorf("reverted to %v, want %v", reverted, original)
+	}
+
+	// A folder deleted since cannot be restored
+	if w := ts.do(http.MethodDelete, "/v1/folders/"+spring, ts.key, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("deleting the folder answered %d: %s", w.Code, w.Body)
+	}
+	w = ts.do(http.MethodPost, "/v1/urls/edited/revert", ts.key, `{"version":1}`)
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "its folderId") {
+		t.Errorf("reverting to a deleted folder answered %d: %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,295 @@
+package server
+
+import (
+	"coding2fun.in/url-shortner/internal/domain"
+	"coding2fun.in/url-shortner/internal/service"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 200
+)
+
+type webhookResponse struct {
+	ID     uint     `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	// Secret is only returned when the endpoint is created or its secret rotated
+	Secret      string    `json:"secret,omitempty"`
+	Description string    `json:"description,omitempty"`
+	IsActive    bool      `json:"isActive"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+func newWebhookResponse(e *domain.WebhookEndpoint) webhookResponse {
+	events := e.Events
+	if events == nil {
+		events = []string{}
+	}
+	return webhookResponse{
+		ID:          e.ID,
+		URL:         e.URL,
+		Events:      events,
+		Description: e.Description,
+		IsActive:    e.IsActive,
+		CreatedAt:   e.CreatedAt,
+	}
+}
+
+type deliveryResponse struct {
+	ID             uint                  `json:"id"`
+	EventId        string                `json:"eventId"`
+	Event          string                `json:"event"`
+	Status         domain.DeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	NextAttemptAt  *time.Time            `json:"nextAttemptAt,omitempty"`
+	LastStatusCode int                   `json:"lastStatusCode,omitempty"`
+	LastError      string                `json:"lastError,omitempty"`
+	DeliveredAt    *time.Time            `json:"deliveredAt,omitempty"`
+	CreatedAt      time.Time             `json:"createdAt"`
+	// Payload and Log are only returned for a single delivery
+	Payload json.RawMessage   `json:"payload,omitempty"`
+	Log     []attemptResponse `json:"log,omitempty"`
+}
+
+type attemptResponse struct {
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"durationMs"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+func newDeliveryResponse(d *domain.WebhookDelivery) deliveryResponse {
+	resp := deliveryResponse{
+		ID:             d.ID,
+		EventId:        d.EventId,
+		Event:          d.Event,
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		LastStatusCode: d.LastStatusCode,
+		LastError:      d.LastError,
+		DeliveredAt:    d.DeliveredAt,
+		CreatedAt:      d.CreatedAt,
+	}
+	if d.Status == domain.DeliveryPending || d.Status == domain.DeliveryRetrying {
+		resp.NextAttemptAt = &d.NextAttemptAt
+	}
+	return resp
+}
+
+type deliveryListResponse struct {
+	Deliveries []deliveryResponse `json:"deliveries"`
+	NextCursor string             `json:"nextCursor,omitempty"`
+}
+
+func (s *Server) createWebhookHandler(ctx *gin.Context) {
+	var in service.WebhookInput
+	if err := ctx.ShouldBindJSON(&in); err != nil {
+		abortWithBindError(ctx, err)
+		return
+	}
+
+	endpoint, err := s.webhooks.Create(ctx, principal(ctx), in)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	resp := newWebhookResponse(endpoint)
+	resp.Secret = endpoint.Secret
+	ctx.JSON(http.StatusCreated, resp)
+}
+
+func (s *Server) listWebhooksHandler(ctx *gin.Context) {
+	endpoints, err := s.webhooks.List(ctx, principal(ctx))
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	resp := make([]webhookResponse, 0, len(endpoints))
+	for i := range endpoints {
+		resp = append(resp, newWebhookResponse(&endpoints[i]))
+	}
+	ctx.JSON(http.StatusOK, gin.H{"webhooks": resp})
+}
+
+func (s *Server) webhookHandler(ctx *gin.Context) {
+	id, ok := pathID(ctx, "id")
+	if !ok {
+		return
+	}
+
+	endpoint, err := s.webhooks.Get(ctx, principal(ctx), id)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, newWebhookResponse(endpoint))
+}
+
+func (s *Server) updateWebhookHandler(ctx *gin.Context) {
+	id, ok := pathID(ctx, "id")
+	if !ok {
+		return
+	}
+
+	var patch service.WebhookPatch
+	if err := ctx.ShouldBindJSON(&patch); err != nil {
+		abortWithBindError(ctx, err)
+		return
+	}
+
+	endpoint, err := s.webhooks.Update(ctx, principal(ctx), id, patch)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, newWebhookResponse(endpoint))
+}
+
+// deleteWebhookHandler removes an endpoint, its queued deliveries are dropped
+func (s *Server) deleteWebhookHandler(ctx *gin.Context) {
+	id, ok := pathID(ctx, "id")
+	if !ok {
+		return
+	}
+
+	if err := s.webhooks.Delete(ctx, principal(ctx), id); err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+func (s *Server) rotateWebhookSecretHandler(ctx *gin.Context) {
+	id, ok := pathID(ctx, "id")
+	if !ok {
+		return
+	}
+
+	endpoint, err := s.webhooks.RotateSecret(ctx, principal(ctx), id)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	resp := newWebhookResponse(endpoint)
+	resp.Secret = endpoint.Secret
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// listDeliveriesHandler lists the deliveries of an endpoint newest first, the
+// cursor of a full page continues with older ones
+func (s *Server) listDeliveriesHandler(ctx *gin.Context) {
+	id, ok := pathID(ctx, "id")
+	if !ok {
+		return
+	}
+	filter, err := deliveryFilter(ctx)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	filter.EndpointId = id
+
+	deliveries, err := s.webhooks.Deliveries(ctx, principal(ctx), filter)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	resp := deliveryListResponse{Deliveries: make([]deliveryResponse, 0, len(deliveries))}
+	for i := range deliveries {
+		resp.Deliveries = append(resp.Deliveries, newDeliveryResponse(&deliveries[i]))
+	}
+	if len(deliveries) == filter.Limit {
+		resp.NextCursor = strconv.FormatUint(uint64(deliveries[len(deliveries)-1].ID), 10)
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// deliveryHandler returns a delivery with its payload and the log of its attempts
+func (s *Server) deliveryHandler(ctx *gin.Context) {
+	id, ok := pathID(ctx, "id")
+	if !ok {
+		return
+	}
+	deliveryId, ok := pathID(ctx, "deliveryId")
+	if !ok {
+		return
+	}
+
+	delivery, err := s.webhooks.Delivery(ctx, principal(ctx), id, deliveryId)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	resp := newDeliveryResponse(delivery)
+	resp.Payload = rawJSON(delivery.Payload)
+	resp.Log = make([]attemptResponse, 0, len(delivery.Log))
+	for _, a := range delivery.Log {
+		resp.Log = append(resp.Log, attemptResponse{
+			Attempt:    a.Attempt,
+			StatusCode: a.StatusCode,
+			Error:      a.Error,
+			DurationMs: a.Duration.Milliseconds(),
+			CreatedAt:  a.CreatedAt,
+		})
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// replayDeliveryHandler queues a delivery again, a dead one included
+func (s *Server) replayDeliveryHandler(ctx *gin.Context) {
+	id, ok := pathID(ctx, "id")
+	if !ok {
+		return
+	}
+	deliveryId, ok := pathID(ctx, "deliveryId")
+	if !ok {
+		return
+	}
+
+	delivery, err := s.webhooks.Replay(ctx, principal(ctx), id, deliveryId)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusAccepted, newDeliveryResponse(delivery))
+}
+
+func deliveryFilter(ctx *gin.Context) (domain.DeliveryFilter, error) {
+	verr := &domain.ValidationError{}
+	filter := domain.DeliveryFilter{Limit: defaultDeliveryLimit}
+
+	switch status := domain.DeliveryStatus(ctx.Query("status")); status {
+	case "", domain.DeliveryPending, domain.DeliveryRetrying, domain.DeliverySucceeded, domain.DeliveryDead:
+		filter.Status = status
+	default:
+		verr.Add("status", "must be pending, retrying, succeeded or dead")
+	}
+
+	if value := ctx.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxDeliveryLimit {
+			verr.Add("limit", "must be between 1 and "+strconv.Itoa(maxDeliveryLimit))
+		}
+		filter.Limit = limit
+	}
+
+	if value := ctx.Query("cursor"); value != "" {
+		cursor, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			verr.Add("cursor", "is invalid")
+		}
+		filter.BeforeId = uint(cursor)
+	}
+
+	return filter, verr.OrNil()
+}
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"coding2fun.in/url-shortner/internal/audit"
+	"coding2fun.in/url-shortner/internal/config"
+	"coding2fun.in/url-shortner/internal/domain"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AccountService manages accounts and their API keys, every change is audited
+type AccountService struct {
+	accounts domain.AccountRepository
+	audit    *audit.Logger
+	cfg      config.APIKeysConfig
+}
+
+func NewAccountService(accounts domain.AccountRepository, audit *audit.Logger, cfg config.APIKeysConfig) *AccountService {
+	return &AccountService{accounts: accounts, audit: audit, cfg: cfg}
+}
+
+func (s *AccountService) Create(ctx context.Context, email string) (*domain.Account, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if !strings.Contains(email, "@") {
+		return nil, domain.NewValidationError("email", "must be an email address")
+	}
+
+	account := &domain.Account{Email: email}
+	if err := s.accounts.Create(ctx, account); err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, audit.Entry{
+		AccountId: account.ID,
+		Action:    domain.AuditAccountCreate,
+		Resource:  accountResource(account.ID),
+		After:     map[string]any{"email": account.Email, "isActive": account.IsActive},
+	})
+	return account, nil
+}
+
+func (s *AccountService) Activate(ctx context.Context, id uint) error {
+	if err := s.accounts.Activate(ctx, id); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, audit.Entry{
+		AccountId: id,
+		Action:    domain.AuditAccountActivate,
+		Resource:  accountResource(id),
+		After:     map[string]any{"isActive": true},
+	})
+	return nil
+}
+
+// APIKeyInput describes a new key. Scopes are required, the domain and
+// address restrictions are optional.
+type APIKeyInput struct {
+	Name           string     `json:"name"`
+	Scopes         []string   `json:"scopes"`
+	AllowedDomains []string   `json:"allowedDomains"`
+	AllowedCIDRs   []string   `json:"allowedCidrs"`
+	ExpiresAt      *time.Time `json:"expiresAt"`
+}
+
+// CreateAPIKey issues a key for the account, the plain key is only returned here
+func (s *AccountService) CreateAPIKey(ctx context.Context, accountId uint, in APIKeyInput) (*domain.APIKey, string, error) {
+	verr := &domain.ValidationError{}
+	key := &domain.APIKey{AccountId: accountId, Name: strings.TrimSpace(in.Name)}
+
+	scopes, err := domain.ParseScopes(in.Scopes)
+	if err != nil {
+		verr.Add("scopes", err.Error())
+	} else if len(scopes) == 0 {
+		verr.Add("scopes", "at least one scope is required")
+	}
+	key.Scopes = scopes
+
+	for _, raw := range in.AllowedDomains {
+		host := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(raw)), ".")
+		if host == "" || strings.ContainsAny(host, "/:@ ") {
+			verr.Add("allowedDomains", fmt.Sprintf("%q is not a domain name", raw))
+			continue
+		}
+		key.AllowedDomains = append(key.AllowedDomains, host)
+	}
+	for _, raw := range in.AllowedCIDRs {
+		cidr, err := normalizeCIDR(raw)
+		if err != nil {
+			verr.Add("allowedCidrs", fmt.Sprintf("%q is not an address or CIDR range", raw))
+			continue
+		}
+		key.AllowedCIDRs = append(key.AllowedCIDRs, cidr)
+	}
+
+	if in.ExpiresAt != nil {
+		if !in.ExpiresAt.After(time.Now()) {
+			verr.Add("expiresAt", "must be in the future")
+		}
+		key.ExpiresAt = *in.ExpiresAt
+	}
+	if err := verr.OrNil(); err != nil {
+		return nil, "", err
+	}
+
+	plain, err := s.accounts.CreateAPIKey(ctx, key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	s.audit.Record(ctx, audit.Entry{
+		AccountId: accountId,
+		Action:    domain.AuditAPIKeyCreate,
+		Resource:  apiKeyResource(key.ID),
+		After:     apiKeySettings(key),
+	})
+	return key, plain, nil
+}
+
+func (s *AccountService) APIKeys(ctx context.Context, accountId uint) ([]domain.APIKey, error) {
+	return s.accounts.ListAPIKeys(ctx, accountId)
+}
+
+// RotateAPIKey issues a successor with the settings of the key. The old key
+// keeps working for the overlap, nil uses the configured window.
+func (s *AccountService) RotateAPIKey(ctx context.Context, accountId, id uint, overlap *time.Duration) (*domain.APIKey, string, error) {
+	window := s.cfg.RotationOverlap
+	if overlap != nil {
+		if *overlap < 0 || *overlap > s.cfg.MaxRotationOverlap {
+			return nil, "", domain.NewValidationError("overlap", fmt.Sprintf("must be between 0s and %s", s.cfg.MaxRotationOverlap))
+		}
+		window = *overlap
+	}
+
+	old, key, plain, err := s.accounts.RotateAPIKey(ctx, accountId, id, time.Now().Add(window))
+	if err != nil {
+		return nil, "", err
+	}
+
+	s.audit.Record(ctx, audit.Entry{
+		AccountId: accountId,
+		Action:    domain.AuditAPIKeyRotate,
+		Resource:  apiKeyResource(old.ID),
+		After:     map[string]any{"rotatedToId": key.ID, "expiresAt": old.ExpiresAt},
+	})
+	return key, plain, nil
+}
+
+func (s *AccountService) DeactivateAPIKey(ctx context.Context, accountId, id uint) error {
+	if err := s.accounts.DeactivateAPIKey(ctx, accountId, id); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, audit.Entry{
+		AccountId: accountId,
+		Action:    domain.AuditAPIKeyDeactivate,
+		Resource:  apiKeyResource(id),
+		Before:    map[string]any{"isActive": true},
+		After:     map[string]any{"isActive": false},
+	})
+	return nil
+}
+
+// RevokeAPIKeys deactivates every key of the account at once, rotated keys
+// lose their overlap too
+func (s *AccountService) RevokeAPIKeys(ctx context.Context, accountId uint) (int64, error) {
+	revoked, err := s.accounts.DeactivateAPIKeys(ctx, accountId)
+	if err != nil {
+		return 0, err
+	}
+
+	s.audit.Record(ctx, audit.Entry{
+		AccountId: accountId,
+		Action:    domain.AuditAPIKeyRevokeAll,
+		Resource:  accountResource(accountId),
+		After:     map[string]any{"revoked": revoked},
+	})
+	return revoked, nil
+}
+
+// normalizeCIDR accepts a CIDR range or a single address, which becomes a host range
+func normalizeCIDR(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "/") {
+		ip := net.ParseIP(raw)
+		if ip == nil {
+			return "", fmt.Errorf("invalid address %q", raw)
+		}
+		if ip.To4() != nil {
+			return ip.String() + "/32", nil
+		}
+		return ip.String() + "/128", nil
+	}
+	_, network, err := net.ParseCIDR(raw)
+	if err != nil {
+		return "", err
+	}
+	return network.String(), nil
+}
+
+func apiKeySettings(key *domain.APIKey) map[string]any {
+	return map[string]any{
+		"name":           key.Name,
+		"isActive":       key.IsActive,
+		"scopes":         key.Scopes,
+		"allowedDomains": key.AllowedDomains,
+		"allowedCidrs":   key.AllowedCIDRs,
+	}
+}
+
+func accountResource(id uint) string {
+	return "account:" + strconv.FormatUint(uint64(id), 10)
+}
+
+func apiKeyResource(id uint) string {
+	return "api_key:" + strconv.FormatUint(uint64(id), 10)
+}
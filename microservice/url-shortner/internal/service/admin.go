@@ -0,0 +1,126 @@
+package service
+
+import (
+	"coding2fun.in/url-shortner/internal/audit"
+	"coding2fun.in/url-shortner/internal/config"
+	"coding2fun.in/url-shortner/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// AdminService backs the operator tooling. It acts across organizations without
+// a principal, every change is audited on behalf of the context's actor.
+type AdminService struct {
+	urls     domain.ShortURLRepository
+	domains  domain.DomainRepository
+	orgs     domain.OrganizationRepository
+	stats    domain.StatsRepository
+	audit    *audit.Logger
+	baseHost string
+}
+
+func NewAdminService(urls domain.ShortURLRepository, domains domain.DomainRepository, orgs domain.OrganizationRepository, stats domain.StatsRepository, audit *audit.Logger, cfg config.LinksConfig) *AdminService {
+	baseHost := ""
+	if u, err := url.Parse(cfg.BaseURL); err == nil {
+		baseHost = strings.ToLower(u.Hostname())
+	}
+	return &AdminService{urls: urls, domains: domains, orgs: orgs, stats: stats, audit: audit, baseHost: baseHost}
+}
+
+// Link looks up a code on the domain serving host, empty is the shared domain
+func (s *AdminService) Link(ctx context.Context, host, code string) (*domain.ShortUrl, error) {
+	domainId, err := s.domainID(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	return s.urls.GetSourceURL(ctx, domainId, code)
+}
+
+// DeactivateLink disables a link with the reason. Instances serving it from
+// their link cache keep redirecting until the cached copy expires.
+func (s *AdminService) DeactivateLink(ctx context.Context, host, code, reason string) (*domain.ShortUrl, error) {
+	link, err := s.Link(ctx, host, code)
+	if err != nil {
+		return nil, err
+	}
+
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		reason = "disabled by an operator"
+	}
+	if err := s.urls.DisableURL(ctx, link.ID, reason); err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, audit.Entry{
+		AccountId:      link.AccountId,
+		OrganizationId: link.OrganizationId,
+		Action:         domain.AuditLinkDisable,
+		Resource:       "link:" + link.ShortCode,
+		Before:         map[string]any{"isActive": link.IsActive},
+		After:          map[string]any{"isActive": false, "disabledReason": reason},
+	})
+	link.IsActive = false
+	link.DisabledReason = reason
+	return link, nil
+}
+
+// ReassignLink moves a link to an organization the account is a member of. A
+// link on a custom domain can only move within the domain's organization.
+func (s *AdminService) ReassignLink(ctx context.Context, host, code string, orgId, accountId uint) (*domain.ShortUrl, error) {
+	link, err := s.Link(ctx, host, code)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.orgs.GetMembership(ctx, orgId, accountId); errors.Is(err, domain.ErrNotFound) {
+		return nil, domain.NewValidationError("account", "is not a member of the organization")
+	} else if err != nil {
+		return nil, err
+	}
+	if link.DomainId != 0 {
+		d, err := s.domains.FindByID(ctx, link.DomainId)
+		if err != nil {
+			return nil, err
+		}
+		if d.OrganizationId != orgId {
+			return nil, domain.NewValidationError("organization", fmt.Sprintf("does not own the domain %s", d.Host))
+		}
+	}
+
+	if err := s.urls.ReassignURL(ctx, link.ID, orgId, accountId); err != nil {
+		return nil, err
+	}
+
+	before := map[string]any{"organizationId": link.OrganizationId, "accountId": link.AccountId}
+	link.OrganizationId, link.AccountId = orgId, accountId
+	s.audit.Record(ctx, audit.Entry{
+		AccountId:      accountId,
+		OrganizationId: orgId,
+		Action:         domain.AuditLinkReassign,
+		Resource:       "link:" + link.ShortCode,
+		Before:         before,
+		After:          map[string]any{"organizationId": orgId, "accountId": accountId},
+	})
+	return link, nil
+}
+
+func (s *AdminService) Stats(ctx context.Context) (*domain.ServiceStats, error) {
+	return s.stats.Totals(ctx)
+}
+
+// domainID resolves a host to its verified domain, links only exist on those
+func (s *AdminService) domainID(ctx context.Context, host string) (uint, error) {
+	host = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+	if host == "" || host == s.baseHost {
+		return 0, nil
+	}
+	d, err := s.domains.FindVerified(ctx, host)
+	if err != nil {
+		return 0, err
+	}
+	return d.ID, nil
+}
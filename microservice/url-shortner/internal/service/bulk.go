@@ -0,0 +1,158 @@
+package service
+
+import (
+	"coding2fun.in/url-shortner/internal/audit"
+	"coding2fun.in/url-shortner/internal/config"
+	"coding2fun.in/url-shortner/internal/domain"
+	"coding2fun.in/url-shortner/internal/log"
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+// BulkReport is the per-row outcome of a bulk link creation
+type BulkReport struct {
+	Total     int                    `json:"total"`
+	Succeeded int                    `json:"succeeded"`
+	Failed    int                    `json:"failed"`
+	Results   []domain.BulkRowResult `json:"results"`
+}
+
+func newBulkReport(results []domain.BulkRowResult) *BulkReport {
+	report := &BulkReport{Total: len(results), Results: results}
+	for _, r := range results {
+		if r.Error == nil {
+			report.Succeeded++
+		} else {
+			report.Failed++
+		}
+	}
+	return report
+}
+
+// BulkService creates links in bulk. Small requests are processed inline while
+// large ones become background jobs so they do not outlive the write timeout.
+type BulkService struct {
+	links   *LinkService
+	jobs    domain.BulkJobRepository
+	cfg     config.LinksConfig
+	workers chan struct{}
+	wg      sync.WaitGroup
+}
+
+func NewBulkService(links *LinkService, jobs domain.BulkJobRepository, cfg config.LinksConfig) *BulkService {
+	return &BulkService{
+		links:   links,
+		jobs:    jobs,
+		cfg:     cfg,
+		workers: make(chan struct{}, max(cfg.BulkWorkers, 1)),
+	}
+}
+
+// Submit processes the rows inline and returns the report, or queues a job when
+// there are more rows than the sync limit or the sync budget allows
+func (s *BulkService) Submit(ctx context.Context, p *domain.Principal, inputs []LinkInput) (*BulkReport, *domain.BulkJob, error) {
+	if err := p.Authorize(domain.PermLinksWrite); err != nil {
+		return nil, nil, err
+	}
+	if len(inputs) == 0 {
+		return nil, nil, domain.NewValidationError("rows", "at least one row is required")
+	}
+	if len(inputs) > s.cfg.BulkMaxRows {
+		return nil, nil, domain.NewValidationError("rows", fmt.Sprintf("at most %d rows are allowed", s.cfg.BulkMaxRows))
+	}
+
+	if s.inline(len(inputs)) {
+		return newBulkReport(s.links.CreateBatch(ctx, p, inputs)), nil, nil
+	}
+
+	job := &domain.BulkJob{
+		AccountId:      p.AccountId,
+		OrganizationId: p.OrganizationId,
+		APIKeyId:       p.APIKeyId,
+		Status:         domain.BulkJobPending,
+		Total:          len(inputs),
+	}
+	if err := s.jobs.Create(ctx, job); err != nil {
+		return nil, nil, err
+	}
+
+	s.wg.Add(1)
+	go s.run(job, *p, audit.ActorFrom(ctx), inputs)
+
+	return nil, job, nil
+}
+
+// inline reports whether the rows are answered within the request. The budget
+// assumes the worst case of every destination lookup running into the timeout.
+func (s *BulkService) inline(rows int) bool {
+	return rows <= s.cfg.BulkSyncLimit && time.Duration(rows)*s.cfg.ResolveTimeout <= s.cfg.BulkSyncBudget
+}
+
+// Job returns a bulk job of the principal's organization
+func (s *BulkService) Job(ctx context.Context, p *domain.Principal, id uint) (*domain.BulkJob, error) {
+	return s.jobs.Get(ctx, p.OrganizationId, id)
+}
+
+// Recover fails the jobs a previous process left unfinished, their input is not persisted
+func (s *BulkService) Recover(ctx context.Context) error {
+	n, err := s.jobs.FailInterrupted(ctx)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		log.Warn("Marked interrupted bulk jobs as failed", zap.Int64("jobs", n))
+	}
+	return nil
+}
+
+// Shutdown waits for running jobs until the context is done
+func (s *BulkService) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("bulk jobs still running: %w", ctx.Err())
+	}
+}
+
+// run processes a job in the background on behalf of the actor that submitted it
+func (s *BulkService) run(job *domain.BulkJob, p domain.Principal, actor audit.Actor, inputs []LinkInput) {
+	defer s.wg.Done()
+
+	s.workers <- struct{}{}
+	defer func() { <-s.workers }()
+
+	ctx := audit.WithActor(context.Background(), actor)
+	job.Status = domain.BulkJobRunning
+	if err := s.jobs.Update(ctx, job); err != nil {
+		log.Error("Failed to start bulk job", zap.Uint("jobId", job.ID), zap.Error(err))
+	}
+
+	report := newBulkReport(s.links.CreateBatch(ctx, &p, inputs))
+
+	finishedAt := time.Now()
+	job.Status = domain.BulkJobCompleted
+	job.Succeeded = report.Succeeded
+	job.Failed = report.Failed
+	job.Results = report.Results
+	job.FinishedAt = &finishedAt
+	if err := s.jobs.Update(ctx, job); err != nil {
+		log.Error("Failed to store bulk job report", zap.Uint("jobId", job.ID), zap.Error(err))
+		return
+	}
+
+	log.Info("Bulk job finished",
+		zap.Uint("jobId", job.ID),
+		zap.Int("succeeded", report.Succeeded),
+		zap.Int("failed", report.Failed),
+	)
+}
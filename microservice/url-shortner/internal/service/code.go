@@ -0,0 +1,23 @@
+package service
+
+import (
+	"crypto/rand"
+	"math/big"
+)
+
+const codeAlphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+var codeAlphabetLen = big.NewInt(int64(len(codeAlphabet)))
+
+// generateCode returns a random base62 short code
+func generateCode(length int) (string, error) {
+	code := make([]byte, length)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, codeAlphabetLen)
+		if err != nil {
+			return "", err
+		}
+		code[i] = codeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
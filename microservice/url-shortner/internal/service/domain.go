@@ -0,0 +1,512 @@
+package service
+
+import (
+	"coding2fun.in/url-shortner/internal/audit"
+	"coding2fun.in/url-shortner/internal/config"
+	"coding2fun.in/url-shortner/internal/domain"
+	"coding2fun.in/url-shortner/internal/safeurl"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// verificationLabel is prepended to a domain to name its TXT record
+	verificationLabel = "_url-shortner"
+	// verificationPrefix starts the value of the TXT record
+	verificationPrefix  = "url-shortner-verification="
+	maxNotFoundPageSize = 64 << 10 // 64 KB
+)
+
+// TXTResolver looks up DNS TXT records, *net.Resolver satisfies it
+type TXTResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// DomainInput adds a custom domain
+type DomainInput struct {
+	Host         string `json:"host"`
+	FallbackURL  string `json:"fallbackUrl"`
+	NotFoundPage string `json:"notFoundPage"`
+}
+
+// DomainPatch changes the fallback of a domain, absent fields are left unchanged
+type DomainPatch struct {
+	FallbackURL  *string `json:"fallbackUrl"`
+	NotFoundPage *string `json:"notFoundPage"`
+}
+
+type hostEntry struct {
+	domain    *domain.Domain
+	expiresAt time.Time
+}
+
+// DomainService manages the custom domains of organizations and resolves request
+// hosts to the domain serving them
+type DomainService struct {
+	domains   domain.DomainRepository
+	validator *safeurl.Validator
+	resolver  TXTResolver
+	audit     *audit.Logger
+	cfg       config.LinksConfig
+	baseHost  string
+	ttl       time.Duration
+	// maxHosts bounds hosts, random Host headers must not grow it
+	maxHosts int
+
+	mu    sync.RWMutex
+	hosts map[string]hostEntry
+	names map[uint]string
+}
+
+func NewDomainService(domains domain.DomainRepository, validator *safeurl.Validator, resolver TXTResolver, audit *audit.Logger, cfg config.LinksConfig, cache config.CacheConfig) *DomainService {
+	baseHost := ""
+	if u, err := url.Parse(cfg.BaseURL); err == nil {
+		baseHost = strings.ToLower(u.Hostname())
+	}
+	s := &DomainService{
+		domains:   domains,
+		validator: validator,
+		resolver:  resolver,
+		audit:     audit,
+		cfg:       cfg,
+		baseHost:  baseHost,
+		ttl:       cache.LinkTTL,
+		maxHosts:  cache.DomainMaxEntries,
+		hosts:     make(map[string]hostEntry),
+		names:     make(map[uint]string),
+	}
+	// Destinations on the verified domains would redirect from one short link to another
+	if validator != nil {
+		validator.BlockShortDomains(s)
+	}
+	return s
+}
+
+// VerificationRecord returns the name and value of the TXT record that proves ownership of the domain
+func VerificationRecord(d *domain.Domain) (string, string) {
+	return verificationLabel + "." + d.Host, verificationPrefix + d.VerificationToken
+}
+
+func (s *DomainService) Create(ctx context.Context, p *domain.Principal, in DomainInput) (*domain.Domain, error) {
+	if err := p.Authorize(domain.PermDomainsWrite); err != nil {
+		return nil, err
+	}
+	verr := &domain.ValidationError{}
+
+	host, err := normalizeDomain(in.Host)
+	if err != nil {
+		verr.Add("host", err.Error())
+	} else if host == s.baseHost {
+		verr.Add("host", "is the shared domain")
+	} else if err := s.allow(p, host); err != nil {
+		return nil, err
+	}
+
+	d := &domain.Domain{AccountId: p.AccountId, OrganizationId: p.OrganizationId, Host: host}
+	if err := s.fallback(ctx, d, &in.FallbackURL, &in.NotFoundPage, verr); err != nil {
+		return nil, err
+	}
+	if err := verr.OrNil(); err != nil {
+		return nil, err
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, fmt.Errorf("failed to generate verification token: %w", err)
+	}
+	d.VerificationToken = hex.EncodeToString(token)
+
+	// Several organizations may claim an unverified host, the first to verify it owns it
+	if _, err := s.domains.FindVerified(ctx, host); err == nil {
+		return nil, domain.NewValidationError("host", "is already registered")
+	} else if !errors.Is(err, domain.ErrNotFound) {
+		return nil, err
+	}
+	if err := s.domains.Create(ctx, d); err != nil {
+		if errors.Is(err, domain.ErrConflict) {
+			return nil, fmt.Errorf("%w: %w", domain.NewValidationError("host", "is already registered"), err)
+		}
+		return nil, err
+	}
+
+	s.audit.Record(ctx, audit.Entry{
+		AccountId:      p.AccountId,
+		OrganizationId: p.OrganizationId,
+		Action:         domain.AuditDomainCreate,
+		Resource:       domainResource(d.ID),
+		After:          map[string]any{"host": d.Host, "fallbackUrl": d.FallbackURL},
+	})
+	return d, nil
+}
+
+func (s *DomainService) List(ctx context.Context, p *domain.Principal) ([]domain.Domain, error) {
+	return s.domains.List(ctx, p.OrganizationId)
+}
+
+func (s *DomainService) Get(ctx context.Context, p *domain.Principal, id uint) (*domain.Domain, error) {
+	return s.domains.Get(ctx, p.OrganizationId, id)
+}
+
+func (s *DomainService) Update(ctx context.Context, p *domain.Principal, id uint, patch DomainPatch) (*domain.Domain, error) {
+	if err := p.Authorize(domain.PermDomainsWrite); err != nil {
+		return nil, err
+	}
+	d, err := s.managed(ctx, p, id)
+	if err != nil {
+		return nil, err
+	}
+	before := domainFallback(d)
+
+	verr := &domain.ValidationError{}
+	if err := s.fallback(ctx, d, patch.FallbackURL, patch.NotFoundPage, verr); err != nil {
+		return nil, err
+	}
+	if err := verr.OrNil(); err != nil {
+		return nil, err
+	}
+
+	if err := s.domains.Update(ctx, d); err != nil {
+		return nil, err
+	}
+	s.forget(d)
+
+	old, updated := audit.Diff(before, domainFallback(d))
+	s.audit.Record(ctx, audit.Entry{
+		AccountId:      p.AccountId,
+		OrganizationId: p.OrganizationId,
+		Action:         domain.AuditDomainUpdate,
+		Resource:       domainResource(d.ID),
+		Before:         old,
+		After:          updated,
+	})
+	return d, nil
+}
+
+func (s *DomainService) Delete(ctx context.Context, p *domain.Principal, id uint) error {
+	if err := p.Authorize(domain.PermDomainsWrite); err != nil {
+		return err
+	}
+	d, err := s.managed(ctx, p, id)
+	if err != nil {
+		return err
+	}
+	if err := s.domains.Delete(ctx, p.OrganizationId, id); err != nil {
+		return err
+	}
+	s.forget(d)
+
+	s.audit.Record(ctx, audit.Entry{
+		AccountId:      p.AccountId,
+		OrganizationId: p.OrganizationId,
+		Action:         domain.AuditDomainDelete,
+		Resource:       domainResource(d.ID),
+		Before:         map[string]any{"host": d.Host},
+	})
+	return nil
+}
+
+// Verify checks the TXT record of the domain and marks it verified when the token matches
+func (s *DomainService) Verify(ctx context.Context, p *domain.Principal, id uint) (*domain.Domain, error) {
+	if err := p.Authorize(domain.PermDomainsWrite); err != nil {
+		return nil, err
+	}
+	d, err := s.managed(ctx, p, id)
+	if err != nil {
+		return nil, err
+	}
+	if d.Verified() {
+		return d, nil
+	}
+
+	if err := s.checkOwnership(ctx, d); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	d.VerifiedAt = &now
+	if err := s.domains.Update(ctx, d); err != nil {
+		if errors.Is(err, domain.ErrConflict) {
+			return nil, fmt.Errorf("%w: %w", domain.NewValidationError("host", "was verified by another organization"), err)
+		}
+		return nil, err
+	}
+	s.forget(d)
+
+	s.audit.Record(ctx, audit.Entry{
+		AccountId:      p.AccountId,
+		OrganizationId: p.OrganizationId,
+		Action:         domain.AuditDomainVerify,
+		Resource:       domainResource(d.ID),
+		After:          map[string]any{"host": d.Host, "verifiedAt": now},
+	})
+	return d, nil
+}
+
+// checkOwnership looks for the verification token among the TXT records of the domain
+func (s *DomainService) checkOwnership(ctx context.Context, d *domain.Domain) error {
+	if s.cfg.ResolveTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.cfg.ResolveTimeout)
+		defer cancel()
+	}
+
+	name, want := VerificationRecord(d)
+	records, err := s.resolver.LookupTXT(ctx, name)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && !dnsErr.IsNotFound && !dnsErr.IsTimeout {
+			return fmt.Errorf("failed to look up %s: %w", name, err)
+		}
+		return fmt.Errorf("%w: %w", domain.NewValidationError("host", "no TXT record found at "+name), err)
+	}
+	for _, record := range records {
+		if strings.TrimSpace(record) == want {
+			return nil
+		}
+	}
+	return domain.NewValidationError("host", "the TXT record at "+name+" does not hold the verification token")
+}
+
+// Resolve returns the verified domain serving a request host, or nil when the
+// host is not a custom domain. Hosts that are no domain name are never looked
+// up, the others are cached for the configured TTL.
+func (s *DomainService) Resolve(ctx context.Context, host string) (*domain.Domain, error) {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "" || host == s.baseHost {
+		return nil, nil
+	}
+
+	s.mu.RLock()
+	entry, ok := s.hosts[host]
+	s.mu.RUnlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.domain, nil
+	}
+	// Domains are stored normalized, any other spelling cannot match one
+	if normalized, err := normalizeDomain(host); err != nil || normalized != host {
+		return nil, nil
+	}
+
+	d, err := s.domains.FindVerified(ctx, host)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	if _, ok := s.hosts[host]; !ok && len(s.hosts) >= s.maxHosts {
+		s.evictHost()
+	}
+	s.hosts[host] = hostEntry{domain: d, expiresAt: time.Now().Add(s.ttl)}
+	s.mu.Unlock()
+	return d, nil
+}
+
+// evictHost removes expired hosts, or an arbitrary one when none has expired.
+// The caller holds the lock.
+func (s *DomainService) evictHost() {
+	now := time.Now()
+	for host, entry := range s.hosts {
+		if now.After(entry.expiresAt) {
+			delete(s.hosts, host)
+		}
+	}
+	if len(s.hosts) < s.maxHosts {
+		return
+	}
+	for host := range s.hosts {
+		delete(s.hosts, host)
+		return
+	}
+}
+
+// ShortURL returns the public short url of a link, links on a custom domain are served over https
+func (s *DomainService) ShortURL(ctx context.Context, link *domain.ShortUrl) (string, error) {
+	if link.DomainId == 0 {
+		return s.cfg.BaseURL + "/" + link.ShortCode, nil
+	}
+	host, err := s.Host(ctx, link.DomainId)
+	if err != nil {
+		return "", err
+	}
+	return "https://" + host + "/" + link.ShortCode, nil
+}
+
+// Host returns the host name of a domain for building short urls
+func (s *DomainService) Host(ctx context.Context, id uint) (string, error) {
+	s.mu.RLock()
+	host, ok := s.names[id]
+	s.mu.RUnlock()
+	if ok {
+		return host, nil
+	}
+
+	d, err := s.domains.FindByID(ctx, id)
+	if err != nil {
+		return "", err
+	}
+
+	s.remember(d)
+	return d.Host, nil
+}
+
+// remember caches the host of a domain, hosts never change so the name stays
+// cached for the life of the process
+func (s *DomainService) remember(d *domain.Domain) {
+	s.mu.Lock()
+	s.names[d.ID] = d.Host
+	s.mu.Unlock()
+}
+
+// ID returns the id of an organization's domain given its host, an empty host is the shared domain
+func (s *DomainService) ID(ctx context.Context, p *domain.Principal, host string) (uint, error) {
+	host = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+	if err := s.allow(p, host); err != nil {
+		return 0, err
+	}
+	if host == "" || host == s.baseHost {
+		return 0, nil
+	}
+	d, err := s.domains.GetByHost(ctx, p.OrganizationId, host)
+	if err != nil {
+		return 0, err
+	}
+	s.remember(d)
+	return d.ID, nil
+}
+
+// VerifiedID is ID for new links, which may only be created on verified domains
+func (s *DomainService) VerifiedID(ctx context.Context, p *domain.Principal, host string) (uint, error) {
+	host = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+	if err := s.allow(p, host); err != nil {
+		return 0, err
+	}
+	if host == "" || host == s.baseHost {
+		return 0, nil
+	}
+	d, err := s.domains.GetByHost(ctx, p.OrganizationId, host)
+	if errors.Is(err, domain.ErrNotFound) {
+		return 0, fmt.Errorf("%w: %w", domain.NewValidationError("domain", "is not a domain of this organization"), err)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if !d.Verified() {
+		return 0, domain.NewValidationError("domain", "is not verified yet")
+	}
+	s.remember(d)
+	return d.ID, nil
+}
+
+// AllowedIDs returns the ids of the organization's domains the caller's key is
+// restricted to, nil when it may act on every domain
+func (s *DomainService) AllowedIDs(ctx context.Context, p *domain.Principal) ([]uint, error) {
+	if len(p.Domains) == 0 {
+		return nil, nil
+	}
+	ids := make([]uint, 0, len(p.Domains))
+	for _, host := range p.Domains {
+		if host == s.baseHost {
+			ids = append(ids, 0)
+			continue
+		}
+		d, err := s.domains.GetByHost(ctx, p.OrganizationId, host)
+		if errors.Is(err, domain.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, d.ID)
+	}
+	return ids, nil
+}
+
+// allow fails with ErrForbidden when the caller's key is restricted to other
+// domains, an empty host is the shared domain
+func (s *DomainService) allow(p *domain.Principal, host string) error {
+	if host == "" {
+		host = s.baseHost
+	}
+	if p.AllowsDomain(host) {
+		return nil
+	}
+	return fmt.Errorf("api key may not act on %s: %w", host, domain.ErrForbidden)
+}
+
+// managed returns a domain of the organization the caller's key may change
+func (s *DomainService) managed(ctx context.Context, p *domain.Principal, id uint) (*domain.Domain, error) {
+	d, err := s.domains.Get(ctx, p.OrganizationId, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.allow(p, d.Host); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// fallback validates and applies the fallback fields of a domain
+func (s *DomainService) fallback(ctx context.Context, d *domain.Domain, fallbackURL, page *string, verr *domain.ValidationError) error {
+	if fallbackURL != nil {
+		if target := strings.TrimSpace(*fallbackURL); target == "" {
+			d.FallbackURL = ""
+		} else if normalized, err := s.validator.Validate(ctx, target); err != nil {
+			var urlErr *domain.ValidationError
+			if !errors.As(err, &urlErr) {
+				return err
+			}
+			verr.Add("fallbackUrl", "is not an allowed destination")
+		} else {
+			d.FallbackURL = normalized
+		}
+	}
+	if page != nil {
+		if len(*page) > maxNotFoundPageSize {
+			verr.Add("notFoundPage", fmt.Sprintf("must be at most %d bytes", maxNotFoundPageSize))
+		}
+		d.NotFoundPage = *page
+	}
+	return nil
+}
+
+func (s *DomainService) forget(d *domain.Domain) {
+	s.mu.Lock()
+	delete(s.hosts, d.Host)
+	s.mu.Unlock()
+}
+
+// normalizeDomain lowercases and IDNA encodes a host name, it must be a plain
+// domain name without scheme, port or path
+func normalizeDomain(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", errors.New("is required")
+	}
+	u, err := safeurl.Normalize("https://" + raw)
+	if err != nil || u.Path != "" || u.RawQuery != "" || u.Port() != "" || u.User != nil {
+		return "", errors.New("must be a domain name like go.example.com")
+	}
+
+	host := u.Hostname()
+	if net.ParseIP(host) != nil || !strings.Contains(host, ".") || len(host) > 253 {
+		return "", errors.New("must be a domain name like go.example.com")
+	}
+	return host, nil
+}
+
+func domainFallback(d *domain.Domain) map[string]any {
+	return map[string]any{"fallbackUrl": d.FallbackURL, "notFoundPage": d.NotFoundPage}
+}
+
+func domainResource(id uint) string {
+	return "domain:" + strconv.FormatUint(uint64(id), 10)
+}
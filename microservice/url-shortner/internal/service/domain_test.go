@@ -0,0 +1,266 @@
+package service
+
+import (
+	"coding2fun.in/url-shortner/internal/audit"
+	"coding2fun.in/url-shortner/internal/config"
+	"coding2fun.in/url-shortner/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+type fakeTXTResolver map[string][]string
+
+func (f fakeTXTResolver) LookupTXT(_ context.Context, name string) ([]string, error) {
+	records, ok := f[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return records, nil
+}
+
+type fakeDomains struct {
+	domains map[uint]*domain.Domain
+	// lookups counts the calls of FindVerified
+	lookups int
+}
+
+func (f *fakeDomains) Create(_ context.Context, d *domain.Domain) error {
+	for _, existing := range f.domains {
+		if existing.Host == d.Host && existing.OrganizationId == d.OrganizationId {
+			return domain.ErrConflict
+		}
+	}
+	d.ID = uint(len(f.domains) + 1)
+	f.domains[d.ID] = d
+	return nil
+}
+
+func (f *fakeDomains) Get(_ context.Context, orgId, id uint) (*domain.Domain, error) {
+	if d, ok := f.domains[id]; ok && d.OrganizationId == orgId {
+		copied := *d
+		return &copied, nil
+	}
+	return nil, domain.ErrNotFound
+}
+
+func (f *fakeDomains) GetByHost(_ context.Context, orgId uint, host string) (*domain.Domain, error) {
+	for _, d := range f.domains {
+		if d.Host == host && d.OrganizationId == orgId {
+			copied := *d
+			return &copied, nil
+		}
+	}
+	return nil, domain.ErrNotFound
+}
+
+func (f *fakeDomains) List(_ context.Context, orgId uint) ([]domain.Domain, error) {
+	var list []domain.Domain
+	for _, d := range f.domains {
+		if d.OrganizationId == orgId {
+			list = append(list, *d)
+		}
+	}
+	return list, nil
+}
+
+func (f *fakeDomains) FindVerified(_ context.Context, host string) (*domain.Domain, error) {
+	f.lookups++
+	for _, d := range f.domains {
+		if d.Host == host && d.Verified() {
+			copied := *d
+			return &copied, nil
+		}
+	}
+	return nil, domain.ErrNotFound
+}
+
+func (f *fakeDomains) FindByID(_ context.Context, id uint) (*domain.Domain, error) {
+	if d, ok := f.domains[id]; ok {
+		copied := *d
+		return &copied, nil
+	}
+	return nil, domain.ErrNotFound
+}
+
+func (f *fakeDomains) Update(_ context.Context, d *domain.Domain) error {
+	for _, existing := range f.domains {
+		if existing.ID != d.ID && existing.Host == d.Host && existing.Verified() && d.Verified() {
+			return domain.ErrConflict
+		}
+	}
+	copied := *d
+	f.domains[d.ID] = &copied
+	return nil
+}
+
+func (f *fakeDomains) Delete(_ context.Context, orgId, id uint) error {
+	delete(f.domains, id)
+	return nil
+}
+
+type discardAudit struct{}
+
+func (discardAudit) Append(context.Context, []*domain.AuditEvent) error { return nil }
+
+func (discardAudit) List(context.Context, domain.AuditFilter) ([]domain.AuditEvent, error) {
+	return nil, nil
+}
+
+func (discardAudit) Scan(context.Context, uint, int) ([]domain.AuditEvent, error) { return nil, nil }
+
+func newTestDomainService(resolver TXTResolver) *DomainService {
+	cfg := config.LinksConfig{BaseURL: "https://sho.rt", ResolveTimeout: time.Second}
+	return NewDomainService(&fakeDomains{domains: map[uint]*domain.Domain{}}, nil, resolver, audit.NewLogger(discardAudit{}), cfg, config.CacheConfig{LinkTTL: time.Minute, DomainMaxEntries: 2})
+}
+
+func TestDomainVerification(t *testing.T) {
+	resolver := fakeTXTResolver{}
+	s := newTestDomainService(resolver)
+	p := &domain.Principal{AccountId: 1, OrganizationId: 1, Role: domain.RoleOwner, Scopes: domain.Scopes{domain.ScopeAdmin}}
+	ctx := context.Background()
+
+	d, err := s.Create(ctx, p, DomainInput{Host: "Go.Example.COM"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if d.Host != "go.example.com" {
+		t.Errorf("Create() host = %q, want go.example.com", d.Host)
+	}
+
+	var verr *domain.ValidationError
+	if _, err := s.Verify(ctx, p, d.ID); !errors.As(err, &verr) {
+		t.Fatalf("Verify() without record error = %v, want validation error", err)
+	}
+
+	name, value := VerificationRecord(d)
+	if name != "_url-shortner.go.example.com" {
+		t.Errorf("VerificationRecord() name = %q", name)
+	}
+	resolver[name] = []string{"v=spf1 -all", "url-shortner-verification=wrong"}
+	if _, err := s.Verify(ctx, p, d.ID); !errors.As(err, &verr) {
+		t.Fatalf("Verify() with wrong token error = %v, want validation error", err)
+	}
+	if got, _ := s.Resolve(ctx, "go.example.com"); got != nil {
+		t.Fatalf("Resolve() of unverified domain = %v, want nil", got)
+	}
+
+	resolver[name] = append(resolver[name], value)
+	verified, err := s.Verify(ctx, p, d.ID)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if !verified.Verified() {
+		t.Fatal("Verify() did not mark the domain verified")
+	}
+
+	// The unverified lookup above was cached, verifying drops it
+	got, err := s.Resolve(ctx, "go.example.com")
+	if err != nil || got == nil || got.ID != d.ID {
+		t.Fatalf("Resolve() = %v, %v, want domain %d", got, err, d.ID)
+	}
+	if _, err := s.Verify(ctx, &domain.Principal{AccountId: 2, OrganizationId: 2, Role: domain.RoleOwner, Scopes: domain.Scopes{domain.ScopeAdmin}}, d.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("Verify() by another organization error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestDomainCreateRejects(t *testing.T) {
+	s := newTestDomainService(fakeTXTResolver{})
+	p := &domain.Principal{AccountId: 1, OrganizationId: 1, Role: domain.RoleOwner, Scopes: domain.Scopes{domain.ScopeAdmin}}
+
+	tests := []string{
+		"",
+		"sho.rt",
+		"localhost",
+		"203.0.113.7",
+		"go.example.com:8443",
+		"go.example.com/path",
+		"https://go.example.com",
+		"user@go.example.com",
+	}
+	for _, host := range tests {
+		var verr *domain.ValidationError
+		if _, err := s.Create(context.Background(), p, DomainInput{Host: host}); !errors.As(err, &verr) {
+			t.Errorf("Create(%q) error = %v, want validation error", host, err)
+		}
+	}
+}
+
+func TestDomainFirstVerificationWins(t *testing.T) {
+	resolver := fakeTXTResolver{}
+	s := newTestDomainService(resolver)
+	ctx := context.Background()
+	squatter := &domain.Principal{AccountId: 1, OrganizationId: 1, Role: domain.RoleOwner, Scopes: domain.Scopes{domain.ScopeAdmin}}
+	owner := &domain.Principal{AccountId: 2, OrganizationId: 2, Role: domain.RoleOwner, Scopes: domain.Scopes{domain.ScopeAdmin}}
+
+	claim, err := s.Create(ctx, squatter, DomainInput{Host: "go.example.com"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	owned, err := s.Create(ctx, owner, DomainInput{Host: "go.example.com"})
+	if err != nil {
+		t.Fatalf("Create() of a host claimed by another organization error = %v", err)
+	}
+
+	name, value := VerificationRecord(owned)
+	resolver[name] = []string{value}
+	if _, err := s.Verify(ctx, owner, owned.ID); err != nil {
+		t.Fatalf("Verify() by the owner error = %v", err)
+	}
+
+	// Only the token of the owner is published, and a verified host is taken for good
+	_, squatterToken := VerificationRecord(claim)
+	resolver[name] = append(resolver[name], squatterToken)
+	var verr *domain.ValidationError
+	if _, err := s.Verify(ctx, squatter, claim.ID); !errors.As(err, &verr) {
+		t.Errorf("Verify() of a host verified by another organization error = %v, want validation error", err)
+	}
+	third := &domain.Principal{AccountId: 3, OrganizationId: 3, Role: domain.RoleOwner, Scopes: domain.Scopes{domain.ScopeAdmin}}
+	if _, err := s.Create(ctx, third, DomainInput{Host: "go.example.com"}); !errors.As(err, &verr) {
+		t.Errorf("Create() of a verified host error = %v, want validation error", err)
+	}
+}
+
+func TestDomainResolveCacheIsBounded(t *testing.T) {
+	resolver := fakeTXTResolver{}
+	s := newTestDomainService(resolver)
+	repo := s.domains.(*fakeDomains)
+	p := &domain.Principal{AccountId: 1, OrganizationId: 1, Role: domain.RoleOwner, Scopes: domain.Scopes{domain.ScopeAdmin}}
+	ctx := context.Background()
+
+	d, err := s.Create(ctx, p, DomainInput{Host: "go.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, value := VerificationRecord(d)
+	resolver[name] = []string{value}
+	if _, err := s.Verify(ctx, p, d.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Hosts no domain could be stored as are not looked up
+	before := repo.lookups
+	for _, host := range []string{"localhost", "192.0.2.1", "bad_host!", "go.example.com:8080", "xn--bcher-kva.example", "Bücher.example"} {
+		if got, err := s.Resolve(ctx, host); got != nil || err != nil {
+			t.Errorf("Resolve(%q) = %v, %v", host, got, err)
+		}
+	}
+	if repo.lookups-before != 1 {
+		t.Errorf("looked up %d hosts, want only the punycode one", repo.lookups-before)
+	}
+
+	for i := range 50 {
+		if _, err := s.Resolve(ctx, fmt.Sprintf("random-%d.example.net", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, err := s.Resolve(ctx, "go.example.com"); err != nil || got == nil || got.ID != d.ID {
+		t.Errorf("Resolve() of the verified domain = %v, %v", got, err)
+	}
+	if n := len(s.hosts); n > 2 {
+		t.Errorf("remembered %d hosts, want at most 2", n)
+	}
+}
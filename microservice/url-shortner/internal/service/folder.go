@@ -0,0 +1,147 @@
+package service
+
+import (
+	"coding2fun.in/url-shortner/internal/audit"
+	"coding2fun.in/url-shortner/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const maxFolderNameLength = 64
+
+// FolderInput names a folder
+type FolderInput struct {
+	Name string `json:"name"`
+}
+
+// FolderService manages the folders grouping an organization's links
+type FolderService struct {
+	folders domain.FolderRepository
+	audit   *audit.Logger
+}
+
+func NewFolderService(folders domain.FolderRepository, audit *audit.Logger) *FolderService {
+	return &FolderService{folders: folders, audit: audit}
+}
+
+func (s *FolderService) Create(ctx context.Context, p *domain.Principal, in FolderInput) (*domain.Folder, error) {
+	if err := p.Authorize(domain.PermLinksWrite); err != nil {
+		return nil, err
+	}
+	name, err := folderName(in.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	folder := &domain.Folder{OrganizationId: p.OrganizationId, Name: name}
+	if err := s.folders.Create(ctx, folder); err != nil {
+		return nil, folderConflict(err)
+	}
+
+	s.audit.Record(ctx, audit.Entry{
+		AccountId:      p.AccountId,
+		OrganizationId: p.OrganizationId,
+		Action:         domain.AuditFolderCreate,
+		Resource:       folderResource(folder.ID),
+		After:          map[string]any{"name": folder.Name},
+	})
+	return folder, nil
+}
+
+func (s *FolderService) List(ctx context.Context, p *domain.Principal) ([]domain.Folder, error) {
+	if err := p.Authorize(domain.PermLinksRead); err != nil {
+		return nil, err
+	}
+	return s.folders.List(ctx, p.OrganizationId)
+}
+
+func (s *FolderService) Rename(ctx context.Context, p *domain.Principal, id uint, in FolderInput) (*domain.Folder, error) {
+	if err := p.Authorize(domain.PermLinksWrite); err != nil {
+		return nil, err
+	}
+	name, err := folderName(in.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := s.folders.Get(ctx, p.OrganizationId, id)
+	if err != nil {
+		return nil, err
+	}
+	if current.Name == name {
+		return current, nil
+	}
+	folder, err := s.folders.Rename(ctx, p.OrganizationId, id, name)
+	if err != nil {
+		return nil, folderConflict(err)
+	}
+
+	s.audit.Record(ctx, audit.Entry{
+		AccountId:      p.AccountId,
+		OrganizationId: p.OrganizationId,
+		Action:         domain.AuditFolderUpdate,
+		Resource:       folderResource(folder.ID),
+		Before:         map[string]any{"name": current.Name},
+		After:          map[string]any{"name": folder.Name},
+	})
+	return folder, nil
+}
+
+// Delete removes a folder, its links are kept outside of any folder
+func (s *FolderService) Delete(ctx context.Context, p *domain.Principal, id uint) error {
+	if err := p.Authorize(domain.PermLinksWrite); err != nil {
+		return err
+	}
+	folder, err := s.folders.Delete(ctx, p.OrganizationId, id)
+	if err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, audit.Entry{
+		AccountId:      p.AccountId,
+		OrganizationId: p.OrganizationId,
+		Action:         domain.AuditFolderDelete,
+		Resource:       folderResource(folder.ID),
+		Before:         map[string]any{"name": folder.Name},
+	})
+	return nil
+}
+
+// check adds a field error unless the id is 0 or a folder of the principal's organization
+func (s *FolderService) check(ctx context.Context, p *domain.Principal, id uint, verr *domain.ValidationError) error {
+	if id == 0 {
+		return nil
+	}
+	_, err := s.folders.Get(ctx, p.OrganizationId, id)
+	if errors.Is(err, domain.ErrNotFound) {
+		verr.Add("folderId", "is not a folder of this organization")
+		return nil
+	}
+	return err
+}
+
+func folderName(raw string) (string, error) {
+	name := strings.TrimSpace(raw)
+	switch {
+	case name == "":
+		return "", domain.NewValidationError("name", "is required")
+	case utf8.RuneCountInString(name) > maxFolderNameLength:
+		return "", domain.NewValidationError("name", fmt.Sprintf("must be at most %d characters", maxFolderNameLength))
+	}
+	return name, nil
+}
+
+func folderConflict(err error) error {
+	if errors.Is(err, domain.ErrConflict) {
+		return fmt.Errorf("%w: %w", domain.NewValidationError("name", "is already used by another folder"), err)
+	}
+	return err
+}
+
+func folderResource(id uint) string {
+	return "folder:" + strconv.FormatUint(uint64(id), 10)
+}
@@ -0,0 +1,964 @@
+package service
+
+import (
+	"coding2fun.in/url-shortner/internal/audit"
+	"coding2fun.in/url-shortner/internal/config"
+	"coding2fun.in/url-shortner/internal/domain"
+	"coding2fun.in/url-shortner/internal/log"
+	"coding2fun.in/url-shortner/internal/safeurl"
+	"coding2fun.in/url-shortner/internal/threat"
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	maxURLLength   = 2048
+	maxTags        = 10
+	maxTagLength   = 32
+	maxTitleLength = 200
+	maxUTMLength   = 200
+	maxRules       = 20
+	maxRuleValues  = 50
+	maxVariants    = 10
+	maxWeight      = 1000
+
+	minSearchLength = 2
+	maxSearchLength = 200
+	maxCodeAttempts = 3
+
+	minPasswordLength = 4
+	// bcrypt ignores everything past 72 bytes
+	maxPasswordLength = 72
+)
+
+// redirectTypes are the redirect statuses a link may use
+var redirectTypes = map[int]bool{
+	http.StatusMovedPermanently:  true,
+	http.StatusFound:             true,
+	http.StatusTemporaryRedirect: true,
+	http.StatusPermanentRedirect: true,
+}
+
+var slugPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{3,64}$`)
+
+// reservedSlugs collide with the service's own top level routes
+var reservedSlugs = map[string]bool{
+	"docs":   true,
+	"health": true,
+	"v1":     true,
+}
+
+// LinkInput is a link creation request as supplied by a client
+type LinkInput struct {
+	URL       string   `json:"url"`
+	Slug      string   `json:"slug"`
+	ExpiresAt string   `json:"expiresAt"`
+	Tags      []string `json:"tags"`
+	Password  string   `json:"password"`
+	MaxClicks int64    `json:"maxClicks"`
+	// OneTime is a shorthand for a click limit of one
+	OneTime      bool `json:"oneTime"`
+	RedirectType int  `json:"redirectType"`
+	// Domain is a verified custom domain of the account, empty for the shared domain
+	Domain   string     `json:"domain"`
+	Title    string     `json:"title"`
+	FolderId uint       `json:"folderId"`
+	UTM      domain.UTM `json:"utm"`
+	// QueryPassthrough is shortLink or destination to forward the query
+	// string of the short url, empty to drop it
+	QueryPassthrough string `json:"queryPassthrough"`
+	// Rules redirect matching visitors to their own url, the first match wins
+	Rules []domain.RedirectRule `json:"rules"`
+	// Variants split the visitors no rule matched over weighted urls
+	Variants []domain.LinkVariant `json:"variants"`
+}
+
+// LinkPatch is a partial edit of a link, absent fields are left unchanged and
+// an empty expiresAt removes the expiry
+type LinkPatch struct {
+	URL          *string `json:"url"`
+	ExpiresAt    *string `json:"expiresAt"`
+	IsActive     *bool   `json:"isActive"`
+	RedirectType *int    `json:"redirectType"`
+	Title        *string `json:"title"`
+	// FolderId moves the link into a folder, 0 takes it out of its folder
+	FolderId *uint `json:"folderId"`
+	// Tags replaces the tags of the link
+	Tags *[]string `json:"tags"`
+	// UTM replaces the UTM parameters of the link
+	UTM              *domain.UTM `json:"utm"`
+	QueryPassthrough *string     `json:"queryPassthrough"`
+	// Rules replaces the redirect rules of the link
+	Rules *[]domain.RedirectRule `json:"rules"`
+	// Variants replaces the A/B split, variants keeping their url keep their clicks
+	Variants *[]domain.LinkVariant `json:"variants"`
+}
+
+type LinkService struct {
+	urls      domain.ShortURLRepository
+	domains   *DomainService
+	folders   *FolderService
+	validator *safeurl.Validator
+	threats   *threat.Checker
+	audit     *audit.Logger
+	cfg       config.LinksConfig
+}
+
+func NewLinkService(urls domain.ShortURLRepository, domains *DomainService, folders *FolderService, validator *safeurl.Validator, threats *threat.Checker, audit *audit.Logger, cfg config.LinksConfig) *LinkService {
+	return &LinkService{urls: urls, domains: domains, folders: folders, validator: validator, threats: threats, audit: audit, cfg: cfg}
+}
+
+// ShortURL returns the public short url of a link
+func (s *LinkService) ShortURL(ctx context.Context, link *domain.ShortUrl) (string, error) {
+	return s.domains.ShortURL(ctx, link)
+}
+
+// Get returns a link owned by the principal's organization, host names its
+// domain and is empty for the shared domain
+func (s *LinkService) Get(ctx context.Context, p *domain.Principal, host, code string) (*domain.ShortUrl, error) {
+	domainId, err := s.domains.ID(ctx, p, host)
+	if err != nil {
+		return nil, err
+	}
+	return s.urls.GetURL(ctx, p.OrganizationId, domainId, code)
+}
+
+// List returns a page of the links of the principal's organization matching
+// the filter, host limits it to one domain and an empty host lists them all
+func (s *LinkService) List(ctx context.Context, p *domain.Principal, host string, filter domain.LinkFilter) ([]domain.ShortUrl, error) {
+	if err := p.Authorize(domain.PermLinksRead); err != nil {
+		return nil, err
+	}
+
+	ids, err := s.listedDomains(ctx, p, host)
+	if err != nil || (ids != nil && len(ids) == 0) {
+		return nil, err
+	}
+	filter.DomainIds = ids
+
+	filter.OrganizationId = p.OrganizationId
+	filter.Tag = strings.ToLower(strings.TrimSpace(filter.Tag))
+	filter.DestinationHost = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(filter.DestinationHost)), ".")
+	if filter.Now.IsZero() {
+		filter.Now = time.Now()
+	}
+	return s.urls.ListURLs(ctx, filter)
+}
+
+// Search finds the links of the principal's organization whose code,
+// destination, title or tags match the query, best matches first
+func (s *LinkService) Search(ctx context.Context, p *domain.Principal, query string, limit int) ([]domain.ShortUrl, error) {
+	if err := p.Authorize(domain.PermLinksRead); err != nil {
+		return nil, err
+	}
+	query = strings.Join(strings.Fields(query), " ")
+	switch {
+	case utf8.RuneCountInString(query) < minSearchLength:
+		return nil, domain.NewValidationError("q", fmt.Sprintf("must be at least %d characters", minSearchLength))
+	case utf8.RuneCountInString(query) > maxSearchLength:
+		return nil, domain.NewValidationError("q", fmt.Sprintf("must be at most %d characters", maxSearchLength))
+	}
+
+	ids, err := s.listedDomains(ctx, p, "")
+	if err != nil || (ids != nil && len(ids) == 0) {
+		return nil, err
+	}
+	return s.urls.SearchURLs(ctx, domain.LinkSearch{OrganizationId: p.OrganizationId, DomainIds: ids, Query: query, Limit: limit})
+}
+
+// Tags returns the tags used on the links of the principal's organization
+func (s *LinkService) Tags(ctx context.Context, p *domain.Principal) ([]domain.TagCount, error) {
+	if err := p.Authorize(domain.PermLinksRead); err != nil {
+		return nil, err
+	}
+	return s.urls.ListTags(ctx, p.OrganizationId)
+}
+
+// listedDomains returns the domains a listing covers: the one named by host,
+// those the caller's key is restricted to, or nil for every domain
+func (s *LinkService) listedDomains(ctx context.Context, p *domain.Principal, host string) ([]uint, error) {
+	if strings.TrimSpace(host) == "" {
+		return s.domains.AllowedIDs(ctx, p)
+	}
+	domainId, err := s.domains.ID(ctx, p, host)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %w", domain.NewValidationError("domain", "is not a domain of this organization"), err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []uint{domainId}, nil
+}
+
+// Unlock reports whether the password opens a protected link
+func (s *LinkService) Unlock(link *domain.ShortUrl, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(password)) == nil
+}
+
+// Create validates the input and stores a new link. A generated code that
+// collides with an existing one is regenerated.
+func (s *LinkService) Create(ctx context.Context, p *domain.Principal, in LinkInput) (*domain.ShortUrl, error) {
+	if err := p.Authorize(domain.PermLinksWrite); err != nil {
+		return nil, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		link, err := s.build(ctx, p, in)
+		if err != nil {
+			return nil, err
+		}
+
+		err = s.urls.CreateURL(ctx, link)
+		if err == nil {
+			s.audit.Record(ctx, createdEntry(link))
+			return link, nil
+		}
+		if !errors.Is(err, domain.ErrConflict) || link.CustomSlug != nil || attempt == maxCodeAttempts {
+			return nil, slugConflict(err, link)
+		}
+	}
+}
+
+// Update edits a link of the principal's organization, the replaced state is kept as a version
+func (s *LinkService) Update(ctx context.Context, p *domain.Principal, host, code string, patch LinkPatch) (*domain.ShortUrl, error) {
+	if err := p.Authorize(domain.PermLinksWrite); err != nil {
+		return nil, err
+	}
+
+	current, err := s.Get(ctx, p, host, code)
+	if err != nil {
+		return nil, err
+	}
+
+	verr := &domain.ValidationError{}
+	var changes domain.LinkChanges
+
+	if patch.URL != nil {
+		destination, err := s.destination(ctx, p, *patch.URL, verr)
+		if err != nil {
+			return nil, err
+		}
+		changes.OriginalURL = &destination
+	}
+
+	if patch.ExpiresAt != nil {
+		var expiresAt time.Time
+		if expiry := strings.TrimSpace(*patch.ExpiresAt); expiry != "" {
+			expiresAt, err = time.Parse(time.RFC3339, expiry)
+			if err != nil {
+				verr.Add("expiresAt", "must be an RFC 3339 timestamp")
+			} else if !expiresAt.After(time.Now()) {
+				verr.Add("expiresAt", "must be in the future")
+			}
+		}
+		changes.ExpiresAt = &expiresAt
+	}
+
+	if patch.RedirectType != nil && !redirectTypes[*patch.RedirectType] {
+		verr.Add("redirectType", "must be 301, 302, 307 or 308")
+	}
+	changes.RedirectType = patch.RedirectType
+	changes.IsActive = patch.IsActive
+
+	if patch.Title != nil {
+		title := linkTitle(*patch.Title, verr)
+		changes.Title = &title
+	}
+	if patch.FolderId != nil {
+		if err := s.folders.check(ctx, p, *patch.FolderId, verr); err != nil {
+			return nil, err
+		}
+		changes.FolderId = patch.FolderId
+	}
+	if patch.Tags != nil {
+		tags := buildTags(*patch.Tags, verr)
+		changes.Tags = &tags
+	}
+	if patch.UTM != nil {
+		utm := linkUTM(*patch.UTM, verr)
+		changes.UTM = &utm
+	}
+	if patch.QueryPassthrough != nil {
+		passthrough := queryPassthrough(*patch.QueryPassthrough, verr)
+		changes.QueryPassthrough = &passthrough
+	}
+	if patch.Rules != nil {
+		rules, err := s.rules(ctx, p, *patch.Rules, verr)
+		if err != nil {
+			return nil, err
+		}
+		changes.Rules = &rules
+	}
+	if patch.Variants != nil {
+		variants, err := s.variants(ctx, p, *patch.Variants, verr)
+		if err != nil {
+			return nil, err
+		}
+		changes.Variants = &variants
+	}
+
+	s.checkActivation(current, changes, verr)
+	if err := verr.OrNil(); err != nil {
+		return nil, err
+	}
+	return s.update(ctx, p, current, changes, domain.AuditLinkUpdate)
+}
+
+// update applies the changes and audits the fields that changed
+func (s *LinkService) update(ctx context.Context, p *domain.Principal, current *domain.ShortUrl, changes domain.LinkChanges, action string) (*domain.ShortUrl, error) {
+	link, replaced, err := s.urls.UpdateURL(ctx, p.OrganizationId, current.DomainId, current.ShortCode, changes, p.APIKeyId)
+	if err != nil {
+		return nil, err
+	}
+	if replaced != nil {
+		before, after := audit.Diff(linkState(current), linkState(link))
+		s.audit.Record(ctx, audit.Entry{
+			AccountId:      link.AccountId,
+			OrganizationId: link.OrganizationId,
+			Action:         action,
+			Resource:       linkResource(link.ShortCode),
+			Before:         before,
+			After:          after,
+		})
+	}
+	return link, nil
+}
+
+// History returns the prior versions of a link, newest first
+func (s *LinkService) History(ctx context.Context, p *domain.Principal, host, code string) (*domain.ShortUrl, []domain.LinkVersion, error) {
+	link, err := s.Get(ctx, p, host, code)
+	if err != nil {
+		return nil, nil, err
+	}
+	versions, err := s.urls.ListVersions(ctx, p.OrganizationId, link.DomainId, code)
+	if err != nil {
+		return nil, nil, err
+	}
+	return link, versions, nil
+}
+
+// Revert restores the fields of a prior version as a new version of the link.
+// Versions saved without a snapshot only restore their own columns.
+func (s *LinkService) Revert(ctx context.Context, p *domain.Principal, host, code string, version int) (*domain.ShortUrl, error) {
+	if err := p.Authorize(domain.PermLinksWrite); err != nil {
+		return nil, err
+	}
+
+	current, err := s.Get(ctx, p, host, code)
+	if err != nil {
+		return nil, err
+	}
+
+	prior, err := s.urls.GetVersion(ctx, p.OrganizationId, current.DomainId, code, version)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %w", domain.NewValidationError("version", "does not exist"), err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The version may predate the destination checks, it has to pass them as
+	// a new destination would
+	urlErr := &domain.ValidationError{}
+	destination, err := s.destination(ctx, p, prior.OriginalURL, urlErr)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := domain.LinkChanges{
+		OriginalURL:  &destination,
+		ExpiresAt:    &prior.ExpiresAt,
+		IsActive:     &prior.IsActive,
+		RedirectType: &prior.RedirectType,
+	}
+	snapshotErr := &domain.ValidationError{}
+	if err := s.restoreSnapshot(ctx, p, prior.Snapshot, &changes, snapshotErr); err != nil {
+		return nil, err
+	}
+
+	verr := &domain.ValidationError{}
+	for _, f := range urlErr.Fields {
+		verr.Add("version", "its destination "+f.Message)
+	}
+	for _, f := range snapshotErr.Fields {
+		verr.Add("version", "its "+f.Field+" "+f.Message)
+	}
+	s.checkActivation(current, changes, verr)
+	if err := verr.OrNil(); err != nil {
+		return nil, err
+	}
+	return s.update(ctx, p, current, changes, domain.AuditLinkRevert)
+}
+
+// restoreSnapshot adds the fields of a version's snapshot to the changes of a
+// revert. They are checked like a new edit, the folder may be gone and the
+// rules and variants lead to destinations that may be blocked by now.
+func (s *LinkService) restoreSnapshot(ctx context.Context, p *domain.Principal, snapshot *domain.LinkSnapshot, changes *domain.LinkChanges, verr *domain.ValidationError) error {
+	if snapshot == nil {
+		return nil
+	}
+	if err := s.folders.check(ctx, p, snapshot.FolderId, verr); err != nil {
+		return err
+	}
+	rules, err := s.rules(ctx, p, snapshot.Rules, verr)
+	if err != nil {
+		return err
+	}
+	variants, err := s.variants(ctx, p, snapshot.Variants, verr)
+	if err != nil {
+		return err
+	}
+	tags := buildTags(snapshot.Tags, verr)
+
+	changes.Title = &snapshot.Title
+	changes.FolderId = &snapshot.FolderId
+	changes.Tags = &tags
+	changes.UTM = &snapshot.UTM
+	changes.QueryPassthrough = &snapshot.QueryPassthrough
+	changes.Rules = &rules
+	changes.Variants = &variants
+	return nil
+}
+
+// checkActivation refuses to reactivate a link the service disabled for a reason that still holds
+func (s *LinkService) checkActivation(current *domain.ShortUrl, changes domain.LinkChanges, verr *domain.ValidationError) {
+	if changes.IsActive == nil || !*changes.IsActive || current.IsActive {
+		return
+	}
+	if current.MaxClicks > 0 && current.ConsumedClicks >= current.MaxClicks {
+		verr.Add("isActive", "the link reached its click limit")
+	}
+	if changes.OriginalURL == nil {
+		if _, listed := s.threats.Check(current.OriginalURL); listed {
+			verr.Add("isActive", "the destination is flagged as phishing or malware")
+		}
+	}
+}
+
+// CreateBatch creates the links in batched transactions and reports the outcome of every row
+func (s *LinkService) CreateBatch(ctx context.Context, p *domain.Principal, inputs []LinkInput) []domain.BulkRowResult {
+	results := make([]domain.BulkRowResult, len(inputs))
+	for i := range results {
+		results[i].Row = i + 1
+	}
+
+	batchSize := s.cfg.BulkBatchSize
+	if batchSize <= 0 {
+		batchSize = len(inputs)
+	}
+	for start := 0; start < len(inputs); start += batchSize {
+		end := min(start+batchSize, len(inputs))
+		s.createChunk(ctx, p, inputs[start:end], results[start:end])
+	}
+	return results
+}
+
+func (s *LinkService) createChunk(ctx context.Context, p *domain.Principal, inputs []LinkInput, results []domain.BulkRowResult) {
+	var pending []int
+	for i := range inputs {
+		pending = append(pending, i)
+	}
+
+	for attempt := 1; len(pending) > 0; attempt++ {
+		links := make([]*domain.ShortUrl, 0, len(pending))
+		rows := make([]int, 0, len(pending))
+		for _, i := range pending {
+			link, err := s.build(ctx, p, inputs[i])
+			if err != nil {
+				results[i].Error = rowError(err)
+				continue
+			}
+			links = append(links, link)
+			rows = append(rows, i)
+		}
+		pending = nil
+
+		if len(links) == 0 {
+			return
+		}
+
+		rowErrs, err := s.urls.CreateURLs(ctx, links)
+		if err != nil {
+			log.Error("Failed to create link batch", zap.Int("rows", len(links)), zap.Error(err))
+			for _, i := range rows {
+				results[i].Error = rowError(err)
+			}
+			return
+		}
+
+		var created []audit.Entry
+		for j, rowErr := range rowErrs {
+			i := rows[j]
+			switch {
+			case rowErr == nil:
+				results[i].ShortCode = links[j].ShortCode
+				created = append(created, createdEntry(links[j]))
+			case errors.Is(rowErr, domain.ErrConflict) && links[j].CustomSlug == nil && attempt < maxCodeAttempts:
+				pending = append(pending, i)
+			default:
+				results[i].Error = rowError(slugConflict(rowErr, links[j]))
+			}
+		}
+		s.audit.Record(ctx, created...)
+	}
+}
+
+// build validates the input and turns it into a new link owned by the principal
+func (s *LinkService) build(ctx context.Context, p *domain.Principal, in LinkInput) (*domain.ShortUrl, error) {
+	verr := &domain.ValidationError{}
+
+	destination, err := s.destination(ctx, p, in.URL, verr)
+	if err != nil {
+		return nil, err
+	}
+
+	link := &domain.ShortUrl{
+		AccountId:      p.AccountId,
+		OrganizationId: p.OrganizationId,
+		APIKeyId:       p.APIKeyId,
+		OriginalURL:    destination,
+		IsActive:       true,
+		RedirectType:   http.StatusFound,
+	}
+
+	domainId, err := s.domains.VerifiedID(ctx, p, in.Domain)
+	var domainErr *domain.ValidationError
+	if errors.As(err, &domainErr) {
+		verr.Fields = append(verr.Fields, domainErr.Fields...)
+	} else if err != nil {
+		return nil, err
+	}
+	link.DomainId = domainId
+
+	if slug := strings.TrimSpace(in.Slug); slug != "" {
+		if !slugPattern.MatchString(slug) {
+			verr.Add("slug", "must be 3 to 64 letters, digits, '-' or '_'")
+		} else if reservedSlugs[strings.ToLower(slug)] {
+			verr.Add("slug", "is reserved")
+		}
+		link.ShortCode = slug
+		link.CustomSlug = &slug
+	} else {
+		code, err := generateCode(s.cfg.CodeLength)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate short code: %w", err)
+		}
+		link.ShortCode = code
+	}
+
+	if expiry := strings.TrimSpace(in.ExpiresAt); expiry != "" {
+		expiresAt, err := time.Parse(time.RFC3339, expiry)
+		if err != nil {
+			verr.Add("expiresAt", "must be an RFC 3339 timestamp")
+		} else if !expiresAt.After(time.Now()) {
+			verr.Add("expiresAt", "must be in the future")
+		}
+		link.ExpiresAt = expiresAt
+	}
+
+	link.Tags = buildTags(in.Tags, verr)
+	link.Title = linkTitle(in.Title, verr)
+	if err := s.folders.check(ctx, p, in.FolderId, verr); err != nil {
+		return nil, err
+	}
+	link.FolderId = in.FolderId
+	link.UTM = linkUTM(in.UTM, verr)
+	link.QueryPassthrough = queryPassthrough(in.QueryPassthrough, verr)
+	if link.Rules, err = s.rules(ctx, p, in.Rules, verr); err != nil {
+		return nil, err
+	}
+	if link.Variants, err = s.variants(ctx, p, in.Variants, verr); err != nil {
+		return nil, err
+	}
+
+	if in.Password != "" {
+		if len(in.Password) < minPasswordLength || len(in.Password) > maxPasswordLength {
+			verr.Add("password", fmt.Sprintf("must be %d to %d characters", minPasswordLength, maxPasswordLength))
+		} else {
+			hash, err := bcrypt.GenerateFromPassword([]byte(in.Password), bcrypt.DefaultCost)
+			if err != nil {
+				return nil, fmt.Errorf("failed to hash link password: %w", err)
+			}
+			link.PasswordHash = string(hash)
+		}
+	}
+
+	if in.RedirectType != 0 {
+		if !redirectTypes[in.RedirectType] {
+			verr.Add("redirectType", "must be 301, 302, 307 or 308")
+		}
+		link.RedirectType = in.RedirectType
+	}
+
+	switch {
+	case in.MaxClicks < 0:
+		verr.Add("maxClicks", "must not be negative")
+	case in.OneTime && in.MaxClicks > 1:
+		verr.Add("oneTime", "cannot be combined with maxClicks above 1")
+	case in.OneTime:
+		link.MaxClicks = 1
+	default:
+		link.MaxClicks = in.MaxClicks
+	}
+
+	if err := verr.OrNil(); err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+// destination validates and normalizes a destination url, field errors are added to verr
+func (s *LinkService) destination(ctx context.Context, p *domain.Principal, raw string, verr *domain.ValidationError) (string, error) {
+	destination := strings.TrimSpace(raw)
+	if destination == "" {
+		verr.Add("url", "is required")
+	} else if len(destination) > maxURLLength {
+		verr.Add("url", fmt.Sprintf("must be at most %d characters", maxURLLength))
+	} else if normalized, err := s.validator.Validate(ctx, destination); err != nil {
+		var urlErr *domain.ValidationError
+		if !errors.As(err, &urlErr) {
+			return "", err
+		}
+		verr.Fields = append(verr.Fields, urlErr.Fields...)
+	} else if reason, listed := s.threats.Check(normalized); listed {
+		log.Warn("Rejected listed destination", zap.Uint("accountId", p.AccountId), zap.String("list", reason))
+		verr.Add("url", "is flagged as phishing or malware")
+	} else {
+		destination = normalized
+	}
+	return destination, nil
+}
+
+// rules validates and normalizes redirect rules, field errors are added to verr
+// under rules[i]
+func (s *LinkService) rules(ctx context.Context, p *domain.Principal, in []domain.RedirectRule, verr *domain.ValidationError) ([]domain.RedirectRule, error) {
+	if len(in) > maxRules {
+		verr.Add("rules", fmt.Sprintf("at most %d rules are allowed", maxRules))
+		return nil, nil
+	}
+
+	rules := make([]domain.RedirectRule, 0, len(in))
+	for i, r := range in {
+		field := fmt.Sprintf("rules[%d]", i)
+		rule := domain.RedirectRule{
+			Position:   i,
+			Countries:  ruleValues(r.Countries, strings.ToUpper),
+			OS:         ruleValues(r.OS, strings.ToLower),
+			Devices:    ruleValues(r.Devices, strings.ToLower),
+			Languages:  ruleValues(r.Languages, strings.ToLower),
+			Referrers:  ruleValues(r.Referrers, referrerHost),
+			From:       r.From,
+			Until:      r.Until,
+			DailyFrom:  strings.TrimSpace(r.DailyFrom),
+			DailyUntil: strings.TrimSpace(r.DailyUntil),
+			TimeZone:   strings.TrimSpace(r.TimeZone),
+		}
+
+		urlErr := &domain.ValidationError{}
+		destination, err := s.destination(ctx, p, r.Destination, urlErr)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range urlErr.Fields {
+			verr.Add(field+"."+f.Field, f.Message)
+		}
+		rule.Destination = destination
+
+		for _, values := range []struct {
+			name    string
+			values  []string
+			allowed func(string) bool
+			message string
+		}{
+			{"countries", rule.Countries, countryPattern.MatchString, "must be ISO 3166 country codes"},
+			{"os", rule.OS, allowedIn(domain.RuleOS), "must be one of " + strings.Join(domain.RuleOS, ", ")},
+			{"devices", rule.Devices, allowedIn(domain.RuleDevices), "must be one of " + strings.Join(domain.RuleDevices, ", ")},
+			{"languages", rule.Languages, languagePattern.MatchString, "must be language tags such as en or pt-br"},
+			{"referrers", rule.Referrers, hostPattern.MatchString, "must be host names"},
+		} {
+			if len(values.values) > maxRuleValues {
+				verr.Add(field+"."+values.name, fmt.Sprintf("must have at most %d entries", maxRuleValues))
+			} else if !all(values.values, values.allowed) {
+				verr.Add(field+"."+values.name, values.message)
+			}
+		}
+
+		if rule.From != nil && rule.Until != nil && !rule.Until.After(*rule.From) {
+			verr.Add(field+".until", "must be after from")
+		}
+		switch {
+		case (rule.DailyFrom == "") != (rule.DailyUntil == ""):
+			verr.Add(field+".dailyFrom", "must be set together with dailyUntil")
+		case rule.DailyFrom == "":
+		case domain.ClockMinutes(rule.DailyFrom) < 0:
+			verr.Add(field+".dailyFrom", "must be an HH:MM clock time")
+		case domain.ClockMinutes(rule.DailyUntil) < 0:
+			verr.Add(field+".dailyUntil", "must be an HH:MM clock time")
+		case rule.DailyFrom == rule.DailyUntil:
+			verr.Add(field+".dailyUntil", "must differ from dailyFrom")
+		}
+		if rule.TimeZone != "" {
+			if _, err := time.LoadLocation(rule.TimeZone); err != nil {
+				verr.Add(field+".timeZone", "is not a known time zone")
+			}
+		}
+
+		if len(rule.Countries)+len(rule.OS)+len(rule.Devices)+len(rule.Languages)+len(rule.Referrers) == 0 &&
+			rule.From == nil && rule.Until == nil && rule.DailyFrom == "" {
+			verr.Add(field, "must have at least one condition")
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// variants validates an A/B split, field errors are added to verr under
+// variants[i]. A split needs two variants at least and no url twice.
+func (s *LinkService) variants(ctx context.Context, p *domain.Principal, in []domain.LinkVariant, verr *domain.ValidationError) ([]domain.LinkVariant, error) {
+	switch {
+	case len(in) == 0:
+		return nil, nil
+	case len(in) == 1:
+		verr.Add("variants", "must have at least 2 variants, or none")
+		return nil, nil
+	case len(in) > maxVariants:
+		verr.Add("variants", fmt.Sprintf("at most %d variants are allowed", maxVariants))
+		return nil, nil
+	}
+
+	variants := make([]domain.LinkVariant, 0, len(in))
+	seen := make(map[string]bool, len(in))
+	for i, v := range in {
+		field := fmt.Sprintf("variants[%d]", i)
+
+		urlErr := &domain.ValidationError{}
+		destination, err := s.destination(ctx, p, v.Destination, urlErr)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range urlErr.Fields {
+			verr.Add(field+"."+f.Field, f.Message)
+		}
+		if len(urlErr.Fields) == 0 && seen[destination] {
+			verr.Add(field+".url", "is already a variant")
+		}
+		seen[destination] = true
+
+		if v.Weight < 1 || v.Weight > maxWeight {
+			verr.Add(field+".weight", fmt.Sprintf("must be between 1 and %d", maxWeight))
+		}
+		variants = append(variants, domain.LinkVariant{Position: i, Destination: destination, Weight: v.Weight})
+	}
+	return variants, nil
+}
+
+// Stats returns the analytics of a link with the clicks of its A/B variants
+func (s *LinkService) Stats(ctx context.Context, p *domain.Principal, host, code string) (*domain.URLAnalytics, error) {
+	if err := p.Authorize(domain.PermAnalyticsRead); err != nil {
+		return nil, err
+	}
+	domainId, err := s.domains.ID(ctx, p, host)
+	if err != nil {
+		return nil, err
+	}
+	return s.urls.GetStats(ctx, p.OrganizationId, domainId, code)
+}
+
+// auditedLink is the audited state of a link, secrets like the password hash stay out of it
+type auditedLink struct {
+	OriginalURL      string                `json:"originalUrl"`
+	ExpiresAt        *time.Time            `json:"expiresAt"`
+	IsActive         bool                  `json:"isActive"`
+	RedirectType     int                   `json:"redirectType"`
+	Title            string                `json:"title,omitempty"`
+	FolderId         uint                  `json:"folderId,omitempty"`
+	Tags             []string              `json:"tags,omitempty"`
+	UTM              *domain.UTM           `json:"utm,omitempty"`
+	QueryPassthrough string                `json:"queryPassthrough,omitempty"`
+	Rules            []domain.RedirectRule `json:"rules,omitempty"`
+	Variants         []domain.LinkVariant  `json:"variants,omitempty"`
+}
+
+func linkState(link *domain.ShortUrl) auditedLink {
+	return auditedLink{
+		OriginalURL:      link.OriginalURL,
+		ExpiresAt:        optionalTime(link.ExpiresAt),
+		IsActive:         link.IsActive,
+		RedirectType:     link.RedirectType,
+		Title:            link.Title,
+		FolderId:         link.FolderId,
+		Tags:             tagNames(link.Tags),
+		UTM:              optionalUTM(link.UTM),
+		QueryPassthrough: string(link.QueryPassthrough),
+		Rules:            link.Rules,
+		Variants:         link.Variants,
+	}
+}
+
+func createdEntry(link *domain.ShortUrl) audit.Entry {
+	return audit.Entry{
+		AccountId:      link.AccountId,
+		OrganizationId: link.OrganizationId,
+		Action:         domain.AuditLinkCreate,
+		Resource:       linkResource(link.ShortCode),
+		After: map[string]any{
+			"link":              linkState(link),
+			"maxClicks":         link.MaxClicks,
+			"passwordProtected": link.Protected(),
+		},
+	}
+}
+
+func linkResource(code string) string {
+	return "link:" + code
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func optionalUTM(utm domain.UTM) *domain.UTM {
+	if utm.IsZero() {
+		return nil
+	}
+	return &utm
+}
+
+func tagNames(tags []domain.Tag) []string {
+	names := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// linkTitle trims a title, field errors are added to verr
+func linkTitle(raw string, verr *domain.ValidationError) string {
+	title := strings.TrimSpace(raw)
+	if utf8.RuneCountInString(title) > maxTitleLength {
+		verr.Add("title", fmt.Sprintf("must be at most %d characters", maxTitleLength))
+	}
+	return title
+}
+
+// linkUTM trims the UTM values of a link, field errors are added to verr
+func linkUTM(raw domain.UTM, verr *domain.ValidationError) domain.UTM {
+	utm := domain.UTM{
+		Source:   strings.TrimSpace(raw.Source),
+		Medium:   strings.TrimSpace(raw.Medium),
+		Campaign: strings.TrimSpace(raw.Campaign),
+		Term:     strings.TrimSpace(raw.Term),
+		Content:  strings.TrimSpace(raw.Content),
+	}
+	for _, v := range []struct{ field, value string }{
+		{"utm.source", utm.Source}, {"utm.medium", utm.Medium}, {"utm.campaign", utm.Campaign},
+		{"utm.term", utm.Term}, {"utm.content", utm.Content},
+	} {
+		if utf8.RuneCountInString(v.value) > maxUTMLength {
+			verr.Add(v.field, fmt.Sprintf("must be at most %d characters", maxUTMLength))
+		}
+	}
+	return utm
+}
+
+func queryPassthrough(raw string, verr *domain.ValidationError) domain.QueryPassthrough {
+	passthrough := domain.QueryPassthrough(strings.TrimSpace(raw))
+	if !passthrough.Valid() {
+		verr.Add("queryPassthrough", "must be shortLink, destination or empty")
+	}
+	return passthrough
+}
+
+var (
+	countryPattern  = regexp.MustCompile(`^[A-Z]{2}$`)
+	languagePattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+	hostPattern     = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*$`)
+)
+
+// ruleValues normalizes the values of a rule condition, dropping empty and
+// repeated ones. A condition without values is nil.
+func ruleValues(values []string, normalize func(string) string) []string {
+	var out []string
+	for _, value := range values {
+		value = normalize(strings.TrimSpace(value))
+		if value != "" && !slices.Contains(out, value) {
+			out = append(out, value)
+		}
+	}
+	return out
+}
+
+// referrerHost accepts a referrer as a host name or a url
+func referrerHost(value string) string {
+	if strings.Contains(value, "://") {
+		return domain.HostOf(value)
+	}
+	return strings.TrimSuffix(strings.ToLower(value), ".")
+}
+
+func allowedIn(allowed []string) func(string) bool {
+	return func(value string) bool { return slices.Contains(allowed, value) }
+}
+
+func all(values []string, ok func(string) bool) bool {
+	for _, value := range values {
+		if !ok(value) {
+			return false
+		}
+	}
+	return true
+}
+
+func buildTags(names []string, verr *domain.ValidationError) []domain.Tag {
+	seen := make(map[string]bool, len(names))
+	tags := make([]domain.Tag, 0, len(names))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		if len(name) > maxTagLength {
+			verr.Add("tags", fmt.Sprintf("tag %q is longer than %d characters", name, maxTagLength))
+			continue
+		}
+		seen[name] = true
+		tags = append(tags, domain.Tag{Name: name})
+	}
+	if len(tags) > maxTags {
+		verr.Add("tags", fmt.Sprintf("at most %d tags are allowed", maxTags))
+	}
+	return tags
+}
+
+// slugConflict reports a taken custom slug as a field error
+func slugConflict(err error, link *domain.ShortUrl) error {
+	if errors.Is(err, domain.ErrConflict) && link.CustomSlug != nil {
+		return fmt.Errorf("%w: %w", domain.NewValidationError("slug", "is already taken"), err)
+	}
+	return err
+}
+
+func rowError(err error) *domain.RowError {
+	code := domain.ErrorCode(err)
+	rowErr := &domain.RowError{Code: code, Message: domain.ErrorMessage(code)}
+
+	var verr *domain.ValidationError
+	if errors.As(err, &verr) {
+		rowErr.Details = verr.Fields
+	}
+	return rowErr
+}
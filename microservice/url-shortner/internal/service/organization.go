@@ -0,0 +1,268 @@
+package service
+
+import (
+	"coding2fun.in/url-shortner/internal/audit"
+	"coding2fun.in/url-shortner/internal/domain"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	invitationTTL       = 7 * 24 * time.Hour
+	invitationPrefix    = "usi_"
+	maxOrganizationName = 100
+)
+
+// InvitationInput invites an email address to the caller's organization
+type InvitationInput struct {
+	Email string      `json:"email"`
+	Role  domain.Role `json:"role"`
+}
+
+// OrganizationService manages organizations, their members and invitations.
+// Owners are the only ones who may hand out or take away the owner role.
+type OrganizationService struct {
+	orgs  domain.OrganizationRepository
+	audit *audit.Logger
+}
+
+func NewOrganizationService(orgs domain.OrganizationRepository, audit *audit.Logger) *OrganizationService {
+	return &OrganizationService{orgs: orgs, audit: audit}
+}
+
+// Create starts an organization owned by the caller's account
+func (s *OrganizationService) Create(ctx context.Context, p *domain.Principal, name string) (*domain.Organization, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxOrganizationName {
+		return nil, domain.NewValidationError("name", fmt.Sprintf("must be 1 to %d characters", maxOrganizationName))
+	}
+
+	org := &domain.Organization{Name: name}
+	if err := s.orgs.Create(ctx, org, p.AccountId); err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, audit.Entry{
+		AccountId:      p.AccountId,
+		OrganizationId: org.ID,
+		Action:         domain.AuditOrgCreate,
+		Resource:       organizationResource(org.ID),
+		After:          map[string]any{"name": org.Name, "owner": p.AccountId},
+	})
+	return org, nil
+}
+
+// Memberships lists the organizations of the caller's account with its role in each
+func (s *OrganizationService) Memberships(ctx context.Context, p *domain.Principal) ([]domain.Membership, error) {
+	return s.orgs.Memberships(ctx, p.AccountId)
+}
+
+func (s *OrganizationService) Members(ctx context.Context, p *domain.Principal) ([]domain.Membership, error) {
+	return s.orgs.ListMembers(ctx, p.OrganizationId)
+}
+
+// UpdateRole changes the role of a member of the caller's organization
+func (s *OrganizationService) UpdateRole(ctx context.Context, p *domain.Principal, accountId uint, role domain.Role) error {
+	if err := p.Authorize(domain.PermMembersWrite); err != nil {
+		return err
+	}
+	if !role.Valid() {
+		return domain.NewValidationError("role", "must be owner, admin, editor or viewer")
+	}
+
+	member, err := s.orgs.GetMembership(ctx, p.OrganizationId, accountId)
+	if err != nil {
+		return err
+	}
+	if err := requireOwner(p, member.Role, role); err != nil {
+		return err
+	}
+
+	prior, err := s.orgs.UpdateRole(ctx, p.OrganizationId, accountId, role)
+	if err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, audit.Entry{
+		AccountId:      p.AccountId,
+		OrganizationId: p.OrganizationId,
+		Action:         domain.AuditMemberUpdate,
+		Resource:       memberResource(p.OrganizationId, accountId),
+		Before:         map[string]any{"role": prior},
+		After:          map[string]any{"role": role},
+	})
+	return nil
+}
+
+// RemoveMember takes a member out of the caller's organization, members may leave on
+// their own with a key of the full account
+func (s *OrganizationService) RemoveMember(ctx context.Context, p *domain.Principal, accountId uint) error {
+	if accountId == p.AccountId {
+		// Leaving acts for the account, a key limited to links may not do it
+		if err := p.AuthorizeAccount(); err != nil {
+			return err
+		}
+	} else {
+		if err := p.Authorize(domain.PermMembersWrite); err != nil {
+			return err
+		}
+		member, err := s.orgs.GetMembership(ctx, p.OrganizationId, accountId)
+		if err != nil {
+			return err
+		}
+		if err := requireOwner(p, member.Role, ""); err != nil {
+			return err
+		}
+	}
+
+	removed, err := s.orgs.RemoveMember(ctx, p.OrganizationId, accountId)
+	if err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, audit.Entry{
+		AccountId:      p.AccountId,
+		OrganizationId: p.OrganizationId,
+		Action:         domain.AuditMemberRemove,
+		Resource:       memberResource(p.OrganizationId, accountId),
+		Before:         map[string]any{"role": removed.Role},
+	})
+	return nil
+}
+
+// Invite creates an invitation to the caller's organization, the plain token is only returned here
+func (s *OrganizationService) Invite(ctx context.Context, p *domain.Principal, in InvitationInput) (*domain.Invitation, string, error) {
+	if err := p.Authorize(domain.PermMembersWrite); err != nil {
+		return nil, "", err
+	}
+
+	verr := &domain.ValidationError{}
+	email := strings.ToLower(strings.TrimSpace(in.Email))
+	if !strings.Contains(email, "@") {
+		verr.Add("email", "must be an email address")
+	}
+	if !in.Role.Valid() {
+		verr.Add("role", "must be owner, admin, editor or viewer")
+	}
+	if err := verr.OrNil(); err != nil {
+		return nil, "", err
+	}
+	if err := requireOwner(p, "", in.Role); err != nil {
+		return nil, "", err
+	}
+
+	token, err := generateInvitationToken()
+	if err != nil {
+		return nil, "", err
+	}
+	invitation := &domain.Invitation{
+		OrganizationId: p.OrganizationId,
+		Email:          email,
+		Role:           in.Role,
+		TokenHash:      hashInvitationToken(token),
+		InvitedBy:      p.AccountId,
+		ExpiresAt:      time.Now().Add(invitationTTL),
+	}
+	if err := s.orgs.CreateInvitation(ctx, invitation); err != nil {
+		return nil, "", err
+	}
+
+	s.audit.Record(ctx, audit.Entry{
+		AccountId:      p.AccountId,
+		OrganizationId: p.OrganizationId,
+		Action:         domain.AuditInvitationCreate,
+		Resource:       invitationResource(invitation.ID),
+		After:          map[string]any{"email": invitation.Email, "role": invitation.Role, "expiresAt": invitation.ExpiresAt},
+	})
+	return invitation, token, nil
+}
+
+func (s *OrganizationService) Invitations(ctx context.Context, p *domain.Principal) ([]domain.Invitation, error) {
+	if err := p.Authorize(domain.PermMembersWrite); err != nil {
+		return nil, err
+	}
+	return s.orgs.ListInvitations(ctx, p.OrganizationId)
+}
+
+func (s *OrganizationService) RevokeInvitation(ctx context.Context, p *domain.Principal, id uint) error {
+	if err := p.Authorize(domain.PermMembersWrite); err != nil {
+		return err
+	}
+
+	invitation, err := s.orgs.RevokeInvitation(ctx, p.OrganizationId, id)
+	if err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, audit.Entry{
+		AccountId:      p.AccountId,
+		OrganizationId: p.OrganizationId,
+		Action:         domain.AuditInvitationRevoke,
+		Resource:       invitationResource(invitation.ID),
+		Before:         map[string]any{"email": invitation.Email, "role": invitation.Role},
+	})
+	return nil
+}
+
+// Accept makes the caller's account a member of the inviting organization.
+// Unknown, used and expired tokens are all reported as not found.
+func (s *OrganizationService) Accept(ctx context.Context, p *domain.Principal, token string) (*domain.Membership, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, domain.NewValidationError("token", "is required")
+	}
+
+	membership, err := s.orgs.AcceptInvitation(ctx, hashInvitationToken(token), p.AccountId, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, audit.Entry{
+		AccountId:      p.AccountId,
+		OrganizationId: membership.OrganizationId,
+		Action:         domain.AuditInvitationAccept,
+		Resource:       memberResource(membership.OrganizationId, p.AccountId),
+		After:          map[string]any{"role": membership.Role},
+	})
+	return membership, nil
+}
+
+// requireOwner keeps the owner role in the hands of owners, only they may
+// grant it or change and remove members holding it
+func requireOwner(p *domain.Principal, current, next domain.Role) error {
+	if (current == domain.RoleOwner || next == domain.RoleOwner) && p.Role != domain.RoleOwner {
+		return fmt.Errorf("only owners may manage the owner role: %w", domain.ErrForbidden)
+	}
+	return nil
+}
+
+func generateInvitationToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate invitation token: %w", err)
+	}
+	return invitationPrefix + hex.EncodeToString(b), nil
+}
+
+func hashInvitationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func organizationResource(id uint) string {
+	return "organization:" + strconv.FormatUint(uint64(id), 10)
+}
+
+func memberResource(orgId, accountId uint) string {
+	return organizationResource(orgId) + "/member:" + strconv.FormatUint(uint64(accountId), 10)
+}
+
+func invitationResource(id uint) string {
+	return "invitation:" + strconv.FormatUint(uint64(id), 10)
+}
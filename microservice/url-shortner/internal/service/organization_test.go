@@ -0,0 +1,35 @@
+package service
+
+import (
+	"coding2fun.in/url-shortner/internal/domain"
+	"errors"
+	"testing"
+)
+
+func TestRequireOwner(t *testing.T) {
+	tests := []struct {
+		actor, current, next domain.Role
+		allowed              bool
+	}{
+		{domain.RoleOwner, domain.RoleOwner, domain.RoleAdmin, true},
+		{domain.RoleOwner, domain.RoleAdmin, domain.RoleOwner, true},
+		{domain.RoleOwner, domain.RoleEditor, domain.RoleViewer, true},
+		{domain.RoleAdmin, domain.RoleEditor, domain.RoleAdmin, true},
+		{domain.RoleAdmin, domain.RoleAdmin, domain.RoleViewer, true},
+		{domain.RoleAdmin, "", domain.RoleEditor, true},
+		{domain.RoleAdmin, domain.RoleAdmin, domain.RoleOwner, false},
+		{domain.RoleAdmin, domain.RoleOwner, domain.RoleAdmin, false},
+		{domain.RoleAdmin, domain.RoleOwner, "", false},
+		{domain.RoleAdmin, "", domain.RoleOwner, false},
+	}
+
+	for _, tt := range tests {
+		err := requireOwner(&domain.Principal{Role: tt.actor}, tt.current, tt.next)
+		if tt.allowed && err != nil {
+			t.Errorf("%s changing %q to %q: %v", tt.actor, tt.current, tt.next, err)
+		}
+		if !tt.allowed && !errors.Is(err, domain.ErrForbidden) {
+			t.Errorf("%s changing %q to %q = %v, want forbidden", tt.actor, tt.current, tt.next, err)
+		}
+	}
+}
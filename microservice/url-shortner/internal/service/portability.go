@@ -0,0 +1,751 @@
+package service
+
+import (
+	"coding2fun.in/url-shortner/internal/archive"
+	"coding2fun.in/url-shortner/internal/audit"
+	"coding2fun.in/url-shortner/internal/config"
+	"coding2fun.in/url-shortner/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	exportPageSize = 500
+	// renameCandidates is how many numbered slugs are checked at once when renaming
+	renameCandidates = 10
+)
+
+// ConflictStrategy decides what happens to an imported link whose code is taken
+type ConflictStrategy string
+
+const (
+	ConflictSkip   ConflictStrategy = "skip"
+	ConflictRename ConflictStrategy = "rename"
+	// ConflictFail aborts the whole import before anything is stored
+	ConflictFail ConflictStrategy = "fail"
+)
+
+// Outcomes of an imported link
+const (
+	ImportImported = "imported"
+	ImportRenamed  = "renamed"
+	ImportSkipped  = "skipped"
+	ImportConflict = "conflict"
+	ImportFailed   = "failed"
+)
+
+type ExportOptions struct {
+	// Format of the archive, jsonl or csv
+	Format archive.Format
+	// PasswordHashes exports the bcrypt hashes of protected links, only
+	// operators ask for them. Without them protected links fail to import.
+	PasswordHashes bool
+}
+
+// ExportSummary counts the records of an export
+type ExportSummary struct {
+	Links    int
+	APIKeys  int
+	Versions int
+}
+
+type ImportOptions struct {
+	// Format of the archive, empty detects it
+	Format   archive.Format
+	Conflict ConflictStrategy
+	// Domain moves every link onto a verified domain of the organization,
+	// otherwise links keep the domain they were exported from
+	Domain string
+	// MaxLinks limits the links of one import, 0 means no limit
+	MaxLinks int
+}
+
+// ImportResult is the outcome of an archived link, rows are numbered from 1
+type ImportResult struct {
+	Row        int              `json:"row"`
+	SourceCode string           `json:"sourceCode,omitempty"`
+	ShortCode  string           `json:"shortCode,omitempty"`
+	Status     string           `json:"status"`
+	Error      *domain.RowError `json:"error,omitempty"`
+}
+
+type ImportReport struct {
+	Format   archive.Format   `json:"format"`
+	Conflict ConflictStrategy `json:"conflict"`
+	Total    int              `json:"total"`
+	// Imported counts the stored links, renamed ones included
+	Imported int `json:"imported"`
+	Renamed  int `json:"renamed"`
+	Skipped  int `json:"skipped"`
+	Failed   int `json:"failed"`
+	// Aborted is set when the fail strategy met a taken code, nothing was stored
+	Aborted bool           `json:"aborted"`
+	Results []ImportResult `json:"results"`
+}
+
+// PortabilityService exports an account's data and imports archives, the
+// service's own ones as well as link exports of bit.ly
+type PortabilityService struct {
+	accounts domain.AccountRepository
+	archives domain.ArchiveRepository
+	links    *LinkService
+	domains  *DomainService
+	audit    *audit.Logger
+	cfg      config.LinksConfig
+}
+
+func NewPortabilityService(accounts domain.AccountRepository, archives domain.ArchiveRepository, links *LinkService, domains *DomainService, audit *audit.Logger, cfg config.LinksConfig) *PortabilityService {
+	return &PortabilityService{accounts: accounts, archives: archives, links: links, domains: domains, audit: audit, cfg: cfg}
+}
+
+// ParseConflictStrategy validates a strategy name, empty is skip
+func ParseConflictStrategy(name string) (ConflictStrategy, error) {
+	switch strategy := ConflictStrategy(name); strategy {
+	case "":
+		return ConflictSkip, nil
+	case ConflictSkip, ConflictRename, ConflictFail:
+		return strategy, nil
+	default:
+		return "", domain.NewValidationError("conflict", "must be skip, rename or fail")
+	}
+}
+
+// Export writes the principal's account, the metadata of its keys and the
+// organization's links with their history and analytics as an archive. Records
+// are streamed a page at a time, each kind in its own pass over the links that
+// existed when the links were written.
+func (s *PortabilityService) Export(ctx context.Context, p *domain.Principal, w io.Writer, opts ExportOptions) (*ExportSummary, error) {
+	if err := p.AuthorizeAccount(); err != nil {
+		return nil, err
+	}
+	if err := p.Authorize(domain.PermLinksRead); err != nil {
+		return nil, err
+	}
+
+	account, err := s.accounts.Get(ctx, p.AccountId)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := s.accounts.ListAPIKeys(ctx, p.AccountId)
+	if err != nil {
+		return nil, err
+	}
+
+	aw, err := archive.NewWriter(w, opts.Format, archive.Header{
+		Version:        archive.Version,
+		ExportedAt:     time.Now().UTC(),
+		AccountId:      account.ID,
+		OrganizationId: p.OrganizationId,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := aw.WriteAccount(archive.Account{ID: account.ID, Email: account.Email, IsActive: account.IsActive, CreatedAt: account.CreatedAt}); err != nil {
+		return nil, err
+	}
+	summary := &ExportSummary{APIKeys: len(keys)}
+	for _, key := range keys {
+		if err := aw.WriteAPIKey(exportAPIKey(&key)); err != nil {
+			return nil, err
+		}
+	}
+
+	var until uint
+	err = s.exportPages(ctx, p.OrganizationId, math.MaxUint, func(urls []domain.ShortUrl) error {
+		for _, url := range urls {
+			link, err := s.exportLink(ctx, &url, opts.PasswordHashes)
+			if err != nil {
+				return err
+			}
+			if err := aw.WriteLink(link); err != nil {
+				return err
+			}
+		}
+		summary.Links += len(urls)
+		until = urls[len(urls)-1].ID
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.exportPages(ctx, p.OrganizationId, until, func(urls []domain.ShortUrl) error {
+		versions, err := s.archives.Versions(ctx, linkIDs(urls))
+		if err != nil {
+			return err
+		}
+		for _, v := range versions {
+			err := aw.WriteVersion(archive.LinkVersion{
+				LinkId:        v.ShortURLId,
+				Version:       v.Version,
+				OriginalURL:   v.OriginalURL,
+				ExpiresAt:     optionalTime(v.ExpiresAt),
+				IsActive:      v.IsActive,
+				RedirectType:  v.RedirectType,
+				ChangedFields: v.ChangedFields,
+				ChangedAt:     v.CreatedAt,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		summary.Versions += len(versions)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.exportPages(ctx, p.OrganizationId, until, func(urls []domain.ShortUrl) error {
+		analytics, err := s.archives.Analytics(ctx, linkIDs(urls))
+		if err != nil {
+			return err
+		}
+		for _, stats := range analytics {
+			err := aw.WriteAnalytics(archive.Analytics{
+				LinkId:        stats.ShortURLId,
+				TotalClicks:   stats.TotalClicks,
+				LastClickedAt: optionalTime(stats.LastClickedAt),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := aw.Close(); err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, audit.Entry{
+		AccountId:      p.AccountId,
+		OrganizationId: p.OrganizationId,
+		Action:         domain.AuditDataExport,
+		Resource:       accountResource(p.AccountId),
+		After:          map[string]any{"links": summary.Links, "apiKeys": summary.APIKeys, "passwordHashes": opts.PasswordHashes},
+	})
+	return summary, nil
+}
+
+// exportPages hands the organization's links up to the id until to fn a page at a time
+func (s *PortabilityService) exportPages(ctx context.Context, orgId, until uint, fn func([]domain.ShortUrl) error) error {
+	for afterId := uint(0); afterId < until; {
+		urls, err := s.archives.Links(ctx, orgId, afterId, exportPageSize)
+		if err != nil {
+			return err
+		}
+		for len(urls) > 0 && urls[len(urls)-1].ID > until {
+			urls = urls[:len(urls)-1]
+		}
+		if len(urls) == 0 {
+			return nil
+		}
+		afterId = urls[len(urls)-1].ID
+
+		if err := fn(urls); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func linkIDs(urls []domain.ShortUrl) []uint {
+	ids := make([]uint, len(urls))
+	for i, url := range urls {
+		ids[i] = url.ID
+	}
+	return ids
+}
+
+// exportLink converts a link, the hash of its password only when asked for
+func (s *PortabilityService) exportLink(ctx context.Context, url *domain.ShortUrl, passwordHash bool) (archive.Link, error) {
+	link := archive.Link{
+		ID:               url.ID,
+		ShortCode:        url.ShortCode,
+		CustomSlug:       url.CustomSlug != nil,
+		OriginalURL:      url.OriginalURL,
+		Title:            url.Title,
+		UTM:              (*archive.UTM)(optionalUTM(url.UTM)),
+		QueryPassthrough: string(url.QueryPassthrough),
+		Rules:            exportRules(url.Rules),
+		Variants:         exportVariants(url.Variants),
+		IsActive:         url.IsActive,
+		DisabledReason:   url.DisabledReason,
+		ExpiresAt:        optionalTime(url.ExpiresAt),
+		Tags:             make([]string, 0, len(url.Tags)),
+		Protected:        url.PasswordHash != "",
+		MaxClicks:        url.MaxClicks,
+		ConsumedClicks:   url.ConsumedClicks,
+		RedirectType:     url.RedirectType,
+		Version:          url.Version,
+		Clicks:           url.Clicks,
+		CreatedAt:        url.CreatedAt,
+	}
+	if passwordHash {
+		link.PasswordHash = url.PasswordHash
+	}
+	for _, tag := range url.Tags {
+		link.Tags = append(link.Tags, tag.Name)
+	}
+	if url.DomainId != 0 {
+		host, err := s.domains.Host(ctx, url.DomainId)
+		if err != nil {
+			return link, err
+		}
+		link.Domain = host
+	}
+	return link, nil
+}
+
+func exportAPIKey(key *domain.APIKey) archive.APIKey {
+	scopes := make([]string, len(key.Scopes))
+	for i, scope := range key.Scopes {
+		scopes[i] = string(scope)
+	}
+	return archive.APIKey{
+		ID:             key.ID,
+		Name:           key.Name,
+		Scopes:         scopes,
+		AllowedDomains: key.AllowedDomains,
+		AllowedCIDRs:   key.AllowedCIDRs,
+		IsActive:       key.IsActive,
+		ExpiresAt:      optionalTime(key.ExpiresAt),
+		LastUsedAt:     optionalTime(key.LastUsed),
+		CreatedAt:      key.CreatedAt,
+	}
+}
+
+// importedLink is an archived link ready to be stored
+type importedLink struct {
+	result    *ImportResult
+	url       *domain.ShortUrl
+	versions  []domain.LinkVersion
+	analytics *domain.URLAnalytics
+	conflict  bool
+}
+
+// Import stores the links of an archive in the principal's organization with
+// new ids, their history and analytics follow them. Keys and the account are
+// not imported. Links keep their codes, a taken code is handled by the conflict
+// strategy: skip leaves the link out, rename numbers a custom slug or generates
+// a new code and fail aborts the import.
+func (s *PortabilityService) Import(ctx context.Context, p *domain.Principal, data []byte, opts ImportOptions) (*ImportReport, error) {
+	if err := p.AuthorizeAccount(); err != nil {
+		return nil, err
+	}
+	if err := p.Authorize(domain.PermLinksWrite); err != nil {
+		return nil, err
+	}
+	strategy, err := ParseConflictStrategy(string(opts.Conflict))
+	if err != nil {
+		return nil, err
+	}
+
+	format := opts.Format
+	if format == "" {
+		format = archive.Detect(data)
+	}
+	a, err := archive.Read(data, format)
+	if err != nil {
+		return nil, domain.NewValidationError("archive", err.Error())
+	}
+	if opts.MaxLinks > 0 && len(a.Links) > opts.MaxLinks {
+		return nil, domain.NewValidationError("archive", fmt.Sprintf("must hold at most %d links", opts.MaxLinks))
+	}
+
+	report := &ImportReport{
+		Format:   format,
+		Conflict: strategy,
+		Total:    len(a.Links),
+		Results:  make([]ImportResult, len(a.Links)),
+	}
+
+	versions := make(map[uint][]archive.LinkVersion)
+	for _, v := range a.Versions {
+		versions[v.LinkId] = append(versions[v.LinkId], v)
+	}
+	analytics := make(map[uint]archive.Analytics)
+	for _, stats := range a.Analytics {
+		analytics[stats.LinkId] = stats
+	}
+
+	hosts := make(map[string]uint)
+	var links []*importedLink
+	for i, l := range a.Links {
+		result := &report.Results[i]
+		result.Row = i + 1
+		result.SourceCode = l.ShortCode
+
+		url, err := s.importURL(ctx, p, l, opts.Domain, hosts)
+		if err != nil {
+			result.Status, result.Error = ImportFailed, rowError(err)
+			continue
+		}
+		link := &importedLink{result: result, url: url}
+		link.versions, link.analytics = importHistory(url, versions[l.ID], analytics[l.ID])
+		links = append(links, link)
+	}
+
+	if err := s.markConflicts(ctx, links); err != nil {
+		return nil, err
+	}
+
+	if strategy == ConflictFail {
+		for _, link := range links {
+			if link.conflict {
+				report.Aborted = true
+				link.result.Status, link.result.Error = ImportConflict, rowError(domain.ErrConflict)
+			}
+		}
+	}
+	for _, link := range links {
+		switch {
+		case report.Aborted && link.result.Status == "":
+			link.result.Status = ImportSkipped
+		case !report.Aborted:
+			s.store(ctx, link, strategy)
+		}
+	}
+
+	for _, r := range report.Results {
+		switch r.Status {
+		case ImportImported:
+			report.Imported++
+		case ImportRenamed:
+			report.Imported++
+			report.Renamed++
+		case ImportSkipped:
+			report.Skipped++
+		default:
+			report.Failed++
+		}
+	}
+
+	s.audit.Record(ctx, audit.Entry{
+		AccountId:      p.AccountId,
+		OrganizationId: p.OrganizationId,
+		Action:         domain.AuditDataImport,
+		Resource:       accountResource(p.AccountId),
+		After: map[string]any{
+			"format":   format,
+			"conflict": strategy,
+			"total":    report.Total,
+			"imported": report.Imported,
+			"renamed":  report.Renamed,
+			"skipped":  report.Skipped,
+			"failed":   report.Failed,
+			"aborted":  report.Aborted,
+		},
+	})
+	return report, nil
+}
+
+// importURL validates an archived link and turns it into a link of the principal's organization
+func (s *PortabilityService) importURL(ctx context.Context, p *domain.Principal, l archive.Link, host string, hosts map[string]uint) (*domain.ShortUrl, error) {
+	verr := &domain.ValidationError{}
+
+	destination, err := s.links.destination(ctx, p, l.OriginalURL, verr)
+	if err != nil {
+		return nil, err
+	}
+
+	url := &domain.ShortUrl{
+		AccountId:      p.AccountId,
+		OrganizationId: p.OrganizationId,
+		APIKeyId:       p.APIKeyId,
+		OriginalURL:    destination,
+		IsActive:       l.IsActive,
+		DisabledReason: l.DisabledReason,
+		PasswordHash:   l.PasswordHash,
+		MaxClicks:      l.MaxClicks,
+		ConsumedClicks: l.ConsumedClicks,
+		RedirectType:   l.RedirectType,
+		Version:        max(l.Version, 1),
+		Clicks:         l.Clicks,
+	}
+	url.CreatedAt = l.CreatedAt
+	if l.ExpiresAt != nil {
+		url.ExpiresAt = *l.ExpiresAt
+	}
+
+	if host == "" {
+		host = l.Domain
+	}
+	domainId, ok := hosts[host]
+	if !ok {
+		domainId, err = s.domains.VerifiedID(ctx, p, host)
+		var domainErr *domain.ValidationError
+		if errors.As(err, &domainErr) {
+			verr.Fields = append(verr.Fields, domainErr.Fields...)
+		} else if err != nil {
+			return nil, err
+		} else {
+			hosts[host] = domainId
+		}
+	}
+	url.DomainId = domainId
+
+	code := strings.TrimSpace(l.ShortCode)
+	switch {
+	case code == "":
+		if code, err = generateCode(s.cfg.CodeLength); err != nil {
+			return nil, fmt.Errorf("failed to generate short code: %w", err)
+		}
+	case !slugPattern.MatchString(code):
+		verr.Add("shortCode", "must be 3 to 64 letters, digits, '-' or '_'")
+	case reservedSlugs[strings.ToLower(code)]:
+		verr.Add("shortCode", "is reserved")
+	}
+	url.ShortCode = code
+	if l.CustomSlug && l.ShortCode != "" {
+		url.CustomSlug = &code
+	}
+
+	url.Tags = buildTags(l.Tags, verr)
+	url.Title = linkTitle(l.Title, verr)
+	if l.UTM != nil {
+		url.UTM = linkUTM(domain.UTM(*l.UTM), verr)
+	}
+	url.QueryPassthrough = queryPassthrough(l.QueryPassthrough, verr)
+	if url.Rules, err = s.links.rules(ctx, p, importRules(l.Rules), verr); err != nil {
+		return nil, err
+	}
+	if url.Variants, err = s.links.variants(ctx, p, importVariants(l.Variants), verr); err != nil {
+		return nil, err
+	}
+	// variants keep their order through validation, their clicks follow them
+	for i := range url.Variants {
+		url.Variants[i].Clicks = l.Variants[i].Clicks
+	}
+
+	if url.RedirectType == 0 {
+		url.RedirectType = http.StatusFound
+	} else if !redirectTypes[url.RedirectType] {
+		verr.Add("redirectType", "must be 301, 302, 307 or 308")
+	}
+	if url.PasswordHash != "" {
+		if _, err := bcrypt.Cost([]byte(url.PasswordHash)); err != nil {
+			verr.Add("passwordHash", "must be a bcrypt hash")
+		}
+	} else if l.Protected {
+		// Importing the link without its password would make it public
+		verr.Add("passwordHash", "is required for a protected link, export it with password hashes")
+	}
+	if url.MaxClicks < 0 || url.ConsumedClicks < 0 || url.Clicks < 0 {
+		verr.Add("clicks", "must not be negative")
+	}
+
+	if err := verr.OrNil(); err != nil {
+		return nil, err
+	}
+	return url, nil
+}
+
+// importHistory converts the versions and analytics of an archived link, the
+// link's version is raised past its newest prior version
+func importHistory(url *domain.ShortUrl, archived []archive.LinkVersion, stats archive.Analytics) ([]domain.LinkVersion, *domain.URLAnalytics) {
+	seen := make(map[int]bool, len(archived))
+	versions := make([]domain.LinkVersion, 0, len(archived))
+	for _, v := range archived {
+		if v.Version < 1 || seen[v.Version] {
+			continue
+		}
+		seen[v.Version] = true
+		version := domain.LinkVersion{
+			Version:       v.Version,
+			OriginalURL:   v.OriginalURL,
+			IsActive:      v.IsActive,
+			RedirectType:  v.RedirectType,
+			ChangedFields: v.ChangedFields,
+			CreatedAt:     v.ChangedAt,
+		}
+		if v.ExpiresAt != nil {
+			version.ExpiresAt = *v.ExpiresAt
+		}
+		versions = append(versions, version)
+		url.Version = max(url.Version, v.Version+1)
+	}
+
+	if stats.LinkId == 0 {
+		return versions, nil
+	}
+	analytics := &domain.URLAnalytics{TotalClicks: stats.TotalClicks}
+	if stats.LastClickedAt != nil {
+		analytics.LastClickedAt = *stats.LastClickedAt
+		url.LastClickedAt = *stats.LastClickedAt
+	}
+	if url.Clicks == 0 {
+		url.Clicks = stats.TotalClicks
+	}
+	return versions, analytics
+}
+
+// reset clears the ids assigned by a rolled back insert
+func (l *importedLink) reset() {
+	l.url.ID = 0
+	for i := range l.url.Tags {
+		l.url.Tags[i].ID = 0
+	}
+	for i := range l.versions {
+		l.versions[i].ID = 0
+	}
+	if l.analytics != nil {
+		l.analytics.ID = 0
+	}
+}
+
+// markConflicts flags the links whose code is taken on their domain, by a
+// stored link or an earlier link of the same import
+func (s *PortabilityService) markConflicts(ctx context.Context, links []*importedLink) error {
+	codes := make(map[uint][]string)
+	for _, link := range links {
+		codes[link.url.DomainId] = append(codes[link.url.DomainId], link.url.ShortCode)
+	}
+
+	taken := make(map[uint]map[string]bool, len(codes))
+	for domainId, batch := range codes {
+		used := make(map[string]bool)
+		for start := 0; start < len(batch); start += exportPageSize {
+			found, err := s.archives.TakenCodes(ctx, domainId, batch[start:min(start+exportPageSize, len(batch))])
+			if err != nil {
+				return err
+			}
+			for code := range found {
+				used[code] = true
+			}
+		}
+		taken[domainId] = used
+	}
+
+	seen := make(map[string]bool, len(links))
+	for _, link := range links {
+		key := linkKey(link.url.DomainId, link.url.ShortCode)
+		link.conflict = taken[link.url.DomainId][link.url.ShortCode] || seen[key]
+		seen[key] = true
+	}
+	return nil
+}
+
+// store imports a link, renaming it when its code is taken and the strategy allows
+func (s *PortabilityService) store(ctx context.Context, link *importedLink, strategy ConflictStrategy) {
+	result := link.result
+	renamed := false
+	for attempt := 1; ; attempt++ {
+		if link.conflict {
+			if strategy != ConflictRename {
+				result.Status = ImportSkipped
+				return
+			}
+			if err := s.rename(ctx, link.url); err != nil {
+				result.Status, result.Error = ImportFailed, rowError(err)
+				return
+			}
+			renamed = true
+		}
+
+		err := s.archives.ImportURL(ctx, link.url, link.versions, link.analytics)
+		if err == nil {
+			result.ShortCode = link.url.ShortCode
+			result.Status = ImportImported
+			if renamed {
+				result.Status = ImportRenamed
+			}
+			return
+		}
+		if !errors.Is(err, domain.ErrConflict) || attempt == maxCodeAttempts {
+			result.Status, result.Error = ImportFailed, rowError(slugConflict(err, link.url))
+			return
+		}
+		// The code was taken since the conflicts were checked, the rolled back ids are dropped
+		link.conflict = true
+		link.reset()
+	}
+}
+
+// rename gives a link a free code, a custom slug gets the first free numbered
+// variant and a generated code is generated anew
+func (s *PortabilityService) rename(ctx context.Context, url *domain.ShortUrl) error {
+	if url.CustomSlug == nil {
+		code, err := generateCode(s.cfg.CodeLength)
+		if err != nil {
+			return fmt.Errorf("failed to generate short code: %w", err)
+		}
+		url.ShortCode = code
+		return nil
+	}
+
+	base := *url.CustomSlug
+	for next := 2; next < 2+maxCodeAttempts*renameCandidates; next += renameCandidates {
+		candidates := make([]string, 0, renameCandidates)
+		for n := next; n < next+renameCandidates; n++ {
+			suffix := "-" + strconv.Itoa(n)
+			candidates = append(candidates, base[:min(len(base), 64-len(suffix))]+suffix)
+		}
+		taken, err := s.archives.TakenCodes(ctx, url.DomainId, candidates)
+		if err != nil {
+			return err
+		}
+		for _, candidate := range candidates {
+			if !taken[candidate] {
+				url.ShortCode = candidate
+				url.CustomSlug = &candidate
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%w: %w", domain.NewValidationError("shortCode", "has no free numbered variant"), domain.ErrConflict)
+}
+
+// linkKey identifies a code on a domain within an import
+func linkKey(domainId uint, code string) string {
+	return strconv.FormatUint(uint64(domainId), 10) + ":" + code
+}
+
+func exportRules(rules []domain.RedirectRule) []archive.Rule {
+	out := make([]archive.Rule, 0, len(rules))
+	for _, r := range rules {
+		out = append(out, archive.Rule{
+			URL: r.Destination, Countries: r.Countries, OS: r.OS, Devices: r.Devices, Languages: r.Languages, Referrers: r.Referrers,
+			From: r.From, Until: r.Until, DailyFrom: r.DailyFrom, DailyUntil: r.DailyUntil, TimeZone: r.TimeZone,
+		})
+	}
+	return out
+}
+
+func importRules(rules []archive.Rule) []domain.RedirectRule {
+	out := make([]domain.RedirectRule, 0, len(rules))
+	for _, r := range rules {
+		out = append(out, domain.RedirectRule{
+			Destination: r.URL, Countries: r.Countries, OS: r.OS, Devices: r.Devices, Languages: r.Languages, Referrers: r.Referrers,
+			From: r.From, Until: r.Until, DailyFrom: r.DailyFrom, DailyUntil: r.DailyUntil, TimeZone: r.TimeZone,
+		})
+	}
+	return out
+}
+
+func exportVariants(variants []domain.LinkVariant) []archive.Variant {
+	out := make([]archive.Variant, 0, len(variants))
+	for _, v := range variants {
+		out = append(out, archive.Variant{URL: v.Destination, Weight: v.Weight, Clicks: v.Clicks})
+	}
+	return out
+}
+
+func importVariants(variants []archive.Variant) []domain.LinkVariant {
+	out := make([]domain.LinkVariant, 0, len(variants))
+	for _, v := range variants {
+		out = append(out, domain.LinkVariant{Destination: v.URL, Weight: v.Weight})
+	}
+	return out
+}
@@ -0,0 +1,582 @@
+package service
+
+import (
+	"coding2fun.in/url-shortner/internal/audit"
+	"coding2fun.in/url-shortner/internal/config"
+	"coding2fun.in/url-shortner/internal/domain"
+	"coding2fun.in/url-shortner/internal/events"
+	"coding2fun.in/url-shortner/internal/log"
+	"coding2fun.in/url-shortner/internal/safeurl"
+	"coding2fun.in/url-shortner/internal/webhook"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	maxWebhookEndpoints      = 10
+	maxWebhookDescription    = 200
+	webhookSecretPrefix      = "whsec_"
+	webhookEventPrefix       = "evt_"
+	maxDeliveryErrorLength   = 512
+	deliveryLeaseMargin      = time.Minute
+	webhookDeliveryBatchSize = 100
+)
+
+// WebhookInput adds a webhook endpoint, no events subscribes it to all of them
+type WebhookInput struct {
+	URL         string   `json:"url"`
+	Events      []string `json:"events"`
+	Description string   `json:"description"`
+}
+
+// WebhookPatch changes an endpoint, absent fields are left unchanged
+type WebhookPatch struct {
+	URL         *string   `json:"url"`
+	Events      *[]string `json:"events"`
+	Description *string   `json:"description"`
+	IsActive    *bool     `json:"isActive"`
+}
+
+// WebhookEvent is the JSON body posted to webhook endpoints
+type WebhookEvent struct {
+	ID             string           `json:"id"`
+	Type           string           `json:"type"`
+	CreatedAt      time.Time        `json:"createdAt"`
+	OrganizationId uint             `json:"organizationId"`
+	Data           WebhookEventData `json:"data"`
+}
+
+type WebhookEventData struct {
+	Link WebhookLink `json:"link"`
+	// Milestone is the click count a link.click_milestone event announces
+	Milestone int64 `json:"milestone,omitempty"`
+}
+
+// WebhookLink is the state of a link when its event happened
+type WebhookLink struct {
+	ShortCode      string     `json:"shortCode"`
+	ShortURL       string     `json:"shortUrl"`
+	OriginalURL    string     `json:"originalUrl"`
+	Title          string     `json:"title,omitempty"`
+	Tags           []string   `json:"tags"`
+	IsActive       bool       `json:"isActive"`
+	ExpiresAt      *time.Time `json:"expiresAt,omitempty"`
+	DisabledReason string     `json:"disabledReason,omitempty"`
+	Clicks         int64      `json:"clicks"`
+	Version        int        `json:"version"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+// WebhookService manages the webhook endpoints of organizations, queues the
+// events of their links for every subscribed endpoint and delivers them.
+// Failed deliveries are retried with exponential backoff until they are dead.
+type WebhookService struct {
+	webhooks  domain.WebhookRepository
+	urls      domain.ShortURLRepository
+	domains   *DomainService
+	validator *safeurl.Validator
+	sender    *webhook.Sender
+	audit     *audit.Logger
+	cfg       config.WebhooksConfig
+}
+
+func NewWebhookService(webhooks domain.WebhookRepository, urls domain.ShortURLRepository, domains *DomainService, validator *safeurl.Validator, sender *webhook.Sender, audit *audit.Logger, cfg config.WebhooksConfig) *WebhookService {
+	return &WebhookService{webhooks: webhooks, urls: urls, domains: domains, validator: validator, sender: sender, audit: audit, cfg: cfg}
+}
+
+// Create adds an endpoint with a new signing secret, which is only returned here and by RotateSecret
+func (s *WebhookService) Create(ctx context.Context, p *domain.Principal, in WebhookInput) (*domain.WebhookEndpoint, error) {
+	if err := p.Authorize(domain.PermWebhooksWrite); err != nil {
+		return nil, err
+	}
+
+	verr := &domain.ValidationError{}
+	url, err := s.endpointURL(ctx, in.URL, verr)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := &domain.WebhookEndpoint{
+		AccountId:      p.AccountId,
+		OrganizationId: p.OrganizationId,
+		URL:            url,
+		Events:         webhookEvents(in.Events, verr),
+		Description:    webhookDescription(in.Description, verr),
+		IsActive:       true,
+	}
+	if err := verr.OrNil(); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.webhooks.ListEndpoints(ctx, p.OrganizationId)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= maxWebhookEndpoints {
+		return nil, fmt.Errorf("an organization has at most %d webhook endpoints: %w", maxWebhookEndpoints, domain.ErrQuotaExceeded)
+	}
+
+	if endpoint.Secret, err = newWebhookSecret(); err != nil {
+		return nil, err
+	}
+	if err := s.webhooks.CreateEndpoint(ctx, endpoint); err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, audit.Entry{
+		AccountId:      p.AccountId,
+		OrganizationId: p.OrganizationId,
+		Action:         domain.AuditWebhookCreate,
+		Resource:       webhookResource(endpoint.ID),
+		After:          endpointState(endpoint),
+	})
+	return endpoint, nil
+}
+
+func (s *WebhookService) List(ctx context.Context, p *domain.Principal) ([]domain.WebhookEndpoint, error) {
+	if err := p.Authorize(domain.PermWebhooksWrite); err != nil {
+		return nil, err
+	}
+	return s.webhooks.ListEndpoints(ctx, p.OrganizationId)
+}
+
+func (s *WebhookService) Get(ctx context.Context, p *domain.Principal, id uint) (*domain.WebhookEndpoint, error) {
+	if err := p.Authorize(domain.PermWebhooksWrite); err != nil {
+		return nil, err
+	}
+	return s.webhooks.GetEndpoint(ctx, p.OrganizationId, id)
+}
+
+// Update changes an endpoint. Deliveries queued while it is inactive wait
+// until it is activated again.
+func (s *WebhookService) Update(ctx context.Context, p *domain.Principal, id uint, patch WebhookPatch) (*domain.WebhookEndpoint, error) {
+	endpoint, err := s.Get(ctx, p, id)
+	if err != nil {
+		return nil, err
+	}
+	before := endpointState(endpoint)
+
+	verr := &domain.ValidationError{}
+	if patch.URL != nil {
+		if endpoint.URL, err = s.endpointURL(ctx, *patch.URL, verr); err != nil {
+			return nil, err
+		}
+	}
+	if patch.Events != nil {
+		endpoint.Events = webhookEvents(*patch.Events, verr)
+	}
+	if patch.Description != nil {
+		endpoint.Description = webhookDescription(*patch.Description, verr)
+	}
+	if patch.IsActive != nil {
+		endpoint.IsActive = *patch.IsActive
+	}
+	if err := verr.OrNil(); err != nil {
+		return nil, err
+	}
+
+	changedFrom, changedTo := audit.Diff(before, endpointState(endpoint))
+	if len(changedFrom) == 0 && len(changedTo) == 0 {
+		return endpoint, nil
+	}
+	if err := s.webhooks.UpdateEndpoint(ctx, endpoint); err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, audit.Entry{
+		AccountId:      p.AccountId,
+		OrganizationId: p.OrganizationId,
+		Action:         domain.AuditWebhookUpdate,
+		Resource:       webhookResource(endpoint.ID),
+		Before:         changedFrom,
+		After:          changedTo,
+	})
+	return endpoint, nil
+}
+
+// RotateSecret replaces the signing secret of an endpoint, requests are signed
+// with the new secret right away
+func (s *WebhookService) RotateSecret(ctx context.Context, p *domain.Principal, id uint) (*domain.WebhookEndpoint, error) {
+	endpoint, err := s.Get(ctx, p, id)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint.Secret, err = newWebhookSecret(); err != nil {
+		return nil, err
+	}
+	if err := s.webhooks.UpdateEndpoint(ctx, endpoint); err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, audit.Entry{
+		AccountId:      p.AccountId,
+		OrganizationId: p.OrganizationId,
+		Action:         domain.AuditWebhookRotate,
+		Resource:       webhookResource(endpoint.ID),
+	})
+	return endpoint, nil
+}
+
+// Delete removes an endpoint together with its queued deliveries and their log
+func (s *WebhookService) Delete(ctx context.Context, p *domain.Principal, id uint) error {
+	if err := p.Authorize(domain.PermWebhooksWrite); err != nil {
+		return err
+	}
+	endpoint, err := s.webhooks.DeleteEndpoint(ctx, p.OrganizationId, id)
+	if err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, audit.Entry{
+		AccountId:      p.AccountId,
+		OrganizationId: p.OrganizationId,
+		Action:         domain.AuditWebhookDelete,
+		Resource:       webhookResource(endpoint.ID),
+		Before:         endpointState(endpoint),
+	})
+	return nil
+}
+
+// Deliveries lists the deliveries of an endpoint matching the filter, newest first
+func (s *WebhookService) Deliveries(ctx context.Context, p *domain.Principal, filter domain.DeliveryFilter) ([]domain.WebhookDelivery, error) {
+	if _, err := s.Get(ctx, p, filter.EndpointId); err != nil {
+		return nil, err
+	}
+	filter.OrganizationId = p.OrganizationId
+	return s.webhooks.ListDeliveries(ctx, filter)
+}
+
+// Delivery returns a delivery of an endpoint with the log of its attempts
+func (s *WebhookService) Delivery(ctx context.Context, p *domain.Principal, endpointId, id uint) (*domain.WebhookDelivery, error) {
+	if err := p.Authorize(domain.PermWebhooksWrite); err != nil {
+		return nil, err
+	}
+	return s.webhooks.GetDelivery(ctx, p.OrganizationId, endpointId, id)
+}
+
+// Replay queues a delivery to be sent again with its original event id and
+// payload, with a fresh set of attempts
+func (s *WebhookService) Replay(ctx context.Context, p *domain.Principal, endpointId, id uint) (*domain.WebhookDelivery, error) {
+	if err := p.Authorize(domain.PermWebhooksWrite); err != nil {
+		return nil, err
+	}
+	delivery, err := s.webhooks.Replay(ctx, p.OrganizationId, endpointId, id, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, audit.Entry{
+		AccountId:      p.AccountId,
+		OrganizationId: p.OrganizationId,
+		Action:         domain.AuditWebhookReplay,
+		Resource:       webhookResource(endpointId),
+		After:          map[string]any{"deliveryId": delivery.ID, "eventId": delivery.EventId},
+	})
+	return delivery, nil
+}
+
+// Subscribe registers the service for the link events and the recorded clicks
+func (s *WebhookService) Subscribe(bus *events.Bus) {
+	bus.Subscribe("webhooks", s.handleEvent,
+		domain.EventLinkCreated, domain.EventLinkUpdated, domain.EventLinkDeactivated, domain.EventLinkExpired, domain.EventClickRecorded)
+}
+
+// handleEvent queues the webhook events of a domain event. Their ids derive
+// from the domain event, so an event handled again is not queued twice.
+func (s *WebhookService) handleEvent(ctx context.Context, e events.Envelope) error {
+	switch event := e.Event.(type) {
+	case domain.LinkCreated:
+		return s.publish(ctx, e, event.Link, 0)
+	case domain.LinkUpdated:
+		return s.publish(ctx, e, event.Link, 0)
+	case domain.LinkDeactivated:
+		return s.publish(ctx, e, event.Link, 0)
+	case domain.LinkExpired:
+		return s.publish(ctx, e, event.Link, 0)
+	case domain.ClickRecorded:
+		return s.clickMilestones(ctx, e, event)
+	}
+	return nil
+}
+
+// clickMilestones queues link.click_milestone for every milestone the clicks
+// carried the link past. The link is only loaded when one was passed.
+func (s *WebhookService) clickMilestones(ctx context.Context, e events.Envelope, event domain.ClickRecorded) error {
+	before := event.Clicks - event.Count
+	var passed []int64
+	for _, milestone := range s.cfg.ClickMilestones {
+		if before < milestone && milestone <= event.Clicks {
+			passed = append(passed, milestone)
+		}
+	}
+	if len(passed) == 0 {
+		return nil
+	}
+
+	links, err := s.urls.GetURLsByID(ctx, []uint{event.LinkId})
+	if err != nil || len(links) == 0 {
+		return err
+	}
+	link := domain.NewLinkState(&links[0])
+	link.Clicks = event.Clicks
+
+	milestone := e
+	milestone.Type = domain.EventClickMilestone
+	for _, n := range passed {
+		if err := s.publish(ctx, milestone, link, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// publish queues a webhook event for the endpoints of the link's organization
+// subscribed to it
+func (s *WebhookService) publish(ctx context.Context, e events.Envelope, link domain.LinkState, milestone int64) error {
+	endpoints, err := s.webhooks.Subscribers(ctx, link.OrganizationId, e.Type)
+	if err != nil || len(endpoints) == 0 {
+		return err
+	}
+
+	// A milestone is announced once however many clicks events pass it
+	id := derivedEventID(e.Type, link.ID, int64(e.ID))
+	if milestone > 0 {
+		id = derivedEventID(e.Type, link.ID, milestone)
+	}
+	payload, err := s.payload(ctx, id, e, link, milestone)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	deliveries := make([]domain.WebhookDelivery, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		deliveries = append(deliveries, domain.WebhookDelivery{
+			EndpointId:     endpoint.ID,
+			OrganizationId: endpoint.OrganizationId,
+			EventId:        id,
+			Event:          e.Type,
+			Payload:        payload,
+			Status:         domain.DeliveryPending,
+			NextAttemptAt:  now,
+		})
+	}
+	return s.webhooks.Enqueue(ctx, deliveries)
+}
+
+func (s *WebhookService) payload(ctx context.Context, id string, e events.Envelope, link domain.LinkState, milestone int64) (string, error) {
+	shortURL, err := s.domains.ShortURL(ctx, &domain.ShortUrl{DomainId: link.DomainId, ShortCode: link.ShortCode})
+	if err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(WebhookEvent{
+		ID:             id,
+		Type:           e.Type,
+		CreatedAt:      e.OccurredAt.UTC(),
+		OrganizationId: link.OrganizationId,
+		Data: WebhookEventData{
+			Link: WebhookLink{
+				ShortCode:      link.ShortCode,
+				ShortURL:       shortURL,
+				OriginalURL:    link.OriginalURL,
+				Title:          link.Title,
+				Tags:           link.Tags,
+				IsActive:       link.IsActive,
+				ExpiresAt:      link.ExpiresAt,
+				DisabledReason: link.DisabledReason,
+				Clicks:         link.Clicks,
+				Version:        link.Version,
+				CreatedAt:      link.CreatedAt,
+			},
+			Milestone: milestone,
+		},
+	})
+	return string(body), err
+}
+
+// Deliver sends the due deliveries over the configured number of workers until
+// none is left. A delivery being sent is leased, another instance running the
+// job claims it only when this one fails to record the outcome in time.
+func (s *WebhookService) Deliver(ctx context.Context) error {
+	workers := max(s.cfg.Workers, 1)
+	batchSize := s.cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = webhookDeliveryBatchSize
+	}
+	rounds := (batchSize + workers - 1) / workers
+	lease := s.cfg.Timeout*time.Duration(rounds) + deliveryLeaseMargin
+
+	for ctx.Err() == nil {
+		deliveries, err := s.webhooks.ClaimDue(ctx, time.Now(), batchSize, lease)
+		if err != nil {
+			return err
+		}
+
+		work := make(chan *domain.WebhookDelivery)
+		var wg sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for d := range work {
+					s.attempt(ctx, d)
+				}
+			}()
+		}
+		for i := range deliveries {
+			work <- &deliveries[i]
+		}
+		close(work)
+		wg.Wait()
+
+		if len(deliveries) < batchSize {
+			break
+		}
+	}
+	return nil
+}
+
+// attempt sends a delivery once and records the outcome. An attempt cut short
+// by shutdown is not counted, the lease hands the delivery to the next run.
+func (s *WebhookService) attempt(ctx context.Context, d *domain.WebhookDelivery) {
+	if d.Endpoint == nil {
+		return
+	}
+	result := s.sender.Send(ctx, d.Endpoint.URL, d.Endpoint.Secret, webhook.Message{ID: d.EventId, Event: d.Event, Body: []byte(d.Payload)})
+	if ctx.Err() != nil {
+		return
+	}
+
+	now := time.Now()
+	d.Attempts++
+	d.LastStatusCode = result.StatusCode
+	d.LastError = ""
+	switch {
+	case result.Err == nil:
+		d.Status = domain.DeliverySucceeded
+		d.DeliveredAt = &now
+	case d.Attempts >= s.cfg.MaxAttempts:
+		d.Status = domain.DeliveryDead
+		d.LastError = domain.ErrorText(result.Err, maxDeliveryErrorLength)
+		log.Warn("Webhook delivery is dead", zap.Uint("endpointId", d.EndpointId), zap.String("eventId", d.EventId), zap.Int("attempts", d.Attempts))
+	default:
+		d.Status = domain.DeliveryRetrying
+		d.LastError = domain.ErrorText(result.Err, maxDeliveryErrorLength)
+		d.NextAttemptAt = now.Add(s.backoff(d.Attempts))
+	}
+
+	err := s.webhooks.RecordAttempt(ctx, d, &domain.WebhookAttempt{
+		DeliveryId: d.ID,
+		EndpointId: d.EndpointId,
+		Attempt:    d.Attempts,
+		StatusCode: result.StatusCode,
+		Error:      d.LastError,
+		Duration:   result.Duration,
+	})
+	if err != nil {
+		log.Error("Failed to record webhook attempt", zap.Uint("deliveryId", d.ID), zap.Error(err))
+	}
+}
+
+// backoff is the delay after the given number of failed attempts, doubling from BackoffBase up to BackoffMax
+func (s *WebhookService) backoff(attempts int) time.Duration {
+	delay := s.cfg.BackoffBase
+	for i := 1; i < attempts && delay < s.cfg.BackoffMax; i++ {
+		delay *= 2
+	}
+	return min(delay, s.cfg.BackoffMax)
+}
+
+// PurgeDeliveries removes succeeded and dead deliveries older than the log retention
+func (s *WebhookService) PurgeDeliveries(ctx context.Context) error {
+	before := time.Now().Add(-s.cfg.LogRetention)
+	var total int64
+	for ctx.Err() == nil {
+		n, err := s.webhooks.PurgeDeliveries(ctx, before, webhookDeliveryBatchSize)
+		if err != nil {
+			return err
+		}
+		total += n
+		if n < webhookDeliveryBatchSize {
+			break
+		}
+	}
+	if total > 0 {
+		log.Info("Purged webhook deliveries", zap.Int64("deliveries", total))
+	}
+	return nil
+}
+
+// endpointURL validates an endpoint url like a link destination, so requests
+// never go to private networks or the service itself
+func (s *WebhookService) endpointURL(ctx context.Context, raw string, verr *domain.ValidationError) (string, error) {
+	url := strings.TrimSpace(raw)
+	if url == "" {
+		verr.Add("url", "is required")
+	} else if len(url) > maxURLLength {
+		verr.Add("url", fmt.Sprintf("must be at most %d characters", maxURLLength))
+	} else if normalized, err := s.validator.Validate(ctx, url); err != nil {
+		var urlErr *domain.ValidationError
+		if !errors.As(err, &urlErr) {
+			return "", err
+		}
+		verr.Fields = append(verr.Fields, urlErr.Fields...)
+	} else {
+		url = normalized
+	}
+	return url, nil
+}
+
+func webhookEvents(events []string, verr *domain.ValidationError) []string {
+	var subscribed []string
+	for i, event := range events {
+		switch {
+		case !slices.Contains(domain.WebhookEvents, event):
+			verr.Add(fmt.Sprintf("events[%d]", i), "must be one of "+strings.Join(domain.WebhookEvents, ", "))
+		case !slices.Contains(subscribed, event):
+			subscribed = append(subscribed, event)
+		}
+	}
+	return subscribed
+}
+
+func webhookDescription(raw string, verr *domain.ValidationError) string {
+	description := strings.TrimSpace(raw)
+	if utf8.RuneCountInString(description) > maxWebhookDescription {
+		verr.Add("description", fmt.Sprintf("must be at most %d characters", maxWebhookDescription))
+	}
+	return description
+}
+
+func newWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return webhookSecretPrefix + hex.EncodeToString(buf), nil
+}
+
+// derivedEventID names a webhook event after what raised it, raising it again yields the same id
+func derivedEventID(event string, linkId uint, n int64) string {
+	sum := sha256.Sum256([]byte(event + ":" + strconv.FormatUint(uint64(linkId), 10) + ":" + strconv.FormatInt(n, 10)))
+	return webhookEventPrefix + hex.EncodeToString(sum[:16])
+}
+
+func endpointState(e *domain.WebhookEndpoint) map[string]any {
+	return map[string]any{"url": e.URL, "events": e.Events, "description": e.Description, "isActive": e.IsActive}
+}
+
+func webhookResource(id uint) string {
+	return "webhook:" + strconv.FormatUint(uint64(id), 10)
+}
@@ -0,0 +1,513 @@
+package service
+
+import (
+	"coding2fun.in/url-shortner/internal/audit"
+	"coding2fun.in/url-shortner/internal/config"
+	"coding2fun.in/url-shortner/internal/domain"
+	"coding2fun.in/url-shortner/internal/events"
+	"coding2fun.in/url-shortner/internal/safeurl"
+	"coding2fun.in/url-shortner/internal/webhook"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeWebhooks struct {
+	mu         sync.Mutex
+	endpoints  map[uint]*domain.WebhookEndpoint
+	deliveries []*domain.WebhookDelivery
+	attempts   []domain.WebhookAttempt
+}
+
+func newFakeWebhooks() *fakeWebhooks {
+	return &fakeWebhooks{endpoints: map[uint]*domain.WebhookEndpoint{}}
+}
+
+func (f *fakeWebhooks) CreateEndpoint(_ context.Context, endpoint *domain.WebhookEndpoint) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	endpoint.ID = uint(len(f.endpoints) + 1)
+	copied := *endpoint
+	f.endpoints[endpoint.ID] = &copied
+	return nil
+}
+
+func (f *fakeWebhooks) GetEndpoint(_ context.Context, orgId, id uint) (*domain.WebhookEndpoint, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if e, ok := f.endpoints[id]; ok && e.OrganizationId == orgId {
+		copied := *e
+		return &copied, nil
+	}
+	return nil, domain.ErrNotFound
+}
+
+func (f *fakeWebhooks) ListEndpoints(_ context.Context, orgId uint) ([]domain.WebhookEndpoint, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var list []domain.WebhookEndpoint
+	for _, e := range f.endpoints {
+		if e.OrganizationId == orgId {
+			list = append(list, *e)
+		}
+	}
+	return list, nil
+}
+
+func (f *fakeWebhooks) UpdateEndpoint(_ context.Context, endpoint *domain.WebhookEndpoint) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	copied := *endpoint
+	f.endpoints[endpoint.ID] = &copied
+	return nil
+}
+
+func (f *fakeWebhooks) DeleteEndpoint(_ context.Context, orgId, id uint) (*domain.WebhookEndpoint, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	e, ok := f.endpoints[id]
+	if !ok || e.OrganizationId != orgId {
+		return nil, domain.ErrNotFound
+	}
+	delete(f.endpoints, id)
+	return e, nil
+}
+
+func (f *fakeWebhooks) Subscribers(_ context.Context, orgId uint, event string) ([]domain.WebhookEndpoint, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var list []domain.WebhookEndpoint
+	for _, e := range f.endpoints {
+		if e.OrganizationId == orgId && e.IsActive && e.Subscribes(event) {
+			list = append(list, *e)
+		}
+	}
+	return list, nil
+}
+
+func (f *fakeWebhooks) Enqueue(_ context.Context, deliveries []domain.WebhookDelivery) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, d := range deliveries {
+		duplicate := false
+		for _, queued := range f.deliveries {
+			duplicate = duplicate || (queued.EndpointId == d.EndpointId && queued.EventId == d.EventId)
+		}
+		if !duplicate {
+			d.ID = uint(len(f.deliveries) + 1)
+			f.deliveries = append(f.deliveries, &d)
+		}
+	}
+	return nil
+}
+
+// ClaimDue ignores the schedule so tests do not wait for the backoff
+func (f *fakeWebhooks) ClaimDue(_ context.Context, _ time.Time, limit int, _ time.Duration) ([]domain.WebhookDelivery, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var due []domain.WebhookDelivery
+	for _, d := range f.deliveries {
+		if (d.Status == domain.DeliveryPending || d.Status == domain.DeliveryRetrying) && len(due) < limit {
+			claimed := *d
+			endpoint := *f.endpoints[d.EndpointId]
+			claimed.Endpoint = &endpoint
+			due = append(due, claimed)
+		}
+	}
+	return due, nil
+}
+
+func (f *fakeWebhooks) RecordAttempt(_ context.Context, delivery *domain.WebhookDelivery, attempt *domain.WebhookAttempt) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stored := *delivery
+	stored.Endpoint = nil
+	f.deliveries[delivery.ID-1] = &stored
+	f.attempts = append(f.attempts, *attempt)
+	return nil
+}
+
+func (f *fakeWebhooks) ListDeliveries(_ context.Context, filter domain.DeliveryFilter) ([]domain.WebhookDelivery, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var list []domain.WebhookDelivery
+	for i := len(f.deliveries) - 1; i >= 0; i-- {
+		d := f.deliveries[i]
+		if d.EndpointId == filter.EndpointId && (filter.Status == "" || d.Status == filter.Status) {
+			list = append(list, *d)
+		}
+	}
+	return list, nil
+}
+
+func (f *fakeWebhooks) GetDelivery(_ context.Context, orgId, endpointId, id uint) (*domain.WebhookDelivery, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if id == 0 || int(id) > len(f.deliveries) {
+		return nil, domain.ErrNotFound
+	}
+	d := *f.deliveries[id-1]
+	if d.OrganizationId != orgId || d.EndpointId != endpointId {
+		return nil, domain.ErrNotFound
+	}
+	for _, a := range f.attempts {
+		if a.DeliveryId == id {
+			d.Log = append(d.Log, a)
+		}
+	}
+	return &d, nil
+}
+
+func (f *fakeWebhooks) Replay(ctx context.Context, orgId, endpointId, id uint, now time.Time) (*domain.WebhookDelivery, error) {
+	d, err := f.GetDelivery(ctx, orgId, endpointId, id)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d.Status, d.Attempts, d.NextAttemptAt, d.Log = domain.DeliveryPending, 0, now, nil
+	stored := *d
+	f.deliveries[id-1] = &stored
+	return d, nil
+}
+
+func (f *fakeWebhooks) PurgeDeliveries(context.Context, time.Time, int) (int64, error) {
+	return 0, nil
+}
+
+func (f *fakeWebhooks) delivery(id uint) domain.WebhookDelivery {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return *f.deliveries[id-1]
+}
+
+// fakeClickURLs serves the links click milestones load, the other methods are not used
+type fakeClickURLs struct {
+	domain.ShortURLRepository
+	links []domain.ShortUrl
+}
+
+func (f *fakeClickURLs) GetURLsByID(_ context.Context, ids []uint) ([]domain.ShortUrl, error) {
+	var found []domain.ShortUrl
+	for _, link := range f.links {
+		for _, id := range ids {
+			if link.ID == id {
+				found = append(found, link)
+			}
+		}
+	}
+	return found, nil
+}
+
+// receiver records the requests of a local webhook endpoint and answers with status
+type receiver struct {
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	w.WriteHeader(r.status)
+}
+
+func (r *receiver) respond(status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = status
+}
+
+func (r *receiver) received() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.requests)
+}
+
+func newTestWebhookService(t *testing.T, urls domain.ShortURLRepository) (*WebhookService, *fakeWebhooks) {
+	t.Helper()
+	links := config.LinksConfig{BaseURL: "https://sho.rt", AllowedSchemes: []string{"http", "https"}, ResolveTimeout: time.Second}
+	validator, err := safeurl.NewValidator(links, net.DefaultResolver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.WebhooksConfig{
+		BatchSize:       10,
+		Workers:         2,
+		Timeout:         5 * time.Second,
+		MaxAttempts:     3,
+		BackoffBase:     time.Minute,
+		BackoffMax:      3 * time.Minute,
+		ClickMilestones: []int64{100, 1000, 10000},
+	}
+	repo := newFakeWebhooks()
+	// The receiver listens on loopback, so private addresses are not blocked
+	s := NewWebhookService(repo, urls, newTestDomainService(fakeTXTResolver{}), validator, webhook.NewSender(cfg.Timeout, false), audit.NewLogger(discardAudit{}), cfg)
+	return s, repo
+}
+
+// emit hands an event to the service the way the relay takes it from the outbox
+func emit(t *testing.T, s *WebhookService, id uint, event domain.Event) {
+	t.Helper()
+	bus := events.NewBus()
+	s.Subscribe(bus)
+	e := events.Envelope{ID: id, Type: event.EventType(), OccurredAt: time.Now(), Event: event}
+	if _, err := bus.Dispatch(context.Background(), e, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func webhookPrincipal() *domain.Principal {
+	return &domain.Principal{AccountId: 1, OrganizationId: 7, Role: domain.RoleOwner, Scopes: domain.Scopes{domain.ScopeAdmin}}
+}
+
+func TestWebhookDeliverySigned(t *testing.T) {
+	recv := &receiver{status: http.StatusNoContent}
+	srv := httptest.NewServer(recv)
+	defer srv.Close()
+
+	s, repo := newTestWebhookService(t, nil)
+	ctx := context.Background()
+	p := webhookPrincipal()
+
+	endpoint, err := s.Create(ctx, p, WebhookInput{URL: srv.URL + "/hooks", Events: []string{"link.created", "link.deactivated"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Create(ctx, p, WebhookInput{URL: srv.URL, Events: []string{"link.updated"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	link := &domain.ShortUrl{OrganizationId: 7, ShortCode: "promo", OriginalURL: "https://example.com/", IsActive: true, Version: 1}
+	link.ID = 3
+	emit(t, s, 1, domain.LinkCreated{Link: domain.NewLinkState(link)})
+	emit(t, s, 2, domain.LinkCreated{Link: domain.LinkState{ID: 4, OrganizationId: 8, ShortCode: "other"}})
+	if len(repo.deliveries) != 1 {
+		t.Fatalf("queued %d deliveries, want 1 for the subscribed endpoint", len(repo.deliveries))
+	}
+
+	if err := s.Deliver(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if recv.received() != 1 {
+		t.Fatalf("receiver got %d requests, want 1", recv.received())
+	}
+
+	req, body := recv.requests[0], recv.bodies[0]
+	if req.URL.Path != "/hooks" || req.Header.Get(webhook.HeaderEvent) != domain.EventLinkCreated {
+		t.Errorf("request to %s for %s, want /hooks for link.created", req.URL.Path, req.Header.Get(webhook.HeaderEvent))
+	}
+	if err := webhook.Verify(endpoint.Secret, req.Header.Get(webhook.HeaderSignature), body, time.Minute, time.Now()); err != nil {
+		t.Errorf("signature does not verify: %v", err)
+	}
+	if err := webhook.Verify("whsec_other", req.Header.Get(webhook.HeaderSignature), body, time.Minute, time.Now()); !errors.Is(err, webhook.ErrInvalidSignature) {
+		t.Errorf("signature verified with another secret: %v", err)
+	}
+
+	var event WebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.ID != req.Header.Get(webhook.HeaderID) || event.Type != domain.EventLinkCreated || event.Data.Link.ShortURL != "https://sho.rt/promo" {
+		t.Errorf("unexpected payload %s", body)
+	}
+
+	delivered := repo.delivery(1)
+	if delivered.Status != domain.DeliverySucceeded || delivered.Attempts != 1 || delivered.DeliveredAt == nil {
+		t.Errorf("delivery is %s after %d attempts, want succeeded after 1", delivered.Status, delivered.Attempts)
+	}
+
+	// A rotated secret signs the next request
+	rotated, err := s.RotateSecret(ctx, p, endpoint.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deactivated := *link
+	deactivated.IsActive = false
+	emit(t, s, 3, domain.LinkDeactivated{Link: domain.NewLinkState(&deactivated)})
+	if err := s.Deliver(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if recv.received() != 2 || recv.requests[1].Header.Get(webhook.HeaderEvent) != domain.EventLinkDeactivated {
+		t.Fatalf("receiver got %d requests, want a second one for link.deactivated", recv.received())
+	}
+	if err := webhook.Verify(rotated.Secret, recv.requests[1].Header.Get(webhook.HeaderSignature), recv.bodies[1], time.Minute, time.Now()); err != nil {
+		t.Errorf("signature does not verify with the rotated secret: %v", err)
+	}
+}
+
+func TestWebhookRetriesUntilDeadAndReplay(t *testing.T) {
+	recv := &receiver{status: http.StatusServiceUnavailable}
+	srv := httptest.NewServer(recv)
+	defer srv.Close()
+
+	s, repo := newTestWebhookService(t, nil)
+	ctx := context.Background()
+	p := webhookPrincipal()
+
+	endpoint, err := s.Create(ctx, p, WebhookInput{URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	link := &domain.ShortUrl{OrganizationId: 7, ShortCode: "promo", OriginalURL: "https://example.com/"}
+	emit(t, s, 1, domain.LinkCreated{Link: domain.NewLinkState(link)})
+
+	wantBackoff := []time.Duration{time.Minute, 2 * time.Minute}
+	for attempt := 1; attempt <= 3; attempt++ {
+		start := time.Now()
+		if err := s.Deliver(ctx); err != nil {
+			t.Fatal(err)
+		}
+		d := repo.delivery(1)
+		if d.Attempts != attempt || d.LastStatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("after run %d: %d attempts with status %d", attempt, d.Attempts, d.LastStatusCode)
+		}
+		if attempt < 3 {
+			if d.Status != domain.DeliveryRetrying {
+				t.Fatalf("after run %d the delivery is %s, want retrying", attempt, d.Status)
+			}
+			if wait := d.NextAttemptAt.Sub(start); wait < wantBackoff[attempt-1] || wait > wantBackoff[attempt-1]+5*time.Second {
+				t.Errorf("attempt %d is retried after %s, want %s", attempt, wait, wantBackoff[attempt-1])
+			}
+		} else if d.Status != domain.DeliveryDead {
+			t.Fatalf("after run %d the delivery is %s, want dead", attempt, d.Status)
+		}
+	}
+
+	// A dead delivery is not attempted again
+	if err := s.Deliver(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if recv.received() != 3 {
+		t.Fatalf("receiver got %d requests, want 3", recv.received())
+	}
+
+	dead, err := s.Deliveries(ctx, p, domain.DeliveryFilter{EndpointId: endpoint.ID, Status: domain.DeliveryDead, Limit: 10})
+	if err != nil || len(dead) != 1 {
+		t.Fatalf("Deliveries(dead) = %d, %v, want the dead delivery", len(dead), err)
+	}
+
+	recv.respond(http.StatusOK)
+	if _, err := s.Replay(ctx, p, endpoint.ID, dead[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Deliver(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	replayed, err := s.Delivery(ctx, p, endpoint.ID, dead[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.Status != domain.DeliverySucceeded || len(replayed.Log) != 4 {
+		t.Errorf("replayed delivery is %s with %d logged attempts, want succeeded with 4", replayed.Status, len(replayed.Log))
+	}
+	if first, last := recv.requests[0].Header.Get(webhook.HeaderID), recv.requests[3].Header.Get(webhook.HeaderID); first != last {
+		t.Errorf("replay sent event %s, want the original %s", last, first)
+	}
+}
+
+func TestWebhookClickMilestones(t *testing.T) {
+	urls := &fakeClickURLs{}
+	s, repo := newTestWebhookService(t, urls)
+	ctx := context.Background()
+
+	if _, err := s.Create(ctx, webhookPrincipal(), WebhookInput{URL: "http://127.0.0.1:9/", Events: []string{domain.EventClickMilestone}}); err != nil {
+		t.Fatal(err)
+	}
+
+	urls.links = []domain.ShortUrl{
+		{OrganizationId: 7, ShortCode: "a", Clicks: 1005},
+		{OrganizationId: 7, ShortCode: "b", Clicks: 99},
+		{OrganizationId: 8, ShortCode: "c", Clicks: 500},
+	}
+	for i := range urls.links {
+		urls.links[i].ID = uint(i + 1)
+	}
+	flushed := []domain.ClickRecorded{
+		{LinkId: 1, OrganizationId: 7, Count: 910, Clicks: 1005},
+		{LinkId: 2, OrganizationId: 7, Count: 5, Clicks: 99},
+		{LinkId: 3, OrganizationId: 8, Count: 450, Clicks: 500},
+	}
+	for i, event := range flushed {
+		emit(t, s, uint(i+1), event)
+	}
+	// An event handed out again queues nothing new
+	emit(t, s, 1, flushed[0])
+
+	var milestones []int64
+	for _, d := range repo.deliveries {
+		var event WebhookEvent
+		if err := json.Unmarshal([]byte(d.Payload), &event); err != nil {
+			t.Fatal(err)
+		}
+		if event.Data.Link.ShortCode != "a" {
+			t.Errorf("milestone queued for link %s", event.Data.Link.ShortCode)
+		}
+		milestones = append(milestones, event.Data.Milestone)
+	}
+	sort.Slice(milestones, func(i, j int) bool { return milestones[i] < milestones[j] })
+	if len(milestones) != 2 || milestones[0] != 100 || milestones[1] != 1000 {
+		t.Errorf("queued milestones %v, want [100 1000]", milestones)
+	}
+}
+
+func TestWebhookCreateRejects(t *testing.T) {
+	s, _ := newTestWebhookService(t, nil)
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		in    WebhookInput
+		field string
+	}{
+		{"missing url", WebhookInput{}, "url"},
+		{"scheme", WebhookInput{URL: "ftp://example.com/hook"}, "url"},
+		{"short domain", WebhookInput{URL: "https://sho.rt/hook"}, "url"},
+		{"unknown event", WebhookInput{URL: "https://example.com/hook", Events: []string{"link.created", "link.deleted"}}, "events[1]"},
+	}
+	for _, tt := range tests {
+		_, err := s.Create(ctx, webhookPrincipal(), tt.in)
+		var verr *domain.ValidationError
+		if !errors.As(err, &verr) || len(verr.Fields) != 1 || verr.Fields[0].Field != tt.field {
+			t.Errorf("%s: Create() = %v, want a validation error on %s", tt.name, err, tt.field)
+		}
+	}
+
+	viewer := &domain.Principal{AccountId: 2, OrganizationId: 7, Role: domain.RoleEditor, Scopes: domain.Scopes{domain.ScopeAdmin}}
+	if _, err := s.Create(ctx, viewer, WebhookInput{URL: "https://example.com/hook"}); !errors.Is(err, domain.ErrForbidden) {
+		t.Errorf("Create() by an editor = %v, want forbidden", err)
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	s := &WebhookService{cfg: config.WebhooksConfig{BackoffBase: 30 * time.Second, BackoffMax: 6 * time.Hour}}
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{5, 8 * time.Minute},
+		{10, 256 * time.Minute},
+		{11, 6 * time.Hour},
+		{60, 6 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := s.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package targeting
+
+import "hash/fnv"
+
+// StickyHash is the hash an A/B split assigns a visitor without a variant
+// cookie by. It is stable for the client address and user agent, and the link
+// code is mixed in so a visitor does not land in the same bucket of every split.
+func StickyHash(code, ip, userAgent string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(code))
+	h.Write([]byte{0})
+	h.Write([]byte(ip))
+	h.Write([]byte{0})
+	h.Write([]byte(userAgent))
+
+	// FNV leaves the low bits poorly mixed for similar inputs, the splitmix64
+	// finalizer spreads them before the hash is reduced modulo the weights
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,145 @@
+// Package targeting derives the attributes redirect rules match on from a
+// redirect request.
+package targeting
+
+import (
+	"coding2fun.in/url-shortner/internal/domain"
+	"coding2fun.in/url-shortner/internal/geoip"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Visitor is the domain.Visitor of a request, each attribute is derived once
+// and only when a rule asks for it
+type Visitor struct {
+	req *http.Request
+	ip  string
+	geo *geoip.Locator
+
+	country, os, device, language, referrer string
+	looked                                  uint8
+}
+
+const (
+	lookedCountry uint8 = 1 << iota
+	lookedPlatform
+	lookedLanguage
+	lookedReferrer
+)
+
+var _ domain.Visitor = (*Visitor)(nil)
+
+// NewVisitor describes the request, ip is the client address the caller
+// resolved, gin's ClientIP behind the configured trusted proxies or the address
+// a trusted gRPC caller forwards, and geo may be nil
+func NewVisitor(req *http.Request, ip string, geo *geoip.Locator) *Visitor {
+	return &Visitor{req: req, ip: ip, geo: geo}
+}
+
+func (v *Visitor) Country() string {
+	if v.looked&lookedCountry == 0 {
+		v.looked |= lookedCountry
+		v.country = v.geo.Country(net.ParseIP(v.ip))
+	}
+	return v.country
+}
+
+func (v *Visitor) Platform() (string, string) {
+	if v.looked&lookedPlatform == 0 {
+		v.looked |= lookedPlatform
+		v.os, v.device = Platform(v.req.UserAgent())
+	}
+	return v.os, v.device
+}
+
+func (v *Visitor) Language() string {
+	if v.looked&lookedLanguage == 0 {
+		v.looked |= lookedLanguage
+		v.language = PreferredLanguage(v.req.Header.Get("Accept-Language"))
+	}
+	return v.language
+}
+
+func (v *Visitor) Referrer() string {
+	if v.looked&lookedReferrer == 0 {
+		v.looked |= lookedReferrer
+		if u, err := url.Parse(v.req.Referer()); err == nil {
+			v.referrer = strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+		}
+	}
+	return v.referrer
+}
+
+// botMarkers are user agent fragments of crawlers and link preview fetchers
+var botMarkers = []string{"bot", "crawler", "spider", "slurp", "facebookexternalhit", "preview", "curl/", "wget/"}
+
+// Platform returns the operating system and device class of a user agent as
+// named by domain.RuleOS and domain.RuleDevices, empty when they are unknown
+func Platform(userAgent string) (os, device string) {
+	if userAgent == "" {
+		return "", ""
+	}
+	ua := strings.ToLower(userAgent)
+
+	switch {
+	case strings.Contains(ua, "iphone") || strings.Contains(ua, "ipad") || strings.Contains(ua, "ipod"):
+		os = "ios"
+	case strings.Contains(ua, "android"):
+		os = "android"
+	case strings.Contains(ua, "windows"):
+		os = "windows"
+	case strings.Contains(ua, "cros"):
+		os = "chromeos"
+	case strings.Contains(ua, "macintosh") || strings.Contains(ua, "mac os x"):
+		os = "macos"
+	case strings.Contains(ua, "linux"):
+		os = "linux"
+	}
+
+	for _, marker := range botMarkers {
+		if strings.Contains(ua, marker) {
+			return os, "bot"
+		}
+	}
+	switch {
+	case strings.Contains(ua, "ipad") || strings.Contains(ua, "tablet") || os == "android" && !strings.Contains(ua, "mobile"):
+		device = "tablet"
+	case strings.Contains(ua, "mobi") || strings.Contains(ua, "iphone") || strings.Contains(ua, "ipod"):
+		device = "mobile"
+	default:
+		device = "desktop"
+	}
+	return os, device
+}
+
+// PreferredLanguage returns the lower case language tag with the highest
+// quality in an Accept-Language header, the first one wins a tie
+func PreferredLanguage(header string) string {
+	var best string
+	bestQ := 0.0
+	for header != "" {
+		var item string
+		item, header, _ = strings.Cut(header, ",")
+		tag, params, _ := strings.Cut(item, ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > bestQ {
+			best, bestQ = tag, q
+		}
+	}
+	return strings.ToLower(best)
+}
@@ -0,0 +1,178 @@
+package targeting
+
+import (
+	"coding2fun.in/url-shortner/internal/domain"
+	"fmt"
+	"math"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const (
+	iphoneUA  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1"
+	ipadUA    = "Mozilla/5.0 (iPad; CPU OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1"
+	pixelUA   = "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36"
+	galaxyUA  = "Mozilla/5.0 (Linux; Android 13; SM-X710) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+	windowsUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+	macUA     = "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_4) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Safari/605.1.15"
+	googleUA  = "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+)
+
+func TestPlatform(t *testing.T) {
+	tests := []struct {
+		ua, os, device string
+	}{
+		{iphoneUA, "ios", "mobile"},
+		{ipadUA, "ios", "tablet"},
+		{pixelUA, "android", "mobile"},
+		{galaxyUA, "android", "tablet"},
+		{windowsUA, "windows", "desktop"},
+		{macUA, "macos", "desktop"},
+		{"Mozilla/5.0 (X11; CrOS x86_64 14541.0.0) AppleWebKit/537.36", "chromeos", "desktop"},
+		{"Mozilla/5.0 (X11; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0", "linux", "desktop"},
+		{googleUA, "", "bot"},
+		{"curl/8.5.0", "", "bot"},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		os, device := Platform(tt.ua)
+		if os != tt.os || device != tt.device {
+			t.Errorf("Platform(%q) = %q, %q, want %q, %q", tt.ua, os, device, tt.os, tt.device)
+		}
+	}
+}
+
+func TestPreferredLanguage(t *testing.T) {
+	tests := []struct {
+		header, want string
+	}{
+		{"de-DE,de;q=0.9,en;q=0.8", "de-de"},
+		{"en;q=0.5, fr-CA", "fr-ca"},
+		{"*, es;q=0.7", "es"},
+		{"pt-BR;q=0.9, pt;q=0.9", "pt-br"},
+		{"en;q=0, it;q=bad", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := PreferredLanguage(tt.header); got != tt.want {
+			t.Errorf("PreferredLanguage(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestTarget(t *testing.T) {
+	noon := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	from, until := noon.Add(-time.Hour), noon.Add(time.Hour)
+	link := &domain.ShortUrl{
+		OriginalURL: "https://example.com/",
+		Rules: []domain.RedirectRule{
+			{Destination: "https://example.com/de", Countries: []string{"DE"}},
+			{Destination: "https://example.com/ios", OS: []string{"ios"}, Languages: []string{"en"}},
+			{Destination: "https://example.com/tablet", Devices: []string{"tablet"}},
+			{Destination: "https://example.com/social", Referrers: []string{"t.co", "facebook.com"}},
+			{Destination: "https://example.com/night", DailyFrom: "22:00", DailyUntil: "06:00", TimeZone: "Asia/Tokyo"},
+			{Destination: "https://example.com/launch", From: &from, Until: &until, Languages: []string{"fr"}},
+		},
+	}
+
+	tests := []struct {
+		name              string
+		ua, lang, referer string
+		now               time.Time
+		want              string
+	}{
+		{"no match", windowsUA, "de", "", noon, "https://example.com/"},
+		{"os and language", iphoneUA, "en-US,en;q=0.9", "", noon, "https://example.com/ios"},
+		{"language mismatch", iphoneUA, "es", "", noon, "https://example.com/"},
+		{"device", ipadUA, "de", "", noon, "https://example.com/tablet"},
+		{"referrer subdomain", windowsUA, "", "https://m.facebook.com/story", noon, "https://example.com/social"},
+		{"referrer lookalike", windowsUA, "", "https://notfacebook.com/", noon, "https://example.com/"},
+		{"daily window past midnight", windowsUA, "", "", time.Date(2026, 3, 10, 14, 30, 0, 0, time.UTC), "https://example.com/night"},
+		{"time window", macUA, "fr", "", noon, "https://example.com/launch"},
+		{"time window not started", macUA, "fr", "", from.Add(-time.Minute), "https://example.com/"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/code", nil)
+		req.Header.Set("User-Agent", tt.ua)
+		req.Header.Set("Accept-Language", tt.lang)
+		req.Header.Set("Referer", tt.referer)
+
+		// Without a geoip database the country rule never matches
+		if got := link.Target(NewVisitor(req, "203.0.113.7", nil), tt.now); got != tt.want {
+			t.Errorf("%s: Target() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStickyHashSplit(t *testing.T) {
+	link := &domain.ShortUrl{Variants: []domain.LinkVariant{
+		{ID: 1, Destination: "https://example.com/a", Weight: 70},
+		{ID: 2, Destination: "https://example.com/b", Weight: 30},
+	}}
+
+	const visitors = 20000
+	counts := make(map[uint]int)
+	for i := 0; i < visitors; i++ {
+		ip := fmt.Sprintf("10.0.%d.%d", i/256, i%256)
+		variant := link.PickVariant(StickyHash("promo", ip, windowsUA))
+		if again := link.PickVariant(StickyHash("promo", ip, windowsUA)); again != variant {
+			t.Fatalf("PickVariant() for %s = %d, then %d", ip, variant.ID, again.ID)
+		}
+		counts[variant.ID]++
+	}
+	if share := float64(counts[1]) / visitors; math.Abs(share-0.7) > 0.02 {
+		t.Errorf("variant a got %.3f of the visitors, want 0.7", share)
+	}
+}
+
+func BenchmarkTarget(b *testing.B) {
+	rules := make([]domain.RedirectRule, 0, 10)
+	for _, country := range []string{"DE", "FR", "IT", "ES", "NL", "BE", "AT", "CH", "PL"} {
+		rules = append(rules, domain.RedirectRule{Destination: "https://example.com/" + country, Countries: []string{country}, Devices: []string{"mobile"}})
+	}
+	rules = append(rules, domain.RedirectRule{
+		Destination: "https://example.com/match",
+		OS:          []string{"android"},
+		Languages:   []string{"pt"},
+		Referrers:   []string{"t.co"},
+		DailyFrom:   "00:00",
+		DailyUntil:  "23:59",
+		TimeZone:    "America/Sao_Paulo",
+	})
+	link := &domain.ShortUrl{OriginalURL: "https://example.com/", Rules: rules}
+
+	req := httptest.NewRequest("GET", "/code", nil)
+	req.Header.Set("User-Agent", pixelUA)
+	req.Header.Set("Accept-Language", "pt-BR,pt;q=0.9,en-US;q=0.8")
+	req.Header.Set("Referer", "https://t.co/abc")
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if link.Target(NewVisitor(req, "203.0.113.7", nil), now) != "https://example.com/match" {
+			b.Fatal("the last rule did not match")
+		}
+	}
+}
+
+func BenchmarkTargetWithoutRules(b *testing.B) {
+	link := &domain.ShortUrl{OriginalURL: "https://example.com/"}
+	req := httptest.NewRequest("GET", "/code", nil)
+	now := time.Now()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		link.Target(NewVisitor(req, "203.0.113.7", nil), now)
+	}
+}
+
+func BenchmarkPlatform(b *testing.B) {
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Platform(pixelUA)
+	}
+}
@@ -0,0 +1,144 @@
+package threat
+
+import (
+	"coding2fun.in/url-shortner/internal/config"
+	"coding2fun.in/url-shortner/internal/log"
+	"coding2fun.in/url-shortner/internal/safeurl"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Checker matches urls against local phishing and malware lists. The lists are
+// reloaded as a whole when any of their files changes.
+type Checker struct {
+	hashPaths   []string
+	domainPaths []string
+
+	mu      sync.RWMutex
+	hashes  []*hashList
+	domains []*domainList
+	stamps  map[string]time.Time
+}
+
+func NewChecker(cfg config.ThreatConfig) (*Checker, error) {
+	c := &Checker{hashPaths: cfg.HashLists, domainPaths: cfg.DomainLists}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Check reports whether the url is listed and names the list that matched
+func (c *Checker) Check(rawURL string) (string, bool) {
+	u, err := safeurl.Normalize(rawURL)
+	if err != nil {
+		return "", false
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	host := u.Hostname()
+	for _, list := range c.domains {
+		if list.contains(host) {
+			return "domain list " + list.name, true
+		}
+	}
+
+	if len(c.hashes) == 0 {
+		return "", false
+	}
+	for _, expr := range expressions(u) {
+		sum := sha256.Sum256([]byte(expr))
+		for _, list := range c.hashes {
+			if list.contains(sum) {
+				return "hash list " + list.name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// ReloadIfChanged reloads the lists when a file was modified since the last
+// load. A list that fails to load keeps the previous lists in place.
+func (c *Checker) ReloadIfChanged(_ context.Context) error {
+	changed, err := c.changed()
+	if err != nil || !changed {
+		return err
+	}
+	return c.load()
+}
+
+func (c *Checker) changed() (bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, path := range append(c.hashPaths, c.domainPaths...) {
+		info, err := os.Stat(path)
+		if err != nil {
+			return false, fmt.Errorf("failed to stat threat list: %w", err)
+		}
+		if !info.ModTime().Equal(c.stamps[path]) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (c *Checker) load() error {
+	stamps := make(map[string]time.Time)
+
+	hashes := make([]*hashList, 0, len(c.hashPaths))
+	for _, path := range c.hashPaths {
+		list, err := loadFile(path, stamps, parseHashList)
+		if err != nil {
+			return err
+		}
+		log.Info("Loaded threat hash list", zap.String("list", list.name), zap.Int("entries", list.size()))
+		hashes = append(hashes, list)
+	}
+
+	domains := make([]*domainList, 0, len(c.domainPaths))
+	for _, path := range c.domainPaths {
+		list, err := loadFile(path, stamps, parseDomainList)
+		if err != nil {
+			return err
+		}
+		log.Info("Loaded threat domain list", zap.String("list", list.name), zap.Int("entries", len(list.domains)))
+		domains = append(domains, list)
+	}
+
+	c.mu.Lock()
+	c.hashes, c.domains, c.stamps = hashes, domains, stamps
+	c.mu.Unlock()
+	return nil
+}
+
+func loadFile[T any](path string, stamps map[string]time.Time, parse func(string, io.Reader) (T, error)) (T, error) {
+	var zero T
+
+	f, err := os.Open(path)
+	if err != nil {
+		return zero, fmt.Errorf("failed to open threat list: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return zero, fmt.Errorf("failed to stat threat list: %w", err)
+	}
+	stamps[path] = info.ModTime()
+
+	list, err := parse(filepath.Base(path), f)
+	if err != nil {
+		return zero, fmt.Errorf("failed to parse threat list: %w", err)
+	}
+	return list, nil
+}
@@ -0,0 +1,114 @@
+package threat
+
+import (
+	"coding2fun.in/url-shortner/internal/config"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeList writes a list file and moves its modification time forward, so a
+// rewrite is seen even on file systems with a coarse clock
+func writeList(t *testing.T, path, content string, stamp time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, stamp, stamp); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	phish := sha256.Sum256([]byte("evil.example/phish/"))
+	hashes := filepath.Join(dir, "hashes.txt")
+	domains := filepath.Join(dir, "domains.txt")
+	now := time.Now()
+	writeList(t, hashes, hex.EncodeToString(phish[:])+"\n", now)
+	writeList(t, domains, "malware.example\n", now)
+
+	c, err := NewChecker(config.ThreatConfig{HashLists: []string{hashes}, DomainLists: []string{domains}})
+	if err != nil {
+		t.Fatalf("NewChecker() error = %v", err)
+	}
+
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"https://www.evil.example/phish/login.html?next=1", "hash list hashes.txt"},
+		{"https://EVIL.example./phish/", "hash list hashes.txt"},
+		{"https://evil.example/other/", ""},
+		{"https://cdn.malware.example/x", "domain list domains.txt"},
+		{"https://example.com/", ""},
+		{"not a url", ""},
+	}
+	for _, tt := range tests {
+		got, listed := c.Check(tt.raw)
+		if got != tt.want || listed != (tt.want != "") {
+			t.Errorf("Check(%q) = %q, %v, want %q", tt.raw, got, listed, tt.want)
+		}
+	}
+}
+
+func TestReloadIfChanged(t *testing.T) {
+	dir := t.TempDir()
+	hashes := filepath.Join(dir, "hashes.txt")
+	domains := filepath.Join(dir, "domains.txt")
+	stamp := time.Now().Add(-time.Hour)
+	writeList(t, hashes, "", stamp)
+	writeList(t, domains, "one.example\n", stamp)
+
+	c, err := NewChecker(config.ThreatConfig{HashLists: []string{hashes}, DomainLists: []string{domains}})
+	if err != nil {
+		t.Fatalf("NewChecker() error = %v", err)
+	}
+	listed := func(host string) bool {
+		_, ok := c.Check("https://" + host + "/")
+		return ok
+	}
+
+	// Unchanged files are not read again, content written under the old
+	// modification time stays unseen
+	writeList(t, domains, "two.example\n", stamp)
+	if err := c.ReloadIfChanged(context.Background()); err != nil {
+		t.Fatalf("ReloadIfChanged() without a change error = %v", err)
+	}
+	if !listed("one.example") || listed("two.example") {
+		t.Fatal("ReloadIfChanged() reloaded lists that did not change")
+	}
+
+	stamp = stamp.Add(time.Minute)
+	writeList(t, domains, "two.example\n", stamp)
+	if err := c.ReloadIfChanged(context.Background()); err != nil {
+		t.Fatalf("ReloadIfChanged() error = %v", err)
+	}
+	if listed("one.example") || !listed("two.example") {
+		t.Fatal("ReloadIfChanged() did not load the changed list")
+	}
+
+	stamp = stamp.Add(time.Minute)
+	writeList(t, hashes, "not a hash\n", stamp)
+	writeList(t, domains, "three.example\n", stamp)
+	if err := c.ReloadIfChanged(context.Background()); err == nil {
+		t.Fatal("ReloadIfChanged() of a malformed list error = nil, want an error")
+	}
+	if !listed("two.example") || listed("three.example") {
+		t.Error("a malformed list replaced the lists loaded before")
+	}
+
+	if err := os.Remove(hashes); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ReloadIfChanged(context.Background()); err == nil {
+		t.Error("ReloadIfChanged() of a removed list error = nil, want an error")
+	}
+	if !listed("two.example") {
+		t.Error("a removed list dropped the lists loaded before")
+	}
+}
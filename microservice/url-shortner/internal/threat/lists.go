@@ -0,0 +1,142 @@
+package threat
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"golang.org/x/net/idna"
+	"io"
+	"net/netip"
+	"net/url"
+	"strings"
+)
+
+const minPrefixBytes = 4
+
+// hashList holds Safe Browsing style SHA-256 hashes of url expressions indexed
+// by their first four bytes. Entries shorter than a full hash match every hash
+// they are a prefix of.
+type hashList struct {
+	name    string
+	buckets map[uint32][][]byte
+}
+
+func parseHashList(name string, r io.Reader) (*hashList, error) {
+	list := &hashList{name: name, buckets: make(map[uint32][][]byte)}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		hash, err := hex.DecodeString(entry)
+		if err != nil || len(hash) < minPrefixBytes || len(hash) > sha256.Size {
+			return nil, fmt.Errorf("%s:%d: expected a hex encoded hash prefix of 4 to 32 bytes", name, line)
+		}
+		key := binary.BigEndian.Uint32(hash)
+		list.buckets[key] = append(list.buckets[key], hash)
+	}
+	return list, scanner.Err()
+}
+
+func (l *hashList) contains(sum [sha256.Size]byte) bool {
+	for _, entry := range l.buckets[binary.BigEndian.Uint32(sum[:])] {
+		if string(sum[:len(entry)]) == string(entry) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *hashList) size() int {
+	n := 0
+	for _, bucket := range l.buckets {
+		n += len(bucket)
+	}
+	return n
+}
+
+// domainList is a plain list of domains, a listed domain also covers its subdomains
+type domainList struct {
+	name    string
+	domains map[string]bool
+}
+
+func parseDomainList(name string, r io.Reader) (*domainList, error) {
+	list := &domainList{name: name, domains: make(map[string]bool)}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		domain, err := idna.Lookup.ToASCII(strings.TrimRight(strings.ToLower(entry), "."))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid domain %q", name, line, entry)
+		}
+		list.domains[domain] = true
+	}
+	return list, scanner.Err()
+}
+
+func (l *domainList) contains(host string) bool {
+	for {
+		if l.domains[host] {
+			return true
+		}
+		i := strings.IndexByte(host, '.')
+		if i < 0 {
+			return false
+		}
+		host = host[i+1:]
+	}
+}
+
+// expressions returns the host suffix and path prefix combinations of a
+// canonical url that are looked up in the hash lists, following the Safe
+// Browsing lookup rules: up to five hosts and six paths
+func expressions(u *url.URL) []string {
+	host := u.Hostname()
+	hosts := []string{host}
+	if _, err := netip.ParseAddr(host); err != nil {
+		parts := strings.Split(host, ".")
+		for i := max(len(parts)-5, 1); i < len(parts)-1; i++ {
+			hosts = append(hosts, strings.Join(parts[i:], "."))
+		}
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	var paths []string
+	if u.RawQuery != "" {
+		paths = append(paths, path+"?"+u.RawQuery)
+	}
+	paths = append(paths, path, "/")
+	prefix := "/"
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i := 0; i < len(segments)-1 && i < 3; i++ {
+		prefix += segments[i] + "/"
+		paths = append(paths, prefix)
+	}
+
+	seen := make(map[string]bool)
+	var exprs []string
+	for _, h := range hosts {
+		for _, p := range paths {
+			expr := h + p
+			if !seen[expr] {
+				seen[expr] = true
+				exprs = append(exprs, expr)
+			}
+		}
+	}
+	return exprs
+}
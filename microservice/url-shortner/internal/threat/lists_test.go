@@ -0,0 +1,122 @@
+package threat
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHashListMatchesPrefixesAndFullHashes(t *testing.T) {
+	prefixed := sha256.Sum256([]byte("prefix.example/"))
+	full := sha256.Sum256([]byte("full.example/"))
+	list, err := parseHashList("test", strings.NewReader("# comment\n\n"+
+		hex.EncodeToString(prefixed[:4])+"\n"+
+		hex.EncodeToString(full[:])+"\n"))
+	if err != nil {
+		t.Fatalf("parseHashList() error = %v", err)
+	}
+
+	// Both differ from a listed hash in the last byte only
+	prefixedTwin, fullTwin := prefixed, full
+	prefixedTwin[sha256.Size-1] ^= 1
+	fullTwin[sha256.Size-1] ^= 1
+
+	tests := []struct {
+		name string
+		sum  [sha256.Size]byte
+		want bool
+	}{
+		{"listed prefix", prefixed, true},
+		{"hash sharing the listed prefix", prefixedTwin, true},
+		{"listed full hash", full, true},
+		{"hash sharing the bucket of a full hash", fullTwin, false},
+		{"unlisted hash", sha256.Sum256([]byte("other.example/")), false},
+	}
+	for _, tt := range tests {
+		if got := list.contains(tt.sum); got != tt.want {
+			t.Errorf("contains() of the %s = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if list.size() != 2 {
+		t.Errorf("size() = %d, want 2", list.size())
+	}
+}
+
+func TestParseHashListRejects(t *testing.T) {
+	tests := []string{
+		"not hex",
+		"abc",
+		"010203",
+		strings.Repeat("ab", sha256.Size+1),
+	}
+	for _, entry := range tests {
+		if _, err := parseHashList("test", strings.NewReader(entry)); err == nil {
+			t.Errorf("parseHashList(%q) error = nil, want an error", entry)
+		}
+	}
+}
+
+func TestDomainListMatchesSubdomains(t *testing.T) {
+	list, err := parseDomainList("test", strings.NewReader("# comment\nEvil.EXAMPLE.\nbücher.example\n"))
+	if err != nil {
+		t.Fatalf("parseDomainList() error = %v", err)
+	}
+
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"evil.example", true},
+		{"www.evil.example", true},
+		{"a.b.evil.example", true},
+		{"xn--bcher-kva.example", true},
+		{"notevil.example", false},
+		{"good.example", false},
+		{"evil.example.com", false},
+		{"example", false},
+	}
+	for _, tt := range tests {
+		if got := list.contains(tt.host); got != tt.want {
+			t.Errorf("contains(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestExpressions(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want []string
+	}{
+		{"http://a.b.c/1/2.html?param=1", []string{
+			"a.b.c/1/2.html?param=1", "a.b.c/1/2.html", "a.b.c/", "a.b.c/1/",
+			"b.c/1/2.html?param=1", "b.c/1/2.html", "b.c/", "b.c/1/",
+		}},
+		{"http://example.com?q=1", []string{"example.com/?q=1", "example.com/"}},
+		{"http://example.com", []string{"example.com/"}},
+		{"http://1.2.3.4/1/", []string{"1.2.3.4/1/", "1.2.3.4/"}},
+		{"http://[2001:db8::1]/a/b", []string{"2001:db8::1/a/b", "2001:db8::1/", "2001:db8::1/a/"}},
+		{"http://a.b.c.d.e.f.g/1.html", []string{
+			"a.b.c.d.e.f.g/1.html", "a.b.c.d.e.f.g/",
+			"c.d.e.f.g/1.html", "c.d.e.f.g/",
+			"d.e.f.g/1.html", "d.e.f.g/",
+			"e.f.g/1.html", "e.f.g/",
+			"f.g/1.html", "f.g/",
+		}},
+		{"http://example.com/a/b/c/d/e.html", []string{
+			"example.com/a/b/c/d/e.html", "example.com/",
+			"example.com/a/", "example.com/a/b/", "example.com/a/b/c/",
+		}},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := expressions(u); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expressions(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
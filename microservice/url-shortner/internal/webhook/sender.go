@@ -0,0 +1,170 @@
+// Package webhook signs webhook payloads and posts them to their endpoints.
+//
+// Every request carries the X-Webhook-Signature header "t=<unix time>,v1=<hex>"
+// where v1 is the HMAC-SHA256 of "<unix time>.<body>" keyed with the endpoint
+// secret. Receivers recompute it and reject stale timestamps to stop replays.
+package webhook
+
+import (
+	"bytes"
+	"coding2fun.in/url-shortner/internal/safeurl"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Request headers of a delivery
+const (
+	HeaderID        = "X-Webhook-Id"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// maxErrorBody caps how much of a failed response is kept in the delivery log
+const maxErrorBody = 512
+
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Message is one signed request
+type Message struct {
+	ID    string
+	Event string
+	Body  []byte
+}
+
+// Result is the outcome of one request, Err is nil for a 2xx response
+type Result struct {
+	StatusCode int
+	Err        error
+	Duration   time.Duration
+}
+
+// Sender posts messages to webhook endpoints. It does not follow redirects and,
+// when private networks are blocked, refuses to connect to addresses that are
+// not publicly routable, whatever the endpoint host resolves to at send time.
+type Sender struct {
+	client    *http.Client
+	userAgent string
+}
+
+func NewSender(timeout time.Duration, blockPrivate bool) *Sender {
+	dialer := &net.Dialer{Timeout: timeout}
+	if blockPrivate {
+		dialer.Control = publicOnly
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = nil
+
+	return &Sender{
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		userAgent: "url-shortner-webhooks/1.0",
+	}
+}
+
+// Send posts the message to the url signed with the secret
+func (s *Sender) Send(ctx context.Context, url, secret string, msg Message) Result {
+	start := time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(msg.Body))
+	if err != nil {
+		return Result{Err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", s.userAgent)
+	req.Header.Set(HeaderID, msg.ID)
+	req.Header.Set(HeaderEvent, msg.Event)
+	req.Header.Set(HeaderSignature, Sign(secret, start, msg.Body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return Result{Err: err, Duration: time.Since(start)}
+	}
+	defer resp.Body.Close()
+
+	result := Result{StatusCode: resp.StatusCode, Duration: time.Since(start)}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		result.Err = fmt.Errorf("endpoint responded %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	} else {
+		// Drain a little so the connection can be reused
+		io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBody))
+	}
+	return result
+}
+
+// Sign returns the signature header of a body sent at t
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac(secret, ts, body))
+}
+
+// Verify checks a signature header against the body, signatures older than
+// tolerance are rejected
+func Verify(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var ts string
+	var signatures [][]byte
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			if sig, err := hex.DecodeString(value); err == nil {
+				signatures = append(signatures, sig)
+			}
+		}
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("signature timestamp is outside the tolerance: %w", ErrInvalidSignature)
+	}
+	expected := mac(secret, ts, body)
+	for _, sig := range signatures {
+		if hmac.Equal(sig, expected) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+func mac(secret, ts string, body []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(ts))
+	h.Write([]byte("."))
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+// publicOnly rejects connections to addresses that are not publicly routable
+func publicOnly(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !safeurl.IsPublic(addr) {
+		return fmt.Errorf("refusing to connect to non public address %s", host)
+	}
+	return nil
+}
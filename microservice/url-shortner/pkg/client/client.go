@@ -0,0 +1,334 @@
+// Package client is a typed Go client of the url-shortner /v1 API.
+//
+// Requests that fail with a network error, 429 or a 502, 503 or 504 are
+// retried with exponential backoff and jitter, honouring Retry-After. POST and
+// PATCH requests always carry an Idempotency-Key, generated unless one is
+// given with WithIdempotencyKey, and retries reuse it so they are answered
+// with the first response instead of running again.
+package client
+
+import (
+	"bytes"
+	"context"
+	cryptorand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMaxRetries = 3
+	defaultMinBackoff = 200 * time.Millisecond
+	defaultMaxBackoff = 5 * time.Second
+	defaultUserAgent  = "url-shortner-go-client"
+)
+
+// Client calls the API with one API key
+type Client struct {
+	baseURL      string
+	apiKey       string
+	httpClient   *http.Client
+	organization uint
+	userAgent    string
+	maxRetries   int
+	minBackoff   time.Duration
+	maxBackoff   time.Duration
+}
+
+type Option func(*Client)
+
+// WithHTTPClient sends the requests with the client instead of http.DefaultClient
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+// WithOrganization acts in the organization instead of the account's personal one
+func WithOrganization(id uint) Option {
+	return func(c *Client) { c.organization = id }
+}
+
+// WithRetries changes how often a failed request is retried and the bounds of
+// the backoff between attempts, 0 retries disables them
+func WithRetries(maxRetries int, minBackoff, maxBackoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.minBackoff = minBackoff
+		c.maxBackoff = maxBackoff
+	}
+}
+
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) { c.userAgent = userAgent }
+}
+
+// New creates a client of the API served at baseURL, like https://sho.rt
+func New(baseURL, apiKey string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     apiKey,
+		httpClient: http.DefaultClient,
+		userAgent:  defaultUserAgent,
+		maxRetries: defaultMaxRetries,
+		minBackoff: defaultMinBackoff,
+		maxBackoff: defaultMaxBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// CallOption adjusts a single request
+type CallOption func(*call)
+
+type call struct {
+	idempotencyKey string
+	organization   uint
+	domain         string
+}
+
+// WithIdempotencyKey sends the request with the key, repeating a call with the
+// same key returns the response of the first one
+func WithIdempotencyKey(key string) CallOption {
+	return func(c *call) { c.idempotencyKey = key }
+}
+
+// InOrganization acts in the organization for this request only
+func InOrganization(id uint) CallOption {
+	return func(c *call) { c.organization = id }
+}
+
+// OnDomain addresses a link served on a custom domain
+func OnDomain(host string) CallOption {
+	return func(c *call) { c.domain = host }
+}
+
+// APIError is a request the API answered with an error
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Details    []FieldError
+	RequestID  string
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("url-shortner: %d %s: %s", e.StatusCode, e.Code, e.Message)
+	for _, d := range e.Details {
+		msg += fmt.Sprintf("; %s: %s", d.Field, d.Message)
+	}
+	return msg
+}
+
+// IsNotFound reports whether the error is an API error for a missing resource
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// IsConflict reports whether the error is an API error for a conflicting resource
+func IsConflict(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict
+}
+
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	contentType string
+	body        []byte
+	opts        []CallOption
+}
+
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// jsonRequest builds a request with v encoded as its JSON body, nil sends none
+func jsonRequest(method, path string, v any, opts []CallOption) (*request, error) {
+	req := &request{method: method, path: path, opts: opts}
+	if v != nil {
+		body, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("url-shortner: failed to encode request: %w", err)
+		}
+		req.contentType = "application/json"
+		req.body = body
+	}
+	return req, nil
+}
+
+// do sends the request, retrying it when that is safe. Answers outside the
+// 2xx range are returned as *APIError together with the response.
+func (c *Client) do(ctx context.Context, req *request) (*response, error) {
+	settings := call{organization: c.organization}
+	for _, opt := range req.opts {
+		opt(&settings)
+	}
+	if settings.idempotencyKey == "" && (req.method == http.MethodPost || req.method == http.MethodPatch) {
+		settings.idempotencyKey = newIdempotencyKey()
+	}
+
+	query := url.Values{}
+	for k, v := range req.query {
+		query[k] = v
+	}
+	if settings.domain != "" {
+		query.Set("domain", settings.domain)
+	}
+	target := c.baseURL + req.path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, req, target, settings)
+		if attempt >= c.maxRetries || !retryable(resp, err) || ctx.Err() != nil {
+			if err != nil {
+				return nil, err
+			}
+			return resp, apiError(resp)
+		}
+
+		timer := time.NewTimer(c.backoff(attempt, resp))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, req *request, target string, settings call) (*response, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, target, bytes.NewReader(req.body))
+	if err != nil {
+		return nil, fmt.Errorf("url-shortner: %w", err)
+	}
+	httpReq.Header.Set("X-API-Key", c.apiKey)
+	httpReq.Header.Set("Accept", "application/json")
+	httpReq.Header.Set("User-Agent", c.userAgent)
+	if req.contentType != "" {
+		httpReq.Header.Set("Content-Type", req.contentType)
+	}
+	if settings.organization != 0 {
+		httpReq.Header.Set("X-Organization-Id", strconv.FormatUint(uint64(settings.organization), 10))
+	}
+	if settings.idempotencyKey != "" {
+		httpReq.Header.Set("Idempotency-Key", settings.idempotencyKey)
+	}
+
+	httpResp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("url-shortner: %s %s: %w", req.method, req.path, err)
+	}
+	defer httpResp.Body.Close()
+
+	body, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("url-shortner: failed to read response: %w", err)
+	}
+	return &response{status: httpResp.StatusCode, header: httpResp.Header, body: body}, nil
+}
+
+// retryable tells transient failures, writes are safe to repeat since they
+// carry an idempotency key
+func retryable(resp *response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch resp.status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// backoff is the wait before the next attempt, the server's Retry-After wins
+// over the exponential delay with full jitter
+func (c *Client) backoff(attempt int, resp *response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.header.Get("Retry-After")); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second
+		}
+		if at, err := http.ParseTime(resp.header.Get("Retry-After")); err == nil {
+			return max(time.Until(at), 0)
+		}
+	}
+	delay := c.minBackoff << attempt
+	if delay <= 0 || delay > c.maxBackoff {
+		delay = c.maxBackoff
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return rand.N(delay) + 1
+}
+
+func apiError(resp *response) error {
+	if resp.status < 300 {
+		return nil
+	}
+	var envelope struct {
+		Error struct {
+			Code      string       `json:"code"`
+			Message   string       `json:"message"`
+			Details   []FieldError `json:"details"`
+			RequestID string       `json:"requestId"`
+		} `json:"error"`
+	}
+	apiErr := &APIError{StatusCode: resp.status, Message: http.StatusText(resp.status)}
+	if json.Unmarshal(resp.body, &envelope) == nil && envelope.Error.Code != "" {
+		apiErr.Code = envelope.Error.Code
+		apiErr.Message = envelope.Error.Message
+		apiErr.Details = envelope.Error.Details
+		apiErr.RequestID = envelope.Error.RequestID
+	}
+	return apiErr
+}
+
+// decode parses a JSON response body into out
+func decode(resp *response, out any) error {
+	if err := json.Unmarshal(resp.body, out); err != nil {
+		return fmt.Errorf("url-shortner: failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// doJSON sends v as the JSON body and decodes the answer into out, nil skips either
+func (c *Client) doJSON(ctx context.Context, method, path string, v, out any, opts []CallOption) error {
+	req, err := jsonRequest(method, path, v, opts)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	return decode(resp, out)
+}
+
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	_, _ = cryptorand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func id(v uint) string {
+	return strconv.FormatUint(uint64(v), 10)
+}
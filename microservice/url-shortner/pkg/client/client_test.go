@@ -0,0 +1,178 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder answers with the scripted statuses in turn, the last one repeats,
+// and keeps the Idempotency-Key of every attempt
+type recorder struct {
+	mu       sync.Mutex
+	statuses []int
+	header   http.Header
+	keys     []string
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	attempt := len(r.keys)
+	r.keys = append(r.keys, req.Header.Get("Idempotency-Key"))
+	r.mu.Unlock()
+
+	status := r.statuses[min(attempt, len(r.statuses)-1)]
+	for name, values := range r.header {
+		w.Header()[name] = values
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if status >= 300 {
+		_, _ = w.Write([]byte(`{"error":{"code":"unavailable","message":"try again","requestId":"req-1","details":[{"field":"url","message":"is busy"}]}}`))
+		return
+	}
+	_, _ = w.Write([]byte(`{"shortCode":"abc1234","originalUrl":"https://example.com/"}`))
+}
+
+func (r *recorder) attempts() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.keys...)
+}
+
+func newTestClient(t *testing.T, r *recorder, opts ...Option) *Client {
+	t.Helper()
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return New(srv.URL, "key", append([]Option{WithRetries(3, time.Millisecond, 2*time.Millisecond)}, opts...)...)
+}
+
+func TestRetriesReuseTheIdempotencyKey(t *testing.T) {
+	r := &recorder{statuses: []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusCreated}}
+	c := newTestClient(t, r)
+
+	link, err := c.CreateLink(context.Background(), LinkInput{URL: "https://example.com/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.ShortCode != "abc1234" {
+		t.Errorf("created %+v", link)
+	}
+	keys := r.attempts()
+	if len(keys) != 3 || keys[0] == "" || keys[1] != keys[0] || keys[2] != keys[0] {
+		t.Errorf("attempts sent the keys %q, want one generated key", keys)
+	}
+
+	// A given key is sent as is, a second call generates a new one
+	r = &recorder{statuses: []int{http.StatusTooManyRequests, http.StatusCreated}}
+	c = newTestClient(t, r)
+	if _, err := c.CreateLink(context.Background(), LinkInput{URL: "https://example.com/"}, WithIdempotencyKey("mine")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateLink(context.Background(), LinkInput{URL: "https://example.com/"}); err != nil {
+		t.Fatal(err)
+	}
+	keys = r.attempts()
+	if len(keys) != 3 || keys[0] != "mine" || keys[1] != "mine" || keys[2] == "mine" || keys[2] == "" {
+		t.Errorf("attempts sent the keys %q", keys)
+	}
+}
+
+func TestReadsCarryNoIdempotencyKey(t *testing.T) {
+	r := &recorder{statuses: []int{http.StatusGatewayTimeout, http.StatusOK}}
+	c := newTestClient(t, r)
+	if _, err := c.Folders(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if keys := r.attempts(); len(keys) != 2 || keys[0] != "" || keys[1] != "" {
+		t.Errorf("a read sent the keys %q", keys)
+	}
+}
+
+func TestRetriesStop(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		attempts int
+	}{
+		{"client errors are final", []int{http.StatusUnprocessableEntity}, 1},
+		{"server errors are final", []int{http.StatusInternalServerError}, 1},
+		{"retries run out", []int{http.StatusServiceUnavailable}, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{statuses: tt.statuses}
+			c := newTestClient(t, r)
+			_, err := c.CreateLink(context.Background(), LinkInput{URL: "https://example.com/"})
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("returned %v, want an API error", err)
+			}
+			if apiErr.StatusCode != tt.statuses[0] || apiErr.Code != "unavailable" || apiErr.RequestID != "req-1" || len(apiErr.Details) != 1 {
+				t.Errorf("returned %+v", apiErr)
+			}
+			if got := len(r.attempts()); got != tt.attempts {
+				t.Errorf("made %d attempts, want %d", got, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestRetryAfterWinsOverBackoff(t *testing.T) {
+	// The backoff alone would wait an hour
+	r := &recorder{statuses: []int{http.StatusTooManyRequests, http.StatusCreated}, header: http.Header{"Retry-After": {"0"}}}
+	c := newTestClient(t, r, WithRetries(1, time.Hour, time.Hour))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := c.CreateLink(ctx, LinkInput{URL: "https://example.com/"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(r.attempts()); got != 2 {
+		t.Errorf("made %d attempts, want 2", got)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	c := New("http://sho.rt", "key", WithRetries(3, 100*time.Millisecond, time.Second))
+
+	retryAfter := func(value string) *response {
+		return &response{status: http.StatusTooManyRequests, header: http.Header{"Retry-After": {value}}}
+	}
+	if got := c.backoff(0, retryAfter("7")); got != 7*time.Second {
+		t.Errorf("Retry-After in seconds waits %s", got)
+	}
+	if got := c.backoff(0, retryAfter(time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat))); got != 0 {
+		t.Errorf("a past Retry-After date waits %s", got)
+	}
+	if got := c.backoff(0, retryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))); got < 59*time.Minute || got > time.Hour {
+		t.Errorf("a Retry-After date an hour ahead waits %s", got)
+	}
+
+	for attempt, limit := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, time.Second, time.Second} {
+		for range 20 {
+			if got := c.backoff(attempt, retryAfter("soon")); got <= 0 || got > limit {
+				t.Fatalf("attempt %d waits %s, want up to %s", attempt, got, limit)
+			}
+		}
+	}
+}
+
+func TestCancelStopsTheBackoff(t *testing.T) {
+	r := &recorder{statuses: []int{http.StatusServiceUnavailable}}
+	c := newTestClient(t, r, WithRetries(3, time.Hour, time.Hour))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.CreateLink(ctx, LinkInput{URL: "https://example.com/"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("returned %v, want the deadline", err)
+	}
+	if got := len(r.attempts()); got != 1 {
+		t.Errorf("made %d attempts, want 1", got)
+	}
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// CreateDomain adds a custom domain, publish its verification record and call VerifyDomain
+func (c *Client) CreateDomain(ctx context.Context, in DomainInput, opts ...CallOption) (*Domain, error) {
+	var domain Domain
+	if err := c.doJSON(ctx, http.MethodPost, "/v1/domains", in, &domain, opts); err != nil {
+		return nil, err
+	}
+	return &domain, nil
+}
+
+func (c *Client) Domains(ctx context.Context, opts ...CallOption) ([]Domain, error) {
+	var resp struct {
+		Domains []Domain `json:"domains"`
+	}
+	if err := c.doJSON(ctx, http.MethodGet, "/v1/domains", nil, &resp, opts); err != nil {
+		return nil, err
+	}
+	return resp.Domains, nil
+}
+
+func (c *Client) Domain(ctx context.Context, domainId uint, opts ...CallOption) (*Domain, error) {
+	var domain Domain
+	if err := c.doJSON(ctx, http.MethodGet, "/v1/domains/"+id(domainId), nil, &domain, opts); err != nil {
+		return nil, err
+	}
+	return &domain, nil
+}
+
+func (c *Client) UpdateDomain(ctx context.Context, domainId uint, patch DomainPatch, opts ...CallOption) (*Domain, error) {
+	var domain Domain
+	if err := c.doJSON(ctx, http.MethodPatch, "/v1/domains/"+id(domainId), patch, &domain, opts); err != nil {
+		return nil, err
+	}
+	return &domain, nil
+}
+
+func (c *Client) DeleteDomain(ctx context.Context, domainId uint, opts ...CallOption) error {
+	return c.doJSON(ctx, http.MethodDelete, "/v1/domains/"+id(domainId), nil, nil, opts)
+}
+
+// VerifyDomain checks the TXT record of a domain
+func (c *Client) VerifyDomain(ctx context.Context, domainId uint, opts ...CallOption) (*Domain, error) {
+	var domain Domain
+	if err := c.doJSON(ctx, http.MethodPost, "/v1/domains/"+id(domainId)+"/verify", nil, &domain, opts); err != nil {
+		return nil, err
+	}
+	return &domain, nil
+}
+
+// AuditEvents lists a page of the organization's audit events, newest first
+func (c *Client) AuditEvents(ctx context.Context, q AuditQuery, opts ...CallOption) (*AuditPage, error) {
+	query := url.Values{}
+	if !q.From.IsZero() {
+		query.Set("from", q.From.Format(time.RFC3339Nano))
+	}
+	if !q.To.IsZero() {
+		query.Set("to", q.To.Format(time.RFC3339Nano))
+	}
+	if q.Limit > 0 {
+		query.Set("limit", strconv.Itoa(q.Limit))
+	}
+	if q.Cursor != "" {
+		query.Set("cursor", q.Cursor)
+	}
+
+	resp, err := c.do(ctx, &request{method: http.MethodGet, path: "/v1/audit", query: query, opts: opts})
+	if err != nil {
+		return nil, err
+	}
+	var page AuditPage
+	if err := decode(resp, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
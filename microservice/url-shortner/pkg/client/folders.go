@@ -0,0 +1,37 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+func (c *Client) CreateFolder(ctx context.Context, in FolderInput, opts ...CallOption) (*Folder, error) {
+	var folder Folder
+	if err := c.doJSON(ctx, http.MethodPost, "/v1/folders", in, &folder, opts); err != nil {
+		return nil, err
+	}
+	return &folder, nil
+}
+
+func (c *Client) Folders(ctx context.Context, opts ...CallOption) ([]Folder, error) {
+	var resp struct {
+		Folders []Folder `json:"folders"`
+	}
+	if err := c.doJSON(ctx, http.MethodGet, "/v1/folders", nil, &resp, opts); err != nil {
+		return nil, err
+	}
+	return resp.Folders, nil
+}
+
+func (c *Client) RenameFolder(ctx context.Context, folderId uint, in FolderInput, opts ...CallOption) (*Folder, error) {
+	var folder Folder
+	if err := c.doJSON(ctx, http.MethodPatch, "/v1/folders/"+id(folderId), in, &folder, opts); err != nil {
+		return nil, err
+	}
+	return &folder, nil
+}
+
+// DeleteFolder removes a folder, its links are kept outside of any folder
+func (c *Client) DeleteFolder(ctx context.Context, folderId uint, opts ...CallOption) error {
+	return c.doJSON(ctx, http.MethodDelete, "/v1/folders/"+id(folderId), nil, nil, opts)
+}
@@ -0,0 +1,98 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// CreateAPIKey issues a key for the account, its value is only returned here
+func (c *Client) CreateAPIKey(ctx context.Context, in APIKeyInput, opts ...CallOption) (*APIKey, error) {
+	var key APIKey
+	if err := c.doJSON(ctx, http.MethodPost, "/v1/keys", in, &key, opts); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (c *Client) APIKeys(ctx context.Context, opts ...CallOption) ([]APIKey, error) {
+	var keys []APIKey
+	if err := c.doJSON(ctx, http.MethodGet, "/v1/keys", nil, &keys, opts); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// RotateAPIKey replaces a key, the old one keeps working for the overlap. A
+// zero overlap uses the server's configured window.
+func (c *Client) RotateAPIKey(ctx context.Context, keyId uint, overlap time.Duration, opts ...CallOption) (*APIKey, error) {
+	body := map[string]string{}
+	if overlap > 0 {
+		body["overlap"] = overlap.String()
+	}
+	var key APIKey
+	if err := c.doJSON(ctx, http.MethodPost, "/v1/keys/"+id(keyId)+"/rotate", body, &key, opts); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (c *Client) DeactivateAPIKey(ctx context.Context, keyId uint, opts ...CallOption) error {
+	return c.doJSON(ctx, http.MethodDelete, "/v1/keys/"+id(keyId), nil, nil, opts)
+}
+
+// RevokeAPIKeys revokes every key of the account, the client's own included
+func (c *Client) RevokeAPIKeys(ctx context.Context, opts ...CallOption) (int, error) {
+	var resp struct {
+		Revoked int `json:"revoked"`
+	}
+	if err := c.doJSON(ctx, http.MethodDelete, "/v1/keys", nil, &resp, opts); err != nil {
+		return 0, err
+	}
+	return resp.Revoked, nil
+}
+
+// Export downloads the account's data as jsonl or csv, jsonl by default
+func (c *Client) Export(ctx context.Context, format string, opts ...CallOption) ([]byte, error) {
+	query := url.Values{}
+	if format != "" {
+		query.Set("format", format)
+	}
+	resp, err := c.do(ctx, &request{method: http.MethodGet, path: "/v1/export", query: query, opts: opts})
+	if err != nil {
+		return nil, err
+	}
+	return resp.body, nil
+}
+
+// Import loads the links of an archive or a bit.ly export. When the fail
+// strategy meets a taken code the report is returned with the conflict error.
+func (c *Client) Import(ctx context.Context, data []byte, in ImportOptions, opts ...CallOption) (*ImportReport, error) {
+	query := url.Values{}
+	if in.Format != "" {
+		query.Set("format", in.Format)
+	}
+	if in.Conflict != "" {
+		query.Set("conflict", in.Conflict)
+	}
+	if in.Domain != "" {
+		query.Set("domain", in.Domain)
+	}
+
+	req := &request{method: http.MethodPost, path: "/v1/import", query: query, contentType: "application/octet-stream", body: data, opts: opts}
+	resp, err := c.do(ctx, req)
+	// An aborted import answers 409 with its report instead of an error envelope
+	if err != nil && (resp == nil || resp.status != http.StatusConflict) {
+		return nil, err
+	}
+	var report ImportReport
+	if decodeErr := decode(resp, &report); decodeErr != nil || (err != nil && !report.Aborted) {
+		return nil, errors.Join(err, decodeErr)
+	}
+	if report.Aborted {
+		return &report, &APIError{StatusCode: http.StatusConflict, Code: "conflict", Message: "import aborted, a short code is taken"}
+	}
+	return &report, nil
+}
@@ -0,0 +1,224 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func linkPath(code string) string {
+	return "/v1/urls/" + url.PathEscape(code)
+}
+
+func (c *Client) CreateLink(ctx context.Context, in LinkInput, opts ...CallOption) (*Link, error) {
+	var link Link
+	if err := c.doJSON(ctx, http.MethodPost, "/v1/urls", in, &link, opts); err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// Links lists a page of the organization's links, OnDomain limits it to one domain
+func (c *Client) Links(ctx context.Context, q LinkQuery, opts ...CallOption) (*LinkPage, error) {
+	query := url.Values{}
+	flags := []struct {
+		name  string
+		value *bool
+	}{{"active", q.Active}, {"expired", q.Expired}}
+	for _, f := range flags {
+		if f.value != nil {
+			query.Set(f.name, strconv.FormatBool(*f.value))
+		}
+	}
+	if q.Tag != "" {
+		query.Set("tag", q.Tag)
+	}
+	if q.Folder != nil {
+		query.Set("folder", id(*q.Folder))
+	}
+	if q.Host != "" {
+		query.Set("host", q.Host)
+	}
+	if !q.CreatedFrom.IsZero() {
+		query.Set("createdFrom", q.CreatedFrom.Format(time.RFC3339Nano))
+	}
+	if !q.CreatedTo.IsZero() {
+		query.Set("createdTo", q.CreatedTo.Format(time.RFC3339Nano))
+	}
+	if q.Sort != "" {
+		query.Set("sort", q.Sort)
+	}
+	if q.Ascending {
+		query.Set("order", "asc")
+	}
+	if q.Limit > 0 {
+		query.Set("limit", strconv.Itoa(q.Limit))
+	}
+	if q.Cursor != "" {
+		query.Set("cursor", q.Cursor)
+	}
+	if len(q.Fields) > 0 {
+		query.Set("fields", strings.Join(q.Fields, ","))
+	}
+
+	resp, err := c.do(ctx, &request{method: http.MethodGet, path: "/v1/urls", query: query, opts: opts})
+	if err != nil {
+		return nil, err
+	}
+	var page LinkPage
+	if err := decode(resp, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// SearchLinks finds the links whose code, destination, title or tags match
+// the query, best matches first. A limit of 0 uses the server default.
+func (c *Client) SearchLinks(ctx context.Context, q string, limit int, opts ...CallOption) ([]Link, error) {
+	query := url.Values{"q": {q}}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	resp, err := c.do(ctx, &request{method: http.MethodGet, path: "/v1/urls/search", query: query, opts: opts})
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Links []Link `json:"links"`
+	}
+	if err := decode(resp, &result); err != nil {
+		return nil, err
+	}
+	return result.Links, nil
+}
+
+// Tags lists the tags used on the organization's links with their link counts
+func (c *Client) Tags(ctx context.Context, opts ...CallOption) ([]Tag, error) {
+	var resp struct {
+		Tags []Tag `json:"tags"`
+	}
+	if err := c.doJSON(ctx, http.MethodGet, "/v1/tags", nil, &resp, opts); err != nil {
+		return nil, err
+	}
+	return resp.Tags, nil
+}
+
+// BulkCreateLinks creates many links at once, large requests become a job to poll with BulkJob
+func (c *Client) BulkCreateLinks(ctx context.Context, in []LinkInput, opts ...CallOption) (*BulkResult, error) {
+	req, err := jsonRequest(http.MethodPost, "/v1/urls/bulk", in, opts)
+	if err != nil {
+		return nil, err
+	}
+	return c.bulk(ctx, req)
+}
+
+// BulkCreateLinksCSV creates the links of a CSV file with the columns url,
+// slug, expiresAt and tags
+func (c *Client) BulkCreateLinksCSV(ctx context.Context, csv []byte, opts ...CallOption) (*BulkResult, error) {
+	return c.bulk(ctx, &request{method: http.MethodPost, path: "/v1/urls/bulk", contentType: "text/csv", body: csv, opts: opts})
+}
+
+func (c *Client) bulk(ctx context.Context, req *request) (*BulkResult, error) {
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.status == http.StatusAccepted {
+		var job BulkJob
+		if err := decode(resp, &job); err != nil {
+			return nil, err
+		}
+		return &BulkResult{Job: &job}, nil
+	}
+	var report BulkReport
+	if err := decode(resp, &report); err != nil {
+		return nil, err
+	}
+	return &BulkResult{Report: &report}, nil
+}
+
+func (c *Client) BulkJob(ctx context.Context, jobId uint, opts ...CallOption) (*BulkJob, error) {
+	var job BulkJob
+	if err := c.doJSON(ctx, http.MethodGet, "/v1/urls/bulk/"+id(jobId), nil, &job, opts); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// UpdateLink edits a link, use OnDomain for links on a custom domain
+func (c *Client) UpdateLink(ctx context.Context, code string, patch LinkPatch, opts ...CallOption) (*Link, error) {
+	var link Link
+	if err := c.doJSON(ctx, http.MethodPatch, linkPath(code), patch, &link, opts); err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+func (c *Client) LinkHistory(ctx context.Context, code string, opts ...CallOption) (*LinkHistory, error) {
+	var history LinkHistory
+	if err := c.doJSON(ctx, http.MethodGet, linkPath(code)+"/history", nil, &history, opts); err != nil {
+		return nil, err
+	}
+	return &history, nil
+}
+
+// LinkStats reports the clicks of a link and of the variants of its A/B split,
+// it needs the analytics:read permission
+func (c *Client) LinkStats(ctx context.Context, code string, opts ...CallOption) (*LinkStats, error) {
+	var stats LinkStats
+	if err := c.doJSON(ctx, http.MethodGet, linkPath(code)+"/stats", nil, &stats, opts); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// RevertLink restores a prior version of a link
+func (c *Client) RevertLink(ctx context.Context, code string, version int, opts ...CallOption) (*Link, error) {
+	var link Link
+	body := map[string]int{"version": version}
+	if err := c.doJSON(ctx, http.MethodPost, linkPath(code)+"/revert", body, &link, opts); err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// LinkQR renders the short url of a link as a QR code, it returns the image
+// and its content type
+func (c *Client) LinkQR(ctx context.Context, code string, qr QROptions, opts ...CallOption) ([]byte, string, error) {
+	query := url.Values{}
+	if qr.Format != "" {
+		query.Set("format", qr.Format)
+	}
+	if qr.Size > 0 {
+		query.Set("size", strconv.Itoa(qr.Size))
+	}
+	if qr.ECC != "" {
+		query.Set("ecc", qr.ECC)
+	}
+	if qr.Margin != nil {
+		query.Set("margin", strconv.Itoa(*qr.Margin))
+	}
+	if qr.FG != "" {
+		query.Set("fg", qr.FG)
+	}
+	if qr.BG != "" {
+		query.Set("bg", qr.BG)
+	}
+	if qr.Logo {
+		query.Set("logo", "true")
+	}
+
+	resp, err := c.do(ctx, &request{method: http.MethodGet, path: linkPath(code) + "/qr", query: query, opts: opts})
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.status != http.StatusOK {
+		return nil, "", fmt.Errorf("url-shortner: unexpected status %d", resp.status)
+	}
+	return resp.body, resp.header.Get("Content-Type"), nil
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// CreateOrganization creates an organization owned by the account
+func (c *Client) CreateOrganization(ctx context.Context, name string, opts ...CallOption) (*Organization, error) {
+	var org Organization
+	if err := c.doJSON(ctx, http.MethodPost, "/v1/orgs", map[string]string{"name": name}, &org, opts); err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// Organizations lists the organizations of the account with its role in each
+func (c *Client) Organizations(ctx context.Context, opts ...CallOption) ([]Organization, error) {
+	var resp struct {
+		Organizations []Organization `json:"organizations"`
+	}
+	if err := c.doJSON(ctx, http.MethodGet, "/v1/orgs", nil, &resp, opts); err != nil {
+		return nil, err
+	}
+	return resp.Organizations, nil
+}
+
+func (c *Client) AcceptInvitation(ctx context.Context, token string, opts ...CallOption) (*Membership, error) {
+	var membership Membership
+	if err := c.doJSON(ctx, http.MethodPost, "/v1/invitations/accept", map[string]string{"token": token}, &membership, opts); err != nil {
+		return nil, err
+	}
+	return &membership, nil
+}
+
+func (c *Client) Members(ctx context.Context, opts ...CallOption) ([]Member, error) {
+	var resp struct {
+		Members []Member `json:"members"`
+	}
+	if err := c.doJSON(ctx, http.MethodGet, "/v1/org/members", nil, &resp, opts); err != nil {
+		return nil, err
+	}
+	return resp.Members, nil
+}
+
+func (c *Client) UpdateMember(ctx context.Context, accountId uint, role string, opts ...CallOption) error {
+	return c.doJSON(ctx, http.MethodPatch, "/v1/org/members/"+id(accountId), map[string]string{"role": role}, nil, opts)
+}
+
+// RemoveMember removes a member from the organization, members may remove
+// themselves with a key of the full account
+func (c *Client) RemoveMember(ctx context.Context, accountId uint, opts ...CallOption) error {
+	return c.doJSON(ctx, http.MethodDelete, "/v1/org/members/"+id(accountId), nil, nil, opts)
+}
+
+// Invite invites an email address into the organization, the token is only returned here
+func (c *Client) Invite(ctx context.Context, email, role string, opts ...CallOption) (*Invitation, error) {
+	var invitation Invitation
+	body := map[string]string{"email": email, "role": role}
+	if err := c.doJSON(ctx, http.MethodPost, "/v1/org/invitations", body, &invitation, opts); err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func (c *Client) Invitations(ctx context.Context, opts ...CallOption) ([]Invitation, error) {
+	var resp struct {
+		Invitations []Invitation `json:"invitations"`
+	}
+	if err := c.doJSON(ctx, http.MethodGet, "/v1/org/invitations", nil, &resp, opts); err != nil {
+		return nil, err
+	}
+	return resp.Invitations, nil
+}
+
+func (c *Client) RevokeInvitation(ctx context.Context, invitationId uint, opts ...CallOption) error {
+	return c.doJSON(ctx, http.MethodDelete, "/v1/org/invitations/"+id(invitationId), nil, nil, opts)
+}
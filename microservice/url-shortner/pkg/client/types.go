@@ -0,0 +1,479 @@
+package client
+
+import "time"
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// RowError is the failure of one row of a bulk request or an import
+type RowError struct {
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Details []FieldError `json:"details,omitempty"`
+}
+
+type LinkInput struct {
+	URL  string `json:"url"`
+	Slug string `json:"slug,omitempty"`
+	// Title names the link in listings and search
+	Title    string `json:"title,omitempty"`
+	FolderId uint   `json:"folderId,omitempty"`
+	// ExpiresAt is an RFC 3339 time
+	ExpiresAt string   `json:"expiresAt,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+	Password  string   `json:"password,omitempty"`
+	MaxClicks int64    `json:"maxClicks,omitempty"`
+	OneTime   bool     `json:"oneTime,omitempty"`
+	// RedirectType is 301, 302, 307 or 308, 302 by default
+	RedirectType int `json:"redirectType,omitempty"`
+	// Domain is a verified custom domain, the shared domain by default
+	Domain string `json:"domain,omitempty"`
+	UTM    *UTM   `json:"utm,omitempty"`
+	// QueryPassthrough is shortLink or destination to forward the query
+	// string of the short url, the one whose parameters win a conflict
+	QueryPassthrough string `json:"queryPassthrough,omitempty"`
+	// Rules are evaluated in order, the first one the visitor matches picks
+	// the destination and the url is the fallback
+	Rules []RedirectRule `json:"rules,omitempty"`
+	// Variants split the visitors no rule matched over weighted urls
+	Variants []Variant `json:"variants,omitempty"`
+}
+
+// Variant is a weighted destination of an A/B split, visitors keep the
+// variant they were assigned
+type Variant struct {
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+}
+
+// LinkStats holds the clicks of a link and of its variants
+type LinkStats struct {
+	ShortCode     string         `json:"shortCode"`
+	TotalClicks   int64          `json:"totalClicks"`
+	LastClickedAt *time.Time     `json:"lastClickedAt,omitempty"`
+	Variants      []VariantStats `json:"variants,omitempty"`
+}
+
+// VariantStats compares the share of the clicks a variant received with the
+// share its weight asks for
+type VariantStats struct {
+	URL           string  `json:"url"`
+	Weight        int     `json:"weight"`
+	Clicks        int64   `json:"clicks"`
+	Share         float64 `json:"share"`
+	ExpectedShare float64 `json:"expectedShare"`
+}
+
+// RedirectRule sends the visitors meeting all of its set conditions to URL
+type RedirectRule struct {
+	URL string `json:"url"`
+	// Countries are ISO 3166 codes looked up from the visitor's IP
+	Countries []string `json:"countries,omitempty"`
+	// OS is any of android, chromeos, ios, linux, macos and windows
+	OS []string `json:"os,omitempty"`
+	// Devices is any of bot, desktop, mobile and tablet
+	Devices []string `json:"devices,omitempty"`
+	// Languages match the preferred Accept-Language, en also matches en-US
+	Languages []string `json:"languages,omitempty"`
+	// Referrers are hosts, their subdomains match too
+	Referrers []string   `json:"referrers,omitempty"`
+	From      *time.Time `json:"from,omitempty"`
+	Until     *time.Time `json:"until,omitempty"`
+	// DailyFrom and DailyUntil are HH:MM clock times in TimeZone, UTC by default
+	DailyFrom  string `json:"dailyFrom,omitempty"`
+	DailyUntil string `json:"dailyUntil,omitempty"`
+	TimeZone   string `json:"timeZone,omitempty"`
+}
+
+// UTM holds the campaign parameters merged into the destination on redirect
+type UTM struct {
+	Source   string `json:"source,omitempty"`
+	Medium   string `json:"medium,omitempty"`
+	Campaign string `json:"campaign,omitempty"`
+	Term     string `json:"term,omitempty"`
+	Content  string `json:"content,omitempty"`
+}
+
+// LinkPatch changes the set fields of a link, an empty ExpiresAt removes the expiry
+type LinkPatch struct {
+	URL   *string `json:"url,omitempty"`
+	Title *string `json:"title,omitempty"`
+	// FolderId moves the link into a folder, 0 takes it out of its folder
+	FolderId *uint `json:"folderId,omitempty"`
+	// Tags replaces the tags of the link
+	Tags         *[]string `json:"tags,omitempty"`
+	ExpiresAt    *string   `json:"expiresAt,omitempty"`
+	IsActive     *bool     `json:"isActive,omitempty"`
+	RedirectType *int      `json:"redirectType,omitempty"`
+	// UTM replaces the UTM parameters, an empty UTM removes them
+	UTM *UTM `json:"utm,omitempty"`
+	// QueryPassthrough is shortLink, destination or empty to stop forwarding
+	QueryPassthrough *string `json:"queryPassthrough,omitempty"`
+	// Rules replaces the redirect rules, an empty slice removes them
+	Rules *[]RedirectRule `json:"rules,omitempty"`
+	// Variants replaces the A/B split, an empty slice removes it
+	Variants *[]Variant `json:"variants,omitempty"`
+}
+
+type Link struct {
+	ShortCode         string         `json:"shortCode"`
+	ShortURL          string         `json:"shortUrl"`
+	OriginalURL       string         `json:"originalUrl"`
+	Title             string         `json:"title,omitempty"`
+	FolderId          uint           `json:"folderId,omitempty"`
+	IsActive          bool           `json:"isActive"`
+	ExpiresAt         *time.Time     `json:"expiresAt,omitempty"`
+	Clicks            int64          `json:"clicks"`
+	LastClickedAt     *time.Time     `json:"lastClickedAt,omitempty"`
+	Tags              []string       `json:"tags"`
+	PasswordProtected bool           `json:"passwordProtected"`
+	MaxClicks         int64          `json:"maxClicks,omitempty"`
+	RedirectType      int            `json:"redirectType"`
+	Version           int            `json:"version"`
+	CreatedAt         time.Time      `json:"createdAt"`
+	UTM               *UTM           `json:"utm,omitempty"`
+	QueryPassthrough  string         `json:"queryPassthrough,omitempty"`
+	Rules             []RedirectRule `json:"rules,omitempty"`
+	Variants          []Variant      `json:"variants,omitempty"`
+}
+
+// LinkQuery filters and orders a link listing, zero values do not filter
+type LinkQuery struct {
+	Active  *bool
+	Expired *bool
+	Tag     string
+	Folder  *uint
+	// Host only lists links whose destination is on the host
+	Host        string
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	// Sort is created, clicks or lastClicked, newest or highest first unless Ascending
+	Sort      string
+	Ascending bool
+	Limit     int
+	Cursor    string
+	// Fields reduces the links to these fields, the others are left zero
+	Fields []string
+}
+
+type LinkPage struct {
+	Links []Link `json:"links"`
+	// NextCursor fetches the next page, empty on the last one
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+type Tag struct {
+	Name  string `json:"name"`
+	Links int64  `json:"links"`
+}
+
+type FolderInput struct {
+	Name string `json:"name"`
+}
+
+type Folder struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	Links     int64     `json:"links"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type LinkVersion struct {
+	Version       int        `json:"version"`
+	OriginalURL   string     `json:"originalUrl"`
+	ExpiresAt     *time.Time `json:"expiresAt,omitempty"`
+	IsActive      bool       `json:"isActive"`
+	RedirectType  int        `json:"redirectType"`
+	ChangedBy     uint       `json:"changedBy"`
+	ChangedFields []string   `json:"changedFields"`
+	ChangedAt     time.Time  `json:"changedAt"`
+}
+
+type LinkHistory struct {
+	ShortCode      string        `json:"shortCode"`
+	CurrentVersion int           `json:"currentVersion"`
+	Versions       []LinkVersion `json:"versions"`
+}
+
+type BulkRowResult struct {
+	Row       int       `json:"row"`
+	ShortCode string    `json:"shortCode,omitempty"`
+	Error     *RowError `json:"error,omitempty"`
+}
+
+type BulkReport struct {
+	Total     int             `json:"total"`
+	Succeeded int             `json:"succeeded"`
+	Failed    int             `json:"failed"`
+	Results   []BulkRowResult `json:"results"`
+}
+
+type BulkJob struct {
+	ID         uint            `json:"id"`
+	Status     string          `json:"status"`
+	Total      int             `json:"total"`
+	Succeeded  int             `json:"succeeded"`
+	Failed     int             `json:"failed"`
+	Error      string          `json:"error,omitempty"`
+	Results    []BulkRowResult `json:"results,omitempty"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	FinishedAt *time.Time      `json:"finishedAt,omitempty"`
+	StatusURL  string          `json:"statusUrl"`
+}
+
+// Done reports whether the job finished, successfully or not
+func (j *BulkJob) Done() bool {
+	return j.Status == "completed" || j.Status == "failed"
+}
+
+// BulkResult is the answer to a bulk request, Report when it was processed
+// inline and Job when it runs in the background
+type BulkResult struct {
+	Report *BulkReport
+	Job    *BulkJob
+}
+
+// QROptions render a QR code, zero values use the server defaults
+type QROptions struct {
+	// Format is png or svg
+	Format string
+	Size   int
+	// ECC is the error correction level, L, M, Q or H
+	ECC    string
+	Margin *int
+	// FG and BG are hex colors like 1a2b3c
+	FG   string
+	BG   string
+	Logo bool
+}
+
+type DomainInput struct {
+	Host         string `json:"host"`
+	FallbackURL  string `json:"fallbackUrl,omitempty"`
+	NotFoundPage string `json:"notFoundPage,omitempty"`
+}
+
+// DomainPatch changes the set fields of a domain, empty values clear them
+type DomainPatch struct {
+	FallbackURL  *string `json:"fallbackUrl,omitempty"`
+	NotFoundPage *string `json:"notFoundPage,omitempty"`
+}
+
+type VerificationRecord struct {
+	Type  string `json:"type"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type Domain struct {
+	ID           uint               `json:"id"`
+	Host         string             `json:"host"`
+	Verified     bool               `json:"verified"`
+	VerifiedAt   *time.Time         `json:"verifiedAt,omitempty"`
+	Verification VerificationRecord `json:"verification"`
+	FallbackURL  string             `json:"fallbackUrl,omitempty"`
+	NotFoundPage string             `json:"notFoundPage,omitempty"`
+	CreatedAt    time.Time          `json:"createdAt"`
+}
+
+type AuditQuery struct {
+	From   time.Time
+	To     time.Time
+	Limit  int
+	Cursor string
+}
+
+type AuditEvent struct {
+	ID        uint           `json:"id"`
+	Actor     string         `json:"actor"`
+	APIKeyId  uint           `json:"apiKeyId,omitempty"`
+	IP        string         `json:"ip,omitempty"`
+	RequestID string         `json:"requestId,omitempty"`
+	Action    string         `json:"action"`
+	Resource  string         `json:"resource"`
+	Before    map[string]any `json:"before,omitempty"`
+	After     map[string]any `json:"after,omitempty"`
+	CreatedAt time.Time      `json:"createdAt"`
+	PrevHash  string         `json:"prevHash"`
+	Hash      string         `json:"hash"`
+}
+
+type AuditPage struct {
+	Events []AuditEvent `json:"events"`
+	// NextCursor fetches the next page, empty on the last one
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+type APIKeyInput struct {
+	Name           string     `json:"name,omitempty"`
+	Scopes         []string   `json:"scopes"`
+	AllowedDomains []string   `json:"allowedDomains,omitempty"`
+	AllowedCIDRs   []string   `json:"allowedCidrs,omitempty"`
+	ExpiresAt      *time.Time `json:"expiresAt,omitempty"`
+}
+
+type APIKey struct {
+	ID     uint     `json:"id"`
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// Key is only set when the key was issued or rotated
+	Key            string     `json:"key,omitempty"`
+	AllowedDomains []string   `json:"allowedDomains"`
+	AllowedCIDRs   []string   `json:"allowedCidrs"`
+	IsActive       bool       `json:"isActive"`
+	ExpiresAt      *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt     *time.Time `json:"lastUsedAt,omitempty"`
+	RotatedToId    uint       `json:"rotatedToId,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+type ImportOptions struct {
+	// Format is jsonl, csv or bitly, detected from the data when empty
+	Format string
+	// Conflict is skip, rename or fail, skip by default
+	Conflict string
+	// Domain is a verified custom domain receiving every link
+	Domain string
+}
+
+type ImportResult struct {
+	Row        int       `json:"row"`
+	SourceCode string    `json:"sourceCode,omitempty"`
+	ShortCode  string    `json:"shortCode,omitempty"`
+	Status     string    `json:"status"`
+	Error      *RowError `json:"error,omitempty"`
+}
+
+type ImportReport struct {
+	Format   string         `json:"format"`
+	Conflict string         `json:"conflict"`
+	Total    int            `json:"total"`
+	Imported int            `json:"imported"`
+	Renamed  int            `json:"renamed"`
+	Skipped  int            `json:"skipped"`
+	Failed   int            `json:"failed"`
+	Aborted  bool           `json:"aborted"`
+	Results  []ImportResult `json:"results"`
+}
+
+type Organization struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type Membership struct {
+	OrganizationId uint   `json:"organizationId"`
+	Role           string `json:"role"`
+}
+
+type Member struct {
+	AccountId uint      `json:"accountId"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	JoinedAt  time.Time `json:"joinedAt"`
+}
+
+type Invitation struct {
+	ID        uint      `json:"id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	// Token is only set when the invitation was created
+	Token     string    `json:"token,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type WebhookInput struct {
+	URL string `json:"url"`
+	// Events to send, all of them when empty
+	Events      []string `json:"events,omitempty"`
+	Description string   `json:"description,omitempty"`
+}
+
+// WebhookPatch changes the set fields of a webhook endpoint
+type WebhookPatch struct {
+	URL         *string   `json:"url,omitempty"`
+	Events      *[]string `json:"events,omitempty"`
+	Description *string   `json:"description,omitempty"`
+	IsActive    *bool     `json:"isActive,omitempty"`
+}
+
+type Webhook struct {
+	ID     uint     `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	// Secret is only set when the endpoint was created or its secret rotated
+	Secret      string    `json:"secret,omitempty"`
+	Description string    `json:"description,omitempty"`
+	IsActive    bool      `json:"isActive"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+type WebhookDeliveryQuery struct {
+	// Status is pending, retrying, succeeded or dead
+	Status string
+	Limit  int
+	Cursor string
+}
+
+type WebhookDelivery struct {
+	ID             uint       `json:"id"`
+	EventId        string     `json:"eventId"`
+	Event          string     `json:"event"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"nextAttemptAt,omitempty"`
+	LastStatusCode int        `json:"lastStatusCode,omitempty"`
+	LastError      string     `json:"lastError,omitempty"`
+	DeliveredAt    *time.Time `json:"deliveredAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	// Payload and Log are only set on a single delivery
+	Payload *WebhookEvent    `json:"payload,omitempty"`
+	Log     []WebhookAttempt `json:"log,omitempty"`
+}
+
+type WebhookAttempt struct {
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"durationMs"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+type WebhookDeliveryPage struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+	// NextCursor fetches the next page, empty on the last one
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// WebhookEvent is the body posted to webhook endpoints
+type WebhookEvent struct {
+	ID             string    `json:"id"`
+	Type           string    `json:"type"`
+	CreatedAt      time.Time `json:"createdAt"`
+	OrganizationId uint      `json:"organizationId"`
+	Data           struct {
+		Link WebhookLink `json:"link"`
+		// Milestone is the click count of a link.click_milestone event
+		Milestone int64 `json:"milestone,omitempty"`
+	} `json:"data"`
+}
+
+// WebhookLink is the state of a link when its event happened
+type WebhookLink struct {
+	ShortCode      string     `json:"shortCode"`
+	ShortURL       string     `json:"shortUrl"`
+	OriginalURL    string     `json:"originalUrl"`
+	Title          string     `json:"title,omitempty"`
+	Tags           []string   `json:"tags"`
+	IsActive       bool       `json:"isActive"`
+	ExpiresAt      *time.Time `json:"expiresAt,omitempty"`
+	DisabledReason string     `json:"disabledReason,omitempty"`
+	Clicks         int64      `json:"clicks"`
+	Version        int        `json:"version"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
@@ -0,0 +1,153 @@
+package client
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader carries the signature of a webhook request
+const SignatureHeader = "X-Webhook-Signature"
+
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// CreateWebhook adds a webhook endpoint, the returned secret is not shown again
+func (c *Client) CreateWebhook(ctx context.Context, in WebhookInput, opts ...CallOption) (*Webhook, error) {
+	var webhook Webhook
+	if err := c.doJSON(ctx, http.MethodPost, "/v1/webhooks", in, &webhook, opts); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (c *Client) Webhooks(ctx context.Context, opts ...CallOption) ([]Webhook, error) {
+	var resp struct {
+		Webhooks []Webhook `json:"webhooks"`
+	}
+	if err := c.doJSON(ctx, http.MethodGet, "/v1/webhooks", nil, &resp, opts); err != nil {
+		return nil, err
+	}
+	return resp.Webhooks, nil
+}
+
+func (c *Client) Webhook(ctx context.Context, webhookId uint, opts ...CallOption) (*Webhook, error) {
+	var webhook Webhook
+	if err := c.doJSON(ctx, http.MethodGet, "/v1/webhooks/"+id(webhookId), nil, &webhook, opts); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (c *Client) UpdateWebhook(ctx context.Context, webhookId uint, patch WebhookPatch, opts ...CallOption) (*Webhook, error) {
+	var webhook Webhook
+	if err := c.doJSON(ctx, http.MethodPatch, "/v1/webhooks/"+id(webhookId), patch, &webhook, opts); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (c *Client) DeleteWebhook(ctx context.Context, webhookId uint, opts ...CallOption) error {
+	return c.doJSON(ctx, http.MethodDelete, "/v1/webhooks/"+id(webhookId), nil, nil, opts)
+}
+
+// RotateWebhookSecret replaces the signing secret of an endpoint
+func (c *Client) RotateWebhookSecret(ctx context.Context, webhookId uint, opts ...CallOption) (*Webhook, error) {
+	var webhook Webhook
+	if err := c.doJSON(ctx, http.MethodPost, "/v1/webhooks/"+id(webhookId)+"/rotate", nil, &webhook, opts); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// WebhookDeliveries lists a page of the deliveries of an endpoint, newest first
+func (c *Client) WebhookDeliveries(ctx context.Context, webhookId uint, q WebhookDeliveryQuery, opts ...CallOption) (*WebhookDeliveryPage, error) {
+	query := url.Values{}
+	if q.Status != "" {
+		query.Set("status", q.Status)
+	}
+	if q.Limit > 0 {
+		query.Set("limit", strconv.Itoa(q.Limit))
+	}
+	if q.Cursor != "" {
+		query.Set("cursor", q.Cursor)
+	}
+
+	resp, err := c.do(ctx, &request{method: http.MethodGet, path: "/v1/webhooks/" + id(webhookId) + "/deliveries", query: query, opts: opts})
+	if err != nil {
+		return nil, err
+	}
+	var page WebhookDeliveryPage
+	if err := decode(resp, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// WebhookDelivery returns a delivery with its payload and attempts
+func (c *Client) WebhookDelivery(ctx context.Context, webhookId, deliveryId uint, opts ...CallOption) (*WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	path := "/v1/webhooks/" + id(webhookId) + "/deliveries/" + id(deliveryId)
+	if err := c.doJSON(ctx, http.MethodGet, path, nil, &delivery, opts); err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// ReplayWebhookDelivery queues a delivery again with its original event id
+func (c *Client) ReplayWebhookDelivery(ctx context.Context, webhookId, deliveryId uint, opts ...CallOption) (*WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	path := "/v1/webhooks/" + id(webhookId) + "/deliveries/" + id(deliveryId) + "/replay"
+	if err := c.doJSON(ctx, http.MethodPost, path, nil, &delivery, opts); err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// ParseWebhook checks the signature header of a webhook request body and
+// decodes it, signatures older than tolerance are rejected to stop replays
+func ParseWebhook(secret, signature string, body []byte, tolerance time.Duration) (*WebhookEvent, error) {
+	var ts string
+	var signatures [][]byte
+	for _, part := range strings.Split(signature, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			if sig, err := hex.DecodeString(value); err == nil {
+				signatures = append(signatures, sig)
+			}
+		}
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return nil, ErrInvalidSignature
+	}
+	if age := time.Since(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return nil, ErrInvalidSignature
+	}
+
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(ts + "."))
+	h.Write(body)
+	expected := h.Sum(nil)
+	for _, sig := range signatures {
+		if hmac.Equal(sig, expected) {
+			var event WebhookEvent
+			if err := json.Unmarshal(body, &event); err != nil {
+				return nil, err
+			}
+			return &event, nil
+		}
+	}
+	return nil, ErrInvalidSignature
+}
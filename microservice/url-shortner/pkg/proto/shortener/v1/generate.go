@@ -0,0 +1,4 @@
+// Package shortenerv1 is the generated gRPC API of the url shortener, see shortener.proto
+package shortenerv1
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative shortener.proto
@@ -0,0 +1,1857 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: shortener.proto
+
+// The gRPC API of the url shortener, served next to the REST API. Callers
+// authenticate like REST clients: an API key in the x-api-key metadata or as a
+// bearer token in authorization, x-organization-id selects the organization.
+
+package shortenerv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type LinkSort int32
+
+const (
+	LinkSort_LINK_SORT_UNSPECIFIED  LinkSort = 0
+	LinkSort_LINK_SORT_CREATED      LinkSort = 1
+	LinkSort_LINK_SORT_CLICKS       LinkSort = 2
+	LinkSort_LINK_SORT_LAST_CLICKED LinkSort = 3
+)
+
+// Enum value maps for LinkSort.
+var (
+	LinkSort_name = map[int32]string{
+		0: "LINK_SORT_UNSPECIFIED",
+		1: "LINK_SORT_CREATED",
+		2: "LINK_SORT_CLICKS",
+		3: "LINK_SORT_LAST_CLICKED",
+	}
+	LinkSort_value = map[string]int32{
+		"LINK_SORT_UNSPECIFIED":  0,
+		"LINK_SORT_CREATED":      1,
+		"LINK_SORT_CLICKS":       2,
+		"LINK_SORT_LAST_CLICKED": 3,
+	}
+)
+
+func (x LinkSort) Enum() *LinkSort {
+	p := new(LinkSort)
+	*p = x
+	return p
+}
+
+func (x LinkSort) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (LinkSort) Descriptor() protoreflect.EnumDescriptor {
+	return file_shortener_proto_enumTypes[0].Descriptor()
+}
+
+func (LinkSort) Type() protoreflect.EnumType {
+	return &file_shortener_proto_enumTypes[0]
+}
+
+func (x LinkSort) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use LinkSort.Descriptor instead.
+func (LinkSort) EnumDescriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{0}
+}
+
+type UTM struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Source        string                 `protobuf:"bytes,1,opt,name=source,proto3" json:"source,omitempty"`
+	Medium        string                 `protobuf:"bytes,2,opt,name=medium,proto3" json:"medium,omitempty"`
+	Campaign      string                 `protobuf:"bytes,3,opt,name=campaign,proto3" json:"campaign,omitempty"`
+	Term          string                 `protobuf:"bytes,4,opt,name=term,proto3" json:"term,omitempty"`
+	Content       string                 `protobuf:"bytes,5,opt,name=content,proto3" json:"content,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UTM) Reset() {
+	*x = UTM{}
+	mi := &file_shortener_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UTM) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UTM) ProtoMessage() {}
+
+func (x *UTM) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UTM.ProtoReflect.Descriptor instead.
+func (*UTM) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *UTM) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *UTM) GetMedium() string {
+	if x != nil {
+		return x.Medium
+	}
+	return ""
+}
+
+func (x *UTM) GetCampaign() string {
+	if x != nil {
+		return x.Campaign
+	}
+	return ""
+}
+
+func (x *UTM) GetTerm() string {
+	if x != nil {
+		return x.Term
+	}
+	return ""
+}
+
+func (x *UTM) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+// RedirectRule sends the visitors matching all of its set conditions to its url
+type RedirectRule struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Url           string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	Countries     []string               `protobuf:"bytes,2,rep,name=countries,proto3" json:"countries,omitempty"`
+	Os            []string               `protobuf:"bytes,3,rep,name=os,proto3" json:"os,omitempty"`
+	Devices       []string               `protobuf:"bytes,4,rep,name=devices,proto3" json:"devices,omitempty"`
+	Languages     []string               `protobuf:"bytes,5,rep,name=languages,proto3" json:"languages,omitempty"`
+	Referrers     []string               `protobuf:"bytes,6,rep,name=referrers,proto3" json:"referrers,omitempty"`
+	From          *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=from,proto3" json:"from,omitempty"`
+	Until         *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=until,proto3" json:"until,omitempty"`
+	DailyFrom     string                 `protobuf:"bytes,9,opt,name=daily_from,json=dailyFrom,proto3" json:"daily_from,omitempty"`
+	DailyUntil    string                 `protobuf:"bytes,10,opt,name=daily_until,json=dailyUntil,proto3" json:"daily_until,omitempty"`
+	TimeZone      string                 `protobuf:"bytes,11,opt,name=time_zone,json=timeZone,proto3" json:"time_zone,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RedirectRule) Reset() {
+	*x = RedirectRule{}
+	mi := &file_shortener_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RedirectRule) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RedirectRule) ProtoMessage() {}
+
+func (x *RedirectRule) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RedirectRule.ProtoReflect.Descriptor instead.
+func (*RedirectRule) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *RedirectRule) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *RedirectRule) GetCountries() []string {
+	if x != nil {
+		return x.Countries
+	}
+	return nil
+}
+
+func (x *RedirectRule) GetOs() []string {
+	if x != nil {
+		return x.Os
+	}
+	return nil
+}
+
+func (x *RedirectRule) GetDevices() []string {
+	if x != nil {
+		return x.Devices
+	}
+	return nil
+}
+
+func (x *RedirectRule) GetLanguages() []string {
+	if x != nil {
+		return x.Languages
+	}
+	return nil
+}
+
+func (x *RedirectRule) GetReferrers() []string {
+	if x != nil {
+		return x.Referrers
+	}
+	return nil
+}
+
+func (x *RedirectRule) GetFrom() *timestamppb.Timestamp {
+	if x != nil {
+		return x.From
+	}
+	return nil
+}
+
+func (x *RedirectRule) GetUntil() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Until
+	}
+	return nil
+}
+
+func (x *RedirectRule) GetDailyFrom() string {
+	if x != nil {
+		return x.DailyFrom
+	}
+	return ""
+}
+
+func (x *RedirectRule) GetDailyUntil() string {
+	if x != nil {
+		return x.DailyUntil
+	}
+	return ""
+}
+
+func (x *RedirectRule) GetTimeZone() string {
+	if x != nil {
+		return x.TimeZone
+	}
+	return ""
+}
+
+type LinkVariant struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Url           string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	Weight        int32                  `protobuf:"varint,2,opt,name=weight,proto3" json:"weight,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LinkVariant) Reset() {
+	*x = LinkVariant{}
+	mi := &file_shortener_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LinkVariant) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LinkVariant) ProtoMessage() {}
+
+func (x *LinkVariant) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LinkVariant.ProtoReflect.Descriptor instead.
+func (*LinkVariant) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *LinkVariant) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *LinkVariant) GetWeight() int32 {
+	if x != nil {
+		return x.Weight
+	}
+	return 0
+}
+
+type Link struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	ShortCode         string                 `protobuf:"bytes,1,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	ShortUrl          string                 `protobuf:"bytes,2,opt,name=short_url,json=shortUrl,proto3" json:"short_url,omitempty"`
+	OriginalUrl       string                 `protobuf:"bytes,3,opt,name=original_url,json=originalUrl,proto3" json:"original_url,omitempty"`
+	Title             string                 `protobuf:"bytes,4,opt,name=title,proto3" json:"title,omitempty"`
+	FolderId          uint64                 `protobuf:"varint,5,opt,name=folder_id,json=folderId,proto3" json:"folder_id,omitempty"`
+	IsActive          bool                   `protobuf:"varint,6,opt,name=is_active,json=isActive,proto3" json:"is_active,omitempty"`
+	ExpiresAt         *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	Clicks            int64                  `protobuf:"varint,8,opt,name=clicks,proto3" json:"clicks,omitempty"`
+	LastClickedAt     *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=last_clicked_at,json=lastClickedAt,proto3" json:"last_clicked_at,omitempty"`
+	Tags              []string               `protobuf:"bytes,10,rep,name=tags,proto3" json:"tags,omitempty"`
+	PasswordProtected bool                   `protobuf:"varint,11,opt,name=password_protected,json=passwordProtected,proto3" json:"password_protected,omitempty"`
+	MaxClicks         int64                  `protobuf:"varint,12,opt,name=max_clicks,json=maxClicks,proto3" json:"max_clicks,omitempty"`
+	RedirectType      int32                  `protobuf:"varint,13,opt,name=redirect_type,json=redirectType,proto3" json:"redirect_type,omitempty"`
+	Version           int32                  `protobuf:"varint,14,opt,name=version,proto3" json:"version,omitempty"`
+	CreatedAt         *timestamppb.Timestamp `protobuf:"bytes,15,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	Utm               *UTM                   `protobuf:"bytes,16,opt,name=utm,proto3" json:"utm,omitempty"`
+	QueryPassthrough  string                 `protobuf:"bytes,17,opt,name=query_passthrough,json=queryPassthrough,proto3" json:"query_passthrough,omitempty"`
+	Rules             []*RedirectRule        `protobuf:"bytes,18,rep,name=rules,proto3" json:"rules,omitempty"`
+	Variants          []*LinkVariant         `protobuf:"bytes,19,rep,name=variants,proto3" json:"variants,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *Link) Reset() {
+	*x = Link{}
+	mi := &file_shortener_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Link) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Link) ProtoMessage() {}
+
+func (x *Link) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Link.ProtoReflect.Descriptor instead.
+func (*Link) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Link) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+func (x *Link) GetShortUrl() string {
+	if x != nil {
+		return x.ShortUrl
+	}
+	return ""
+}
+
+func (x *Link) GetOriginalUrl() string {
+	if x != nil {
+		return x.OriginalUrl
+	}
+	return ""
+}
+
+func (x *Link) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *Link) GetFolderId() uint64 {
+	if x != nil {
+		return x.FolderId
+	}
+	return 0
+}
+
+func (x *Link) GetIsActive() bool {
+	if x != nil {
+		return x.IsActive
+	}
+	return false
+}
+
+func (x *Link) GetExpiresAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return nil
+}
+
+func (x *Link) GetClicks() int64 {
+	if x != nil {
+		return x.Clicks
+	}
+	return 0
+}
+
+func (x *Link) GetLastClickedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastClickedAt
+	}
+	return nil
+}
+
+func (x *Link) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *Link) GetPasswordProtected() bool {
+	if x != nil {
+		return x.PasswordProtected
+	}
+	return false
+}
+
+func (x *Link) GetMaxClicks() int64 {
+	if x != nil {
+		return x.MaxClicks
+	}
+	return 0
+}
+
+func (x *Link) GetRedirectType() int32 {
+	if x != nil {
+		return x.RedirectType
+	}
+	return 0
+}
+
+func (x *Link) GetVersion() int32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Link) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Link) GetUtm() *UTM {
+	if x != nil {
+		return x.Utm
+	}
+	return nil
+}
+
+func (x *Link) GetQueryPassthrough() string {
+	if x != nil {
+		return x.QueryPassthrough
+	}
+	return ""
+}
+
+func (x *Link) GetRules() []*RedirectRule {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
+func (x *Link) GetVariants() []*LinkVariant {
+	if x != nil {
+		return x.Variants
+	}
+	return nil
+}
+
+type CreateLinkRequest struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	Url          string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	Slug         string                 `protobuf:"bytes,2,opt,name=slug,proto3" json:"slug,omitempty"`
+	ExpiresAt    *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	Tags         []string               `protobuf:"bytes,4,rep,name=tags,proto3" json:"tags,omitempty"`
+	Password     string                 `protobuf:"bytes,5,opt,name=password,proto3" json:"password,omitempty"`
+	MaxClicks    int64                  `protobuf:"varint,6,opt,name=max_clicks,json=maxClicks,proto3" json:"max_clicks,omitempty"`
+	OneTime      bool                   `protobuf:"varint,7,opt,name=one_time,json=oneTime,proto3" json:"one_time,omitempty"`
+	RedirectType int32                  `protobuf:"varint,8,opt,name=redirect_type,json=redirectType,proto3" json:"redirect_type,omitempty"`
+	// domain is a verified custom domain, empty for the shared domain
+	Domain   string `protobuf:"bytes,9,opt,name=domain,proto3" json:"domain,omitempty"`
+	Title    string `protobuf:"bytes,10,opt,name=title,proto3" json:"title,omitempty"`
+	FolderId uint64 `protobuf:"varint,11,opt,name=folder_id,json=folderId,proto3" json:"folder_id,omitempty"`
+	Utm      *UTM   `protobuf:"bytes,12,opt,name=utm,proto3" json:"utm,omitempty"`
+	// query_passthrough is shortLink or destination, empty to drop the query
+	QueryPassthrough string          `protobuf:"bytes,13,opt,name=query_passthrough,json=queryPassthrough,proto3" json:"query_passthrough,omitempty"`
+	Rules            []*RedirectRule `protobuf:"bytes,14,rep,name=rules,proto3" json:"rules,omitempty"`
+	Variants         []*LinkVariant  `protobuf:"bytes,15,rep,name=variants,proto3" json:"variants,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *CreateLinkRequest) Reset() {
+	*x = CreateLinkRequest{}
+	mi := &file_shortener_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateLinkRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateLinkRequest) ProtoMessage() {}
+
+func (x *CreateLinkRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateLinkRequest.ProtoReflect.Descriptor instead.
+func (*CreateLinkRequest) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *CreateLinkRequest) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *CreateLinkRequest) GetSlug() string {
+	if x != nil {
+		return x.Slug
+	}
+	return ""
+}
+
+func (x *CreateLinkRequest) GetExpiresAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return nil
+}
+
+func (x *CreateLinkRequest) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *CreateLinkRequest) GetPassword() string {
+	if x != nil {
+		return x.Password
+	}
+	return ""
+}
+
+func (x *CreateLinkRequest) GetMaxClicks() int64 {
+	if x != nil {
+		return x.MaxClicks
+	}
+	return 0
+}
+
+func (x *CreateLinkRequest) GetOneTime() bool {
+	if x != nil {
+		return x.OneTime
+	}
+	return false
+}
+
+func (x *CreateLinkRequest) GetRedirectType() int32 {
+	if x != nil {
+		return x.RedirectType
+	}
+	return 0
+}
+
+func (x *CreateLinkRequest) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+func (x *CreateLinkRequest) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *CreateLinkRequest) GetFolderId() uint64 {
+	if x != nil {
+		return x.FolderId
+	}
+	return 0
+}
+
+func (x *CreateLinkRequest) GetUtm() *UTM {
+	if x != nil {
+		return x.Utm
+	}
+	return nil
+}
+
+func (x *CreateLinkRequest) GetQueryPassthrough() string {
+	if x != nil {
+		return x.QueryPassthrough
+	}
+	return ""
+}
+
+func (x *CreateLinkRequest) GetRules() []*RedirectRule {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
+func (x *CreateLinkRequest) GetVariants() []*LinkVariant {
+	if x != nil {
+		return x.Variants
+	}
+	return nil
+}
+
+type GetLinkRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	Domain        string                 `protobuf:"bytes,2,opt,name=domain,proto3" json:"domain,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetLinkRequest) Reset() {
+	*x = GetLinkRequest{}
+	mi := &file_shortener_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetLinkRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetLinkRequest) ProtoMessage() {}
+
+func (x *GetLinkRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetLinkRequest.ProtoReflect.Descriptor instead.
+func (*GetLinkRequest) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *GetLinkRequest) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *GetLinkRequest) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+type ListLinksRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// domain limits the listing to one domain, empty lists them all
+	Domain   string  `protobuf:"bytes,1,opt,name=domain,proto3" json:"domain,omitempty"`
+	Active   *bool   `protobuf:"varint,2,opt,name=active,proto3,oneof" json:"active,omitempty"`
+	Expired  *bool   `protobuf:"varint,3,opt,name=expired,proto3,oneof" json:"expired,omitempty"`
+	Tag      string  `protobuf:"bytes,4,opt,name=tag,proto3" json:"tag,omitempty"`
+	FolderId *uint64 `protobuf:"varint,5,opt,name=folder_id,json=folderId,proto3,oneof" json:"folder_id,omitempty"`
+	// host matches the host of the destination
+	Host          string                 `protobuf:"bytes,6,opt,name=host,proto3" json:"host,omitempty"`
+	CreatedFrom   *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=created_from,json=createdFrom,proto3" json:"created_from,omitempty"`
+	CreatedTo     *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=created_to,json=createdTo,proto3" json:"created_to,omitempty"`
+	Sort          LinkSort               `protobuf:"varint,9,opt,name=sort,proto3,enum=shortener.v1.LinkSort" json:"sort,omitempty"`
+	Ascending     bool                   `protobuf:"varint,10,opt,name=ascending,proto3" json:"ascending,omitempty"`
+	Limit         int32                  `protobuf:"varint,11,opt,name=limit,proto3" json:"limit,omitempty"`
+	Cursor        string                 `protobuf:"bytes,12,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListLinksRequest) Reset() {
+	*x = ListLinksRequest{}
+	mi := &file_shortener_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListLinksRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListLinksRequest) ProtoMessage() {}
+
+func (x *ListLinksRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListLinksRequest.ProtoReflect.Descriptor instead.
+func (*ListLinksRequest) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ListLinksRequest) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+func (x *ListLinksRequest) GetActive() bool {
+	if x != nil && x.Active != nil {
+		return *x.Active
+	}
+	return false
+}
+
+func (x *ListLinksRequest) GetExpired() bool {
+	if x != nil && x.Expired != nil {
+		return *x.Expired
+	}
+	return false
+}
+
+func (x *ListLinksRequest) GetTag() string {
+	if x != nil {
+		return x.Tag
+	}
+	return ""
+}
+
+func (x *ListLinksRequest) GetFolderId() uint64 {
+	if x != nil && x.FolderId != nil {
+		return *x.FolderId
+	}
+	return 0
+}
+
+func (x *ListLinksRequest) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *ListLinksRequest) GetCreatedFrom() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedFrom
+	}
+	return nil
+}
+
+func (x *ListLinksRequest) GetCreatedTo() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedTo
+	}
+	return nil
+}
+
+func (x *ListLinksRequest) GetSort() LinkSort {
+	if x != nil {
+		return x.Sort
+	}
+	return LinkSort_LINK_SORT_UNSPECIFIED
+}
+
+func (x *ListLinksRequest) GetAscending() bool {
+	if x != nil {
+		return x.Ascending
+	}
+	return false
+}
+
+func (x *ListLinksRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *ListLinksRequest) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+type ListLinksResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Links []*Link                `protobuf:"bytes,1,rep,name=links,proto3" json:"links,omitempty"`
+	// next_cursor continues the listing, empty on the last page
+	NextCursor    string `protobuf:"bytes,2,opt,name=next_cursor,json=nextCursor,proto3" json:"next_cursor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListLinksResponse) Reset() {
+	*x = ListLinksResponse{}
+	mi := &file_shortener_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListLinksResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListLinksResponse) ProtoMessage() {}
+
+func (x *ListLinksResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListLinksResponse.ProtoReflect.Descriptor instead.
+func (*ListLinksResponse) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ListLinksResponse) GetLinks() []*Link {
+	if x != nil {
+		return x.Links
+	}
+	return nil
+}
+
+func (x *ListLinksResponse) GetNextCursor() string {
+	if x != nil {
+		return x.NextCursor
+	}
+	return ""
+}
+
+// Tags, RedirectRules and LinkVariants tell a replaced list from an absent one
+type Tags struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Names         []string               `protobuf:"bytes,1,rep,name=names,proto3" json:"names,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Tags) Reset() {
+	*x = Tags{}
+	mi := &file_shortener_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Tags) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Tags) ProtoMessage() {}
+
+func (x *Tags) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Tags.ProtoReflect.Descriptor instead.
+func (*Tags) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Tags) GetNames() []string {
+	if x != nil {
+		return x.Names
+	}
+	return nil
+}
+
+type RedirectRules struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Rules         []*RedirectRule        `protobuf:"bytes,1,rep,name=rules,proto3" json:"rules,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RedirectRules) Reset() {
+	*x = RedirectRules{}
+	mi := &file_shortener_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RedirectRules) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RedirectRules) ProtoMessage() {}
+
+func (x *RedirectRules) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RedirectRules.ProtoReflect.Descriptor instead.
+func (*RedirectRules) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *RedirectRules) GetRules() []*RedirectRule {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
+type LinkVariants struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Variants      []*LinkVariant         `protobuf:"bytes,1,rep,name=variants,proto3" json:"variants,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LinkVariants) Reset() {
+	*x = LinkVariants{}
+	mi := &file_shortener_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LinkVariants) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LinkVariants) ProtoMessage() {}
+
+func (x *LinkVariants) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LinkVariants.ProtoReflect.Descriptor instead.
+func (*LinkVariants) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *LinkVariants) GetVariants() []*LinkVariant {
+	if x != nil {
+		return x.Variants
+	}
+	return nil
+}
+
+type UpdateLinkRequest struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	Code         string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	Domain       string                 `protobuf:"bytes,2,opt,name=domain,proto3" json:"domain,omitempty"`
+	Url          *string                `protobuf:"bytes,3,opt,name=url,proto3,oneof" json:"url,omitempty"`
+	ExpiresAt    *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	IsActive     *bool                  `protobuf:"varint,5,opt,name=is_active,json=isActive,proto3,oneof" json:"is_active,omitempty"`
+	RedirectType *int32                 `protobuf:"varint,6,opt,name=redirect_type,json=redirectType,proto3,oneof" json:"redirect_type,omitempty"`
+	Title        *string                `protobuf:"bytes,7,opt,name=title,proto3,oneof" json:"title,omitempty"`
+	// folder_id moves the link into a folder, 0 takes it out of its folder
+	FolderId         *uint64        `protobuf:"varint,8,opt,name=folder_id,json=folderId,proto3,oneof" json:"folder_id,omitempty"`
+	Tags             *Tags          `protobuf:"bytes,9,opt,name=tags,proto3" json:"tags,omitempty"`
+	Utm              *UTM           `protobuf:"bytes,10,opt,name=utm,proto3" json:"utm,omitempty"`
+	QueryPassthrough *string        `protobuf:"bytes,11,opt,name=query_passthrough,json=queryPassthrough,proto3,oneof" json:"query_passthrough,omitempty"`
+	Rules            *RedirectRules `protobuf:"bytes,12,opt,name=rules,proto3" json:"rules,omitempty"`
+	Variants         *LinkVariants  `protobuf:"bytes,13,opt,name=variants,proto3" json:"variants,omitempty"`
+	// remove_expiry removes the expiry of the link, expires_at must not be set too
+	RemoveExpiry  bool `protobuf:"varint,14,opt,name=remove_expiry,json=removeExpiry,proto3" json:"remove_expiry,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateLinkRequest) Reset() {
+	*x = UpdateLinkRequest{}
+	mi := &file_shortener_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateLinkRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateLinkRequest) ProtoMessage() {}
+
+func (x *UpdateLinkRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateLinkRequest.ProtoReflect.Descriptor instead.
+func (*UpdateLinkRequest) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *UpdateLinkRequest) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *UpdateLinkRequest) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+func (x *UpdateLinkRequest) GetUrl() string {
+	if x != nil && x.Url != nil {
+		return *x.Url
+	}
+	return ""
+}
+
+func (x *UpdateLinkRequest) GetExpiresAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return nil
+}
+
+func (x *UpdateLinkRequest) GetIsActive() bool {
+	if x != nil && x.IsActive != nil {
+		return *x.IsActive
+	}
+	return false
+}
+
+func (x *UpdateLinkRequest) GetRedirectType() int32 {
+	if x != nil && x.RedirectType != nil {
+		return *x.RedirectType
+	}
+	return 0
+}
+
+func (x *UpdateLinkRequest) GetTitle() string {
+	if x != nil && x.Title != nil {
+		return *x.Title
+	}
+	return ""
+}
+
+func (x *UpdateLinkRequest) GetFolderId() uint64 {
+	if x != nil && x.FolderId != nil {
+		return *x.FolderId
+	}
+	return 0
+}
+
+func (x *UpdateLinkRequest) GetTags() *Tags {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *UpdateLinkRequest) GetUtm() *UTM {
+	if x != nil {
+		return x.Utm
+	}
+	return nil
+}
+
+func (x *UpdateLinkRequest) GetQueryPassthrough() string {
+	if x != nil && x.QueryPassthrough != nil {
+		return *x.QueryPassthrough
+	}
+	return ""
+}
+
+func (x *UpdateLinkRequest) GetRules() *RedirectRules {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
+func (x *UpdateLinkRequest) GetVariants() *LinkVariants {
+	if x != nil {
+		return x.Variants
+	}
+	return nil
+}
+
+func (x *UpdateLinkRequest) GetRemoveExpiry() bool {
+	if x != nil {
+		return x.RemoveExpiry
+	}
+	return false
+}
+
+// ResolveRequest names the link and describes the visitor redirect rules and
+// the A/B split are evaluated against
+type ResolveRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Code  string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	// domain is the host of a custom domain, empty for the shared domain
+	Domain   string `protobuf:"bytes,2,opt,name=domain,proto3" json:"domain,omitempty"`
+	Password string `protobuf:"bytes,3,opt,name=password,proto3" json:"password,omitempty"`
+	// query is the query string of the short url
+	Query          string `protobuf:"bytes,4,opt,name=query,proto3" json:"query,omitempty"`
+	ClientIp       string `protobuf:"bytes,5,opt,name=client_ip,json=clientIp,proto3" json:"client_ip,omitempty"`
+	UserAgent      string `protobuf:"bytes,6,opt,name=user_agent,json=userAgent,proto3" json:"user_agent,omitempty"`
+	AcceptLanguage string `protobuf:"bytes,7,opt,name=accept_language,json=acceptLanguage,proto3" json:"accept_language,omitempty"`
+	Referrer       string `protobuf:"bytes,8,opt,name=referrer,proto3" json:"referrer,omitempty"`
+	// variant_id keeps a returning visitor on the variant it was assigned
+	VariantId     uint64 `protobuf:"varint,9,opt,name=variant_id,json=variantId,proto3" json:"variant_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResolveRequest) Reset() {
+	*x = ResolveRequest{}
+	mi := &file_shortener_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResolveRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResolveRequest) ProtoMessage() {}
+
+func (x *ResolveRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResolveRequest.ProtoReflect.Descriptor instead.
+func (*ResolveRequest) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ResolveRequest) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *ResolveRequest) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+func (x *ResolveRequest) GetPassword() string {
+	if x != nil {
+		return x.Password
+	}
+	return ""
+}
+
+func (x *ResolveRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *ResolveRequest) GetClientIp() string {
+	if x != nil {
+		return x.ClientIp
+	}
+	return ""
+}
+
+func (x *ResolveRequest) GetUserAgent() string {
+	if x != nil {
+		return x.UserAgent
+	}
+	return ""
+}
+
+func (x *ResolveRequest) GetAcceptLanguage() string {
+	if x != nil {
+		return x.AcceptLanguage
+	}
+	return ""
+}
+
+func (x *ResolveRequest) GetReferrer() string {
+	if x != nil {
+		return x.Referrer
+	}
+	return ""
+}
+
+func (x *ResolveRequest) GetVariantId() uint64 {
+	if x != nil {
+		return x.VariantId
+	}
+	return 0
+}
+
+type ResolveResponse struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	Destination  string                 `protobuf:"bytes,1,opt,name=destination,proto3" json:"destination,omitempty"`
+	RedirectType int32                  `protobuf:"varint,2,opt,name=redirect_type,json=redirectType,proto3" json:"redirect_type,omitempty"`
+	// variant_id is the A/B variant the visitor was sent to, 0 for none
+	VariantId     uint64 `protobuf:"varint,3,opt,name=variant_id,json=variantId,proto3" json:"variant_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResolveResponse) Reset() {
+	*x = ResolveResponse{}
+	mi := &file_shortener_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResolveResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResolveResponse) ProtoMessage() {}
+
+func (x *ResolveResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResolveResponse.ProtoReflect.Descriptor instead.
+func (*ResolveResponse) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ResolveResponse) GetDestination() string {
+	if x != nil {
+		return x.Destination
+	}
+	return ""
+}
+
+func (x *ResolveResponse) GetRedirectType() int32 {
+	if x != nil {
+		return x.RedirectType
+	}
+	return 0
+}
+
+func (x *ResolveResponse) GetVariantId() uint64 {
+	if x != nil {
+		return x.VariantId
+	}
+	return 0
+}
+
+type GetLinkStatsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	Domain        string                 `protobuf:"bytes,2,opt,name=domain,proto3" json:"domain,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetLinkStatsRequest) Reset() {
+	*x = GetLinkStatsRequest{}
+	mi := &file_shortener_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetLinkStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetLinkStatsRequest) ProtoMessage() {}
+
+func (x *GetLinkStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetLinkStatsRequest.ProtoReflect.Descriptor instead.
+func (*GetLinkStatsRequest) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *GetLinkStatsRequest) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *GetLinkStatsRequest) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+type VariantStats struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Url           string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	Weight        int32                  `protobuf:"varint,2,opt,name=weight,proto3" json:"weight,omitempty"`
+	Clicks        int64                  `protobuf:"varint,3,opt,name=clicks,proto3" json:"clicks,omitempty"`
+	Share         float64                `protobuf:"fixed64,4,opt,name=share,proto3" json:"share,omitempty"`
+	ExpectedShare float64                `protobuf:"fixed64,5,opt,name=expected_share,json=expectedShare,proto3" json:"expected_share,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VariantStats) Reset() {
+	*x = VariantStats{}
+	mi := &file_shortener_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VariantStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VariantStats) ProtoMessage() {}
+
+func (x *VariantStats) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VariantStats.ProtoReflect.Descriptor instead.
+func (*VariantStats) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *VariantStats) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *VariantStats) GetWeight() int32 {
+	if x != nil {
+		return x.Weight
+	}
+	return 0
+}
+
+func (x *VariantStats) GetClicks() int64 {
+	if x != nil {
+		return x.Clicks
+	}
+	return 0
+}
+
+func (x *VariantStats) GetShare() float64 {
+	if x != nil {
+		return x.Share
+	}
+	return 0
+}
+
+func (x *VariantStats) GetExpectedShare() float64 {
+	if x != nil {
+		return x.ExpectedShare
+	}
+	return 0
+}
+
+type LinkStats struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ShortCode     string                 `protobuf:"bytes,1,opt,name=short_code,json=shortCode,proto3" json:"short_code,omitempty"`
+	TotalClicks   int64                  `protobuf:"varint,2,opt,name=total_clicks,json=totalClicks,proto3" json:"total_clicks,omitempty"`
+	LastClickedAt *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=last_clicked_at,json=lastClickedAt,proto3" json:"last_clicked_at,omitempty"`
+	Variants      []*VariantStats        `protobuf:"bytes,4,rep,name=variants,proto3" json:"variants,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LinkStats) Reset() {
+	*x = LinkStats{}
+	mi := &file_shortener_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LinkStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LinkStats) ProtoMessage() {}
+
+func (x *LinkStats) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LinkStats.ProtoReflect.Descriptor instead.
+func (*LinkStats) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *LinkStats) GetShortCode() string {
+	if x != nil {
+		return x.ShortCode
+	}
+	return ""
+}
+
+func (x *LinkStats) GetTotalClicks() int64 {
+	if x != nil {
+		return x.TotalClicks
+	}
+	return 0
+}
+
+func (x *LinkStats) GetLastClickedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastClickedAt
+	}
+	return nil
+}
+
+func (x *LinkStats) GetVariants() []*VariantStats {
+	if x != nil {
+		return x.Variants
+	}
+	return nil
+}
+
+var File_shortener_proto protoreflect.FileDescriptor
+
+const file_shortener_proto_rawDesc = "" +
+	"\n" +
+	"\x0fshortener.proto\x12\fshortener.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x7f\n" +
+	"\x03UTM\x12\x16\n" +
+	"\x06source\x18\x01 \x01(\tR\x06source\x12\x16\n" +
+	"\x06medium\x18\x02 \x01(\tR\x06medium\x12\x1a\n" +
+	"\bcampaign\x18\x03 \x01(\tR\bcampaign\x12\x12\n" +
+	"\x04term\x18\x04 \x01(\tR\x04term\x12\x18\n" +
+	"\acontent\x18\x05 \x01(\tR\acontent\"\xe3\x02\n" +
+	"\fRedirectRule\x12\x10\n" +
+	"\x03url\x18\x01 \x01(\tR\x03url\x12\x1c\n" +
+	"\tcountries\x18\x02 \x03(\tR\tcountries\x12\x0e\n" +
+	"\x02os\x18\x03 \x03(\tR\x02os\x12\x18\n" +
+	"\adevices\x18\x04 \x03(\tR\adevices\x12\x1c\n" +
+	"\tlanguages\x18\x05 \x03(\tR\tlanguages\x12\x1c\n" +
+	"\treferrers\x18\x06 \x03(\tR\treferrers\x12.\n" +
+	"\x04from\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\x04from\x120\n" +
+	"\x05until\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\x05until\x12\x1d\n" +
+	"\n" +
+	"daily_from\x18\t \x01(\tR\tdailyFrom\x12\x1f\n" +
+	"\vdaily_until\x18\n" +
+	" \x01(\tR\n" +
+	"dailyUntil\x12\x1b\n" +
+	"\ttime_zone\x18\v \x01(\tR\btimeZone\"7\n" +
+	"\vLinkVariant\x12\x10\n" +
+	"\x03url\x18\x01 \x01(\tR\x03url\x12\x16\n" +
+	"\x06weight\x18\x02 \x01(\x05R\x06weight\"\xe3\x05\n" +
+	"\x04Link\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x01 \x01(\tR\tshortCode\x12\x1b\n" +
+	"\tshort_url\x18\x02 \x01(\tR\bshortUrl\x12!\n" +
+	"\foriginal_url\x18\x03 \x01(\tR\voriginalUrl\x12\x14\n" +
+	"\x05title\x18\x04 \x01(\tR\x05title\x12\x1b\n" +
+	"\tfolder_id\x18\x05 \x01(\x04R\bfolderId\x12\x1b\n" +
+	"\tis_active\x18\x06 \x01(\bR\bisActive\x129\n" +
+	"\n" +
+	"expires_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\texpiresAt\x12\x16\n" +
+	"\x06clicks\x18\b \x01(\x03R\x06clicks\x12B\n" +
+	"\x0flast_clicked_at\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\rlastClickedAt\x12\x12\n" +
+	"\x04tags\x18\n" +
+	" \x03(\tR\x04tags\x12-\n" +
+	"\x12password_protected\x18\v \x01(\bR\x11passwordProtected\x12\x1d\n" +
+	"\n" +
+	"max_clicks\x18\f \x01(\x03R\tmaxClicks\x12#\n" +
+	"\rredirect_type\x18\r \x01(\x05R\fredirectType\x12\x18\n" +
+	"\aversion\x18\x0e \x01(\x05R\aversion\x129\n" +
+	"\n" +
+	"created_at\x18\x0f \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x12#\n" +
+	"\x03utm\x18\x10 \x01(\v2\x11.shortener.v1.UTMR\x03utm\x12+\n" +
+	"\x11query_passthrough\x18\x11 \x01(\tR\x10queryPassthrough\x120\n" +
+	"\x05rules\x18\x12 \x03(\v2\x1a.shortener.v1.RedirectRuleR\x05rules\x125\n" +
+	"\bvariants\x18\x13 \x03(\v2\x19.shortener.v1.LinkVariantR\bvariants\"\x89\x04\n" +
+	"\x11CreateLinkRequest\x12\x10\n" +
+	"\x03url\x18\x01 \x01(\tR\x03url\x12\x12\n" +
+	"\x04slug\x18\x02 \x01(\tR\x04slug\x129\n" +
+	"\n" +
+	"expires_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\texpiresAt\x12\x12\n" +
+	"\x04tags\x18\x04 \x03(\tR\x04tags\x12\x1a\n" +
+	"\bpassword\x18\x05 \x01(\tR\bpassword\x12\x1d\n" +
+	"\n" +
+	"max_clicks\x18\x06 \x01(\x03R\tmaxClicks\x12\x19\n" +
+	"\bone_time\x18\a \x01(\bR\aoneTime\x12#\n" +
+	"\rredirect_type\x18\b \x01(\x05R\fredirectType\x12\x16\n" +
+	"\x06domain\x18\t \x01(\tR\x06domain\x12\x14\n" +
+	"\x05title\x18\n" +
+	" \x01(\tR\x05title\x12\x1b\n" +
+	"\tfolder_id\x18\v \x01(\x04R\bfolderId\x12#\n" +
+	"\x03utm\x18\f \x01(\v2\x11.shortener.v1.UTMR\x03utm\x12+\n" +
+	"\x11query_passthrough\x18\r \x01(\tR\x10queryPassthrough\x120\n" +
+	"\x05rules\x18\x0e \x03(\v2\x1a.shortener.v1.RedirectRuleR\x05rules\x125\n" +
+	"\bvariants\x18\x0f \x03(\v2\x19.shortener.v1.LinkVariantR\bvariants\"<\n" +
+	"\x0eGetLinkRequest\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x16\n" +
+	"\x06domain\x18\x02 \x01(\tR\x06domain\"\xc5\x03\n" +
+	"\x10ListLinksRequest\x12\x16\n" +
+	"\x06domain\x18\x01 \x01(\tR\x06domain\x12\x1b\n" +
+	"\x06active\x18\x02 \x01(\bH\x00R\x06active\x88\x01\x01\x12\x1d\n" +
+	"\aexpired\x18\x03 \x01(\bH\x01R\aexpired\x88\x01\x01\x12\x10\n" +
+	"\x03tag\x18\x04 \x01(\tR\x03tag\x12 \n" +
+	"\tfolder_id\x18\x05 \x01(\x04H\x02R\bfolderId\x88\x01\x01\x12\x12\n" +
+	"\x04host\x18\x06 \x01(\tR\x04host\x12=\n" +
+	"\fcreated_from\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\vcreatedFrom\x129\n" +
+	"\n" +
+	"created_to\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedTo\x12*\n" +
+	"\x04sort\x18\t \x01(\x0e2\x16.shortener.v1.LinkSortR\x04sort\x12\x1c\n" +
+	"\tascending\x18\n" +
+	" \x01(\bR\tascending\x12\x14\n" +
+	"\x05limit\x18\v \x01(\x05R\x05limit\x12\x16\n" +
+	"\x06cursor\x18\f \x01(\tR\x06cursorB\t\n" +
+	"\a_activeB\n" +
+	"\n" +
+	"\b_expiredB\f\n" +
+	"\n" +
+	"_folder_id\"^\n" +
+	"\x11ListLinksResponse\x12(\n" +
+	"\x05links\x18\x01 \x03(\v2\x12.shortener.v1.LinkR\x05links\x12\x1f\n" +
+	"\vnext_cursor\x18\x02 \x01(\tR\n" +
+	"nextCursor\"\x1c\n" +
+	"\x04Tags\x12\x14\n" +
+	"\x05names\x18\x01 \x03(\tR\x05names\"A\n" +
+	"\rRedirectRules\x120\n" +
+	"\x05rules\x18\x01 \x03(\v2\x1a.shortener.v1.RedirectRuleR\x05rules\"E\n" +
+	"\fLinkVariants\x125\n" +
+	"\bvariants\x18\x01 \x03(\v2\x19.shortener.v1.LinkVariantR\bvariants\"\xff\x04\n" +
+	"\x11UpdateLinkRequest\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x16\n" +
+	"\x06domain\x18\x02 \x01(\tR\x06domain\x12\x15\n" +
+	"\x03url\x18\x03 \x01(\tH\x00R\x03url\x88\x01\x01\x129\n" +
+	"\n" +
+	"expires_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\texpiresAt\x12 \n" +
+	"\tis_active\x18\x05 \x01(\bH\x01R\bisActive\x88\x01\x01\x12(\n" +
+	"\rredirect_type\x18\x06 \x01(\x05H\x02R\fredirectType\x88\x01\x01\x12\x19\n" +
+	"\x05title\x18\a \x01(\tH\x03R\x05title\x88\x01\x01\x12 \n" +
+	"\tfolder_id\x18\b \x01(\x04H\x04R\bfolderId\x88\x01\x01\x12&\n" +
+	"\x04tags\x18\t \x01(\v2\x12.shortener.v1.TagsR\x04tags\x12#\n" +
+	"\x03utm\x18\n" +
+	" \x01(\v2\x11.shortener.v1.UTMR\x03utm\x120\n" +
+	"\x11query_passthrough\x18\v \x01(\tH\x05R\x10queryPassthrough\x88\x01\x01\x121\n" +
+	"\x05rules\x18\f \x01(\v2\x1b.shortener.v1.RedirectRulesR\x05rules\x126\n" +
+	"\bvariants\x18\r \x01(\v2\x1a.shortener.v1.LinkVariantsR\bvariants\x12#\n" +
+	"\rremove_expiry\x18\x0e \x01(\bR\fremoveExpiryB\x06\n" +
+	"\x04_urlB\f\n" +
+	"\n" +
+	"_is_activeB\x10\n" +
+	"\x0e_redirect_typeB\b\n" +
+	"\x06_titleB\f\n" +
+	"\n" +
+	"_folder_idB\x14\n" +
+	"\x12_query_passthrough\"\x8e\x02\n" +
+	"\x0eResolveRequest\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x16\n" +
+	"\x06domain\x18\x02 \x01(\tR\x06domain\x12\x1a\n" +
+	"\bpassword\x18\x03 \x01(\tR\bpassword\x12\x14\n" +
+	"\x05query\x18\x04 \x01(\tR\x05query\x12\x1b\n" +
+	"\tclient_ip\x18\x05 \x01(\tR\bclientIp\x12\x1d\n" +
+	"\n" +
+	"user_agent\x18\x06 \x01(\tR\tuserAgent\x12'\n" +
+	"\x0faccept_language\x18\a \x01(\tR\x0eacceptLanguage\x12\x1a\n" +
+	"\breferrer\x18\b \x01(\tR\breferrer\x12\x1d\n" +
+	"\n" +
+	"variant_id\x18\t \x01(\x04R\tvariantId\"w\n" +
+	"\x0fResolveResponse\x12 \n" +
+	"\vdestination\x18\x01 \x01(\tR\vdestination\x12#\n" +
+	"\rredirect_type\x18\x02 \x01(\x05R\fredirectType\x12\x1d\n" +
+	"\n" +
+	"variant_id\x18\x03 \x01(\x04R\tvariantId\"A\n" +
+	"\x13GetLinkStatsRequest\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x16\n" +
+	"\x06domain\x18\x02 \x01(\tR\x06domain\"\x8d\x01\n" +
+	"\fVariantStats\x12\x10\n" +
+	"\x03url\x18\x01 \x01(\tR\x03url\x12\x16\n" +
+	"\x06weight\x18\x02 \x01(\x05R\x06weight\x12\x16\n" +
+	"\x06clicks\x18\x03 \x01(\x03R\x06clicks\x12\x14\n" +
+	"\x05share\x18\x04 \x01(\x01R\x05share\x12%\n" +
+	"\x0eexpected_share\x18\x05 \x01(\x01R\rexpectedShare\"\xc9\x01\n" +
+	"\tLinkStats\x12\x1d\n" +
+	"\n" +
+	"short_code\x18\x01 \x01(\tR\tshortCode\x12!\n" +
+	"\ftotal_clicks\x18\x02 \x01(\x03R\vtotalClicks\x12B\n" +
+	"\x0flast_clicked_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\rlastClickedAt\x126\n" +
+	"\bvariants\x18\x04 \x03(\v2\x1a.shortener.v1.VariantStatsR\bvariants*n\n" +
+	"\bLinkSort\x12\x19\n" +
+	"\x15LINK_SORT_UNSPECIFIED\x10\x00\x12\x15\n" +
+	"\x11LINK_SORT_CREATED\x10\x01\x12\x14\n" +
+	"\x10LINK_SORT_CLICKS\x10\x02\x12\x1a\n" +
+	"\x16LINK_SORT_LAST_CLICKED\x10\x032\x9e\x02\n" +
+	"\vLinkService\x12A\n" +
+	"\n" +
+	"CreateLink\x12\x1f.shortener.v1.CreateLinkRequest\x1a\x12.shortener.v1.Link\x12;\n" +
+	"\aGetLink\x12\x1c.shortener.v1.GetLinkRequest\x1a\x12.shortener.v1.Link\x12L\n" +
+	"\tListLinks\x12\x1e.shortener.v1.ListLinksRequest\x1a\x1f.shortener.v1.ListLinksResponse\x12A\n" +
+	"\n" +
+	"UpdateLink\x12\x1f.shortener.v1.UpdateLinkRequest\x1a\x12.shortener.v1.Link2X\n" +
+	"\x0eResolveService\x12F\n" +
+	"\aResolve\x12\x1c.shortener.v1.ResolveRequest\x1a\x1d.shortener.v1.ResolveResponse2^\n" +
+	"\x10AnalyticsService\x12J\n" +
+	"\fGetLinkStats\x12!.shortener.v1.GetLinkStatsRequest\x1a\x17.shortener.v1.LinkStatsB?Z=coding2fun.in/url-shortner/pkg/proto/shortener/v1;shortenerv1b\x06proto3"
+
+var (
+	file_shortener_proto_rawDescOnce sync.Once
+	file_shortener_proto_rawDescData []byte
+)
+
+func file_shortener_proto_rawDescGZIP() []byte {
+	file_shortener_proto_rawDescOnce.Do(func() {
+		file_shortener_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_shortener_proto_rawDesc), len(file_shortener_proto_rawDesc)))
+	})
+	return file_shortener_proto_rawDescData
+}
+
+var file_shortener_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_shortener_proto_msgTypes = make([]protoimpl.MessageInfo, 17)
+var file_shortener_proto_goTypes = []any{
+	(LinkSort)(0),                 // 0: shortener.v1.LinkSort
+	(*UTM)(nil),                   // 1: shortener.v1.UTM
+	(*RedirectRule)(nil),          // 2: shortener.v1.RedirectRule
+	(*LinkVariant)(nil),           // 3: shortener.v1.LinkVariant
+	(*Link)(nil),                  // 4: shortener.v1.Link
+	(*CreateLinkRequest)(nil),     // 5: shortener.v1.CreateLinkRequest
+	(*GetLinkRequest)(nil),        // 6: shortener.v1.GetLinkRequest
+	(*ListLinksRequest)(nil),      // 7: shortener.v1.ListLinksRequest
+	(*ListLinksResponse)(nil),     // 8: shortener.v1.ListLinksResponse
+	(*Tags)(nil),                  // 9: shortener.v1.Tags
+	(*RedirectRules)(nil),         // 10: shortener.v1.RedirectRules
+	(*LinkVariants)(nil),          // 11: shortener.v1.LinkVariants
+	(*UpdateLinkRequest)(nil),     // 12: shortener.v1.UpdateLinkRequest
+	(*ResolveRequest)(nil),        // 13: shortener.v1.ResolveRequest
+	(*ResolveResponse)(nil),       // 14: shortener.v1.ResolveResponse
+	(*GetLinkStatsRequest)(nil),   // 15: shortener.v1.GetLinkStatsRequest
+	(*VariantStats)(nil),          // 16: shortener.v1.VariantStats
+	(*LinkStats)(nil),             // 17: shortener.v1.LinkStats
+	(*timestamppb.Timestamp)(nil), // 18: google.protobuf.Timestamp
+}
+var file_shortener_proto_depIdxs = []int32{
+	18, // 0: shortener.v1.RedirectRule.from:type_name -> google.protobuf.Timestamp
+	18, // 1: shortener.v1.RedirectRule.until:type_name -> google.protobuf.Timestamp
+	18, // 2: shortener.v1.Link.expires_at:type_name -> google.protobuf.Timestamp
+	18, // 3: shortener.v1.Link.last_clicked_at:type_name -> google.protobuf.Timestamp
+	18, // 4: shortener.v1.Link.created_at:type_name -> google.protobuf.Timestamp
+	1,  // 5: shortener.v1.Link.utm:type_name -> shortener.v1.UTM
+	2,  // 6: shortener.v1.Link.rules:type_name -> shortener.v1.RedirectRule
+	3,  // 7: shortener.v1.Link.variants:type_name -> shortener.v1.LinkVariant
+	18, // 8: shortener.v1.CreateLinkRequest.expires_at:type_name -> google.protobuf.Timestamp
+	1,  // 9: shortener.v1.CreateLinkRequest.utm:type_name -> shortener.v1.UTM
+	2,  // 10: shortener.v1.CreateLinkRequest.rules:type_name -> shortener.v1.RedirectRule
+	3,  // 11: shortener.v1.CreateLinkRequest.variants:type_name -> shortener.v1.LinkVariant
+	18, // 12: shortener.v1.ListLinksRequest.created_from:type_name -> google.protobuf.Timestamp
+	18, // 13: shortener.v1.ListLinksRequest.created_to:type_name -> google.protobuf.Timestamp
+	0,  // 14: shortener.v1.ListLinksRequest.sort:type_name -> shortener.v1.LinkSort
+	4,  // 15: shortener.v1.ListLinksResponse.links:type_name -> shortener.v1.Link
+	2,  // 16: shortener.v1.RedirectRules.rules:type_name -> shortener.v1.RedirectRule
+	3,  // 17: shortener.v1.LinkVariants.variants:type_name -> shortener.v1.LinkVariant
+	18, // 18: shortener.v1.UpdateLinkRequest.expires_at:type_name -> google.protobuf.Timestamp
+	9,  // 19: shortener.v1.UpdateLinkRequest.tags:type_name -> shortener.v1.Tags
+	1,  // 20: shortener.v1.UpdateLinkRequest.utm:type_name -> shortener.v1.UTM
+	10, // 21: shortener.v1.UpdateLinkRequest.rules:type_name -> shortener.v1.RedirectRules
+	11, // 22: shortener.v1.UpdateLinkRequest.variants:type_name -> shortener.v1.LinkVariants
+	18, // 23: shortener.v1.LinkStats.last_clicked_at:type_name -> google.protobuf.Timestamp
+	16, // 24: shortener.v1.LinkStats.variants:type_name -> shortener.v1.VariantStats
+	5,  // 25: shortener.v1.LinkService.CreateLink:input_type -> shortener.v1.CreateLinkRequest
+	6,  // 26: shortener.v1.LinkService.GetLink:input_type -> shortener.v1.GetLinkRequest
+	7,  // 27: shortener.v1.LinkService.ListLinks:input_type -> shortener.v1.ListLinksRequest
+	12, // 28: shortener.v1.LinkService.UpdateLink:input_type -> shortener.v1.UpdateLinkRequest
+	13, // 29: shortener.v1.ResolveService.Resolve:input_type -> shortener.v1.ResolveRequest
+	15, // 30: shortener.v1.AnalyticsService.GetLinkStats:input_type -> shortener.v1.GetLinkStatsRequest
+	4,  // 31: shortener.v1.LinkService.CreateLink:output_type -> shortener.v1.Link
+	4,  // 32: shortener.v1.LinkService.GetLink:output_type -> shortener.v1.Link
+	8,  // 33: shortener.v1.LinkService.ListLinks:output_type -> shortener.v1.ListLinksResponse
+	4,  // 34: shortener.v1.LinkService.UpdateLink:output_type -> shortener.v1.Link
+	14, // 35: shortener.v1.ResolveService.Resolve:output_type -> shortener.v1.ResolveResponse
+	17, // 36: shortener.v1.AnalyticsService.GetLinkStats:output_type -> shortener.v1.LinkStats
+	31, // [31:37] is the sub-list for method output_type
+	25, // [25:31] is the sub-list for method input_type
+	25, // [25:25] is the sub-list for extension type_name
+	25, // [25:25] is the sub-list for extension extendee
+	0,  // [0:25] is the sub-list for field type_name
+}
+
+func init() { file_shortener_proto_init() }
+func file_shortener_proto_init() {
+	if File_shortener_proto != nil {
+		return
+	}
+	file_shortener_proto_msgTypes[6].OneofWrappers = []any{}
+	file_shortener_proto_msgTypes[11].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_shortener_proto_rawDesc), len(file_shortener_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   17,
+			NumExtensions: 0,
+			NumServices:   3,
+		},
+		GoTypes:           file_shortener_proto_goTypes,
+		DependencyIndexes: file_shortener_proto_depIdxs,
+		EnumInfos:         file_shortener_proto_enumTypes,
+		MessageInfos:      file_shortener_proto_msgTypes,
+	}.Build()
+	File_shortener_proto = out.File
+	file_shortener_proto_goTypes = nil
+	file_shortener_proto_depIdxs = nil
+}